@@ -0,0 +1,196 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+var (
+	// ErrUnknownClass is returned for DataSourceClassIDs nobody registered.
+	ErrUnknownClass = errors.New("unknown data source class")
+	// ErrUnsupported is returned by optional operations that a class
+	// implements for some configurations only, e.g. some SQL dialects.
+	ErrUnsupported = errors.New("not supported by the data source class")
+	// ErrInvalidQuery is wrapped by errors about query properties that the
+	// class cannot make sense of, such as malformed macros.
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
+// ReadOnlyViolation is returned for queries that would write through a
+// read-only data source. Statement counts from 1.
+type ReadOnlyViolation struct {
+	Statement int    `json:"statement"`
+	Keyword   string `json:"keyword,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+func (e *ReadOnlyViolation) Error() string {
+	return fmt.Sprintf("read-only data source: statement %d: %s", e.Statement, e.Reason)
+}
+
+func (e *ReadOnlyViolation) ErrorCode() string { return "read_only_violation" }
+func (e *ReadOnlyViolation) ErrorDetails() any { return e }
+
+// Class is the build-time implementation behind a domain.DataSourceClass.
+// It turns DataSource and Query properties into TableData.
+type Class interface {
+	Descriptor() domain.DataSourceClass
+	Query(ctx context.Context, ds domain.DataSource, q domain.Query) (domain.TableData, error)
+}
+
+// StreamingClass is implemented by classes that can hand out results in
+// batches of at most batchSize rows while the backend is still producing
+// them. Every batch shares the columns of the first one. Their types are
+// those of the first batch too, except that classes whose backends type
+// values rather than columns may widen a column in a later batch, from
+// integer to number or from any type to string.
+type StreamingClass interface {
+	Class
+	StreamQuery(
+		ctx context.Context,
+		ds domain.DataSource,
+		q domain.Query,
+		batchSize int,
+		emit func(domain.TableData) error,
+	) error
+}
+
+// WindowingClass is implemented by classes that filter, sort and page rows
+// in their backend. The first batch carries the number of rows matching
+// opts.Filters in Total. Other classes are windowed in memory.
+type WindowingClass interface {
+	Class
+	StreamWindow(
+		ctx context.Context,
+		ds domain.DataSource,
+		q domain.Query,
+		opts domain.DataOptions,
+		batchSize int,
+		emit func(domain.TableData) error,
+	) error
+}
+
+// PoolingClass is implemented by classes that keep connections open per
+// data source between queries.
+type PoolingClass interface {
+	Class
+	// Reconfigure drops the pool of ds if its connection settings changed;
+	// the next query opens a new one.
+	Reconfigure(ds domain.DataSource)
+	// Release closes the pool of a deleted data source.
+	Release(id domain.DataSourceID)
+	PoolStats() []domain.PoolStats
+}
+
+// HealthChecker is implemented by classes that can diagnose the
+// connection to a data source step by step.
+type HealthChecker interface {
+	Class
+	HealthCheck(ctx context.Context, ds domain.DataSource) domain.HealthCheckReport
+}
+
+// BindingClass is implemented by classes that bind variable values as
+// parameters of the listed query properties, from Query.Variables, rather
+// than have them interpolated into the text.
+type BindingClass interface {
+	Class
+	BoundProperties() []domain.PropertyKey
+}
+
+// InterpolatingClass is implemented by classes that take variable values
+// written into the listed query properties. Variables reach only the
+// properties listed here or by BindingClass; the others, such as the
+// mutation flag or limits of a query, are used as written, so that a
+// selected value cannot change how a query runs.
+type InterpolatingClass interface {
+	Class
+	InterpolatedProperties() []domain.PropertyKey
+}
+
+// SchemaIntrospector is implemented by classes that can list the tables
+// and columns of a data source, e.g. for query autocompletion.
+type SchemaIntrospector interface {
+	Class
+	Introspect(ctx context.Context, ds domain.DataSource) (domain.DatabaseSchema, error)
+}
+
+// Registry holds the classes compiled into the server, in registration order.
+type Registry struct {
+	classes map[domain.DataSourceClassID]Class
+	order   []domain.DataSourceClassID
+}
+
+func NewRegistry(classes ...Class) *Registry {
+	r := &Registry{classes: make(map[domain.DataSourceClassID]Class, len(classes))}
+	for _, c := range classes {
+		id := c.Descriptor().ID
+		if _, ok := r.classes[id]; !ok {
+			r.order = append(r.order, id)
+		}
+		r.classes[id] = c
+	}
+	return r
+}
+
+func (r *Registry) Get(id domain.DataSourceClassID) (Class, error) {
+	c, ok := r.classes[id]
+	if !ok {
+		return nil, ErrUnknownClass
+	}
+	return c, nil
+}
+
+// Descriptors lists every registered class for the data-source-classes API.
+func (r *Registry) Descriptors() []domain.DataSourceClass {
+	out := make([]domain.DataSourceClass, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.classes[id].Descriptor())
+	}
+	return out
+}
+
+// PropertyDescriptors describes the properties of the class id, or none
+// for classes nobody registered.
+func (r *Registry) PropertyDescriptors(id domain.DataSourceClassID) []domain.PropertyDescriptor {
+	c, ok := r.classes[id]
+	if !ok {
+		return nil
+	}
+	return c.Descriptor().PropertyDescriptors
+}
+
+// DataSourceUpdated lets pooling classes rebuild pools of changed sources.
+func (r *Registry) DataSourceUpdated(ds domain.DataSource) {
+	if c, ok := r.classes[ds.ClassID].(PoolingClass); ok {
+		c.Reconfigure(ds)
+	}
+	// the class may have changed; other classes drop their pools
+	for id, c := range r.classes {
+		if p, ok := c.(PoolingClass); ok && id != ds.ClassID {
+			p.Release(ds.ID)
+		}
+	}
+}
+
+// DataSourceDeleted closes the pools of a deleted data source.
+func (r *Registry) DataSourceDeleted(id domain.DataSourceID) {
+	for _, c := range r.classes {
+		if p, ok := c.(PoolingClass); ok {
+			p.Release(id)
+		}
+	}
+}
+
+// PoolStats lists the open pools of every pooling class.
+func (r *Registry) PoolStats() []domain.PoolStats {
+	var out []domain.PoolStats
+	for _, id := range r.order {
+		if p, ok := r.classes[id].(PoolingClass); ok {
+			out = append(out, p.PoolStats()...)
+		}
+	}
+	return out
+}
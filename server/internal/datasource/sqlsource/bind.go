@@ -0,0 +1,93 @@
+package sqlsource
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// BoundProperties reports that variables in the sql property are bound as
+// parameters rather than spliced into the statement.
+func (c *Class) BoundProperties() []domain.PropertyKey {
+	return []domain.PropertyKey{QueryPropertySQL}
+}
+
+// expand replaces the macros and the references to q.Variables outside
+// the literals and comments of stmt, in textual order, binding what they
+// stand for as parameters numbered after args. It returns the statement
+// with args extended by the values; see macro for the macros.
+//
+// By default a variable with several values expands to a comma-separated
+// list of placeholders, to be used in IN (...), and one without values
+// binds NULL. Other formats, such as ${name:regex} for the regular
+// expression operators of the database, bind the formatted values as a
+// single parameter, as do raw values.
+func (c *Class) expand(stmt string, q domain.Query, args []any) (string, []any, error) {
+	if len(q.Variables) == 0 && !macroStart.MatchString(stmt) {
+		return stmt, args, nil
+	}
+	s := []rune(stmt)
+	spans, err := codeSpans(s, c.dialect.Syntax())
+	if err != nil {
+		return "", nil, err
+	}
+	bind := func(v any) string {
+		args = append(args, v)
+		return c.dialect.Placeholder(len(args))
+	}
+	replace := func(text string) string {
+		return domain.ReplaceVariables(text, func(ref domain.VariableRef) (string, bool) {
+			v, ok := q.Variables[ref.Name]
+			if !ok {
+				return "", false
+			}
+			switch {
+			case v.Raw || ref.Format != "" && ref.Format != domain.VariableFormatCSV:
+				return bind(domain.FormatVariable(v, ref.Format)), true
+			case len(v.Values) == 0:
+				return bind(nil), true
+			}
+			marks := make([]string, len(v.Values))
+			for i, val := range v.Values {
+				marks[i] = bind(string(val))
+			}
+			return strings.Join(marks, ", "), true
+		})
+	}
+
+	// last is where the text still to write starts; a macro call may end
+	// in a later span than it starts, or partway into one.
+	var b strings.Builder
+	last := 0
+	for k := 0; k < len(spans); k++ {
+		start, end := max(spans[k].start, last), spans[k].end
+		if start >= end {
+			continue
+		}
+		b.WriteString(string(s[last:start]))
+		text := string(s[start:end])
+		loc := macroStart.FindStringSubmatchIndex(text)
+		if loc == nil {
+			b.WriteString(replace(text))
+			last = end
+			continue
+		}
+		b.WriteString(replace(text[:loc[0]]))
+		// arguments are expressions and intervals, never variables
+		margs, next, err := macroArgs(s, spans[k:], start+utf8.RuneCountInString(text[:loc[1]]))
+		if err != nil {
+			return "", nil, err
+		}
+		expansion, err := c.macro(text[loc[2]:loc[3]], margs, q.Range, bind)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(expansion)
+		// go on from the end of the call, in this span or a later one
+		last = next
+		k--
+	}
+	b.WriteString(string(s[last:]))
+	return b.String(), args, nil
+}
@@ -0,0 +1,54 @@
+package sqlsource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestQueryBindsVariables(t *testing.T) {
+	ds := newSQLiteDataSource(t, `CREATE TABLE t (name TEXT); INSERT INTO t VALUES ('a'), ('b'), ('c');`)
+	class := sqlsource.NewSQLite()
+
+	table, err := class.Query(context.Background(), ds, domain.Query{
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"sql": "SELECT name, '$names' AS literal FROM t WHERE name IN ($names) -- $names\nORDER BY name",
+		},
+		Variables: map[domain.Name]domain.VariableValue{"names": {Values: []domain.PropertyValue{"a", "c"}}},
+	})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if table.NumRows() != 2 || table.Columns[0].Values[0] != "a" || table.Columns[0].Values[1] != "c" {
+		t.Fatalf("table = %+v, want rows a and c", table)
+	}
+	if got := table.Columns[1].Values[0]; got != "$names" {
+		t.Fatalf("literal = %q, want the reference left alone", got)
+	}
+}
+
+func TestQueryBindsFormattedVariables(t *testing.T) {
+	ds := newSQLiteDataSource(t, "")
+	class := sqlsource.NewSQLite()
+
+	table, err := class.Query(context.Background(), ds, domain.Query{
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"sql": "SELECT ${hosts:regex} AS re, ${hosts:pipe} AS pipe, $all AS raw",
+		},
+		Variables: map[domain.Name]domain.VariableValue{
+			"hosts": {Values: []domain.PropertyValue{"a.example", "b"}},
+			"all":   {Values: []domain.PropertyValue{".*"}, Raw: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	want := []domain.PropertyValue{`(a\.example|b)`, "a.example|b", ".*"}
+	for i, w := range want {
+		if got := table.Columns[i].Values[0]; got != w {
+			t.Errorf("%s = %q, want %q bound as one parameter", table.Columns[i].Name, got, w)
+		}
+	}
+}
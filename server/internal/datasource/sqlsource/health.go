@@ -0,0 +1,140 @@
+package sqlsource
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// NetworkDialect is implemented by dialects of databases reached over the
+// network, so that health checks can tell DNS, TCP and TLS failures apart
+// from authentication ones.
+type NetworkDialect interface {
+	// Address returns the host and port of the server.
+	Address(props map[domain.PropertyKey]domain.PropertyValue) (host, port string, err error)
+	// StartTLS negotiates TLS on conn as the properties ask. It reports
+	// false when the properties let the connection go without TLS.
+	StartTLS(ctx context.Context, conn net.Conn, host string, props map[domain.PropertyKey]domain.PropertyValue) (bool, error)
+}
+
+// AccessChecker is implemented by dialects that can tell whether the
+// database is reachable before the driver silently creates it.
+type AccessChecker interface {
+	CheckAccess(props map[domain.PropertyKey]domain.PropertyValue) error
+}
+
+// healthQuery is the sample query of health checks.
+const healthQuery = "SELECT 1"
+
+// HealthCheck connects to ds from scratch, bypassing its pool, and runs a
+// sample query. Network steps are skipped for dialects that are not
+// NetworkDialects.
+func (c *Class) HealthCheck(ctx context.Context, ds domain.DataSource) domain.HealthCheckReport {
+	r := healthRun{report: domain.HealthCheckReport{OK: true}}
+
+	if nd, ok := c.dialect.(NetworkDialect); ok {
+		checkNetwork(ctx, &r, nd, ds.Properties)
+	} else {
+		r.skip("not a network database", domain.HealthCheckDNS, domain.HealthCheckTCP, domain.HealthCheckTLS)
+	}
+
+	// without an ID the data source gets a *sql.DB of its own
+	db, release, err := c.db(domain.DataSource{ClassID: ds.ClassID, Properties: ds.Properties})
+	if err == nil {
+		defer release()
+	}
+	r.step(domain.HealthCheckAuth, func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		if ac, ok := c.dialect.(AccessChecker); ok {
+			if err := ac.CheckAccess(ds.Properties); err != nil {
+				return "", err
+			}
+		}
+		return "", db.PingContext(ctx)
+	})
+	r.step(domain.HealthCheckQuery, func() (string, error) {
+		var one any
+		return healthQuery, db.QueryRowContext(ctx, healthQuery).Scan(&one)
+	})
+	return r.report
+}
+
+func checkNetwork(ctx context.Context, r *healthRun, nd NetworkDialect, props map[domain.PropertyKey]domain.PropertyValue) {
+	var host, port, addr string
+	r.step(domain.HealthCheckDNS, func() (string, error) {
+		var err error
+		if host, port, err = nd.Address(props); err != nil {
+			return "", err
+		}
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", err
+		}
+		addr = addrs[0]
+		return addr, nil
+	})
+
+	var conn net.Conn
+	r.step(domain.HealthCheckTCP, func() (string, error) {
+		var d net.Dialer
+		var err error
+		if conn, err = d.DialContext(ctx, "tcp", net.JoinHostPort(addr, port)); err != nil {
+			return "", err
+		}
+		return conn.RemoteAddr().String(), nil
+	})
+	if conn != nil {
+		defer conn.Close()
+	}
+
+	r.step(domain.HealthCheckTLS, func() (string, error) {
+		used, err := nd.StartTLS(ctx, conn, host, props)
+		if err == nil && !used {
+			return "", skipStep("not requested")
+		}
+		return "", err
+	})
+}
+
+// skipStep ends a step as skipped rather than failed.
+type skipStep string
+
+func (s skipStep) Error() string { return string(s) }
+
+// healthRun records steps until one fails; the rest are skipped.
+type healthRun struct {
+	report domain.HealthCheckReport
+	failed bool
+}
+
+func (r *healthRun) step(step domain.HealthCheckStep, fn func() (string, error)) {
+	if r.failed {
+		r.skip("an earlier step failed", step)
+		return
+	}
+	start := time.Now()
+	detail, err := fn()
+	res := domain.HealthCheckResult{Step: step, Status: domain.HealthCheckOK, Latency: time.Since(start), Detail: detail}
+	var skip skipStep
+	switch {
+	case errors.As(err, &skip):
+		res.Status, res.Detail = domain.HealthCheckSkipped, string(skip)
+	case err != nil:
+		res.Status, res.Error = domain.HealthCheckFailed, err.Error()
+		r.failed, r.report.OK = true, false
+	}
+	r.report.Steps = append(r.report.Steps, res)
+}
+
+func (r *healthRun) skip(reason string, steps ...domain.HealthCheckStep) {
+	for _, s := range steps {
+		r.report.Steps = append(r.report.Steps, domain.HealthCheckResult{
+			Step: s, Status: domain.HealthCheckSkipped, Detail: reason,
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package sqlsource_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestSQLiteHealthCheck(t *testing.T) {
+	class := sqlsource.NewSQLite()
+	ds := newSQLiteDataSource(t, `CREATE TABLE t (n INTEGER);`)
+
+	report := class.HealthCheck(context.Background(), ds)
+	if !report.OK {
+		t.Fatalf("report = %+v, want OK", report)
+	}
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckDNS:   domain.HealthCheckSkipped,
+		domain.HealthCheckTCP:   domain.HealthCheckSkipped,
+		domain.HealthCheckTLS:   domain.HealthCheckSkipped,
+		domain.HealthCheckAuth:  domain.HealthCheckOK,
+		domain.HealthCheckQuery: domain.HealthCheckOK,
+	})
+
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	ds.Properties["path"] = domain.PropertyValue(missing)
+	report = class.HealthCheck(context.Background(), ds)
+	if report.OK {
+		t.Fatalf("missing file reported OK")
+	}
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckAuth:  domain.HealthCheckFailed,
+		domain.HealthCheckQuery: domain.HealthCheckSkipped,
+	})
+}
+
+func TestPostgresHealthCheckNetworkSteps(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	// answer every SSLRequest with 'N', like a server without TLS
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 8)
+			if _, err := conn.Read(buf); err == nil {
+				conn.Write([]byte{'N'})
+			}
+			conn.Close()
+		}
+	}()
+
+	class := sqlsource.NewPostgres()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ds := domain.DataSource{
+		ClassID: "postgres",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"host": "localhost", "port": domain.PropertyValue(port), "database": "app", "user": "reader", "sslmode": "require",
+		},
+	}
+	report := class.HealthCheck(context.Background(), ds)
+	if report.OK {
+		t.Fatalf("report OK although TLS was refused")
+	}
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckDNS:   domain.HealthCheckOK,
+		domain.HealthCheckTCP:   domain.HealthCheckOK,
+		domain.HealthCheckTLS:   domain.HealthCheckFailed,
+		domain.HealthCheckAuth:  domain.HealthCheckSkipped,
+		domain.HealthCheckQuery: domain.HealthCheckSkipped,
+	})
+
+	ln.Close()
+	report = class.HealthCheck(context.Background(), ds)
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckDNS: domain.HealthCheckOK,
+		domain.HealthCheckTCP: domain.HealthCheckFailed,
+		domain.HealthCheckTLS: domain.HealthCheckSkipped,
+	})
+}
+
+func TestMySQLHealthCheckNetworkSteps(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	// greet every connection like a server without TLS
+	greeting := append([]byte{10}, "8.0.36\x00"...)
+	greeting = append(greeting, make([]byte, 4+8+1)...)
+	// CLIENT_PROTOCOL_41, CLIENT_SECURE_CONNECTION and CLIENT_PLUGIN_AUTH
+	// but not CLIENT_SSL
+	greeting = append(greeting, 0x00, 0x82, 45, 0x02, 0x00, 0x08, 0x00, 21)
+	greeting = append(greeting, make([]byte, 10+13)...)
+	greeting = append(greeting, "mysql_native_password\x00"...)
+	packet := append([]byte{byte(len(greeting)), 0, 0, 0}, greeting...)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(packet)
+			conn.Close()
+		}
+	}()
+
+	class := sqlsource.NewMySQL()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ds := domain.DataSource{
+		ClassID: "mysql",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"host": "localhost", "port": domain.PropertyValue(port), "database": "app", "user": "reader", "tls": "true",
+		},
+	}
+	report := class.HealthCheck(context.Background(), ds)
+	if report.OK {
+		t.Fatalf("report OK although TLS was not offered")
+	}
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckDNS:   domain.HealthCheckOK,
+		domain.HealthCheckTCP:   domain.HealthCheckOK,
+		domain.HealthCheckTLS:   domain.HealthCheckFailed,
+		domain.HealthCheckAuth:  domain.HealthCheckSkipped,
+		domain.HealthCheckQuery: domain.HealthCheckSkipped,
+	})
+
+	ds.Properties["tls"] = "preferred"
+	report = class.HealthCheck(context.Background(), ds)
+	expectSteps(t, report, map[domain.HealthCheckStep]domain.HealthCheckStatus{
+		domain.HealthCheckDNS: domain.HealthCheckOK,
+		domain.HealthCheckTCP: domain.HealthCheckOK,
+		domain.HealthCheckTLS: domain.HealthCheckSkipped,
+	})
+}
+
+func expectSteps(t *testing.T, report domain.HealthCheckReport, want map[domain.HealthCheckStep]domain.HealthCheckStatus) {
+	t.Helper()
+	if len(report.Steps) != 5 {
+		t.Fatalf("steps = %+v, want all five", report.Steps)
+	}
+	for _, res := range report.Steps {
+		if status, ok := want[res.Step]; ok && res.Status != status {
+			t.Fatalf("step %s = %s (%s), want %s", res.Step, res.Status, res.Error, status)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package sqlsource
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Syntax holds the lexical rules of a dialect that decide where comments,
+// string literals and quoted identifiers start and end.
+type Syntax struct {
+	// NestedComments makes block comments nest, as in Postgres.
+	NestedComments bool
+	// ExecutableComments makes the text of /*! ... */ and /*M! ... */
+	// comments code, as in MySQL and MariaDB.
+	ExecutableComments bool
+	// HashComments makes # start a comment to the end of the line.
+	HashComments bool
+	// DashCommentSpace makes -- start a comment only when whitespace
+	// follows, as in MySQL, where 1--1 is a subtraction.
+	DashCommentSpace bool
+	// BackslashEscapes makes backslashes escape in every string literal.
+	// Otherwise they do only in E'...' strings when EscapeStrings is set.
+	BackslashEscapes bool
+	EscapeStrings    bool
+	// DoubleQuoteStrings makes "..." a string literal, which
+	// BackslashEscapes applies to, rather than an identifier.
+	DoubleQuoteStrings bool
+	// BacktickIdents and BracketIdents quote identifiers with `...` and
+	// [...].
+	BacktickIdents bool
+	BracketIdents  bool
+	// DollarQuotes enables $tag$...$tag$ strings.
+	DollarQuotes bool
+}
+
+// span is a [start, end) range of runes.
+type span struct{ start, end int }
+
+// codeSpans returns the parts of s outside comments, string literals and
+// quoted identifiers, following the rules of syn. On error the spans up
+// to the unterminated part are returned.
+func codeSpans(s []rune, syn Syntax) ([]span, error) {
+	spans, _, err := lex(s, syn)
+	return spans, err
+}
+
+// lex returns the code spans of s, as codeSpans does, and its comments.
+func lex(s []rune, syn Syntax) (spans, comments []span, err error) {
+	start := 0
+	skip := func(i, end int) {
+		if i > start {
+			spans = append(spans, span{start, i})
+		}
+		start = end
+	}
+	for i := 0; i < len(s); {
+		r := s[i]
+		switch {
+		case r == '-' && at(s, i+1) == '-' && (!syn.DashCommentSpace || i+2 == len(s) || isSpaceOrControl(s[i+2])),
+			r == '#' && syn.HashComments:
+			end := i
+			for end < len(s) && s[end] != '\n' {
+				end++
+			}
+			skip(i, end)
+			comments = append(comments, span{i, end})
+			i = end
+		case r == '/' && at(s, i+1) == '*' && syn.ExecutableComments && (at(s, i+2) == '!' || at(s, i+2) == 'M' && at(s, i+3) == '!'):
+			// the text runs as code, and so does the closing */
+			i += 3
+		case r == '/' && at(s, i+1) == '*':
+			end, err := skipBlockComment(s, i, syn.NestedComments)
+			skip(i, end)
+			comments = append(comments, span{i, end})
+			if err != nil {
+				return spans, comments, err
+			}
+			i = end
+		case r == '\'' || r == '"' && syn.DoubleQuoteStrings:
+			// E'...' strings allow backslash escapes
+			escapes := syn.BackslashEscapes || r == '\'' && syn.EscapeStrings &&
+				i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i < 2 || !isIdentRune(s[i-2]))
+			end, err := skipQuoted(s, i, r, r, escapes)
+			skip(i, end)
+			if err != nil {
+				return spans, comments, err
+			}
+			i = end
+		case r == '"', r == '`' && syn.BacktickIdents:
+			end, err := skipQuoted(s, i, r, r, false)
+			skip(i, end)
+			if err != nil {
+				return spans, comments, err
+			}
+			i = end
+		case r == '[' && syn.BracketIdents:
+			end, err := skipQuoted(s, i, '[', ']', false)
+			skip(i, end)
+			if err != nil {
+				return spans, comments, err
+			}
+			i = end
+		case r == '$' && syn.DollarQuotes && dollarTag(s, i) != nil && (i == 0 || !isIdentRune(s[i-1])):
+			tag := dollarTag(s, i)
+			end := indexRunes(s, i+len(tag), tag)
+			if end < 0 {
+				skip(i, len(s))
+				return spans, comments, fmt.Errorf("unterminated dollar-quoted string")
+			}
+			skip(i, end+len(tag))
+			i = end + len(tag)
+		case isIdentRune(r):
+			// words may contain dollar signs, which start no quote there
+			for i < len(s) && isIdentRune(s[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	skip(len(s), len(s))
+	return spans, comments, nil
+}
+
+// trimStatement strips the whitespace, semicolons and comments that end
+// stmt, so that it can be nested in another statement.
+func trimStatement(stmt string, syn Syntax) string {
+	s := []rune(stmt)
+	_, comments, err := lex(s, syn)
+	if err != nil {
+		return strings.TrimRight(strings.TrimSpace(stmt), ";")
+	}
+	end := len(s)
+	for end > 0 {
+		if n := len(comments); n > 0 && comments[n-1].end >= end {
+			end = comments[n-1].start
+			comments = comments[:n-1]
+			continue
+		}
+		if r := s[end-1]; r != ';' && !unicode.IsSpace(r) {
+			break
+		}
+		end--
+	}
+	return strings.TrimSpace(string(s[:end]))
+}
+
+func isSpaceOrControl(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
+func at(s []rune, i int) rune {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func indexRunes(s []rune, from int, sub []rune) int {
+	for i := from; i+len(sub) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// skipBlockComment returns the index after the comment opening at i.
+func skipBlockComment(s []rune, i int, nested bool) (int, error) {
+	depth := 0
+	for i < len(s) {
+		switch {
+		case s[i] == '/' && at(s, i+1) == '*' && (nested || depth == 0):
+			depth++
+			i += 2
+		case s[i] == '*' && at(s, i+1) == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return i, fmt.Errorf("unterminated comment")
+}
+
+// skipQuoted returns the index after the quoted text opening at i and
+// closing with quote, where a doubled closing quote stands for itself.
+func skipQuoted(s []rune, i int, open, quote rune, backslashEscapes bool) (int, error) {
+	for i++; i < len(s); i++ {
+		switch {
+		case backslashEscapes && s[i] == '\\':
+			i++
+		case s[i] == quote && at(s, i+1) == quote && open == quote:
+			i++
+		case s[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return i, fmt.Errorf("unterminated quoted text")
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted string at i, or nil
+// when the dollar sign starts something else, such as a $1 parameter.
+func dollarTag(s []rune, i int) []rune {
+	for j := i + 1; j < len(s); j++ {
+		switch r := s[j]; {
+		case r == '$':
+			return s[i : j+1]
+		case unicode.IsLetter(r) || r == '_' || j > i+1 && unicode.IsDigit(r):
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package sqlsource
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/timerange"
+)
+
+// TimeMacroDialect is implemented by dialects that expand the time macros
+// $__timeFilter and $__timeGroup. bind adds a parameter and returns its
+// placeholder; it is called once per use, since some dialects number
+// placeholders by position.
+type TimeMacroDialect interface {
+	// TimeFilter returns a condition that the timestamp expr lies within
+	// [from, to].
+	TimeFilter(expr string, from, to time.Time, bind func(any) string) string
+	// TimeGroup returns expr truncated to a multiple of interval since the
+	// Unix epoch, as a timestamp.
+	TimeGroup(expr string, interval time.Duration, bind func(any) string) string
+}
+
+// macroStart matches the name and opening parenthesis of a macro call.
+var macroStart = regexp.MustCompile(`\$__(timeFilter|timeGroup|unixEpochFilter)\s*\(`)
+
+// macro expands the macro name called with args against the time range r.
+//
+//	$__timeFilter(col)              col within the range
+//	$__timeGroup(col, 5m)           col in buckets of 5m; $__interval picks
+//	                                the interval of the range
+//	$__unixEpochFilter(col)         col, in seconds since the epoch, within
+//	                                the range
+func (c *Class) macro(name string, args []string, r *domain.TimeRange, bind func(any) string) (string, error) {
+	want := 1
+	if name == "timeGroup" {
+		want = 2
+	}
+	if len(args) != want || args[0] == "" {
+		return "", fmt.Errorf("%w: $__%s takes %d argument(s)", datasource.ErrInvalidQuery, name, want)
+	}
+	if r == nil {
+		return "", fmt.Errorf("%w: $__%s needs a time range", datasource.ErrInvalidQuery, name)
+	}
+	if name == "unixEpochFilter" {
+		return fmt.Sprintf("%s >= %s AND %s <= %s", args[0], bind(r.From.Unix()), args[0], bind(r.To.Unix())), nil
+	}
+
+	d, ok := c.dialect.(TimeMacroDialect)
+	if !ok {
+		return "", fmt.Errorf("%w: $__%s is not supported by %s", datasource.ErrInvalidQuery, name, c.dialect.ClassName())
+	}
+	if name == "timeFilter" {
+		return d.TimeFilter(args[0], r.From.UTC(), r.To.UTC(), bind), nil
+	}
+	interval := r.Interval
+	switch args[1] {
+	case "$__interval", "${__interval}":
+	default:
+		var err error
+		if interval, err = timerange.ParseDuration(args[1]); err != nil {
+			return "", fmt.Errorf("%w: $__timeGroup: %v", datasource.ErrInvalidQuery, err)
+		}
+	}
+	if interval <= 0 {
+		return "", fmt.Errorf("%w: $__timeGroup needs a positive interval", datasource.ErrInvalidQuery)
+	}
+	return d.TimeGroup(args[0], interval, bind), nil
+}
+
+// macroArgs splits the arguments of a macro call at top-level commas.
+// Parentheses and commas count only in the code spans of s, so literals
+// and quoted identifiers stay whole. i follows the opening parenthesis;
+// end follows the closing one.
+func macroArgs(s []rune, spans []span, i int) (args []string, end int, err error) {
+	depth, start := 0, i
+	for _, sp := range spans {
+		for j := max(sp.start, i); j < sp.end; j++ {
+			switch s[j] {
+			case '(':
+				depth++
+			case ',':
+				if depth == 0 {
+					args = append(args, strings.TrimSpace(string(s[start:j])))
+					start = j + 1
+				}
+			case ')':
+				if depth == 0 {
+					return append(args, strings.TrimSpace(string(s[start:j]))), j + 1, nil
+				}
+				depth--
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("%w: unterminated macro call", datasource.ErrInvalidQuery)
+}
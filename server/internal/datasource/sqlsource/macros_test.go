@@ -0,0 +1,123 @@
+package sqlsource_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestTimeMacros(t *testing.T) {
+	ds := newSQLiteDataSource(t, `CREATE TABLE events (at TEXT, epoch INTEGER, kind TEXT);
+		INSERT INTO events VALUES
+			('2024-03-01T10:01:00Z', 1709287260, 'a'),
+			('2024-03-01 10:04:00', 1709287440, 'b'),
+			('2024-03-01T10:07:00Z', 1709287620, 'a'),
+			('2024-03-02T00:00:00Z', 1709337600, 'a');`)
+	class := sqlsource.NewSQLite()
+	r := &domain.TimeRange{
+		From:     time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		Interval: 5 * time.Minute,
+	}
+	query := func(stmt string) (domain.TableData, error) {
+		return class.Query(context.Background(), ds, domain.Query{
+			Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": domain.PropertyValue(stmt)},
+			// the variables around the macros check that SQLite's
+			// positional parameters are bound in order
+			Variables: map[domain.Name]domain.VariableValue{"kind": {Values: []domain.PropertyValue{"a"}}},
+			Range:     r,
+		})
+	}
+
+	table, err := query(`SELECT $__timeGroup(at, $__interval) AS bucket, COUNT(*) AS n FROM events
+		WHERE kind = $kind AND $__timeFilter(at) AND kind IN ($kind) GROUP BY 1 ORDER BY 1`)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if table.NumRows() != 2 || table.Columns[0].Values[0] != "2024-03-01 10:00:00" || table.Columns[0].Values[1] != "2024-03-01 10:05:00" {
+		t.Fatalf("buckets = %v, want 10:00 and 10:05", table.Columns[0].Values)
+	}
+
+	table, err = query(`SELECT COUNT(*) FROM events WHERE $__unixEpochFilter(epoch) AND $__timeFilter(at)`)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if n := table.Columns[0].Ints[0]; n != 3 {
+		t.Fatalf("n = %d, want 3 in the hour", n)
+	}
+
+	// quoted arguments stay whole, whatever they contain
+	table, err = query(`SELECT COUNT(*), '(' FROM events WHERE $__timeFilter(COALESCE("at", ',)')) AND kind = $kind`)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if n := table.Columns[0].Ints[0]; n != 2 {
+		t.Fatalf("n = %d, want 2 of kind a in the hour", n)
+	}
+
+	for _, stmt := range []string{
+		"SELECT $__timeFilter() FROM events",
+		"SELECT $__timeGroup(at) FROM events",
+		"SELECT $__timeGroup(at, 5q) FROM events",
+		"SELECT $__timeFilter(at FROM events",
+		"SELECT $__timeFilter(at, ')') FROM events",
+	} {
+		if _, err := query(stmt); !errors.Is(err, datasource.ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", stmt, err)
+		}
+	}
+	_, err = class.Query(context.Background(), ds, domain.Query{
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT 1 WHERE $__timeFilter(at)"},
+	})
+	if !errors.Is(err, datasource.ErrInvalidQuery) {
+		t.Errorf("without a range: expected ErrInvalidQuery, got %v", err)
+	}
+	// macros in literals and comments stay as written
+	table, err = query("SELECT '$__timeFilter(at)' -- $__timeGroup(\n")
+	if err != nil || table.Columns[0].Values[0] != "$__timeFilter(at)" {
+		t.Fatalf("literal: %v, %+v", err, table)
+	}
+}
+
+func TestPostgresTimeMacros(t *testing.T) {
+	var args []any
+	bind := func(v any) string {
+		args = append(args, v)
+		return sqlsource.Postgres{}.Placeholder(len(args))
+	}
+	from, to := time.Unix(0, 0), time.Unix(3600, 0)
+	if got := (sqlsource.Postgres{}).TimeFilter("ts", from, to, bind); got != "ts BETWEEN $1 AND $2" {
+		t.Errorf("TimeFilter = %s", got)
+	}
+	got := sqlsource.Postgres{}.TimeGroup("ts", 90*time.Second, bind)
+	if want := "to_timestamp(floor(extract(epoch from ts)::float8 / $3::float8) * $4::float8)"; got != want {
+		t.Errorf("TimeGroup = %s, want %s", got, want)
+	}
+	if len(args) != 4 || args[0] != from || args[2] != 90.0 {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestMySQLTimeMacros(t *testing.T) {
+	var args []any
+	bind := func(v any) string {
+		args = append(args, v)
+		return sqlsource.MySQL{}.Placeholder(len(args))
+	}
+	from, to := time.Unix(0, 0), time.Unix(3600, 0)
+	if got := (sqlsource.MySQL{}).TimeFilter("ts", from, to, bind); got != "ts BETWEEN ? AND ?" {
+		t.Errorf("TimeFilter = %s", got)
+	}
+	got := sqlsource.MySQL{}.TimeGroup("ts", 90*time.Second, bind)
+	if want := "FROM_UNIXTIME(UNIX_TIMESTAMP(ts) DIV ? * ?)"; got != want {
+		t.Errorf("TimeGroup = %s, want %s", got, want)
+	}
+	if len(args) != 4 || args[1] != to || args[2] != int64(90) {
+		t.Errorf("args = %v", args)
+	}
+}
@@ -0,0 +1,271 @@
+package sqlsource
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql" // registers the "mysql" driver
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// MySQL is the dialect of the "mysql" DataSourceClass.
+type MySQL struct{}
+
+func NewMySQL() *Class { return New(MySQL{}) }
+
+func (MySQL) ClassID() domain.DataSourceClassID { return "mysql" }
+func (MySQL) ClassName() domain.Name            { return "MySQL" }
+func (MySQL) DriverName() string                { return "mysql" }
+
+func (MySQL) PropertyDescriptors() []domain.PropertyDescriptor {
+	return []domain.PropertyDescriptor{
+		{Key: "host", Name: "Host", Type: domain.PropertyTypeString, Category: "Connection", Order: 0, IsRequired: true},
+		{Key: "port", Name: "Port", Type: domain.PropertyTypeNumber, Category: "Connection", Order: 1},
+		{Key: "database", Name: "Database", Type: domain.PropertyTypeString, Category: "Connection", Order: 2, IsRequired: true},
+		{Key: "user", Name: "User", Type: domain.PropertyTypeString, Category: "Authentication", Order: 3, IsRequired: true},
+		{Key: "password", Name: "Password", Type: domain.PropertyTypeString, Category: "Authentication", Order: 4, IsSecret: true},
+		{
+			Key: "tls", Name: "TLS", Type: domain.PropertyTypeString, Category: "Connection", Order: 5,
+			Candidates: []domain.PropertyValue{"false", "preferred", "skip-verify", "true"},
+		},
+	}
+}
+
+// DSN asks the driver to parse DATE, DATETIME and TIMESTAMP values into
+// times, which it otherwise returns as text.
+func (MySQL) DSN(props map[domain.PropertyKey]domain.PropertyValue) (string, error) {
+	host := string(props["host"])
+	if host == "" {
+		return "", fmt.Errorf("mysql: host is required")
+	}
+	port := string(props["port"])
+	if port == "" {
+		port = "3306"
+	}
+	cfg := mysql.NewConfig()
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, port)
+	cfg.DBName = string(props["database"])
+	cfg.User = string(props["user"])
+	cfg.Passwd = string(props["password"])
+	cfg.ParseTime = true
+	switch tls := string(props["tls"]); tls {
+	case "", "false", "preferred", "skip-verify", "true":
+		cfg.TLSConfig = tls
+	default:
+		return "", fmt.Errorf("mysql: unknown tls mode %q", tls)
+	}
+	return cfg.FormatDSN(), nil
+}
+
+// ColumnType maps the type names reported by the driver, which prefixes
+// unsigned integer types with UNSIGNED. UNSIGNED BIGINT may overflow an
+// int64, and DECIMAL is read as its exact text, so both are rendered as
+// strings, as are TIME, BIT, binary and spatial types.
+func (MySQL) ColumnType(name string) domain.PropertyType {
+	switch strings.ToUpper(name) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR",
+		"UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT":
+		return domain.PropertyTypeInteger
+	case "FLOAT", "DOUBLE", "UNSIGNED FLOAT", "UNSIGNED DOUBLE":
+		return domain.PropertyTypeNumber
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return domain.PropertyTypeTime
+	case "JSON":
+		return domain.PropertyTypeJSON
+	}
+	return domain.PropertyTypeString
+}
+
+func (MySQL) Placeholder(int) string { return "?" }
+
+// Contains lowers both sides, since LIKE is case-sensitive under binary
+// collations. Backslashes escape in MySQL string literals.
+func (MySQL) Contains(expr, arg string) string {
+	return fmt.Sprintf(`LOWER(CAST(%s AS CHAR)) LIKE LOWER(%s) ESCAPE '\\'`, expr, arg)
+}
+
+// TimeFilter binds the ends of the range as times, which the driver sends
+// in UTC; DATETIME columns are taken to hold UTC.
+func (MySQL) TimeFilter(expr string, from, to time.Time, bind func(any) string) string {
+	return fmt.Sprintf("%s BETWEEN %s AND %s", expr, bind(from), bind(to))
+}
+
+// TimeGroup works in whole seconds, the resolution of UNIX_TIMESTAMP.
+func (MySQL) TimeGroup(expr string, interval time.Duration, bind func(any) string) string {
+	secs := max(int64(interval/time.Second), 1)
+	return fmt.Sprintf("FROM_UNIXTIME(UNIX_TIMESTAMP(%s) DIV %s * %s)", expr, bind(secs), bind(secs))
+}
+
+// Limit uses the largest row count for no limit because MySQL requires
+// LIMIT before OFFSET.
+func (MySQL) Limit(limit, offset int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+// QuoteIdent uses backticks, since double quotes delimit strings unless
+// the server runs with ANSI_QUOTES.
+func (MySQL) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Syntax follows the default sql_mode, in which backslashes escape in
+// strings and double quotes delimit them; CheckReadOnly also checks
+// statements as NO_BACKSLASH_ESCAPES would read them.
+func (MySQL) Syntax() Syntax {
+	return Syntax{
+		ExecutableComments: true, HashComments: true, DashCommentSpace: true,
+		BackslashEscapes: true, DoubleQuoteStrings: true, BacktickIdents: true,
+	}
+}
+
+func (MySQL) Address(props map[domain.PropertyKey]domain.PropertyValue) (string, string, error) {
+	host := string(props["host"])
+	if host == "" {
+		return "", "", fmt.Errorf("mysql: host is required")
+	}
+	port := string(props["port"])
+	if port == "" {
+		port = "3306"
+	}
+	return host, port, nil
+}
+
+// Capability flags of the client/server protocol.
+const (
+	mysqlClientProtocol41 = 0x00000200
+	mysqlClientSSL        = 0x00000800
+	mysqlClientSecureConn = 0x00008000
+)
+
+// StartTLS reads the server greeting and, when the server offers TLS,
+// answers with an SSLRequest and completes the handshake the way the tls
+// property asks, as the driver does. A server without TLS fails only the
+// modes that require it.
+func (MySQL) StartTLS(ctx context.Context, conn net.Conn, host string, props map[domain.PropertyKey]domain.PropertyValue) (bool, error) {
+	mode := string(props["tls"])
+	if mode == "" || mode == "false" {
+		return false, nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return false, err
+	}
+	greeting := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return false, err
+	}
+	caps, err := mysqlGreetingCaps(greeting)
+	if err != nil {
+		return false, err
+	}
+	if caps&mysqlClientSSL == 0 {
+		if mode != "preferred" {
+			return false, fmt.Errorf("mysql: server does not accept TLS but tls is %s", mode)
+		}
+		return false, nil
+	}
+
+	// SSLRequest: the capabilities, the largest packet size, the
+	// utf8mb4_general_ci collation and 23 reserved bytes
+	var req [4 + 32]byte
+	req[0], req[3] = 32, header[3]+1
+	binary.LittleEndian.PutUint32(req[4:8], mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConn)
+	binary.LittleEndian.PutUint32(req[8:12], 1<<24-1)
+	req[12] = 45
+	if _, err := conn.Write(req[:]); err != nil {
+		return false, err
+	}
+
+	cfg := &tls.Config{ServerName: host, InsecureSkipVerify: mode != "true"}
+	if err := tls.Client(conn, cfg).HandshakeContext(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mysqlGreetingCaps returns the lower capability flags of the initial
+// handshake packet, which hold CLIENT_SSL.
+func mysqlGreetingCaps(p []byte) (uint32, error) {
+	if len(p) > 0 && p[0] == 0xff {
+		// an error packet: code, then the message after a SQL state marker
+		msg := p[min(len(p), 3):]
+		if len(msg) > 0 && msg[0] == '#' {
+			msg = msg[min(len(msg), 6):]
+		}
+		return 0, fmt.Errorf("mysql: server refused the connection: %s", msg)
+	}
+	if len(p) == 0 || p[0] != 10 {
+		return 0, fmt.Errorf("mysql: unexpected greeting")
+	}
+	// protocol version, server version, connection ID, 8 bytes of auth
+	// data and a filler byte precede the flags
+	end := 1
+	for end < len(p) && p[end] != 0 {
+		end++
+	}
+	i := end + 1 + 4 + 8 + 1
+	if i+2 > len(p) {
+		return 0, fmt.Errorf("mysql: unexpected greeting")
+	}
+	return uint32(binary.LittleEndian.Uint16(p[i : i+2])), nil
+}
+
+// ReadOnly makes the session read-only rather than opening a read-only
+// transaction, which MySQL would keep open, with its snapshot and
+// metadata locks, for as long as the rows are read. restore makes the
+// session writable before the connection returns to the pool.
+func (MySQL) ReadOnly(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION READ ONLY"); err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SET SESSION TRANSACTION READ WRITE"); err != nil {
+			// never hand a read-only connection to a mutation query
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}, nil
+}
+
+// DescribeSchema reads information_schema, which lists only what the user
+// has privileges on; the system schemas are left out. Type names are
+// rebuilt as the driver reports them, so that ColumnType tells unsigned
+// integers apart.
+func (m MySQL) DescribeSchema(ctx context.Context, db *sql.DB) (domain.DatabaseSchema, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.TABLE_SCHEMA, c.TABLE_NAME, t.TABLE_TYPE,
+			IF(t.TABLE_TYPE = 'BASE TABLE', COALESCE(t.TABLE_COMMENT, ''), ''),
+			c.COLUMN_NAME, c.COLUMN_TYPE,
+			CONCAT(IF(c.COLUMN_TYPE LIKE '%unsigned%', 'UNSIGNED ', ''), UPPER(c.DATA_TYPE)),
+			c.IS_NULLABLE = 'YES', c.COLUMN_COMMENT
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+		ORDER BY c.TABLE_SCHEMA, c.TABLE_NAME, c.ORDINAL_POSITION`)
+	if err != nil {
+		return domain.DatabaseSchema{}, err
+	}
+	return buildSchema(rows, m.ColumnType, func(typ string) domain.TableKind {
+		if strings.HasSuffix(typ, "VIEW") {
+			return domain.TableKindView
+		}
+		return domain.TableKindTable
+	})
+}
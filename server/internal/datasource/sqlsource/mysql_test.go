@@ -0,0 +1,56 @@
+package sqlsource_test
+
+import (
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestMySQLColumnType(t *testing.T) {
+	cases := map[string]domain.PropertyType{
+		"BIGINT":          domain.PropertyTypeInteger,
+		"UNSIGNED INT":    domain.PropertyTypeInteger,
+		"UNSIGNED BIGINT": domain.PropertyTypeString,
+		"DECIMAL":         domain.PropertyTypeString,
+		"double":          domain.PropertyTypeNumber,
+		"DATETIME":        domain.PropertyTypeTime,
+		"TIME":            domain.PropertyTypeString,
+		"JSON":            domain.PropertyTypeJSON,
+		"VARCHAR":         domain.PropertyTypeString,
+	}
+	for name, want := range cases {
+		if got := (sqlsource.MySQL{}).ColumnType(name); got != want {
+			t.Fatalf("ColumnType(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestMySQLDSN(t *testing.T) {
+	dsn, err := sqlsource.MySQL{}.DSN(map[domain.PropertyKey]domain.PropertyValue{
+		"host":     "db.internal",
+		"port":     "3307",
+		"database": "app",
+		"user":     "reader",
+		"password": "p@ss word",
+		"tls":      "true",
+	})
+	if err != nil {
+		t.Fatalf("DSN: %v", err)
+	}
+	const want = "reader:p@ss word@tcp(db.internal:3307)/app?parseTime=true&tls=true"
+	if dsn != want {
+		t.Fatalf("DSN = %s, want %s", dsn, want)
+	}
+
+	if _, err := (sqlsource.MySQL{}).DSN(nil); err == nil {
+		t.Fatalf("DSN without host should fail")
+	}
+	if _, err := (sqlsource.MySQL{}).DSN(map[domain.PropertyKey]domain.PropertyValue{"host": "db", "tls": "maybe"}); err == nil {
+		t.Fatalf("DSN with an unknown tls mode should fail")
+	}
+}
+
+// MySQL has no server in tests; at least keep its reads out of the
+// read-only transactions the driver would hold open while rows stream.
+var _ sqlsource.ReadOnlyConnDialect = sqlsource.MySQL{}
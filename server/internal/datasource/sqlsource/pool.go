@@ -0,0 +1,175 @@
+package sqlsource
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// DataSource properties tuning the connection pool, shared by every dialect.
+const (
+	PropertyMaxOpenConns    domain.PropertyKey = "maxOpenConns"
+	PropertyMaxIdleConns    domain.PropertyKey = "maxIdleConns"
+	PropertyConnMaxLifetime domain.PropertyKey = "connMaxLifetime"
+)
+
+var poolDescriptors = []domain.PropertyDescriptor{
+	{Key: PropertyMaxOpenConns, Name: "Max Open Connections", Type: domain.PropertyTypeInteger, Category: "Pool", Order: 100},
+	{Key: PropertyMaxIdleConns, Name: "Max Idle Connections", Type: domain.PropertyTypeInteger, Category: "Pool", Order: 101},
+	{Key: PropertyConnMaxLifetime, Name: "Connection Max Lifetime", Type: domain.PropertyTypeString, Category: "Pool", Order: 102},
+}
+
+type poolSettings struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+func poolSettingsOf(props map[domain.PropertyKey]domain.PropertyValue) (poolSettings, error) {
+	var s poolSettings
+	for key, dst := range map[domain.PropertyKey]*int{PropertyMaxOpenConns: &s.maxOpen, PropertyMaxIdleConns: &s.maxIdle} {
+		if raw := props[key]; raw != "" {
+			n, err := strconv.Atoi(string(raw))
+			if err != nil || n < 0 {
+				return poolSettings{}, fmt.Errorf("%s must be a non-negative integer", key)
+			}
+			*dst = n
+		}
+	}
+	if raw := props[PropertyConnMaxLifetime]; raw != "" {
+		d, err := time.ParseDuration(string(raw))
+		if err != nil || d < 0 {
+			return poolSettings{}, fmt.Errorf("%s must be a non-negative duration such as 30m", PropertyConnMaxLifetime)
+		}
+		s.maxLifetime = d
+	}
+	return s, nil
+}
+
+type pool struct {
+	db *sql.DB
+	// key changes whenever the DSN or the pool settings do.
+	key string
+}
+
+// pools holds one *sql.DB per saved data source.
+type pools struct {
+	mu   sync.Mutex
+	byID map[domain.DataSourceID]*pool
+}
+
+// poolKey fingerprints what a pool was opened with.
+func poolKey(dsn string, s poolSettings) string {
+	return strings.Join([]string{dsn, strconv.Itoa(s.maxOpen), strconv.Itoa(s.maxIdle), s.maxLifetime.String()}, "\x00")
+}
+
+// db returns the pool of ds, opening or rebuilding it as needed. Unsaved
+// data sources, which have no ID, get a *sql.DB of their own that release
+// closes.
+func (c *Class) db(ds domain.DataSource) (*sql.DB, func(), error) {
+	dsn, err := c.dialect.DSN(ds.Properties)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings, err := poolSettingsOf(ds.Properties)
+	if err != nil {
+		return nil, nil, err
+	}
+	open := func() (*sql.DB, error) {
+		db, err := sql.Open(c.dialect.DriverName(), dsn)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(settings.maxOpen)
+		if settings.maxIdle > 0 {
+			db.SetMaxIdleConns(settings.maxIdle)
+		}
+		db.SetConnMaxLifetime(settings.maxLifetime)
+		return db, nil
+	}
+	if ds.ID.IsZero() {
+		db, err := open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { db.Close() }, nil
+	}
+
+	key := poolKey(dsn, settings)
+	c.pools.mu.Lock()
+	defer c.pools.mu.Unlock()
+	if p, ok := c.pools.byID[ds.ID]; ok {
+		if p.key == key {
+			return p.db, func() {}, nil
+		}
+		closeAsync(p.db)
+	}
+	db, err := open()
+	if err != nil {
+		delete(c.pools.byID, ds.ID)
+		return nil, nil, err
+	}
+	c.pools.byID[ds.ID] = &pool{db: db, key: key}
+	return db, func() {}, nil
+}
+
+// Reconfigure drops the pool of ds when its DSN or pool settings changed.
+func (c *Class) Reconfigure(ds domain.DataSource) {
+	dsn, err := c.dialect.DSN(ds.Properties)
+	settings, serr := poolSettingsOf(ds.Properties)
+	c.pools.mu.Lock()
+	defer c.pools.mu.Unlock()
+	p, ok := c.pools.byID[ds.ID]
+	if !ok || err == nil && serr == nil && p.key == poolKey(dsn, settings) {
+		return
+	}
+	delete(c.pools.byID, ds.ID)
+	closeAsync(p.db)
+}
+
+func (c *Class) Release(id domain.DataSourceID) {
+	c.pools.mu.Lock()
+	defer c.pools.mu.Unlock()
+	if p, ok := c.pools.byID[id]; ok {
+		delete(c.pools.byID, id)
+		closeAsync(p.db)
+	}
+}
+
+func (c *Class) PoolStats() []domain.PoolStats {
+	c.pools.mu.Lock()
+	defer c.pools.mu.Unlock()
+	out := make([]domain.PoolStats, 0, len(c.pools.byID))
+	for id, p := range c.pools.byID {
+		st := p.db.Stats()
+		out = append(out, domain.PoolStats{
+			DataSourceID:      id,
+			ClassID:           c.dialect.ClassID(),
+			MaxOpen:           st.MaxOpenConnections,
+			Open:              st.OpenConnections,
+			InUse:             st.InUse,
+			Idle:              st.Idle,
+			WaitCount:         st.WaitCount,
+			WaitDuration:      st.WaitDuration,
+			MaxIdleClosed:     st.MaxIdleClosed,
+			MaxLifetimeClosed: st.MaxLifetimeClosed,
+			MaxIdleTimeClosed: st.MaxIdleTimeClosed,
+		})
+	}
+	slices.SortFunc(out, func(a, b domain.PoolStats) int {
+		return strings.Compare(a.DataSourceID.String(), b.DataSourceID.String())
+	})
+	return out
+}
+
+// closeAsync closes a replaced pool without blocking on the queries that
+// still use it; sql.DB.Close waits for them to finish.
+func closeAsync(db *sql.DB) {
+	go db.Close()
+}
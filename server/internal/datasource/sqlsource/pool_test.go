@@ -0,0 +1,53 @@
+package sqlsource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestClassPoolsPerDataSource(t *testing.T) {
+	class := sqlsource.NewSQLite()
+	ds := newSQLiteDataSource(t, `CREATE TABLE t (n INTEGER);`)
+	ds.ID = domain.NewDataSourceID(1)
+	ds.Properties[sqlsource.PropertyMaxOpenConns] = "3"
+
+	query := func() {
+		t.Helper()
+		_, err := class.Query(context.Background(), ds, domain.Query{
+			Properties: map[domain.PropertyKey]domain.PropertyValue{sqlsource.QueryPropertySQL: "SELECT n FROM t"},
+		})
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+	}
+	query()
+	query()
+	stats := class.PoolStats()
+	if len(stats) != 1 || stats[0].DataSourceID != ds.ID || stats[0].MaxOpen != 3 || stats[0].Open != 1 {
+		t.Fatalf("stats = %+v, want one reused pool of at most 3 connections", stats)
+	}
+
+	// renaming keeps the pool, changing connection settings drops it
+	ds.Name = "renamed"
+	class.Reconfigure(ds)
+	if len(class.PoolStats()) != 1 {
+		t.Fatalf("pool dropped although connection settings did not change")
+	}
+	ds.Properties[sqlsource.PropertyMaxOpenConns] = "5"
+	class.Reconfigure(ds)
+	if n := len(class.PoolStats()); n != 0 {
+		t.Fatalf("%d pools after reconfigure, want 0", n)
+	}
+	query()
+	if stats := class.PoolStats(); len(stats) != 1 || stats[0].MaxOpen != 5 {
+		t.Fatalf("stats = %+v, want rebuilt pool of 5", stats)
+	}
+
+	class.Release(ds.ID)
+	if n := len(class.PoolStats()); n != 0 {
+		t.Fatalf("%d pools after release, want 0", n)
+	}
+}
@@ -0,0 +1,302 @@
+package sqlsource
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// Postgres is the dialect of the "postgres" DataSourceClass.
+type Postgres struct{}
+
+func NewPostgres() *Class { return New(Postgres{}) }
+
+func (Postgres) ClassID() domain.DataSourceClassID { return "postgres" }
+func (Postgres) ClassName() domain.Name            { return "PostgreSQL" }
+func (Postgres) DriverName() string                { return "pgx" }
+
+func (Postgres) PropertyDescriptors() []domain.PropertyDescriptor {
+	return []domain.PropertyDescriptor{
+		{Key: "host", Name: "Host", Type: domain.PropertyTypeString, Category: "Connection", Order: 0, IsRequired: true},
+		{Key: "port", Name: "Port", Type: domain.PropertyTypeNumber, Category: "Connection", Order: 1},
+		{Key: "database", Name: "Database", Type: domain.PropertyTypeString, Category: "Connection", Order: 2, IsRequired: true},
+		{Key: "user", Name: "User", Type: domain.PropertyTypeString, Category: "Authentication", Order: 3, IsRequired: true},
+		{Key: "password", Name: "Password", Type: domain.PropertyTypeString, Category: "Authentication", Order: 4, IsSecret: true},
+		{
+			Key: "sslmode", Name: "SSL Mode", Type: domain.PropertyTypeString, Category: "Connection", Order: 5,
+			Candidates: []domain.PropertyValue{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"},
+		},
+	}
+}
+
+func (Postgres) DSN(props map[domain.PropertyKey]domain.PropertyValue) (string, error) {
+	host := string(props["host"])
+	if host == "" {
+		return "", fmt.Errorf("postgres: host is required")
+	}
+	if port := props["port"]; port != "" {
+		host += ":" + string(port)
+	}
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   host,
+		Path:   "/" + string(props["database"]),
+	}
+	if user := string(props["user"]); user != "" {
+		if pw, ok := props["password"]; ok {
+			u.User = url.UserPassword(user, string(pw))
+		} else {
+			u.User = url.User(user)
+		}
+	}
+	if mode := props["sslmode"]; mode != "" {
+		u.RawQuery = url.Values{"sslmode": {string(mode)}}.Encode()
+	}
+	return u.String(), nil
+}
+
+// ColumnType maps the type names reported by pgx. Arrays, ranges,
+// geometric and network types are rendered as strings, and so is NUMERIC:
+// pgx reads it as its exact decimal text, which a float64 would round.
+func (Postgres) ColumnType(name string) domain.PropertyType {
+	switch strings.ToUpper(name) {
+	case "INT2", "INT4", "INT8", "OID", "XID", "CID":
+		return domain.PropertyTypeInteger
+	case "FLOAT4", "FLOAT8":
+		return domain.PropertyTypeNumber
+	case "BOOL":
+		return domain.PropertyTypeBoolean
+	case "TIMESTAMPTZ", "TIMESTAMP", "DATE":
+		return domain.PropertyTypeTime
+	case "JSON", "JSONB":
+		return domain.PropertyTypeJSON
+	}
+	return domain.PropertyTypeString
+}
+
+// DescribeColumns looks up Path and Description in the catalog for columns
+// that come straight from a table. Describing the statement through an
+// unnamed prepared statement reveals the source table of every field
+// without running the query again.
+func (Postgres) DescribeColumns(ctx context.Context, db *sql.DB, stmt string) ([]*domain.ColumnMeta, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var fields []pgconn.FieldDescription
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("postgres: unexpected driver connection %T", driverConn)
+		}
+		sd, err := c.Conn().PgConn().Prepare(ctx, "", stmt, nil)
+		if err != nil {
+			return err
+		}
+		fields = sd.Fields
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type attr struct {
+		table uint32
+		num   uint16
+	}
+	described := make(map[attr]domain.ColumnMeta)
+	seen := make(map[uint32]bool)
+	for _, f := range fields {
+		if f.TableOID == 0 || seen[f.TableOID] {
+			continue
+		}
+		seen[f.TableOID] = true
+
+		rows, err := conn.QueryContext(ctx, `
+			SELECT a.attnum, n.nspname, c.relname, a.attname, COALESCE(col_description(c.oid, a.attnum), '')
+			FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE a.attrelid = $1 AND a.attnum > 0`, int64(f.TableOID))
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				num                         int64
+				schema, table, column, note string
+			)
+			if err := rows.Scan(&num, &schema, &table, &column, &note); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			described[attr{f.TableOID, uint16(num)}] = domain.ColumnMeta{
+				Path:        schema + "." + table + "." + column,
+				Description: note,
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	metas := make([]*domain.ColumnMeta, len(fields))
+	for i, f := range fields {
+		if meta, ok := described[attr{f.TableOID, f.TableAttributeNumber}]; ok {
+			metas[i] = &meta
+		}
+	}
+	return metas, nil
+}
+
+func (Postgres) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (Postgres) Contains(expr, arg string) string {
+	return fmt.Sprintf(`CAST(%s AS TEXT) ILIKE %s ESCAPE '\'`, expr, arg)
+}
+
+// TimeFilter binds the ends of the range as timestamptz, which compares
+// with both timestamp types and keeps indexes on expr usable.
+func (Postgres) TimeFilter(expr string, from, to time.Time, bind func(any) string) string {
+	return fmt.Sprintf("%s BETWEEN %s AND %s", expr, bind(from), bind(to))
+}
+
+func (Postgres) TimeGroup(expr string, interval time.Duration, bind func(any) string) string {
+	secs := interval.Seconds()
+	return fmt.Sprintf("to_timestamp(floor(extract(epoch from %s)::float8 / %s::float8) * %s::float8)", expr, bind(secs), bind(secs))
+}
+
+func (Postgres) Limit(limit, offset int) string {
+	if limit <= 0 {
+		return fmt.Sprintf("OFFSET %d", offset)
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (Postgres) QuoteIdent(name string) string { return quoteIdent(name) }
+
+func (Postgres) Syntax() Syntax {
+	return Syntax{NestedComments: true, EscapeStrings: true, DollarQuotes: true}
+}
+
+func (Postgres) Address(props map[domain.PropertyKey]domain.PropertyValue) (string, string, error) {
+	host := string(props["host"])
+	if host == "" {
+		return "", "", fmt.Errorf("postgres: host is required")
+	}
+	port := string(props["port"])
+	if port == "" {
+		port = "5432"
+	}
+	return host, port, nil
+}
+
+// sslRequestCode asks the server to switch to TLS before the startup
+// message, as libpq does.
+const sslRequestCode = 80877103
+
+// StartTLS sends an SSLRequest and completes the handshake the way the
+// sslmode property asks. A server refusing TLS fails only the modes that
+// require it.
+func (Postgres) StartTLS(ctx context.Context, conn net.Conn, host string, props map[domain.PropertyKey]domain.PropertyValue) (bool, error) {
+	mode := string(props["sslmode"])
+	if mode == "disable" {
+		return false, nil
+	}
+	required := mode == "require" || mode == "verify-ca" || mode == "verify-full"
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	var req [8]byte
+	binary.BigEndian.PutUint32(req[0:4], 8)
+	binary.BigEndian.PutUint32(req[4:8], sslRequestCode)
+	if _, err := conn.Write(req[:]); err != nil {
+		return false, err
+	}
+	var resp [1]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return false, err
+	}
+	switch resp[0] {
+	case 'S':
+	case 'N':
+		if required {
+			return false, fmt.Errorf("postgres: server does not accept TLS but sslmode is %s", mode)
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("postgres: unexpected reply %q to SSLRequest", resp[0])
+	}
+
+	cfg := &tls.Config{ServerName: host}
+	switch mode {
+	case "verify-full":
+	case "verify-ca":
+		// verify the chain but not the host name, like libpq
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("postgres: server sent no certificate")
+			}
+			opts := x509.VerifyOptions{Intermediates: x509.NewCertPool()}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	default:
+		cfg.InsecureSkipVerify = true
+	}
+	if err := tls.Client(conn, cfg).HandshakeContext(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DescribeSchema reads the catalog rather than information_schema, which
+// hides the comments and the type names ColumnType expects. System
+// schemas and schemas the user cannot use are left out.
+func (p Postgres) DescribeSchema(ctx context.Context, db *sql.DB) (domain.DatabaseSchema, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT n.nspname, c.relname, c.relkind::text, COALESCE(obj_description(c.oid, 'pg_class'), ''),
+			a.attname, format_type(a.atttypid, a.atttypmod), t.typname, NOT a.attnotnull,
+			COALESCE(col_description(c.oid, a.attnum), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f')
+			AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg\_toast%' AND n.nspname NOT LIKE 'pg\_temp%'
+			AND has_schema_privilege(n.oid, 'USAGE')
+		ORDER BY n.nspname, c.relname, a.attnum`)
+	if err != nil {
+		return domain.DatabaseSchema{}, err
+	}
+	return buildSchema(rows, p.ColumnType, func(relkind string) domain.TableKind {
+		if relkind == "v" || relkind == "m" {
+			return domain.TableKindView
+		}
+		return domain.TableKindTable
+	})
+}
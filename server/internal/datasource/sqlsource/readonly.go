@@ -0,0 +1,179 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+const (
+	// PropertyReadOnly makes a data source refuse statements that write,
+	// except in mutation queries.
+	PropertyReadOnly domain.PropertyKey = "readOnly"
+	// QueryPropertyMutation designates a query allowed to write to a
+	// read-only data source.
+	QueryPropertyMutation domain.PropertyKey = "mutation"
+)
+
+var readOnlyDescriptor = domain.PropertyDescriptor{
+	Key: PropertyReadOnly, Name: "Read Only", Type: domain.PropertyTypeBoolean, Category: "Security", Order: 110,
+}
+
+// ReadOnlyConnDialect is implemented by dialects that make a connection
+// read-only rather than run reads in a read-only transaction, which their
+// driver may ignore. ReadOnly makes conn refuse writes until restore is
+// called.
+type ReadOnlyConnDialect interface {
+	ReadOnly(ctx context.Context, conn *sql.Conn) (restore func(), err error)
+}
+
+// querier is what queries run on: a pool, a connection or a transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readOnly fails closed: a value that is not a boolean counts as set.
+func readOnly(ds domain.DataSource) bool {
+	raw := ds.Properties[PropertyReadOnly]
+	if raw == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(string(raw))
+	return err != nil || b
+}
+
+func mutation(q domain.Query) bool {
+	b, _ := strconv.ParseBool(string(q.Properties[QueryPropertyMutation]))
+	return b
+}
+
+// session returns where the statements of q run on db. Reads from a
+// read-only data source are checked with CheckReadOnly and run in a
+// read-only transaction, so that writes the check cannot see, such as
+// those made by functions, still fail in the database.
+func (c *Class) session(ctx context.Context, db *sql.DB, ds domain.DataSource, q domain.Query, stmt string) (querier, func(), error) {
+	if !readOnly(ds) || mutation(q) {
+		return db, func() {}, nil
+	}
+	if err := CheckReadOnly(c.dialect, stmt); err != nil {
+		return nil, nil, err
+	}
+	if d, ok := c.dialect.(ReadOnlyConnDialect); ok {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		restore, err := d.ReadOnly(ctx, conn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return conn, func() { restore(); conn.Close() }, nil
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, func() { tx.Rollback() }, nil
+}
+
+// readStarts are the leading keywords of statements that only read.
+var readStarts = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true, "SHOW": true, "EXPLAIN": true,
+}
+
+// writeWords are keywords that make a statement write wherever they
+// appear, as in data-modifying CTEs, SELECT INTO and locking reads.
+// Function calls of the same name, such as replace(), are allowed.
+var writeWords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true, "REPLACE": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true, "RENAME": true,
+	"GRANT": true, "REVOKE": true, "COPY": true, "ATTACH": true, "DETACH": true,
+	"VACUUM": true, "REINDEX": true, "CALL": true, "LOCK": true, "INTO": true,
+}
+
+// CheckReadOnly returns a *datasource.ReadOnlyViolation unless every
+// statement in stmt is a read. It follows the comment and quoting rules of
+// d and rejects what it cannot tokenize. Where backslashes escape in
+// strings, stmt must also read as a read without them, since a server
+// setting may turn them off.
+func CheckReadOnly(d Dialect, stmt string) error {
+	syn := d.Syntax()
+	if err := checkReadOnly(syn, stmt); err != nil || !syn.BackslashEscapes {
+		return err
+	}
+	syn.BackslashEscapes = false
+	return checkReadOnly(syn, stmt)
+}
+
+func checkReadOnly(syn Syntax, stmt string) error {
+	statements, err := tokenize(stmt, syn)
+	if err != nil {
+		return &datasource.ReadOnlyViolation{Statement: len(statements), Reason: err.Error()}
+	}
+	for i, words := range statements {
+		if len(words) == 0 {
+			continue
+		}
+		if first := words[0].text; !readStarts[first] {
+			return &datasource.ReadOnlyViolation{
+				Statement: i + 1, Keyword: first, Reason: first + " statements are not allowed",
+			}
+		}
+		for _, w := range words {
+			if writeWords[w.text] && !w.call {
+				return &datasource.ReadOnlyViolation{
+					Statement: i + 1, Keyword: w.text, Reason: w.text + " is not allowed",
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sqlWord is an unquoted keyword or identifier, upper-cased. call is set
+// when it is followed by an opening parenthesis.
+type sqlWord struct {
+	text string
+	call bool
+}
+
+// tokenize splits stmt at semicolons into the words of each statement.
+func tokenize(stmt string, syn Syntax) ([][]sqlWord, error) {
+	var (
+		statements [][]sqlWord
+		words      []sqlWord
+	)
+	s := []rune(stmt)
+	spans, err := codeSpans(s, syn)
+	for _, span := range spans {
+		for i := span.start; i < span.end; {
+			r := s[i]
+			switch {
+			case r == ';':
+				statements = append(statements, words)
+				words = nil
+				i++
+			case unicode.IsLetter(r) || r == '_':
+				start := i
+				for i < span.end && isIdentRune(s[i]) {
+					i++
+				}
+				j := i
+				for j < len(s) && unicode.IsSpace(s[j]) {
+					j++
+				}
+				words = append(words, sqlWord{text: strings.ToUpper(string(s[start:i])), call: at(s, j) == '('})
+			default:
+				i++
+			}
+		}
+	}
+	return append(statements, words), err
+}
@@ -0,0 +1,114 @@
+package sqlsource_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	reads := []string{
+		"SELECT * FROM t",
+		"select replace(name, 'a', 'b') from t;",
+		"WITH x AS (SELECT 1) SELECT * FROM x",
+		"SELECT 'DROP TABLE t; DELETE' AS s, \"update\" FROM t -- DROP\n",
+		"SELECT $body$ ; DROP TABLE t; $body$, $1 /* /* nested */ DELETE */",
+		"SELECT E'it\\'s; DROP TABLE t' FROM t",
+		"EXPLAIN SELECT 1; SELECT 2;",
+	}
+	for _, stmt := range reads {
+		if err := sqlsource.CheckReadOnly(sqlsource.Postgres{}, stmt); err != nil {
+			t.Fatalf("CheckReadOnly(%q) = %v, want nil", stmt, err)
+		}
+	}
+
+	writes := map[string]datasource.ReadOnlyViolation{
+		"DROP TABLE t":            {Statement: 1, Keyword: "DROP"},
+		"SELECT 1; delete from t": {Statement: 2, Keyword: "DELETE"},
+		"WITH gone AS (DELETE FROM t RETURNING *) SELECT * FROM gone": {Statement: 1, Keyword: "DELETE"},
+		"SELECT * INTO copy FROM t":                                   {Statement: 1, Keyword: "INTO"},
+		"SELECT * FROM t FOR UPDATE":                                  {Statement: 1, Keyword: "UPDATE"},
+		"PRAGMA journal_mode = DELETE":                                {Statement: 1, Keyword: "PRAGMA"},
+		"SELECT 'it''s'; SELECT 'open":                                {Statement: 2},
+	}
+	for stmt, want := range writes {
+		err := sqlsource.CheckReadOnly(sqlsource.Postgres{}, stmt)
+		var v *datasource.ReadOnlyViolation
+		if !errors.As(err, &v) || v.Statement != want.Statement || v.Keyword != want.Keyword {
+			t.Fatalf("CheckReadOnly(%q) = %v, want violation of statement %d at %q", stmt, err, want.Statement, want.Keyword)
+		}
+	}
+}
+
+func TestCheckReadOnlyFollowsDialect(t *testing.T) {
+	cases := []struct {
+		dialect sqlsource.Dialect
+		stmt    string
+		want    *datasource.ReadOnlyViolation
+	}{
+		{sqlsource.MySQL{}, `SELECT 'a\'' INTO OUTFILE '/tmp/x' -- '`, &datasource.ReadOnlyViolation{Statement: 1, Keyword: "INTO"}},
+		{sqlsource.MySQL{}, `SELECT "a\"" INTO OUTFILE '/tmp/x' -- "`, &datasource.ReadOnlyViolation{Statement: 1, Keyword: "INTO"}},
+		// read with backslash escapes, a write under NO_BACKSLASH_ESCAPES
+		{sqlsource.MySQL{}, `SELECT 'a\' ; DROP TABLE t; -- '`, &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+		{sqlsource.MySQL{}, "SELECT 1--1 INTO OUTFILE '/tmp/x'", &datasource.ReadOnlyViolation{Statement: 1, Keyword: "INTO"}},
+		{sqlsource.MySQL{}, "SELECT 1 /*! INTO OUTFILE '/tmp/x' */", &datasource.ReadOnlyViolation{Statement: 1, Keyword: "INTO"}},
+		{sqlsource.MySQL{}, "SELECT 1 /* /* */ ; DROP TABLE t; -- */", &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+		{sqlsource.MySQL{}, "SELECT 1 # ; DROP TABLE t\n", nil},
+		{sqlsource.MySQL{}, "SELECT `a;b`, 'it''s' FROM t -- ; DROP TABLE t", nil},
+		{sqlsource.SQLite{}, "SELECT [x'] ; DROP TABLE t; --']", &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+		{sqlsource.SQLite{}, "SELECT 1 /* /* */ ; DROP TABLE t; -- */", &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+		{sqlsource.Postgres{}, "SELECT 1 # 2 ; DROP TABLE t", &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+		{sqlsource.Postgres{}, "SELECT 1 ` ; DROP TABLE t; `", &datasource.ReadOnlyViolation{Statement: 2, Keyword: "DROP"}},
+	}
+	for _, tc := range cases {
+		err := sqlsource.CheckReadOnly(tc.dialect, tc.stmt)
+		if tc.want == nil {
+			if err != nil {
+				t.Fatalf("%s: CheckReadOnly(%q) = %v, want nil", tc.dialect.ClassID(), tc.stmt, err)
+			}
+			continue
+		}
+		var v *datasource.ReadOnlyViolation
+		if !errors.As(err, &v) || v.Statement != tc.want.Statement || v.Keyword != tc.want.Keyword {
+			t.Fatalf("%s: CheckReadOnly(%q) = %v, want violation of statement %d at %q",
+				tc.dialect.ClassID(), tc.stmt, err, tc.want.Statement, tc.want.Keyword)
+		}
+	}
+}
+
+func TestSQLiteReadOnly(t *testing.T) {
+	class := sqlsource.NewSQLite()
+	ds := newSQLiteDataSource(t, `CREATE TABLE t (n INTEGER);`)
+	ds.ID = domain.NewDataSourceID(1)
+	ds.Properties[sqlsource.PropertyReadOnly] = "true"
+	// one connection, so that the mutation reuses the read-only one
+	ds.Properties[sqlsource.PropertyMaxOpenConns] = "1"
+	defer class.Release(ds.ID)
+
+	query := func(stmt string, mutation bool) error {
+		props := map[domain.PropertyKey]domain.PropertyValue{sqlsource.QueryPropertySQL: domain.PropertyValue(stmt)}
+		if mutation {
+			props[sqlsource.QueryPropertyMutation] = "true"
+		}
+		_, err := class.Query(context.Background(), ds, domain.Query{Properties: props})
+		return err
+	}
+
+	var v *datasource.ReadOnlyViolation
+	if err := query("INSERT INTO t VALUES (1)", false); !errors.As(err, &v) {
+		t.Fatalf("insert = %v, want a read-only violation", err)
+	}
+	if err := query("SELECT n FROM t", false); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if err := query("INSERT INTO t VALUES (1)", true); err != nil {
+		t.Fatalf("mutation after a read-only query: %v", err)
+	}
+	if err := query("SELECT n FROM t WHERE n = 1", false); err != nil {
+		t.Fatalf("select after mutation: %v", err)
+	}
+}
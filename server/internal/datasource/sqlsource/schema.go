@@ -0,0 +1,62 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// SchemaDescriber is implemented by dialects that can list the tables and
+// columns visible to the connected user.
+type SchemaDescriber interface {
+	DescribeSchema(ctx context.Context, db *sql.DB) (domain.DatabaseSchema, error)
+}
+
+// Introspect describes the schema of ds through its pool. Column types are
+// mapped with the dialect's ColumnType.
+func (c *Class) Introspect(ctx context.Context, ds domain.DataSource) (domain.DatabaseSchema, error) {
+	d, ok := c.dialect.(SchemaDescriber)
+	if !ok {
+		return domain.DatabaseSchema{}, fmt.Errorf("%w: schema introspection for %s", datasource.ErrUnsupported, c.dialect.ClassID())
+	}
+	db, release, err := c.db(ds)
+	if err != nil {
+		return domain.DatabaseSchema{}, err
+	}
+	defer release()
+	return d.DescribeSchema(ctx, db)
+}
+
+// buildSchema scans rows of (schema, table, kind, table comment, column,
+// database type, type name, nullable, column comment), ordered by schema
+// and table. typeName is what columnType understands, which may differ
+// from the database type shown to users.
+func buildSchema(rows *sql.Rows, columnType func(string) domain.PropertyType, kind func(string) domain.TableKind) (domain.DatabaseSchema, error) {
+	defer rows.Close()
+	out := domain.DatabaseSchema{Schemas: []domain.SchemaInfo{}}
+	for rows.Next() {
+		var (
+			schema, table, rawKind, comment, typeName string
+			col                                       domain.ColumnInfo
+		)
+		if err := rows.Scan(&schema, &table, &rawKind, &comment,
+			&col.Name, &col.DatabaseType, &typeName, &col.Nullable, &col.Comment); err != nil {
+			return domain.DatabaseSchema{}, err
+		}
+		col.Type = columnType(typeName)
+
+		if n := len(out.Schemas); n == 0 || out.Schemas[n-1].Name != schema {
+			out.Schemas = append(out.Schemas, domain.SchemaInfo{Name: schema, Tables: []domain.TableInfo{}})
+		}
+		s := &out.Schemas[len(out.Schemas)-1]
+		if n := len(s.Tables); n == 0 || s.Tables[n-1].Name != table {
+			s.Tables = append(s.Tables, domain.TableInfo{Name: table, Kind: kind(rawKind), Comment: comment})
+		}
+		t := &s.Tables[len(s.Tables)-1]
+		t.Columns = append(t.Columns, col)
+	}
+	return out, rows.Err()
+}
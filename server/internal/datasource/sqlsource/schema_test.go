@@ -0,0 +1,39 @@
+package sqlsource_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestSQLiteIntrospect(t *testing.T) {
+	ds := newSQLiteDataSource(t, `
+		CREATE TABLE orders (id INTEGER PRIMARY KEY, total REAL NOT NULL, placed_at DATETIME, note);
+		CREATE VIEW big_orders AS SELECT id, total FROM orders WHERE total > 100;`)
+
+	schema, err := sqlsource.NewSQLite().Introspect(context.Background(), ds)
+	if err != nil {
+		t.Fatalf("Introspect: %v", err)
+	}
+	want := domain.DatabaseSchema{Schemas: []domain.SchemaInfo{{
+		Name: "main",
+		Tables: []domain.TableInfo{
+			{Name: "big_orders", Kind: domain.TableKindView, Columns: []domain.ColumnInfo{
+				{Name: "id", DatabaseType: "INTEGER", Type: domain.PropertyTypeInteger, Nullable: true},
+				{Name: "total", DatabaseType: "REAL", Type: domain.PropertyTypeNumber, Nullable: true},
+			}},
+			{Name: "orders", Kind: domain.TableKindTable, Columns: []domain.ColumnInfo{
+				{Name: "id", DatabaseType: "INTEGER", Type: domain.PropertyTypeInteger, Nullable: true},
+				{Name: "total", DatabaseType: "REAL", Type: domain.PropertyTypeNumber},
+				{Name: "placed_at", DatabaseType: "DATETIME", Type: domain.PropertyTypeTime, Nullable: true},
+				{Name: "note", Nullable: true},
+			}},
+		},
+	}}}
+	if !reflect.DeepEqual(schema, want) {
+		t.Fatalf("schema = %+v\nwant %+v", schema, want)
+	}
+}
@@ -0,0 +1,152 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the "sqlite3" driver
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// SQLite is the dialect of the "sqlite" DataSourceClass.
+type SQLite struct{}
+
+func NewSQLite() *Class { return New(SQLite{}) }
+
+func (SQLite) ClassID() domain.DataSourceClassID { return "sqlite" }
+func (SQLite) ClassName() domain.Name            { return "SQLite" }
+func (SQLite) DriverName() string                { return "sqlite3" }
+
+func (SQLite) PropertyDescriptors() []domain.PropertyDescriptor {
+	return []domain.PropertyDescriptor{
+		{Key: "path", Name: "Database File", Type: domain.PropertyTypeString, Category: "Connection", Order: 0, IsRequired: true},
+	}
+}
+
+func (SQLite) DSN(props map[domain.PropertyKey]domain.PropertyValue) (string, error) {
+	path := string(props["path"])
+	if path == "" {
+		return "", fmt.Errorf("sqlite: path is required")
+	}
+	return path, nil
+}
+
+// ColumnType follows SQLite's type affinity rules on the declared column
+// type. Expressions have no declared type and are inferred from values.
+func (SQLite) ColumnType(declared string) domain.PropertyType {
+	t := strings.ToUpper(declared)
+	switch {
+	case t == "":
+		return ""
+	case strings.Contains(t, "BOOL"):
+		return domain.PropertyTypeBoolean
+	case strings.Contains(t, "INT"):
+		return domain.PropertyTypeInteger
+	case strings.Contains(t, "DATE"), strings.Contains(t, "TIME"):
+		return domain.PropertyTypeTime
+	case strings.Contains(t, "JSON"):
+		return domain.PropertyTypeJSON
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return domain.PropertyTypeString
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"),
+		strings.Contains(t, "NUM"), strings.Contains(t, "DEC"):
+		return domain.PropertyTypeNumber
+	}
+	return domain.PropertyTypeString
+}
+
+// DynamicTyping marks SQLite as storing any value in any column.
+func (SQLite) DynamicTyping() {}
+
+func (SQLite) Placeholder(int) string { return "?" }
+
+// Contains relies on LIKE, which SQLite matches case-insensitively for
+// ASCII letters.
+func (SQLite) Contains(expr, arg string) string {
+	return fmt.Sprintf(`CAST(%s AS TEXT) LIKE %s ESCAPE '\'`, expr, arg)
+}
+
+// sqliteTime is how times are bound for comparison with julianday.
+const sqliteTime = "2006-01-02 15:04:05.000"
+
+// TimeFilter compares through julianday, since SQLite keeps times as text
+// in any of several formats, or as Julian day numbers. Times kept as Unix
+// epochs need $__unixEpochFilter instead.
+func (SQLite) TimeFilter(expr string, from, to time.Time, bind func(any) string) string {
+	return fmt.Sprintf("julianday(%s) BETWEEN julianday(%s) AND julianday(%s)",
+		expr, bind(from.Format(sqliteTime)), bind(to.Format(sqliteTime)))
+}
+
+// TimeGroup works in whole seconds, the resolution of strftime('%s').
+func (SQLite) TimeGroup(expr string, interval time.Duration, bind func(any) string) string {
+	secs := max(int64(interval/time.Second), 1)
+	return fmt.Sprintf("datetime(CAST(strftime('%%s', %s) AS INTEGER) / %s * %s, 'unixepoch')", expr, bind(secs), bind(secs))
+}
+
+// Limit uses -1 for no limit because SQLite requires LIMIT before OFFSET.
+func (SQLite) Limit(limit, offset int) string {
+	if limit <= 0 {
+		limit = -1
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+}
+
+func (SQLite) QuoteIdent(name string) string { return quoteIdent(name) }
+
+// Syntax also accepts the MySQL and MS Access quoting of identifiers, as
+// SQLite does.
+func (SQLite) Syntax() Syntax {
+	return Syntax{BacktickIdents: true, BracketIdents: true}
+}
+
+// CheckAccess stats the database file, which the driver would otherwise
+// create empty. URIs and in-memory databases are left to the driver.
+func (SQLite) CheckAccess(props map[domain.PropertyKey]domain.PropertyValue) error {
+	path := string(props["path"])
+	if path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return nil
+	}
+	_, err := os.Stat(path)
+	return err
+}
+
+// DescribeSchema lists the tables and views of the main database. SQLite
+// has no comments, and a column without a declared type reports none.
+func (s SQLite) DescribeSchema(ctx context.Context, db *sql.DB) (domain.DatabaseSchema, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT 'main', m.name, m.type, '', p.name, p.type, p.type, NOT p."notnull", ''
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		ORDER BY m.name, p.cid`)
+	if err != nil {
+		return domain.DatabaseSchema{}, err
+	}
+	return buildSchema(rows, s.ColumnType, func(typ string) domain.TableKind {
+		if typ == "view" {
+			return domain.TableKindView
+		}
+		return domain.TableKindTable
+	})
+}
+
+// ReadOnly sets the query_only pragma, since the driver ignores read-only
+// transactions. The pragma outlives transactions, so restore clears it
+// before the connection returns to the pool.
+func (SQLite) ReadOnly(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA query_only = OFF"); err != nil {
+			// never hand a read-only connection to a mutation query
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}, nil
+}
@@ -0,0 +1,184 @@
+package sqlsource_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestSQLiteQueryTypedColumns(t *testing.T) {
+	ds := newSQLiteDataSource(t, `
+		CREATE TABLE events (
+			id INTEGER, score REAL, ok BOOLEAN, at DATETIME, payload JSON, label TEXT
+		);
+		INSERT INTO events VALUES (1, 1.5, 1, '2024-05-01 09:30:00', '{"a":1}', 'first');
+		INSERT INTO events VALUES (2, NULL, 0, NULL, NULL, NULL);
+	`)
+
+	table := runQuery(t, ds, "SELECT id, score, ok, at, payload, label FROM events ORDER BY id")
+
+	want := []domain.PropertyType{
+		domain.PropertyTypeInteger,
+		domain.PropertyTypeNumber,
+		domain.PropertyTypeBoolean,
+		domain.PropertyTypeTime,
+		domain.PropertyTypeJSON,
+		domain.PropertyTypeString,
+	}
+	for i, typ := range want {
+		if table.Columns[i].Type != typ {
+			t.Fatalf("column %s type = %s, want %s", table.Columns[i].Name, table.Columns[i].Type, typ)
+		}
+	}
+	if table.NumRows() != 2 {
+		t.Fatalf("rows = %d, want 2", table.NumRows())
+	}
+	if table.Columns[0].Ints[1] != 2 || table.Columns[1].Floats[0] != 1.5 || !table.Columns[2].Bools[0] {
+		t.Fatalf("unexpected values: %+v", table.Columns[:3])
+	}
+	for _, i := range []int{1, 3, 4, 5} {
+		if !table.Columns[i].IsNull(1) {
+			t.Fatalf("column %s row 1 should be null", table.Columns[i].Name)
+		}
+	}
+	if table.Columns[5].IsNull(0) {
+		t.Fatalf("label row 0 should not be null")
+	}
+}
+
+func TestSQLiteQueryInfersExpressionTypes(t *testing.T) {
+	ds := newSQLiteDataSource(t, `CREATE TABLE t (v INTEGER); INSERT INTO t VALUES ('not a number');`)
+
+	table := runQuery(t, ds, "SELECT 1 AS one, 1 + 0.5 AS half, NULL AS missing, v FROM t")
+
+	if got := table.Columns[0].Type; got != domain.PropertyTypeInteger {
+		t.Fatalf("one type = %s, want integer", got)
+	}
+	if got := table.Columns[1].Type; got != domain.PropertyTypeNumber {
+		t.Fatalf("half type = %s, want number", got)
+	}
+	if got := table.Columns[2]; got.Type != domain.PropertyTypeString || !got.IsNull(0) {
+		t.Fatalf("missing = %+v, want null string", got)
+	}
+	// text stored in an INTEGER column demotes it to strings
+	if got := table.Columns[3]; got.Type != domain.PropertyTypeString || got.Values[0] != "not a number" {
+		t.Fatalf("v = %+v, want string column", got)
+	}
+}
+
+func TestSQLiteQueryRequiresSQL(t *testing.T) {
+	ds := newSQLiteDataSource(t, "")
+	_, err := sqlsource.NewSQLite().Query(context.Background(), ds, domain.Query{})
+	if err != sqlsource.ErrMissingSQL {
+		t.Fatalf("err = %v, want ErrMissingSQL", err)
+	}
+}
+
+func TestSQLiteStreamQueryBatches(t *testing.T) {
+	ds := newSQLiteDataSource(t, `
+		CREATE TABLE t (v INTEGER);
+		INSERT INTO t VALUES (1), (2), (3), (4), (5);
+	`)
+	q := domain.Query{Properties: map[domain.PropertyKey]domain.PropertyValue{
+		sqlsource.QueryPropertySQL: "SELECT v, v * 0.5 AS half FROM t ORDER BY v",
+	}}
+
+	var sizes []int
+	var last domain.TableData
+	err := sqlsource.NewSQLite().StreamQuery(context.Background(), ds, q, 2, func(batch domain.TableData) error {
+		sizes = append(sizes, batch.NumRows())
+		last = batch
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamQuery: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[2] != 1 {
+		t.Fatalf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+	if last.Columns[1].Type != domain.PropertyTypeNumber || last.Columns[1].Floats[0] != 2.5 {
+		t.Fatalf("last batch half = %+v, want number 2.5", last.Columns[1])
+	}
+
+	// a value that does not fit a later batch widens the column from that
+	// batch on, without holding back the batches before it
+	mixed := newSQLiteDataSource(t, `
+		CREATE TABLE m (v INTEGER);
+		INSERT INTO m VALUES (1), (2), (3), ('n/a'), (5), (6);
+	`)
+	var types []domain.PropertyType
+	err = sqlsource.NewSQLite().StreamQuery(context.Background(), mixed, domain.Query{Properties: map[domain.PropertyKey]domain.PropertyValue{
+		sqlsource.QueryPropertySQL: "SELECT v FROM m",
+	}}, 2, func(batch domain.TableData) error {
+		types = append(types, batch.Columns[0].Type)
+		return nil
+	})
+	want := []domain.PropertyType{domain.PropertyTypeInteger, domain.PropertyTypeString, domain.PropertyTypeString}
+	if err != nil || !slices.Equal(types, want) {
+		t.Fatalf("batch types = %v, %v; want %v", types, err, want)
+	}
+
+	// inferred columns widen from integer to number
+	types = nil
+	err = sqlsource.NewSQLite().StreamQuery(context.Background(), mixed, domain.Query{Properties: map[domain.PropertyKey]domain.PropertyValue{
+		sqlsource.QueryPropertySQL: "SELECT CASE WHEN rowid < 3 THEN rowid ELSE rowid / 2.0 END FROM m",
+	}}, 2, func(batch domain.TableData) error {
+		types = append(types, batch.Columns[0].Type)
+		return nil
+	})
+	want = []domain.PropertyType{domain.PropertyTypeInteger, domain.PropertyTypeNumber, domain.PropertyTypeNumber}
+	if err != nil || !slices.Equal(types, want) {
+		t.Fatalf("expression batch types = %v, %v; want %v", types, err, want)
+	}
+
+	// an empty result still reports its columns
+	q.Properties[sqlsource.QueryPropertySQL] = "SELECT v FROM t WHERE v > 10"
+	var batches []domain.TableData
+	err = sqlsource.NewSQLite().StreamQuery(context.Background(), ds, q, 2, func(batch domain.TableData) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamQuery empty: %v", err)
+	}
+	if len(batches) != 1 || len(batches[0].Columns) != 1 || batches[0].NumRows() != 0 {
+		t.Fatalf("empty result batches = %+v", batches)
+	}
+}
+
+// Helpers
+func newSQLiteDataSource(t *testing.T, schema string) domain.DataSource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	if schema != "" {
+		if _, err := db.Exec(schema); err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+	}
+	return domain.DataSource{
+		ClassID:    "sqlite",
+		Name:       "local",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"path": domain.PropertyValue(path)},
+	}
+}
+
+func runQuery(t *testing.T, ds domain.DataSource, stmt string) domain.TableData {
+	t.Helper()
+	table, err := sqlsource.NewSQLite().Query(context.Background(), ds, domain.Query{
+		Properties: map[domain.PropertyKey]domain.PropertyValue{sqlsource.QueryPropertySQL: domain.PropertyValue(stmt)},
+	})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	return table
+}
@@ -0,0 +1,153 @@
+// Package sqlsource implements DataSourceClasses backed by database/sql.
+// Each supported database is described by a Dialect; execution and the
+// mapping of result sets onto TableData are shared.
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// QueryPropertySQL is the Query property holding the statement to run.
+const QueryPropertySQL domain.PropertyKey = "sql"
+
+var ErrMissingSQL = errors.New("query has no sql property")
+
+// Dialect captures what differs between SQL databases.
+type Dialect interface {
+	ClassID() domain.DataSourceClassID
+	ClassName() domain.Name
+	DriverName() string
+	PropertyDescriptors() []domain.PropertyDescriptor
+	// DSN builds the driver connection string from DataSource properties.
+	DSN(props map[domain.PropertyKey]domain.PropertyValue) (string, error)
+	// ColumnType maps a driver database type name onto a column type.
+	// An empty result means the type is inferred from the returned values.
+	ColumnType(databaseTypeName string) domain.PropertyType
+	// Placeholder returns the bind parameter marker for the nth argument,
+	// counting from 1.
+	Placeholder(n int) string
+	// Contains returns a case-insensitive substring match of expr against
+	// the LIKE pattern bound at arg, escaped with a backslash.
+	Contains(expr, arg string) string
+	// Limit returns the clause paging a result; a zero limit means none.
+	Limit(limit, offset int) string
+	// QuoteIdent quotes name as an identifier, such as a result column.
+	QuoteIdent(name string) string
+	// Syntax returns the lexical rules of statements, which CheckReadOnly
+	// and variable expansion follow.
+	Syntax() Syntax
+}
+
+// DynamicTyping is implemented by dialects whose values need not have the
+// declared type of their column, as in SQLite. A batch of their rows
+// cannot settle the column types of the rest; see ReadBatches.
+type DynamicTyping interface {
+	DynamicTyping()
+}
+
+// ColumnDescriber is implemented by dialects that can look up column
+// metadata, such as comments, for the columns a statement returns.
+// The result is aligned with the result columns; entries may be nil.
+type ColumnDescriber interface {
+	DescribeColumns(ctx context.Context, db *sql.DB, stmt string) ([]*domain.ColumnMeta, error)
+}
+
+// Class runs the "sql" query property against a database/sql driver,
+// keeping a connection pool per data source.
+type Class struct {
+	dialect Dialect
+	pools   pools
+}
+
+var (
+	_ datasource.StreamingClass     = (*Class)(nil)
+	_ datasource.WindowingClass     = (*Class)(nil)
+	_ datasource.PoolingClass       = (*Class)(nil)
+	_ datasource.HealthChecker      = (*Class)(nil)
+	_ datasource.SchemaIntrospector = (*Class)(nil)
+	_ datasource.BindingClass       = (*Class)(nil)
+)
+
+func New(d Dialect) *Class {
+	return &Class{dialect: d, pools: pools{byID: make(map[domain.DataSourceID]*pool)}}
+}
+
+func (c *Class) Descriptor() domain.DataSourceClass {
+	return domain.DataSourceClass{
+		ID:                  c.dialect.ClassID(),
+		Name:                c.dialect.ClassName(),
+		PropertyDescriptors: append(append(c.dialect.PropertyDescriptors(), poolDescriptors...), readOnlyDescriptor),
+	}
+}
+
+func (c *Class) Query(ctx context.Context, ds domain.DataSource, q domain.Query) (domain.TableData, error) {
+	var table domain.TableData
+	err := c.StreamQuery(ctx, ds, q, 0, func(batch domain.TableData) error {
+		table = batch
+		return nil
+	})
+	return table, err
+}
+
+func (c *Class) StreamQuery(
+	ctx context.Context,
+	ds domain.DataSource,
+	q domain.Query,
+	batchSize int,
+	emit func(domain.TableData) error,
+) error {
+	stmt := q.Properties[QueryPropertySQL]
+	if stmt == "" {
+		return ErrMissingSQL
+	}
+	bound, args, err := c.expand(string(stmt), q, nil)
+	if err != nil {
+		return err
+	}
+	db, release, err := c.db(ds)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	session, end, err := c.session(ctx, db, ds, q, bound)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	metas := c.describe(ctx, db, bound)
+	rows, err := session.QueryContext(ctx, bound, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return ReadBatches(rows, c.dialect, batchSize, withMetas(metas, emit))
+}
+
+// describe looks up column metadata of stmt when the dialect supports it.
+// Metadata is decorative: a failed lookup must not fail the query.
+func (c *Class) describe(ctx context.Context, db *sql.DB, stmt string) []*domain.ColumnMeta {
+	d, ok := c.dialect.(ColumnDescriber)
+	if !ok {
+		return nil
+	}
+	metas, _ := d.DescribeColumns(ctx, db, stmt)
+	return metas
+}
+
+func withMetas(metas []*domain.ColumnMeta, emit func(domain.TableData) error) func(domain.TableData) error {
+	return func(batch domain.TableData) error {
+		for i := range batch.Columns {
+			if i < len(metas) && metas[i] != nil {
+				batch.Columns[i].Meta = metas[i]
+			}
+		}
+		return emit(batch)
+	}
+}
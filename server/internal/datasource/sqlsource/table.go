@@ -0,0 +1,201 @@
+package sqlsource
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ReadTable drains rows into TableData. Column types come from the
+// dialect's mapping of driver type names; columns without a mapping, such
+// as SQLite expressions, take the type of the values they hold.
+func ReadTable(rows *sql.Rows, d Dialect) (domain.TableData, error) {
+	var table domain.TableData
+	err := ReadBatches(rows, d, 0, func(batch domain.TableData) error {
+		table = batch
+		return nil
+	})
+	return table, err
+}
+
+// ReadBatches drains rows into batches of at most batchSize rows, or a
+// single batch when batchSize is not positive. At least one batch is
+// emitted so that callers always learn the columns.
+//
+// Column types are settled by the first batch, the same way ReadTable
+// settles them for the whole result. A later value that does not fit its
+// column fails the read instead of silently changing the schema mid-stream.
+// Dialects with DynamicTyping widen such columns instead: the batch holding
+// the value, and every later one, has the column as a number when integers
+// meet fractions and as a string otherwise. Earlier batches are not
+// revisited.
+func ReadBatches(rows *sql.Rows, d Dialect, batchSize int, emit func(domain.TableData) error) error {
+	_, dynamic := d.(DynamicTyping)
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	names := make([]domain.Name, len(colTypes))
+	types := make([]domain.PropertyType, len(colTypes))
+	for i, ct := range colTypes {
+		names[i] = domain.Name(ct.Name())
+		types[i] = d.ColumnType(ct.DatabaseTypeName())
+	}
+	declared := slices.Clone(types)
+
+	raw := make([]any, len(colTypes))
+	dest := make([]any, len(colTypes))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+
+	first := true
+	var buffered [][]any
+	flush := func() error {
+		var batch domain.TableData
+		var err error
+		if first || dynamic {
+			for i := range types {
+				if !first && declared[i] == "" {
+					types[i] = widerType(types[i], inferType(buffered, i))
+				}
+			}
+			batch = buildTable(names, types, buffered)
+			for i, col := range batch.Columns {
+				types[i] = col.Type
+			}
+			first = false
+		} else {
+			batch, err = buildFixedTable(names, types, buffered)
+			if err != nil {
+				return err
+			}
+		}
+		buffered = buffered[:0]
+		return emit(batch)
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([]any, len(raw))
+		for i, v := range raw {
+			if b, ok := v.([]byte); ok {
+				// drivers reuse byte buffers between rows
+				v = append([]byte(nil), b...)
+			}
+			row[i] = v
+		}
+		buffered = append(buffered, row)
+		if batchSize > 0 && len(buffered) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if first || len(buffered) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// buildTable converts buffered rows, inferring columns of unknown type and
+// demoting columns whose values do not fit their declared type.
+func buildTable(names []domain.Name, types []domain.PropertyType, rows [][]any) domain.TableData {
+	cols := make([]domain.ColumnData, len(names))
+	for i := range names {
+		typ := types[i]
+		if typ == "" {
+			typ = inferType(rows, i)
+		}
+		if typ == "" {
+			typ = domain.PropertyTypeString
+		}
+		cols[i] = domain.NewColumnData(names[i], typ)
+		for _, row := range rows {
+			appendValue(&cols[i], row[i])
+		}
+	}
+	return domain.TableData{Columns: cols}
+}
+
+func buildFixedTable(names []domain.Name, types []domain.PropertyType, rows [][]any) (domain.TableData, error) {
+	cols := make([]domain.ColumnData, len(names))
+	for i := range names {
+		cols[i] = domain.NewColumnData(names[i], types[i])
+		for _, row := range rows {
+			if err := cols[i].Append(row[i]); err != nil {
+				return domain.TableData{}, fmt.Errorf("column %s: %w", names[i], err)
+			}
+		}
+	}
+	return domain.TableData{Columns: cols}, nil
+}
+
+// appendValue adds v to col, demoting the column to strings when a value
+// does not fit its declared type (SQLite happily stores text in INTEGER).
+func appendValue(col *domain.ColumnData, v any) {
+	if err := col.Append(v); err == nil {
+		return
+	}
+	str := domain.NewColumnData(col.Name, domain.PropertyTypeString)
+	for i := 0; i < col.Len(); i++ {
+		if col.IsNull(i) {
+			str.AppendNull()
+			continue
+		}
+		_ = str.Append(col.String(i))
+	}
+	_ = str.Append(v)
+	*col = str
+}
+
+// inferType is the narrowest type holding the values of column col, or
+// "" when they are all null.
+func inferType(rows [][]any, col int) domain.PropertyType {
+	var typ domain.PropertyType
+	for _, row := range rows {
+		var t domain.PropertyType
+		switch row[col].(type) {
+		case nil:
+			continue
+		case int64:
+			t = domain.PropertyTypeInteger
+		case float64:
+			t = domain.PropertyTypeNumber
+		case bool:
+			t = domain.PropertyTypeBoolean
+		case time.Time:
+			t = domain.PropertyTypeTime
+		default:
+			t = domain.PropertyTypeString
+		}
+		typ = widerType(typ, t)
+	}
+	return typ
+}
+
+// widerType is the type of a column holding values of types a and b,
+// either of which may be unknown.
+func widerType(a, b domain.PropertyType) domain.PropertyType {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	case isNumeric(a) && isNumeric(b):
+		return domain.PropertyTypeNumber
+	}
+	return domain.PropertyTypeString
+}
+
+func isNumeric(t domain.PropertyType) bool {
+	return t == domain.PropertyTypeInteger || t == domain.PropertyTypeNumber
+}
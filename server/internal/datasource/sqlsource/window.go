@@ -0,0 +1,220 @@
+package sqlsource
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+var comparisons = map[domain.FilterOp]string{
+	domain.FilterEq: "=",
+	domain.FilterNe: "<>",
+	domain.FilterGt: ">",
+	domain.FilterGe: ">=",
+	domain.FilterLt: "<",
+	domain.FilterLe: "<=",
+}
+
+// totalColumn carries the number of matching rows alongside each row of
+// a page, computed by the database in the same run as the page.
+const totalColumn = "__refana_total"
+
+// StreamWindow wraps the statement in a subquery so that filtering, sorting
+// and paging run in the database, and counts the matching rows in the same
+// run with a window function. Filter values are bound as parameters of the
+// column's type, which is learned by running the statement with no rows.
+func (c *Class) StreamWindow(
+	ctx context.Context,
+	ds domain.DataSource,
+	q domain.Query,
+	opts domain.DataOptions,
+	batchSize int,
+	emit func(domain.TableData) error,
+) error {
+	stmt := trimStatement(string(q.Properties[QueryPropertySQL]), c.dialect.Syntax())
+	if stmt == "" {
+		return ErrMissingSQL
+	}
+	stmt, stmtArgs, err := c.expand(stmt, q, nil)
+	if err != nil {
+		return err
+	}
+	db, release, err := c.db(ds)
+	if err != nil {
+		return err
+	}
+	defer release()
+	session, end, err := c.session(ctx, db, ds, q, stmt)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	from := "(" + stmt + "\n) AS q"
+	where, order, args := "", "", stmtArgs
+	if len(opts.Filters) > 0 || len(opts.Sort) > 0 {
+		cols, err := c.probe(ctx, session, from, stmtArgs)
+		if err != nil {
+			return err
+		}
+		if where, args, err = c.where(cols, opts.Filters, stmtArgs); err != nil {
+			return err
+		}
+		if order, err = c.orderBy(cols, opts.Sort); err != nil {
+			return err
+		}
+	}
+
+	metas := c.describe(ctx, db, stmt)
+	rows, err := session.QueryContext(ctx, c.windowQuery(from, where, order, opts), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	first := true
+	return ReadBatches(rows, c.dialect, batchSize, withMetas(metas, func(batch domain.TableData) error {
+		totals := batch.Columns[len(batch.Columns)-1]
+		batch.Columns = batch.Columns[:len(batch.Columns)-1]
+		if first {
+			first = false
+			switch {
+			case totals.Len() > 0:
+				total, err := strconv.ParseInt(totals.String(0), 10, 64)
+				if err != nil {
+					return fmt.Errorf("row count: %w", err)
+				}
+				batch.Total = &domain.RowCount{Rows: total}
+			case opts.Offset == 0:
+				batch.Total = &domain.RowCount{}
+			default:
+				// a page past the end counts nothing; count on its own
+				var total int64
+				if err := session.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+from+where, args...).Scan(&total); err != nil {
+					return err
+				}
+				batch.Total = &domain.RowCount{Rows: total}
+			}
+		}
+		return emit(batch)
+	}))
+}
+
+// windowQuery selects a page of from with the number of rows matching where.
+func (c *Class) windowQuery(from, where, order string, opts domain.DataOptions) string {
+	return "SELECT q.*, COUNT(*) OVER () AS " + c.dialect.QuoteIdent(totalColumn) + " FROM " + from + where + order +
+		" " + c.dialect.Limit(opts.Limit, opts.Offset)
+}
+
+// probe returns the result columns of from, keyed by name, with the type
+// the dialect maps them to ("" when it depends on the values).
+func (c *Class) probe(ctx context.Context, db querier, from string, args []any) (map[domain.Name]domain.PropertyType, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+from+" LIMIT 0", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[domain.Name]domain.PropertyType, len(colTypes))
+	for _, ct := range colTypes {
+		cols[domain.Name(ct.Name())] = c.dialect.ColumnType(ct.DatabaseTypeName())
+	}
+	return cols, nil
+}
+
+// where builds the filter clause, binding its values after args.
+func (c *Class) where(cols map[domain.Name]domain.PropertyType, filters []domain.Filter, args []any) (string, []any, error) {
+	if len(filters) == 0 {
+		return "", args, nil
+	}
+	conds := make([]string, 0, len(filters))
+	arg := func(v any) string {
+		args = append(args, v)
+		return c.dialect.Placeholder(len(args))
+	}
+	for _, f := range filters {
+		typ, ok := cols[f.Column]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: %s", tableops.ErrUnknownColumn, f.Column)
+		}
+		col := "q." + c.dialect.QuoteIdent(string(f.Column))
+		switch f.Op {
+		case domain.FilterIsNull:
+			conds = append(conds, col+" IS NULL")
+		case domain.FilterNotNull:
+			conds = append(conds, col+" IS NOT NULL")
+		case domain.FilterContains:
+			conds = append(conds, c.dialect.Contains(col, arg("%"+escapeLike(string(f.Value))+"%")))
+		default:
+			op, ok := comparisons[f.Op]
+			if !ok {
+				return "", nil, fmt.Errorf("%w: unknown operator %q", tableops.ErrInvalidFilter, f.Op)
+			}
+			v, err := filterArg(typ, f.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %s: %v", tableops.ErrInvalidFilter, f.Column, err)
+			}
+			conds = append(conds, col+" "+op+" "+arg(v))
+		}
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// filterArg converts a filter value to the column's type. Columns whose
+// type depends on their values bind numbers as numbers so that SQLite
+// compares them numerically.
+func filterArg(typ domain.PropertyType, v domain.PropertyValue) (any, error) {
+	if typ == "" {
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return f, nil
+		}
+		return string(v), nil
+	}
+	val, err := domain.NewColumnData("", typ).ParseValue(string(v))
+	if err != nil {
+		return nil, err
+	}
+	if typ == domain.PropertyTypeJSON {
+		return string(v), nil
+	}
+	return val, nil
+}
+
+// orderBy sorts nulls last in both directions, as the in-memory fallback does.
+func (c *Class) orderBy(cols map[domain.Name]domain.PropertyType, keys []domain.SortKey) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+	terms := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		if _, ok := cols[k.Column]; !ok {
+			return "", fmt.Errorf("%w: %s", tableops.ErrUnknownColumn, k.Column)
+		}
+		col := "q." + c.dialect.QuoteIdent(string(k.Column))
+		term := col
+		if k.Descending {
+			term += " DESC"
+		}
+		terms = append(terms, col+" IS NULL", term)
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// quoteIdent quotes name in double quotes, as standard SQL does.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
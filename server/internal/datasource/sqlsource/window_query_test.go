@@ -0,0 +1,44 @@
+package sqlsource
+
+import (
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestWindowQueryQuotesIdentifiersPerDialect(t *testing.T) {
+	cols := map[domain.Name]domain.PropertyType{"na`me": domain.PropertyTypeString, `sc"ore`: domain.PropertyTypeInteger}
+	opts := domain.DataOptions{
+		Limit:   10,
+		Sort:    []domain.SortKey{{Column: `sc"ore`, Descending: true}},
+		Filters: []domain.Filter{{Column: "na`me", Op: domain.FilterEq, Value: "x"}},
+	}
+	cases := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{Postgres{}, "SELECT q.*, COUNT(*) OVER () AS \"__refana_total\" FROM (SELECT 1\n) AS q" +
+			" WHERE q.\"na`me\" = $1 ORDER BY q.\"sc\"\"ore\" IS NULL, q.\"sc\"\"ore\" DESC LIMIT 10 OFFSET 0"},
+		{SQLite{}, "SELECT q.*, COUNT(*) OVER () AS \"__refana_total\" FROM (SELECT 1\n) AS q" +
+			" WHERE q.\"na`me\" = ? ORDER BY q.\"sc\"\"ore\" IS NULL, q.\"sc\"\"ore\" DESC LIMIT 10 OFFSET 0"},
+		{MySQL{}, "SELECT q.*, COUNT(*) OVER () AS `__refana_total` FROM (SELECT 1\n) AS q" +
+			" WHERE q.`na``me` = ? ORDER BY q.`sc\"ore` IS NULL, q.`sc\"ore` DESC LIMIT 10 OFFSET 0"},
+	}
+	for _, tc := range cases {
+		c := New(tc.dialect)
+		where, args, err := c.where(cols, opts.Filters, nil)
+		if err != nil {
+			t.Fatalf("%s: where: %v", tc.dialect.ClassID(), err)
+		}
+		order, err := c.orderBy(cols, opts.Sort)
+		if err != nil {
+			t.Fatalf("%s: orderBy: %v", tc.dialect.ClassID(), err)
+		}
+		if got := c.windowQuery("(SELECT 1\n) AS q", where, order, opts); got != tc.want {
+			t.Errorf("%s:\n got %s\nwant %s", tc.dialect.ClassID(), got, tc.want)
+		}
+		if len(args) != 1 {
+			t.Errorf("%s: args = %v", tc.dialect.ClassID(), args)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sqlsource_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+const windowSchema = `
+	CREATE TABLE items (id INTEGER, name TEXT, price REAL);
+	INSERT INTO items VALUES
+		(1, 'apple', 1.5), (2, 'Banana', 0.5), (3, 'cherry', NULL),
+		(4, 'grape_fruit', 3), (5, 'grapefruit', 2.5);`
+
+func TestSQLiteStreamWindow(t *testing.T) {
+	ds := newSQLiteDataSource(t, windowSchema)
+
+	table := runWindow(t, ds, "SELECT * FROM items;", domain.DataOptions{
+		Limit:   2,
+		Offset:  1,
+		Sort:    []domain.SortKey{{Column: "price", Descending: true}},
+		Filters: []domain.Filter{{Column: "id", Op: domain.FilterGe, Value: "2"}},
+	})
+	if table.Total == nil || table.Total.Rows != 4 || table.Total.Estimated {
+		t.Fatalf("total = %+v, want exactly 4", table.Total)
+	}
+	// price desc with nulls last: 3, 2.5, 0.5, NULL
+	ids := table.Columns[0].Ints
+	if len(ids) != 2 || ids[0] != 5 || ids[1] != 2 {
+		t.Fatalf("ids = %v, want [5 2]", ids)
+	}
+
+	table = runWindow(t, ds, "SELECT name FROM items", domain.DataOptions{
+		Filters: []domain.Filter{{Column: "name", Op: domain.FilterContains, Value: "E_F"}},
+	})
+	if table.NumRows() != 1 || table.Columns[0].Values[0] != "grape_fruit" {
+		t.Fatalf("contains matched %v, want only grape_fruit", table.Columns[0].Values)
+	}
+
+	table = runWindow(t, ds, "SELECT id * 2 AS twice FROM items", domain.DataOptions{
+		Filters: []domain.Filter{{Column: "twice", Op: domain.FilterGt, Value: "6"}},
+	})
+	if table.Total.Rows != 2 {
+		t.Fatalf("expression filter total = %d, want 2", table.Total.Rows)
+	}
+
+	// trailing comments and semicolons stay out of the subquery
+	table = runWindow(t, ds, "SELECT id FROM items WHERE name <> '--;' ; -- all but none\n/* done */", domain.DataOptions{Limit: 2})
+	if table.NumRows() != 2 || table.Total.Rows != 5 {
+		t.Fatalf("commented statement: %d rows of %+v, want 2 of 5", table.NumRows(), table.Total)
+	}
+
+	// a page past the end still learns the total
+	table = runWindow(t, ds, "SELECT id FROM items", domain.DataOptions{Limit: 2, Offset: 10})
+	if table.NumRows() != 0 || len(table.Columns) != 1 || table.Total.Rows != 5 {
+		t.Fatalf("page past the end: %d rows, columns %v, total %+v", table.NumRows(), table.Columns, table.Total)
+	}
+}
+
+func TestSQLiteStreamWindowRejectsBadOptions(t *testing.T) {
+	ds := newSQLiteDataSource(t, windowSchema)
+	q := domain.Query{Properties: map[domain.PropertyKey]domain.PropertyValue{
+		sqlsource.QueryPropertySQL: "SELECT * FROM items",
+	}}
+	cases := []struct {
+		opts domain.DataOptions
+		want error
+	}{
+		{domain.DataOptions{Sort: []domain.SortKey{{Column: "nope"}}}, tableops.ErrUnknownColumn},
+		{domain.DataOptions{Filters: []domain.Filter{{Column: "nope", Op: domain.FilterIsNull}}}, tableops.ErrUnknownColumn},
+		{domain.DataOptions{Filters: []domain.Filter{{Column: "id", Op: domain.FilterEq, Value: "x"}}}, tableops.ErrInvalidFilter},
+	}
+	for _, tc := range cases {
+		err := sqlsource.NewSQLite().StreamWindow(context.Background(), ds, q, tc.opts, 0, func(domain.TableData) error { return nil })
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("opts %+v: err = %v, want %v", tc.opts, err, tc.want)
+		}
+	}
+}
+
+func runWindow(t *testing.T, ds domain.DataSource, stmt string, opts domain.DataOptions) domain.TableData {
+	t.Helper()
+	var table domain.TableData
+	q := domain.Query{Properties: map[domain.PropertyKey]domain.PropertyValue{
+		sqlsource.QueryPropertySQL: domain.PropertyValue(stmt),
+	}}
+	err := sqlsource.NewSQLite().StreamWindow(context.Background(), ds, q, opts, 0, func(batch domain.TableData) error {
+		table = batch
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamWindow: %v", err)
+	}
+	return table
+}
@@ -0,0 +1,397 @@
+// Package email implements a notifier that sends notifications as mail
+// through an SMTP server.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+const (
+	SettingHost     domain.PropertyKey = "host"
+	SettingPort     domain.PropertyKey = "port"
+	SettingUsername domain.PropertyKey = "username"
+	SettingPassword domain.PropertyKey = "password"
+	// SettingTLS is one of the TLS modes below; TLSAuto applies when unset.
+	SettingTLS  domain.PropertyKey = "tls"
+	SettingFrom domain.PropertyKey = "from"
+	// SettingTo is a comma separated list of recipients.
+	SettingTo domain.PropertyKey = "to"
+	// SettingSubject is a text/template of the subject executed with the
+	// domain.Notification; DefaultSubject applies when unset.
+	SettingSubject domain.PropertyKey = "subject"
+)
+
+// TLS modes.
+const (
+	// TLSAuto upgrades the connection with STARTTLS when the server offers it.
+	TLSAuto domain.PropertyValue = "auto"
+	// TLSStartTLS requires STARTTLS.
+	TLSStartTLS domain.PropertyValue = "starttls"
+	// TLSImplicit connects over TLS, usually to port 465.
+	TLSImplicit domain.PropertyValue = "tls"
+	TLSNone     domain.PropertyValue = "none"
+)
+
+const (
+	DefaultPort    = 587
+	DefaultSubject = "{{with .Status}}[{{.}}] {{end}}{{.Title}}"
+)
+
+// sessionTimeout bounds each delivery attempt when ctx has no deadline.
+const sessionTimeout = 30 * time.Second
+
+// Email is the SMTP notifier.
+type Email struct{}
+
+func New() *Email {
+	return &Email{}
+}
+
+func (*Email) Descriptor() domain.ContactPointType {
+	return domain.ContactPointType{
+		ID:   "email",
+		Name: "Email",
+		PropertyDescriptors: []domain.PropertyDescriptor{
+			{Key: SettingHost, Name: "SMTP Host", Type: domain.PropertyTypeString, Category: "Server", Order: 0, IsRequired: true},
+			{Key: SettingPort, Name: "SMTP Port", Type: domain.PropertyTypeInteger, Category: "Server", Order: 1},
+			{
+				Key: SettingTLS, Name: "TLS", Type: domain.PropertyTypeString, Category: "Server", Order: 2,
+				Candidates: []domain.PropertyValue{TLSAuto, TLSStartTLS, TLSImplicit, TLSNone},
+			},
+			{Key: SettingUsername, Name: "Username", Type: domain.PropertyTypeString, Category: "Authentication", Order: 3},
+			{Key: SettingPassword, Name: "Password", Type: domain.PropertyTypeString, Category: "Authentication", Order: 4, IsSecret: true},
+			{Key: SettingFrom, Name: "From", Type: domain.PropertyTypeString, Category: "Message", Order: 5, IsRequired: true},
+			{Key: SettingTo, Name: "To", Type: domain.PropertyTypeString, Category: "Message", Order: 6, IsRequired: true},
+			{Key: SettingSubject, Name: "Subject Template", Type: domain.PropertyTypeString, Category: "Message", Order: 7},
+		},
+	}
+}
+
+func (*Email) Validate(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	_, err := configOf(settings)
+	return err
+}
+
+func (e *Email) Notify(ctx context.Context, cp domain.ContactPoint, n domain.Notification) error {
+	cfg, err := configOf(cp.Settings)
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	msg, err := cfg.message(n, time.Now())
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	return classify(cfg.send(ctx, msg))
+}
+
+// config is the parsed settings of a contact point.
+type config struct {
+	host     string
+	port     int
+	tls      domain.PropertyValue
+	username string
+	password string
+	from     *mail.Address
+	to       []*mail.Address
+	subject  *template.Template
+}
+
+func configOf(settings map[domain.PropertyKey]domain.PropertyValue) (config, error) {
+	cfg := config{
+		host:     string(settings[SettingHost]),
+		port:     DefaultPort,
+		tls:      settings[SettingTLS],
+		username: string(settings[SettingUsername]),
+		password: string(settings[SettingPassword]),
+	}
+	if cfg.host == "" {
+		return config{}, fmt.Errorf("%s is required", SettingHost)
+	}
+	if raw := settings[SettingPort]; raw != "" {
+		port, err := strconv.Atoi(string(raw))
+		if err != nil || port <= 0 || port > 65535 {
+			return config{}, fmt.Errorf("%s: must be a port number", SettingPort)
+		}
+		cfg.port = port
+	}
+	switch cfg.tls {
+	case "":
+		cfg.tls = TLSAuto
+	case TLSAuto, TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return config{}, fmt.Errorf("%s: unknown mode %s", SettingTLS, cfg.tls)
+	}
+	var err error
+	if cfg.from, err = mail.ParseAddress(string(settings[SettingFrom])); err != nil {
+		return config{}, fmt.Errorf("%s: %v", SettingFrom, err)
+	}
+	if cfg.to, err = mail.ParseAddressList(string(settings[SettingTo])); err != nil {
+		return config{}, fmt.Errorf("%s: %v", SettingTo, err)
+	}
+	subject := string(settings[SettingSubject])
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if cfg.subject, err = template.New("subject").Option("missingkey=error").Parse(subject); err != nil {
+		return config{}, fmt.Errorf("%s: %v", SettingSubject, err)
+	}
+	return cfg, nil
+}
+
+// message renders n as a mail: plain text, or a multipart mail when n has
+// HTML or attachments.
+func (cfg config) message(n domain.Notification, now time.Time) ([]byte, error) {
+	var subject bytes.Buffer
+	if err := cfg.subject.Execute(&subject, n); err != nil {
+		return nil, fmt.Errorf("%s: %v", SettingSubject, err)
+	}
+	to := make([]string, len(cfg.to))
+	for i, a := range cfg.to {
+		to[i] = a.String()
+	}
+	body, err := bodyOf(n)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", cfg.from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", oneLine(subject.String())))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	for _, k := range slices.Sorted(maps.Keys(body.header)) {
+		header(k, body.header.Get(k))
+	}
+	b.WriteString("\r\n")
+	b.Write(body.data)
+	return b.Bytes(), nil
+}
+
+// entity is a MIME entity: a whole body or a part of a multipart one.
+type entity struct {
+	header textproto.MIMEHeader
+	data   []byte
+}
+
+// bodyOf nests the parts of n the way mail clients expect: text and HTML
+// as alternatives, related to the inline attachments the HTML shows, mixed
+// with the other attachments.
+func bodyOf(n domain.Notification) (entity, error) {
+	message := n.Message
+	if message == "" {
+		message = n.Title
+	}
+	body, err := textEntity("text/plain", message)
+	if err != nil {
+		return entity{}, err
+	}
+	var inline, attached []entity
+	for _, a := range n.Attachments {
+		e := attachmentEntity(a, a.Inline && n.HTML != "")
+		if e.header.Get("Content-ID") != "" {
+			inline = append(inline, e)
+		} else {
+			attached = append(attached, e)
+		}
+	}
+	if n.HTML != "" {
+		html, err := textEntity("text/html", n.HTML)
+		if err != nil {
+			return entity{}, err
+		}
+		if body, err = multipartEntity("alternative", body, html); err != nil {
+			return entity{}, err
+		}
+	}
+	if len(inline) > 0 {
+		if body, err = multipartEntity("related", append([]entity{body}, inline...)...); err != nil {
+			return entity{}, err
+		}
+	}
+	if len(attached) > 0 {
+		if body, err = multipartEntity("mixed", append([]entity{body}, attached...)...); err != nil {
+			return entity{}, err
+		}
+	}
+	return body, nil
+}
+
+func textEntity(contentType, text string) (entity, error) {
+	var b bytes.Buffer
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+		return entity{}, err
+	}
+	if err := qp.Close(); err != nil {
+		return entity{}, err
+	}
+	return entity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		data: b.Bytes(),
+	}, nil
+}
+
+// attachmentEntity encodes a in base64, as a part the HTML refers to by
+// its Content-ID when inline.
+func attachmentEntity(a domain.Attachment, inline bool) entity {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	h := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Name})},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": a.Name})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if inline {
+		h.Set("Content-ID", "<"+a.Name+">")
+	}
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	var b strings.Builder
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	return entity{header: h, data: []byte(b.String())}
+}
+
+func multipartEntity(subtype string, parts ...entity) (entity, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return entity{}, err
+		}
+		if _, err := pw.Write(p.data); err != nil {
+			return entity{}, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return entity{}, err
+	}
+	return entity{
+		header: textproto.MIMEHeader{"Content-Type": {"multipart/" + subtype + "; boundary=" + w.Boundary()}},
+		data:   b.Bytes(),
+	}, nil
+}
+
+// oneLine keeps templates from injecting headers through the subject.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// send delivers msg in one SMTP session.
+func (cfg config) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(cfg.host, strconv.Itoa(cfg.port))
+	tlsConfig := &tls.Config{ServerName: cfg.host}
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if cfg.tls == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sessionTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+	// Cancelling ctx interrupts whatever the session is waiting for.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, cfg.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if cfg.tls == TLSAuto || cfg.tls == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if cfg.tls == TLSStartTLS {
+			return notifier.Permanent(errors.New("smtp server does not offer STARTTLS"))
+		}
+	}
+	if cfg.username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return notifier.Permanent(errors.New("smtp server does not offer authentication"))
+		}
+		if err := c.Auth(smtp.PlainAuth("", cfg.username, cfg.password, cfg.host)); err != nil {
+			// PlainAuth itself refuses to send credentials in the clear to
+			// remote servers, which no retry changes.
+			var tp *textproto.Error
+			var ne net.Error
+			if !errors.As(err, &tp) && !errors.As(err, &ne) {
+				return notifier.Permanent(err)
+			}
+			return err
+		}
+	}
+	if err := c.Mail(cfg.from.Address); err != nil {
+		return err
+	}
+	for _, a := range cfg.to {
+		if err := c.Rcpt(a.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// classify marks the permanent failures of a session: replies in the 5xx
+// range, which servers give for rejected credentials or recipients.
+func classify(err error) error {
+	var tp *textproto.Error
+	if errors.As(err, &tp) && tp.Code >= 500 {
+		return notifier.Permanent(err)
+	}
+	return err
+}
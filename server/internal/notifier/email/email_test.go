@@ -0,0 +1,228 @@
+package email_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/email"
+	"github.com/smilu97/refana/internal/notifier/email/emailtest"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func newSMTPServer(t *testing.T) *emailtest.Server {
+	t.Helper()
+	srv, err := emailtest.NewServer()
+	if err != nil {
+		t.Fatalf("smtp server: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func settingsFor(srv *emailtest.Server) map[domain.PropertyKey]domain.PropertyValue {
+	return map[domain.PropertyKey]domain.PropertyValue{
+		email.SettingHost: domain.PropertyValue(srv.Host),
+		email.SettingPort: domain.PropertyValue(srv.Port),
+		email.SettingFrom: "Refana <alerts@example.com>",
+		email.SettingTo:   "ops@example.com, Dev Team <dev@example.com>",
+	}
+}
+
+func notification() domain.Notification {
+	return domain.Notification{
+		Title:   "Disk almost full – web-1",
+		Message: "disk usage is 97%\non /var",
+		Status:  domain.AlertFiring,
+	}
+}
+
+func TestEmailNotify(t *testing.T) {
+	srv := newSMTPServer(t)
+	srv.RequireAuth("refana", "s3cret")
+	n := email.New()
+	settings := settingsFor(srv)
+	settings[email.SettingUsername] = "refana"
+	settings[email.SettingPassword] = "s3cret"
+	if err := n.Validate(settings); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := n.Notify(context.Background(), domain.ContactPoint{Settings: settings}, notification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("messages = %d, want 1", len(msgs))
+	}
+	got := msgs[0]
+	if got.From != "alerts@example.com" || strings.Join(got.To, ",") != "ops@example.com,dev@example.com" {
+		t.Fatalf("envelope = %s -> %v", got.From, got.To)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil || subject != "[firing] Disk almost full – web-1" {
+		t.Fatalf("subject = %q, %v", subject, err)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(m.Body))
+	if strings.TrimRight(string(body), "\r\n") != "disk usage is 97%\r\non /var" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestEmailSubjectTemplate(t *testing.T) {
+	srv := newSMTPServer(t)
+	settings := settingsFor(srv)
+	settings[email.SettingSubject] = "Alert:\r\nBcc: {{.Title}}"
+	if err := email.New().Notify(context.Background(), domain.ContactPoint{Settings: settings}, notification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(srv.Messages()[0].Data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if m.Header.Get("Bcc") != "" {
+		t.Fatal("the subject template injected a header")
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if subject != "Alert: Bcc: Disk almost full – web-1" {
+		t.Fatalf("subject = %q", subject)
+	}
+}
+
+func TestEmailAttachments(t *testing.T) {
+	srv := newSMTPServer(t)
+	n := domain.Notification{
+		Title:   "Weekly sales",
+		Message: "- Orders: 3 rows",
+		HTML:    `<p>Orders</p><img src="cid:orders.png">`,
+		Attachments: []domain.Attachment{
+			{Name: "orders.png", ContentType: "image/png", Data: []byte("\x89PNG"), Inline: true},
+			{Name: "orders.csv", ContentType: "text/csv", Data: []byte("day,orders\n")},
+		},
+	}
+	if err := email.New().Notify(context.Background(), domain.ContactPoint{Settings: settingsFor(srv)}, n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(srv.Messages()[0].Data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); subject != "Weekly sales" {
+		t.Fatalf("subject = %q", subject)
+	}
+
+	// mixed(related(alternative(text, html), png), csv)
+	type part struct {
+		contentType string
+		header      textproto.MIMEHeader
+		body        []byte
+	}
+	var walk func(contentType string, header textproto.MIMEHeader, body io.Reader) []part
+	walk = func(contentType string, header textproto.MIMEHeader, body io.Reader) []part {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			t.Fatalf("content type %q: %v", contentType, err)
+		}
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			data, _ := io.ReadAll(body)
+			return []part{{mediaType, header, data}}
+		}
+		out := []part{{contentType: mediaType}}
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextRawPart()
+			if err == io.EOF {
+				return out
+			}
+			if err != nil {
+				t.Fatalf("part: %v", err)
+			}
+			out = append(out, walk(p.Header.Get("Content-Type"), p.Header, p)...)
+		}
+	}
+	parts := walk(m.Header.Get("Content-Type"), textproto.MIMEHeader(m.Header), m.Body)
+	var types []string
+	for _, p := range parts {
+		types = append(types, p.contentType)
+	}
+	if got := strings.Join(types, " "); got != "multipart/mixed multipart/related multipart/alternative text/plain text/html image/png text/csv" {
+		t.Fatalf("structure = %s", got)
+	}
+	html, _ := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(parts[4].body)))
+	if string(html) != n.HTML {
+		t.Fatalf("html = %q", html)
+	}
+	png, csv := parts[5], parts[6]
+	if png.header.Get("Content-ID") != "<orders.png>" || !strings.HasPrefix(png.header.Get("Content-Disposition"), "inline") {
+		t.Fatalf("inline image header = %v", png.header)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(string(png.body)); string(data) != "\x89PNG" {
+		t.Fatalf("image = %q", data)
+	}
+	if csv.header.Get("Content-Disposition") != "attachment; filename=orders.csv" {
+		t.Fatalf("attachment header = %v", csv.header)
+	}
+}
+
+func TestEmailErrors(t *testing.T) {
+	srv := newSMTPServer(t)
+	srv.RequireAuth("refana", "s3cret")
+	n := email.New()
+	ctx := context.Background()
+
+	settings := settingsFor(srv)
+	settings[email.SettingUsername] = "refana"
+	settings[email.SettingPassword] = "wrong"
+	err := n.Notify(ctx, domain.ContactPoint{Settings: settings}, notification())
+	if err == nil || !notifier.IsPermanent(err) {
+		t.Fatalf("wrong password: err = %v, want a permanent error", err)
+	}
+
+	settings[email.SettingPassword] = "s3cret"
+	srv.Reject("dev@example.com")
+	err = n.Notify(ctx, domain.ContactPoint{Settings: settings}, notification())
+	if err == nil || !notifier.IsPermanent(err) {
+		t.Fatalf("rejected recipient: err = %v, want a permanent error", err)
+	}
+
+	settings[email.SettingTLS] = email.TLSStartTLS
+	err = n.Notify(ctx, domain.ContactPoint{Settings: settings}, notification())
+	if err == nil || !notifier.IsPermanent(err) {
+		t.Fatalf("STARTTLS not offered: err = %v, want a permanent error", err)
+	}
+	if len(srv.Messages()) != 0 {
+		t.Fatalf("messages = %d, want none", len(srv.Messages()))
+	}
+
+	// Nobody listening is worth retrying.
+	closed := newSMTPServer(t)
+	closed.Close()
+	err = n.Notify(ctx, domain.ContactPoint{Settings: settingsFor(closed)}, notification())
+	if err == nil || notifier.IsPermanent(err) {
+		t.Fatalf("closed server: err = %v, want a transient error", err)
+	}
+
+	for _, bad := range []map[domain.PropertyKey]domain.PropertyValue{
+		{email.SettingHost: "smtp.example.com", email.SettingFrom: "a@example.com", email.SettingTo: "not an address"},
+		{email.SettingHost: "smtp.example.com", email.SettingFrom: "a@example.com", email.SettingTo: "b@example.com", email.SettingPort: "smtp"},
+		{email.SettingHost: "smtp.example.com", email.SettingFrom: "a@example.com", email.SettingTo: "b@example.com", email.SettingTLS: "ssl"},
+		{email.SettingHost: "smtp.example.com", email.SettingFrom: "a@example.com", email.SettingTo: "b@example.com", email.SettingSubject: "{{"},
+	} {
+		if err := n.Validate(bad); err == nil {
+			t.Errorf("Validate(%v) accepted invalid settings", bad)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package emailtest provides an SMTP server for tests of code that sends
+// mail, in the spirit of net/http/httptest. It speaks just enough SMTP for
+// net/smtp clients: EHLO, AUTH PLAIN, MAIL, RCPT, DATA and QUIT, without
+// TLS.
+package emailtest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Message is a mail the server accepted.
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent after DATA, with CRLF line endings.
+	Data string
+}
+
+// Server is an SMTP server listening on a local port.
+type Server struct {
+	// Host and Port are where the server listens.
+	Host string
+	Port string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	username string
+	password string
+	reject   map[string]bool
+	messages []Message
+}
+
+// NewServer starts a server on a free local port that accepts mail from
+// anybody.
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	s := &Server{Host: host, Port: port, reject: make(map[string]bool), listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and waits for open sessions to end.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// RequireAuth makes the server offer AUTH PLAIN and accept mail only from
+// sessions that authenticated as username with password.
+func (s *Server) RequireAuth(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.username, s.password = username, password
+}
+
+// Reject makes the server refuse the recipient with a permanent error.
+func (s *Server) Reject(recipient string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject[recipient] = true
+}
+
+// Messages returns the mails accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.session(conn)
+		}()
+	}
+}
+
+func (s *Server) session(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	s.mu.Lock()
+	username, password := s.username, s.password
+	s.mu.Unlock()
+	reply("220 emailtest ready")
+	var msg Message
+	authed := username == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if username != "" {
+				reply("250-emailtest")
+				reply("250 AUTH PLAIN")
+			} else {
+				reply("250 emailtest")
+			}
+		case "AUTH":
+			mech, resp, _ := strings.Cut(arg, " ")
+			creds, _ := base64.StdEncoding.DecodeString(resp)
+			if strings.ToUpper(mech) == "PLAIN" && string(creds) == "\x00"+username+"\x00"+password {
+				authed = true
+				reply("235 authenticated")
+			} else {
+				reply("535 authentication failed")
+			}
+		case "MAIL":
+			if !authed {
+				reply("530 authentication required")
+				continue
+			}
+			msg = Message{From: address(arg)}
+			reply("250 ok")
+		case "RCPT":
+			to := address(arg)
+			s.mu.Lock()
+			rejected := s.reject[to]
+			s.mu.Unlock()
+			if rejected {
+				reply("550 no such user %s", to)
+				continue
+			}
+			msg.To = append(msg.To, to)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 accepted")
+		case "RSET":
+			msg = Message{}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// address extracts the address of "FROM:<a@b>" and "TO:<a@b>".
+func address(arg string) string {
+	_, a, _ := strings.Cut(arg, ":")
+	a, _, _ = strings.Cut(strings.TrimSpace(a), " ")
+	return strings.Trim(a, "<>")
+}
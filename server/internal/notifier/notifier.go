@@ -0,0 +1,160 @@
+// Package notifier delivers notifications to contact points. Each kind of
+// contact point, such as a webhook or email, is a Notifier compiled into
+// the server and described by a domain.ContactPointType; implementations
+// live in subpackages.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ErrUnknownType is returned for ContactPointTypeIDs nobody registered.
+var ErrUnknownType = errors.New("unknown contact point type")
+
+// Notifier is the build-time implementation behind a
+// domain.ContactPointType. It delivers a notification to a contact point
+// of its type once; retrying is up to the caller, see Backoff.
+type Notifier interface {
+	Descriptor() domain.ContactPointType
+	Notify(ctx context.Context, cp domain.ContactPoint, n domain.Notification) error
+}
+
+// Validator is implemented by notifiers that can reject malformed
+// settings, e.g. unparsable templates, before a contact point is saved.
+// Required settings are checked by Registry.Validate beforehand.
+type Validator interface {
+	Notifier
+	Validate(settings map[domain.PropertyKey]domain.PropertyValue) error
+}
+
+// Registry holds the notifiers compiled into the server, in registration
+// order.
+type Registry struct {
+	notifiers map[domain.ContactPointTypeID]Notifier
+	order     []domain.ContactPointTypeID
+}
+
+func NewRegistry(notifiers ...Notifier) *Registry {
+	r := &Registry{notifiers: make(map[domain.ContactPointTypeID]Notifier, len(notifiers))}
+	for _, n := range notifiers {
+		id := n.Descriptor().ID
+		if _, ok := r.notifiers[id]; !ok {
+			r.order = append(r.order, id)
+		}
+		r.notifiers[id] = n
+	}
+	return r
+}
+
+func (r *Registry) Get(id domain.ContactPointTypeID) (Notifier, error) {
+	n, ok := r.notifiers[id]
+	if !ok {
+		return nil, ErrUnknownType
+	}
+	return n, nil
+}
+
+// Descriptors lists every registered notifier for the contact-point-types
+// API.
+func (r *Registry) Descriptors() []domain.ContactPointType {
+	out := make([]domain.ContactPointType, 0, len(r.order))
+	for _, id := range r.order {
+		out = append(out, r.notifiers[id].Descriptor())
+	}
+	return out
+}
+
+// Validate checks the settings of a contact point of type id: every
+// required setting is present and the notifier, if it is a Validator,
+// accepts them.
+func (r *Registry) Validate(id domain.ContactPointTypeID, settings map[domain.PropertyKey]domain.PropertyValue) error {
+	n, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	for _, d := range n.Descriptor().PropertyDescriptors {
+		if d.IsRequired && settings[d.Key] == "" {
+			return fmt.Errorf("%s is required", d.Key)
+		}
+	}
+	if v, ok := n.(Validator); ok {
+		return v.Validate(settings)
+	}
+	return nil
+}
+
+// permanentError marks delivery errors that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a webhook answering
+// 404 or a mail server rejecting the recipient.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// RetryAfter is implemented by delivery errors that tell how long to wait
+// before the next attempt, such as a 429 with a Retry-After header.
+type RetryAfter interface {
+	RetryAfter() time.Duration
+}
+
+// Backoff retries deliveries up to Attempts times in total, waiting
+// Initial after the first failure and twice as long after each further
+// one, up to Max.
+type Backoff struct {
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+}
+
+// DefaultBackoff applies to notifications sent on behalf of alerts.
+var DefaultBackoff = Backoff{Attempts: 3, Initial: time.Second, Max: 30 * time.Second}
+
+// Do calls send until it succeeds, fails permanently, the attempts run out
+// or ctx is done, and returns the last error.
+func (b Backoff) Do(ctx context.Context, send func(ctx context.Context) error) error {
+	attempts := max(b.Attempts, 1)
+	wait := b.Initial
+	for i := 1; ; i++ {
+		err := send(ctx)
+		if err == nil || IsPermanent(err) || i == attempts {
+			return err
+		}
+		d := wait
+		var ra RetryAfter
+		if errors.As(err, &ra) && ra.RetryAfter() > d {
+			d = ra.RetryAfter()
+		}
+		if b.Max > 0 && d > b.Max {
+			d = b.Max
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (after attempt %d: %v)", ctx.Err(), i, err)
+		case <-timer.C:
+		}
+		wait *= 2
+		if b.Max > 0 && wait > b.Max {
+			wait = b.Max
+		}
+	}
+}
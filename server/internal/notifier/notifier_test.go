@@ -0,0 +1,125 @@
+package notifier_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type retryAfterError struct{ d time.Duration }
+
+func (e retryAfterError) Error() string             { return "slow down" }
+func (e retryAfterError) RetryAfter() time.Duration { return e.d }
+
+func TestBackoffRetries(t *testing.T) {
+	b := notifier.Backoff{Attempts: 4, Initial: time.Millisecond, Max: 2 * time.Millisecond}
+	ctx := context.Background()
+
+	calls := 0
+	err := b.Do(ctx, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("err = %v after %d calls, want success on the third", err, calls)
+	}
+
+	calls = 0
+	err = b.Do(ctx, func(context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	if err == nil || calls != 4 {
+		t.Fatalf("err = %v after %d calls, want the last error after 4", err, calls)
+	}
+
+	calls = 0
+	rejected := errors.New("rejected")
+	err = b.Do(ctx, func(context.Context) error {
+		calls++
+		return notifier.Permanent(rejected)
+	})
+	if !errors.Is(err, rejected) || !notifier.IsPermanent(err) || calls != 1 {
+		t.Fatalf("err = %v after %d calls, want the permanent error at once", err, calls)
+	}
+
+	// Retry-After is honoured up to Max.
+	calls = 0
+	start := time.Now()
+	err = b.Do(ctx, func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return retryAfterError{d: time.Hour}
+		}
+		return nil
+	})
+	if err != nil || time.Since(start) > time.Second {
+		t.Fatalf("err = %v after %v, want Retry-After capped by Max", err, time.Since(start))
+	}
+}
+
+func TestBackoffStopsWithContext(t *testing.T) {
+	b := notifier.Backoff{Attempts: 5, Initial: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := b.Do(ctx, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("unavailable")
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("err = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+type fakeNotifier struct{}
+
+func (fakeNotifier) Descriptor() domain.ContactPointType {
+	return domain.ContactPointType{
+		ID:   "fake",
+		Name: "Fake",
+		PropertyDescriptors: []domain.PropertyDescriptor{
+			{Key: "target", Name: "Target", Type: domain.PropertyTypeString, IsRequired: true},
+		},
+	}
+}
+
+func (fakeNotifier) Notify(context.Context, domain.ContactPoint, domain.Notification) error {
+	return nil
+}
+
+func (fakeNotifier) Validate(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	if settings["target"] == "nowhere" {
+		return errors.New("target: nowhere is not a target")
+	}
+	return nil
+}
+
+func TestRegistryValidate(t *testing.T) {
+	r := notifier.NewRegistry(fakeNotifier{})
+	if got := r.Descriptors(); len(got) != 1 || got[0].ID != "fake" {
+		t.Fatalf("descriptors = %+v", got)
+	}
+	if _, err := r.Get("pager"); !errors.Is(err, notifier.ErrUnknownType) {
+		t.Fatalf("Get(pager) error = %v, want ErrUnknownType", err)
+	}
+	if err := r.Validate("pager", nil); !errors.Is(err, notifier.ErrUnknownType) {
+		t.Fatalf("Validate(pager) error = %v, want ErrUnknownType", err)
+	}
+	if err := r.Validate("fake", nil); err == nil {
+		t.Fatal("missing required setting was accepted")
+	}
+	if err := r.Validate("fake", map[domain.PropertyKey]domain.PropertyValue{"target": "nowhere"}); err == nil {
+		t.Fatal("settings rejected by the notifier were accepted")
+	}
+	if err := r.Validate("fake", map[domain.PropertyKey]domain.PropertyValue{"target": "ops"}); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
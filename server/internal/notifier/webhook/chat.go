@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// Settings of the Slack notifier. Both override what the incoming webhook
+// was configured with, where the receiving server allows it.
+const (
+	SettingChannel  domain.PropertyKey = "channel"
+	SettingUsername domain.PropertyKey = "username"
+)
+
+// Slack posts notifications to Slack-compatible incoming webhooks.
+type Slack struct {
+	client *http.Client
+}
+
+func NewSlack() *Slack {
+	return &Slack{client: &http.Client{Timeout: requestTimeout}}
+}
+
+func (*Slack) Descriptor() domain.ContactPointType {
+	return domain.ContactPointType{
+		ID:   "slack",
+		Name: "Slack",
+		PropertyDescriptors: []domain.PropertyDescriptor{
+			{Key: SettingURL, Name: "Webhook URL", Type: domain.PropertyTypeString, Category: "Webhook", Order: 0, IsRequired: true, IsSecret: true},
+			{Key: SettingChannel, Name: "Channel", Type: domain.PropertyTypeString, Category: "Message", Order: 1},
+			{Key: SettingUsername, Name: "Username", Type: domain.PropertyTypeString, Category: "Message", Order: 2},
+		},
+	}
+}
+
+func (*Slack) Validate(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	return validateURL(settings)
+}
+
+type slackPayload struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (s *Slack) Notify(ctx context.Context, cp domain.ContactPoint, n domain.Notification) error {
+	text := "*" + n.Title + "*"
+	if n.Message != "" {
+		text += "\n" + n.Message
+	}
+	body, err := json.Marshal(slackPayload{
+		Text:     text,
+		Channel:  string(cp.Settings[SettingChannel]),
+		Username: string(cp.Settings[SettingUsername]),
+	})
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	return send(ctx, s.client, http.MethodPost, string(cp.Settings[SettingURL]), jsonHeader(), body)
+}
+
+// Teams posts notifications as message cards to Microsoft Teams incoming
+// webhooks.
+type Teams struct {
+	client *http.Client
+}
+
+func NewTeams() *Teams {
+	return &Teams{client: &http.Client{Timeout: requestTimeout}}
+}
+
+func (*Teams) Descriptor() domain.ContactPointType {
+	return domain.ContactPointType{
+		ID:   "teams",
+		Name: "Microsoft Teams",
+		PropertyDescriptors: []domain.PropertyDescriptor{
+			{Key: SettingURL, Name: "Webhook URL", Type: domain.PropertyTypeString, Category: "Webhook", Order: 0, IsRequired: true, IsSecret: true},
+		},
+	}
+}
+
+func (*Teams) Validate(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	return validateURL(settings)
+}
+
+type messageCard struct {
+	Type       string `json:"@type"`
+	Context    string `json:"@context"`
+	Summary    string `json:"summary"`
+	Title      string `json:"title"`
+	Text       string `json:"text"`
+	ThemeColor string `json:"themeColor"`
+}
+
+// Card colours by notification status.
+const (
+	colorFiring   = "D32F2F"
+	colorResolved = "2E7D32"
+	colorOther    = "757575"
+)
+
+func (t *Teams) Notify(ctx context.Context, cp domain.ContactPoint, n domain.Notification) error {
+	color := colorOther
+	switch n.Status {
+	case domain.AlertFiring:
+		color = colorFiring
+	case domain.AlertResolved:
+		color = colorResolved
+	}
+	body, err := json.Marshal(messageCard{
+		Type:    "MessageCard",
+		Context: "https://schema.org/extensions",
+		Summary: n.Title,
+		Title:   n.Title,
+		// Teams renders text as Markdown, where single newlines collapse.
+		Text:       strings.ReplaceAll(n.Message, "\n", "  \n"),
+		ThemeColor: color,
+	})
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	return send(ctx, t.client, http.MethodPost, string(cp.Settings[SettingURL]), jsonHeader(), body)
+}
+
+func jsonHeader() http.Header {
+	return http.Header{"Content-Type": {"application/json"}}
+}
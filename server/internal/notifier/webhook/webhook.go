@@ -0,0 +1,214 @@
+// Package webhook implements notifiers that deliver over HTTP: a generic
+// webhook whose payload is a template, and the incoming webhooks of Slack
+// and Microsoft Teams. Slack's payload is also understood by Mattermost
+// and Rocket.Chat.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// Settings shared by every notifier of the package.
+const (
+	SettingURL domain.PropertyKey = "url"
+)
+
+// Settings of the generic webhook.
+const (
+	SettingMethod      domain.PropertyKey = "method"
+	SettingContentType domain.PropertyKey = "contentType"
+	// SettingHeaders holds a JSON object of extra request headers, e.g.
+	// {"Authorization": "Bearer ..."}.
+	SettingHeaders domain.PropertyKey = "headers"
+	// SettingTemplate is a text/template of the request body executed with
+	// the domain.Notification. Its json function renders a value as JSON,
+	// e.g. {"summary": {{json .Title}}}. Unset, the body is the
+	// notification as JSON.
+	SettingTemplate domain.PropertyKey = "template"
+)
+
+// requestTimeout bounds each delivery attempt.
+const requestTimeout = 30 * time.Second
+
+// maxErrorBody is how much of an error response is kept in the error.
+const maxErrorBody = 512
+
+// StatusError is a non-2xx answer of the receiving server.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("webhook answered %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// RetryAfter is the delay the server asked for with Retry-After, if any.
+func (e *StatusError) RetryAfter() time.Duration { return e.retryAfter }
+
+// Webhook is the generic webhook notifier.
+type Webhook struct {
+	client *http.Client
+}
+
+func New() *Webhook {
+	return &Webhook{client: &http.Client{Timeout: requestTimeout}}
+}
+
+func (*Webhook) Descriptor() domain.ContactPointType {
+	return domain.ContactPointType{
+		ID:   "webhook",
+		Name: "Webhook",
+		PropertyDescriptors: []domain.PropertyDescriptor{
+			{Key: SettingURL, Name: "URL", Type: domain.PropertyTypeString, Category: "Request", Order: 0, IsRequired: true},
+			{
+				Key: SettingMethod, Name: "Method", Type: domain.PropertyTypeString, Category: "Request", Order: 1,
+				Candidates: []domain.PropertyValue{http.MethodPost, http.MethodPut, http.MethodPatch},
+			},
+			{Key: SettingContentType, Name: "Content Type", Type: domain.PropertyTypeString, Category: "Request", Order: 2},
+			{Key: SettingHeaders, Name: "Headers", Type: domain.PropertyTypeJSON, Category: "Request", Order: 3, IsSecret: true},
+			{Key: SettingTemplate, Name: "Payload Template", Type: domain.PropertyTypeString, Category: "Payload", Order: 4},
+		},
+	}
+}
+
+func (*Webhook) Validate(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	if err := validateURL(settings); err != nil {
+		return err
+	}
+	switch m := strings.ToUpper(string(settings[SettingMethod])); m {
+	case "", http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return fmt.Errorf("%s: unsupported method %s", SettingMethod, m)
+	}
+	if _, err := headers(settings); err != nil {
+		return err
+	}
+	_, err := payloadTemplate(settings)
+	return err
+}
+
+func (w *Webhook) Notify(ctx context.Context, cp domain.ContactPoint, n domain.Notification) error {
+	tmpl, err := payloadTemplate(cp.Settings)
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	var body []byte
+	if tmpl == nil {
+		if body, err = json.Marshal(n); err != nil {
+			return notifier.Permanent(err)
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, n); err != nil {
+			return notifier.Permanent(fmt.Errorf("%s: %w", SettingTemplate, err))
+		}
+		body = buf.Bytes()
+	}
+	h, err := headers(cp.Settings)
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	method := strings.ToUpper(string(cp.Settings[SettingMethod]))
+	if method == "" {
+		method = http.MethodPost
+	}
+	contentType := string(cp.Settings[SettingContentType])
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	h.Set("Content-Type", contentType)
+	return send(ctx, w.client, method, string(cp.Settings[SettingURL]), h, body)
+}
+
+func payloadTemplate(settings map[domain.PropertyKey]domain.PropertyValue) (*template.Template, error) {
+	src := settings[SettingTemplate]
+	if src == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("payload").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"json": toJSON}).
+		Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", SettingTemplate, err)
+	}
+	return tmpl, nil
+}
+
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func headers(settings map[domain.PropertyKey]domain.PropertyValue) (http.Header, error) {
+	h := make(http.Header)
+	raw := settings[SettingHeaders]
+	if raw == "" {
+		return h, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, fmt.Errorf("%s: must be a JSON object of strings: %v", SettingHeaders, err)
+	}
+	for k, v := range m {
+		h.Set(k, v)
+	}
+	return h, nil
+}
+
+func validateURL(settings map[domain.PropertyKey]domain.PropertyValue) error {
+	u, err := url.Parse(string(settings[SettingURL]))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s: must be an http or https URL", SettingURL)
+	}
+	return nil
+}
+
+// send makes one delivery attempt. Answers in the 4xx range other than
+// 408 and 429 are permanent errors.
+func send(ctx context.Context, client *http.Client, method, target string, h http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return notifier.Permanent(err)
+	}
+	req.Header = h
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	serr := &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		serr.retryAfter = time.Duration(secs) * time.Second
+	}
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return serr
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return notifier.Permanent(serr)
+	}
+	return serr
+}
@@ -0,0 +1,199 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/webhook"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type received struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// newReceiver answers each request with the next of statuses, then 200,
+// and reports what it received on the returned channel.
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan received) {
+	t.Helper()
+	ch := make(chan received, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{method: r.Method, header: r.Header, body: string(body)}
+		if len(statuses) > 0 {
+			status := statuses[0]
+			statuses = statuses[1:]
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("try later"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch
+}
+
+func notification() domain.Notification {
+	return domain.Notification{
+		Title:   "High CPU",
+		Message: "cpu > 90 on web-1\ncpu > 90 on web-2",
+		Status:  domain.AlertFiring,
+		Alerts: []domain.NotifiedAlert{{
+			RuleID: domain.NewAlertRuleID(1),
+			Name:   "High CPU",
+			State:  domain.AlertFiring,
+			Labels: map[string]string{"team": "ops"},
+			Since:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}},
+	}
+}
+
+func contactPoint(settings map[domain.PropertyKey]domain.PropertyValue) domain.ContactPoint {
+	return domain.ContactPoint{Name: "ops", Settings: settings}
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	srv, ch := newReceiver(t)
+	w := webhook.New()
+	cp := contactPoint(map[domain.PropertyKey]domain.PropertyValue{
+		webhook.SettingURL:     domain.PropertyValue(srv.URL),
+		webhook.SettingHeaders: `{"Authorization": "Bearer token"}`,
+	})
+	if err := w.Validate(cp.Settings); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := w.Notify(context.Background(), cp, notification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	got := <-ch
+	if got.method != http.MethodPost || got.header.Get("Content-Type") != "application/json" ||
+		got.header.Get("Authorization") != "Bearer token" {
+		t.Fatalf("request = %s %v", got.method, got.header)
+	}
+	var n domain.Notification
+	if err := json.Unmarshal([]byte(got.body), &n); err != nil {
+		t.Fatalf("body %s: %v", got.body, err)
+	}
+	if n.Title != "High CPU" || len(n.Alerts) != 1 || n.Alerts[0].Labels["team"] != "ops" {
+		t.Fatalf("notification = %+v", n)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	srv, ch := newReceiver(t)
+	w := webhook.New()
+	cp := contactPoint(map[domain.PropertyKey]domain.PropertyValue{
+		webhook.SettingURL:         domain.PropertyValue(srv.URL),
+		webhook.SettingMethod:      "put",
+		webhook.SettingContentType: "application/vnd.ops+json",
+		webhook.SettingTemplate: `{"summary": {{json .Title}}, "state": "{{.Status}}", ` +
+			`"teams": [{{range $i, $a := .Alerts}}{{if $i}},{{end}}{{json (index $a.Labels "team")}}{{end}}]}`,
+	})
+	if err := w.Notify(context.Background(), cp, notification()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	got := <-ch
+	if got.method != http.MethodPut || got.header.Get("Content-Type") != "application/vnd.ops+json" {
+		t.Fatalf("request = %s %v", got.method, got.header)
+	}
+	if want := `{"summary": "High CPU", "state": "firing", "teams": ["ops"]}`; got.body != want {
+		t.Fatalf("body = %s, want %s", got.body, want)
+	}
+
+	for _, settings := range []map[domain.PropertyKey]domain.PropertyValue{
+		{webhook.SettingURL: "ftp://example.com"},
+		{webhook.SettingURL: "http://example.com", webhook.SettingMethod: "DELETE"},
+		{webhook.SettingURL: "http://example.com", webhook.SettingHeaders: `["x"]`},
+		{webhook.SettingURL: "http://example.com", webhook.SettingTemplate: `{{.Title`},
+	} {
+		if err := w.Validate(settings); err == nil {
+			t.Errorf("Validate(%v) accepted invalid settings", settings)
+		}
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	w := webhook.New()
+	ctx := context.Background()
+	cases := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusServiceUnavailable, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusNotFound, true},
+		{http.StatusBadRequest, true},
+	}
+	for _, tc := range cases {
+		srv, _ := newReceiver(t, tc.status)
+		err := w.Notify(ctx, contactPoint(map[domain.PropertyKey]domain.PropertyValue{
+			webhook.SettingURL: domain.PropertyValue(srv.URL),
+		}), notification())
+		var serr *webhook.StatusError
+		if !errors.As(err, &serr) || serr.StatusCode != tc.status || serr.Body != "try later" {
+			t.Fatalf("status %d: err = %v", tc.status, err)
+		}
+		if notifier.IsPermanent(err) != tc.permanent {
+			t.Fatalf("status %d: permanent = %v, want %v", tc.status, !tc.permanent, tc.permanent)
+		}
+	}
+
+	// Transient answers are retried until the webhook accepts.
+	srv, ch := newReceiver(t, http.StatusBadGateway, http.StatusServiceUnavailable)
+	b := notifier.Backoff{Attempts: 3, Initial: time.Millisecond}
+	cp := contactPoint(map[domain.PropertyKey]domain.PropertyValue{webhook.SettingURL: domain.PropertyValue(srv.URL)})
+	err := b.Do(ctx, func(ctx context.Context) error { return w.Notify(ctx, cp, notification()) })
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if len(ch) != 3 {
+		t.Fatalf("received %d requests, want 3", len(ch))
+	}
+}
+
+func TestSlackAndTeams(t *testing.T) {
+	srv, ch := newReceiver(t)
+	ctx := context.Background()
+
+	slack := webhook.NewSlack()
+	err := slack.Notify(ctx, contactPoint(map[domain.PropertyKey]domain.PropertyValue{
+		webhook.SettingURL:     domain.PropertyValue(srv.URL),
+		webhook.SettingChannel: "#ops",
+	}), notification())
+	if err != nil {
+		t.Fatalf("Slack: %v", err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte((<-ch).body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["channel"] != "#ops" || !strings.HasPrefix(payload["text"], "*High CPU*\ncpu > 90 on web-1") {
+		t.Fatalf("slack payload = %v", payload)
+	}
+	if _, ok := payload["username"]; ok {
+		t.Fatalf("slack payload = %v, want no username", payload)
+	}
+
+	teams := webhook.NewTeams()
+	err = teams.Notify(ctx, contactPoint(map[domain.PropertyKey]domain.PropertyValue{
+		webhook.SettingURL: domain.PropertyValue(srv.URL),
+	}), notification())
+	if err != nil {
+		t.Fatalf("Teams: %v", err)
+	}
+	if err := json.Unmarshal([]byte((<-ch).body), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["@type"] != "MessageCard" || payload["title"] != "High CPU" ||
+		payload["themeColor"] != "D32F2F" || payload["text"] != "cpu > 90 on web-1  \ncpu > 90 on web-2" {
+		t.Fatalf("teams payload = %v", payload)
+	}
+}
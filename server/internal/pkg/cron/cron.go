@@ -0,0 +1,182 @@
+// Package cron parses the five-field schedules of cron(8), such as
+// "0 8 * * MON" for eight o'clock every Monday, and finds the times they
+// fire at.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid cron expression")
+
+// Schedule is a parsed expression. Its fields are bit sets of the values
+// they allow.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record an unrestricted day of month or of week:
+	// when both are restricted, either one matching is enough, as in cron.
+	domStar, dowStar bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: []string{
+		"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC",
+	}}
+	// Sunday is both 0 and 7.
+	dowField = field{name: "day of week", min: 0, max: 7, names: []string{
+		"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT",
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses minute, hour, day of month, month and day of week separated
+// by spaces, or one of @yearly, @monthly, @weekly, @daily and @hourly.
+// Each field is * or a list of values and ranges (1-5), optionally with a
+// step (*/15, 8-18/2); months and days of week may be given by their
+// first three letters.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return Schedule{}, fmt.Errorf("%w: %q has %d fields, want 5", ErrInvalid, expr, len(parts))
+	}
+	var s Schedule
+	for i, f := range []struct {
+		field
+		dst *uint64
+	}{{minuteField, &s.minute}, {hourField, &s.hour}, {domField, &s.dom}, {monthField, &s.month}, {dowField, &s.dow}} {
+		bits, err := f.parse(parts[i])
+		if err != nil {
+			return Schedule{}, err
+		}
+		*f.dst = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar, s.dowStar = strings.HasPrefix(parts[2], "*"), strings.HasPrefix(parts[4], "*")
+	return s, nil
+}
+
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: bad step %q in %s", ErrInvalid, stepText, f.name)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(first); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// 5/15 runs from 5 through the end of the field.
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("%w: range %s of %s ends before it starts", ErrInvalid, rng, f.name)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: %s %q is not within %d-%d", ErrInvalid, f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// maxDays bounds the search of Next: every valid day of month occurs
+// within the leap year cycle.
+const maxDays = 366 * 5
+
+// Next returns the first time after t, to the minute, that s fires at,
+// read in the location of t. It returns the zero time when s never fires,
+// e.g. on February 30. Times skipped by a daylight saving change run at
+// the same clock time after it.
+func (s Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	y, mo, d := t.Date()
+	for i := 0; i < maxDays; i++ {
+		day := time.Date(y, mo, d+i, 0, 0, 0, 0, loc)
+		if !s.matchDay(day) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if s.minute&(1<<m) == 0 {
+					continue
+				}
+				next := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc)
+				if next.After(t) {
+					return next
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) matchDay(day time.Time) bool {
+	if s.month&(1<<int(day.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<day.Day()) != 0
+	dow := s.dow&(1<<int(day.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
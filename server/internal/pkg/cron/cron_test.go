@@ -0,0 +1,75 @@
+package cron_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/cron"
+)
+
+func TestNext(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2024, 3, 13, 9, 30, 15, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 13, 9, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 13, 9, 45, 0, 0, time.UTC)},
+		{"0 8 * * MON", time.Date(2024, 3, 18, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2024, 3, 14, 8, 0, 0, 0, time.UTC)},
+		{"30 9 * * 3", time.Date(2024, 3, 20, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 3, 13, 13, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2024, 3, 13, 10, 5, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		// Restricted days of month and of week fire on either.
+		{"0 0 1,15 * FRI", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * sat", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		s, err := cron.Parse(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if got := s.Next(now); !got.Equal(tc.want) {
+			t.Errorf("%s: Next = %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+
+	never, _ := cron.Parse("0 0 30 2 *")
+	if got := never.Next(now); !got.IsZero() {
+		t.Errorf("February 30: Next = %s, want zero", got)
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	s, _ := cron.Parse("0 8 * * *")
+	got := s.Next(time.Date(2024, 3, 13, 9, 30, 0, 0, time.UTC).In(berlin))
+	if want := time.Date(2024, 3, 14, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Next = %s, want %s", got, want)
+	}
+	// 02:30 does not exist on the night clocks go forward.
+	s, _ = cron.Parse("30 2 * * *")
+	got = s.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	if want := time.Date(2024, 3, 31, 3, 30, 0, 0, berlin); !got.Equal(want) {
+		t.Fatalf("Next over the gap = %s, want %s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * JUNE *", "@often"} {
+		if _, err := cron.Parse(expr); !errors.Is(err, cron.ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", expr, err)
+		}
+	}
+}
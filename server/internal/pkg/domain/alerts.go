@@ -0,0 +1,83 @@
+package domain
+
+import "time"
+
+type AlertState string
+
+const (
+	// AlertNormal is the state of rules whose condition does not hold.
+	AlertNormal AlertState = "normal"
+	// AlertPending is the state of rules whose condition holds, but for
+	// less than their For duration.
+	AlertPending AlertState = "pending"
+	// AlertFiring is the state of rules whose condition has held for their
+	// For duration.
+	AlertFiring AlertState = "firing"
+	// AlertResolved is the state of firing rules whose condition stopped
+	// holding, until the next evaluation brings them back to normal or
+	// pending.
+	AlertResolved AlertState = "resolved"
+)
+
+// AlertRule evaluates Condition over the result of a component, or of a
+// standalone Query, every Interval. Condition is an expression (see
+// package expression) that yields a boolean and sees the columns of the
+// result by name, each as the list of its values, and rows, the list of
+// rows keyed by column name: max(cpu) > 90 or any(rows, .status == "down").
+type AlertRule struct {
+	ID          AlertRuleID  `json:"id"`
+	Name        Name         `json:"name"`
+	ComponentID *ComponentID `json:"componentId,omitempty"`
+	Query       *Query       `json:"query,omitempty"`
+	// From and To, when set, are the time range of each evaluation,
+	// relative to it, as in now-5m and now.
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Condition string   `json:"condition"`
+	Interval  Duration `json:"interval"`
+	// For is how long the condition must hold before the rule fires.
+	For Duration `json:"for"`
+	// Labels identify the alert to notification policies; Annotations
+	// describe it to people, e.g. a summary.
+	Labels      map[Name]PropertyValue `json:"labels,omitempty"`
+	Annotations map[Name]PropertyValue `json:"annotations,omitempty"`
+	Paused      bool                   `json:"paused,omitempty"`
+	Status      AlertStatus            `json:"status"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+}
+
+// AlertStatus is where the evaluations of a rule have brought it. Since is
+// when it entered State, and Error the failure of the last evaluation, if
+// any, which leaves State as it was.
+type AlertStatus struct {
+	State       AlertState `json:"state"`
+	Since       time.Time  `json:"since"`
+	EvaluatedAt *time.Time `json:"evaluatedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type CreateAlertRuleOptions struct {
+	Name        Name                   `json:"name" validate:"required,max=256"`
+	ComponentID *ComponentID           `json:"componentId"`
+	Query       *Query                 `json:"query"`
+	From        string                 `json:"from" validate:"max=64"`
+	To          string                 `json:"to" validate:"max=64"`
+	Condition   string                 `json:"condition" validate:"required"`
+	Interval    Duration               `json:"interval"`
+	For         Duration               `json:"for"`
+	Labels      map[Name]PropertyValue `json:"labels"`
+	Annotations map[Name]PropertyValue `json:"annotations"`
+	Paused      bool                   `json:"paused"`
+}
+
+type UpdateAlertRuleOptions CreateAlertRuleOptions
+
+// AlertEvent records a change of state of a rule, or an evaluation that
+// failed with a new Error.
+type AlertEvent struct {
+	RuleID AlertRuleID `json:"ruleId"`
+	From   AlertState  `json:"from"`
+	To     AlertState  `json:"to"`
+	Error  string      `json:"error,omitempty"`
+	At     time.Time   `json:"at"`
+}
@@ -0,0 +1,137 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestColumnDataTypedJSONRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 9, 30, 0, 0, time.FixedZone("KST", 9*60*60))
+
+	ints := domain.NewColumnData("n", domain.PropertyTypeInteger)
+	mustAppend(t, &ints, int64(1<<60))
+	mustAppend(t, &ints, nil)
+	times := domain.NewColumnData("at", domain.PropertyTypeTime)
+	mustAppend(t, &times, ts)
+	mustAppend(t, &times, "2024-05-02T00:00:00Z")
+	docs := domain.NewColumnData("doc", domain.PropertyTypeJSON)
+	mustAppend(t, &docs, []byte(`{"a":1}`))
+	mustAppend(t, &docs, nil)
+	flags := domain.NewColumnData("ok", domain.PropertyTypeBoolean)
+	mustAppend(t, &flags, "t")
+	mustAppend(t, &flags, int64(0))
+
+	table := domain.TableData{Columns: []domain.ColumnData{ints, times, docs, flags}}
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var back domain.TableData
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if back.NumRows() != 2 {
+		t.Fatalf("rows = %d, want 2", back.NumRows())
+	}
+	if got := back.Columns[0].Value(0); got != int64(1<<60) {
+		t.Fatalf("int value = %v, want %d", got, int64(1<<60))
+	}
+	if !back.Columns[0].IsNull(1) || back.Columns[0].Value(1) != nil {
+		t.Fatalf("int row 1 should be null")
+	}
+	if got := back.Columns[1].Times[0]; !got.Equal(ts) {
+		t.Fatalf("time = %v, want %v", got, ts)
+	}
+	if _, offset := back.Columns[1].Times[0].Zone(); offset != 9*60*60 {
+		t.Fatalf("time zone offset = %d, want %d", offset, 9*60*60)
+	}
+	if got := string(back.Columns[2].JSON[0]); got != `{"a":1}` {
+		t.Fatalf("json = %s, want {\"a\":1}", got)
+	}
+	if back.Columns[3].Bools[0] != true || back.Columns[3].Bools[1] != false {
+		t.Fatalf("bools = %v, want [true false]", back.Columns[3].Bools)
+	}
+}
+
+func TestColumnDataLegacyWireFormat(t *testing.T) {
+	col := domain.NewColumnData("price", domain.PropertyTypeNumber)
+	mustAppend(t, &col, 1.5)
+	mustAppend(t, &col, nil)
+
+	data, err := json.Marshal(col)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var legacy struct {
+		Name   string   `json:"name"`
+		Type   string   `json:"type"`
+		Values []string `json:"values"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		t.Fatalf("legacy decode: %v", err)
+	}
+	if legacy.Type != "number" || len(legacy.Values) != 2 || legacy.Values[0] != "1.5" || legacy.Values[1] != "" {
+		t.Fatalf("legacy view = %+v, want number [1.5 \"\"]", legacy)
+	}
+
+	// payloads from older writers only carry strings
+	var parsed domain.ColumnData
+	if err := json.Unmarshal([]byte(`{"name":"n","type":"number","values":["2","3.25"]}`), &parsed); err != nil {
+		t.Fatalf("decode legacy payload: %v", err)
+	}
+	if len(parsed.Floats) != 2 || parsed.Floats[1] != 3.25 {
+		t.Fatalf("legacy floats = %v, want [2 3.25]", parsed.Floats)
+	}
+
+	var str domain.ColumnData
+	if err := json.Unmarshal([]byte(`{"name":"s","type":"string","values":["a","b"]}`), &str); err != nil {
+		t.Fatalf("decode string payload: %v", err)
+	}
+	if str.Len() != 2 || str.Values[1] != "b" {
+		t.Fatalf("string values = %v, want [a b]", str.Values)
+	}
+}
+
+func mustAppend(t *testing.T, c *domain.ColumnData, v any) {
+	t.Helper()
+	if err := c.Append(v); err != nil {
+		t.Fatalf("Append(%v): %v", v, err)
+	}
+}
+
+func TestApplyColumnMeta(t *testing.T) {
+	two := uint8(2)
+	table := domain.TableData{Columns: []domain.ColumnData{
+		{Name: "total", Type: domain.PropertyTypeNumber, Meta: &domain.ColumnMeta{Path: "public.orders.total", Description: "gross"}},
+		{Name: "note", Type: domain.PropertyTypeString},
+	}}
+
+	table.ApplyColumnMeta(map[domain.Name]domain.ColumnMeta{
+		"total":   {Unit: "currencyUSD", Decimals: &two, Description: "net"},
+		"missing": {Unit: "ms"},
+	})
+
+	got := table.Columns[0].Meta
+	if got.Unit != "currencyUSD" || *got.Decimals != 2 || got.Description != "net" || got.Path != "public.orders.total" {
+		t.Fatalf("merged meta = %+v", got)
+	}
+	if table.Columns[1].Meta != nil {
+		t.Fatalf("untouched column gained meta %+v", table.Columns[1].Meta)
+	}
+
+	data, err := json.Marshal(table.Columns[0])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back domain.ColumnData
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if back.Meta == nil || back.Meta.Unit != "currencyUSD" {
+		t.Fatalf("meta lost in round trip: %s", data)
+	}
+}
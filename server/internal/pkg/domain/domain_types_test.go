@@ -1,8 +1,11 @@
 package domain_test
 
 import (
+	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/smilu97/refana/internal/pkg/domain"
 )
@@ -82,3 +85,96 @@ func TestPropertyDescriptorShape(t *testing.T) {
 		t.Fatalf("PropertyDescriptor.Candidates type = %s, want []string", f.Type.Kind().String())
 	}
 }
+
+func TestGeneratedIDJSONRoundTrip(t *testing.T) {
+	id := domain.NewDataSourceID(1234567890123)
+	data, err := json.Marshal(id)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `"`+id.String()+`"` {
+		t.Fatalf("marshal = %s, want quoted %s", data, id.String())
+	}
+
+	var back domain.DataSourceID
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if back.Int64() != id.Int64() {
+		t.Fatalf("round trip = %d, want %d", back.Int64(), id.Int64())
+	}
+
+	if _, err := domain.ParseComponentID("not-a-tsid"); err == nil {
+		t.Fatalf("ParseComponentID accepted an invalid id")
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	data, err := json.Marshal(domain.Duration(90 * time.Second))
+	if err != nil || string(data) != `"1m30s"` {
+		t.Fatalf("marshal = %s, %v, want \"1m30s\"", data, err)
+	}
+	var d domain.Duration
+	if err := json.Unmarshal([]byte(`"5m"`), &d); err != nil || time.Duration(d) != 5*time.Minute {
+		t.Fatalf("unmarshal = %v, %v", time.Duration(d), err)
+	}
+	for _, bad := range []string{`"soon"`, `300`} {
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("unmarshal %s: no error", bad)
+		}
+	}
+}
+
+func TestValidateNameAndAliasLength(t *testing.T) {
+	ok := domain.CreateDataSourceOptions{ClassID: "postgres", Name: "n", Alias: "a"}
+	if err := domain.Validate(ok); err != nil {
+		t.Fatalf("Validate valid options: %v", err)
+	}
+
+	longName := ok
+	longName.Name = domain.Name(strings.Repeat("x", 257))
+	if err := domain.Validate(longName); err == nil {
+		t.Fatalf("Validate accepted a 257 character name")
+	}
+
+	longAlias := ok
+	longAlias.Alias = domain.Alias(strings.Repeat("x", 65))
+	if err := domain.Validate(longAlias); err == nil {
+		t.Fatalf("Validate accepted a 65 character alias")
+	}
+
+	query := domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries:         []domain.Query{{Name: domain.Name(strings.Repeat("q", 257))}},
+	}
+	if err := domain.Validate(query); err == nil {
+		t.Fatalf("Validate accepted a 257 character query name")
+	}
+}
+
+func TestRedactAndKeepSecrets(t *testing.T) {
+	descs := []domain.PropertyDescriptor{
+		{Key: "host"},
+		{Key: "password", IsSecret: true},
+		{Key: "token", IsSecret: true},
+	}
+	stored := map[domain.PropertyKey]domain.PropertyValue{"host": "db", "password": "hunter2", "token": ""}
+
+	redacted := domain.RedactSecrets(stored, descs)
+	if redacted["password"] != domain.SecretPlaceholder || redacted["host"] != "db" || redacted["token"] != "" {
+		t.Fatalf("RedactSecrets = %v", redacted)
+	}
+	if stored["password"] != "hunter2" {
+		t.Fatalf("RedactSecrets changed its argument")
+	}
+
+	kept := domain.KeepSecrets(map[domain.PropertyKey]domain.PropertyValue{
+		"host":     domain.SecretPlaceholder,
+		"password": domain.SecretPlaceholder,
+		"token":    "new",
+	}, stored, descs)
+	if kept["password"] != "hunter2" || kept["token"] != "new" || kept["host"] != domain.SecretPlaceholder {
+		t.Fatalf("KeepSecrets = %v", kept)
+	}
+}
@@ -0,0 +1,29 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestReplaceVariablesFormats(t *testing.T) {
+	values := map[domain.Name]domain.VariableValue{
+		"host": {Values: []domain.PropertyValue{"a.example", "b"}},
+		"all":  {Values: []domain.PropertyValue{".*"}, Raw: true},
+	}
+	text := `up{host=~"${host:regex}"} $host ${host:pipe} ${all:regex} $unknown`
+	got := domain.ReplaceVariables(text, func(ref domain.VariableRef) (string, bool) {
+		v, ok := values[ref.Name]
+		if !ok {
+			return "", false
+		}
+		return domain.FormatVariable(v, ref.Format), true
+	})
+	want := `up{host=~"(a\.example|b)"} a.example,b a.example|b .* $unknown`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if refs := domain.VariableRefs("${x:bogus}"); len(refs) != 1 || refs[0].Format.Valid() {
+		t.Fatalf("refs = %+v, want one with an invalid format", refs)
+	}
+}
@@ -1,53 +1,129 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Query describes how to fetch data for a visualisation.
+// DataSourceAlias, when set, takes precedence over DataSourceID so that
+// exported components keep pointing at the same data source across
+// environments where generated IDs differ.
 type Query struct {
-	Name         Name                           `json:"name"`
-	DataSourceID DataSourceID                   `json:"dataSourceId"`
-	Properties   map[PropertyKey]PropertyValue  `json:"properties"`
+	Name            Name                          `json:"name" validate:"max=256"`
+	DataSourceID    DataSourceID                  `json:"dataSourceId"`
+	DataSourceAlias Alias                         `json:"dataSourceAlias,omitempty" validate:"max=64"`
+	Properties      map[PropertyKey]PropertyValue `json:"properties"`
+	// Variables holds the values of the variables referenced by properties
+	// that the class binds itself; see datasource.BindingClass. It is set
+	// for each execution and never stored.
+	Variables map[Name]VariableValue `json:"-"`
+	// Range is the time range of the execution, if the request has one.
+	// Classes read it for time macros and to pick a resolution.
+	Range *TimeRange `json:"-"`
 }
 
 // Component binds a visualisation to its data and layout.
+// Query is the first of Queries, kept for clients that read one query.
 type Component struct {
-	ID              ComponentID                  `json:"id"`
-	VisualisationID VisualisationID              `json:"visualisationId"`
-	Query           Query                        `json:"query"`
-	Name            Name                         `json:"name"`
-	Coordination    Coordination                 `json:"coordination"`
+	ID              ComponentID                   `json:"id"`
+	VisualisationID VisualisationID               `json:"visualisationId"`
+	Query           Query                         `json:"query"`
+	Queries         []Query                       `json:"queries"`
+	Name            Name                          `json:"name"`
+	Coordination    Coordination                  `json:"coordination"`
 	Properties      map[PropertyKey]PropertyValue `json:"properties"`
-	UpdatedAt       time.Time                    `json:"updatedAt"`
+	UpdatedAt       time.Time                     `json:"updatedAt"`
 }
 
 type CreateComponentOptions struct {
-	VisualisationID VisualisationID              `json:"visualisationId"`
-	Queries         []Query                      `json:"queries"`
-	Name            Name                         `json:"name"`
-	Coordination    Coordination                 `json:"coordination"`
+	VisualisationID VisualisationID               `json:"visualisationId" validate:"required,max=64"`
+	Queries         []Query                       `json:"queries" validate:"dive"`
+	Name            Name                          `json:"name" validate:"required,max=256"`
+	Coordination    Coordination                  `json:"coordination"`
 	Properties      map[PropertyKey]PropertyValue `json:"properties"`
 }
 
 type UpdateComponentOptions struct {
-	VisualisationID VisualisationID              `json:"visualisationId"`
-	Queries         []Query                      `json:"queries"`
-	Name            Name                         `json:"name"`
-	Coordination    Coordination                 `json:"coordination"`
+	VisualisationID VisualisationID               `json:"visualisationId" validate:"required,max=64"`
+	Queries         []Query                       `json:"queries" validate:"dive"`
+	Name            Name                          `json:"name" validate:"required,max=256"`
+	Coordination    Coordination                  `json:"coordination"`
 	Properties      map[PropertyKey]PropertyValue `json:"properties"`
 }
 
 // Tabular data returned to the frontend.
+// Values stores string columns. Every other type keeps its data in the
+// matching typed slice (Ints, Floats, Bools, Times or JSON) and Values is
+// only rendered on the wire for clients that predate typed columns.
+// Nulls marks null rows for every type; see table.go for the wire format.
 type ColumnData struct {
-	Name   Name           `json:"name"`
-	Type   PropertyType   `json:"type"`
-	Values []PropertyValue `json:"values"`
+	Name   Name              `json:"name"`
+	Type   PropertyType      `json:"type"`
+	Values []PropertyValue   `json:"values"`
+	Ints   []int64           `json:"-"`
+	Floats []float64         `json:"-"`
+	Bools  []bool            `json:"-"`
+	Times  []time.Time       `json:"-"`
+	JSON   []json.RawMessage `json:"-"`
+	Nulls  NullBitmap        `json:"-"`
+	Meta   *ColumnMeta       `json:"-"`
+}
+
+// ColumnMeta is optional presentation metadata of a column. Classes fill
+// what their backend knows and components may override any field.
+type ColumnMeta struct {
+	// Unit follows Grafana unit ids, e.g. "bytes", "ms" or "currencyUSD".
+	Unit        string `json:"unit,omitempty"`
+	Decimals    *uint8 `json:"decimals,omitempty"`
+	DisplayName Name   `json:"displayName,omitempty" validate:"max=256"`
+	Description string `json:"description,omitempty"`
+	// Path locates the source field, e.g. "public.orders.total".
+	Path string `json:"path,omitempty"`
 }
 
+// TableData is a set of equally long columns. Total and NextCursor are
+// only set when the rows were narrowed with DataOptions. Truncated marks
+// results cut short by a row or byte budget.
 type TableData struct {
-	Columns []ColumnData `json:"columns"`
+	Columns    []ColumnData `json:"columns"`
+	Total      *RowCount    `json:"total,omitempty"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+	Truncated  bool         `json:"truncated,omitempty"`
+}
+
+type QueryJobStatus string
+
+const (
+	QueryJobQueued    QueryJobStatus = "queued"
+	QueryJobRunning   QueryJobStatus = "running"
+	QueryJobSucceeded QueryJobStatus = "succeeded"
+	QueryJobFailed    QueryJobStatus = "failed"
+	QueryJobCanceled  QueryJobStatus = "canceled"
+)
+
+// Finished reports whether the job has stopped running.
+func (s QueryJobStatus) Finished() bool {
+	return s == QueryJobSucceeded || s == QueryJobFailed || s == QueryJobCanceled
+}
+
+// QueryJob is an asynchronous run of a component's query. RowsFetched
+// reports progress while it runs; Result is set once it succeeded and is
+// kept until ExpiresAt.
+type QueryJob struct {
+	ID          QueryJobID     `json:"id"`
+	ComponentID ComponentID    `json:"componentId"`
+	Status      QueryJobStatus `json:"status"`
+	RowsFetched int64          `json:"rowsFetched"`
+	Error       string         `json:"error,omitempty"`
+	Result      *TableData     `json:"result,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"`
+	ExpiresAt   *time.Time     `json:"expiresAt,omitempty"`
 }
 
 // DataSource describes a configured backend data provider.
+// Alias is optional but unique among data sources when present.
 type DataSource struct {
 	ID         DataSourceID                  `json:"id"`
 	ClassID    DataSourceClassID             `json:"classId"`
@@ -57,20 +133,136 @@ type DataSource struct {
 	UpdatedAt  time.Time                     `json:"updatedAt"`
 }
 
+// DataSourceDeletePolicy decides what happens to the components that still
+// reference a data source when it is deleted.
+type DataSourceDeletePolicy string
+
+const (
+	DataSourceDeleteRestrict DataSourceDeletePolicy = "restrict"
+	DataSourceDeleteCascade  DataSourceDeletePolicy = "cascade"
+	DataSourceDeleteForce    DataSourceDeletePolicy = "force"
+)
+
 type CreateDataSourceOptions struct {
-	ClassID    DataSourceClassID             `json:"classId"`
-	Name       Name                          `json:"name"`
-	Alias      Alias                         `json:"alias"`
+	ClassID    DataSourceClassID             `json:"classId" validate:"required,max=64"`
+	Name       Name                          `json:"name" validate:"required,max=256"`
+	Alias      Alias                         `json:"alias" validate:"max=64"`
 	Properties map[PropertyKey]PropertyValue `json:"properties"`
 }
 
 type UpdateDataSourceOptions struct {
-	ClassID    DataSourceClassID             `json:"classId"`
-	Name       Name                          `json:"name"`
-	Alias      Alias                         `json:"alias"`
+	ClassID    DataSourceClassID             `json:"classId" validate:"required,max=64"`
+	Name       Name                          `json:"name" validate:"required,max=256"`
+	Alias      Alias                         `json:"alias" validate:"max=64"`
 	Properties map[PropertyKey]PropertyValue `json:"properties"`
 }
 
+// Transformer is a saved JavaScript transformer that the script steps of
+// component transformations reference by name.
+type Transformer struct {
+	ID          TransformerID `json:"id"`
+	Name        Name          `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Script      string        `json:"script"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+type CreateTransformerOptions struct {
+	Name        Name   `json:"name" validate:"required,max=64"`
+	Description string `json:"description" validate:"max=1024"`
+	Script      string `json:"script" validate:"required"`
+}
+
+type UpdateTransformerOptions CreateTransformerOptions
+
+// PoolStats describes the connection pool a class keeps for a data source.
+type PoolStats struct {
+	DataSourceID      DataSourceID      `json:"dataSourceId"`
+	ClassID           DataSourceClassID `json:"classId"`
+	MaxOpen           int               `json:"maxOpen"`
+	Open              int               `json:"open"`
+	InUse             int               `json:"inUse"`
+	Idle              int               `json:"idle"`
+	WaitCount         int64             `json:"waitCount"`
+	WaitDuration      time.Duration     `json:"waitDurationNs"`
+	MaxIdleClosed     int64             `json:"maxIdleClosed"`
+	MaxLifetimeClosed int64             `json:"maxLifetimeClosed"`
+	MaxIdleTimeClosed int64             `json:"maxIdleTimeClosed"`
+}
+
+// HealthCheckStep names a stage of connecting to a data source, in the
+// order a health check runs them.
+type HealthCheckStep string
+
+const (
+	HealthCheckDNS   HealthCheckStep = "dns"
+	HealthCheckTCP   HealthCheckStep = "tcp"
+	HealthCheckTLS   HealthCheckStep = "tls"
+	HealthCheckAuth  HealthCheckStep = "auth"
+	HealthCheckQuery HealthCheckStep = "query"
+)
+
+type HealthCheckStatus string
+
+const (
+	HealthCheckOK      HealthCheckStatus = "ok"
+	HealthCheckFailed  HealthCheckStatus = "failed"
+	HealthCheckSkipped HealthCheckStatus = "skipped"
+)
+
+// HealthCheckResult is the outcome of one step. Steps that do not apply,
+// such as DNS for a file database, or that follow a failure are skipped.
+type HealthCheckResult struct {
+	Step    HealthCheckStep   `json:"step"`
+	Status  HealthCheckStatus `json:"status"`
+	Latency time.Duration     `json:"latencyNs"`
+	Detail  string            `json:"detail,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// HealthCheckReport is OK when no step failed.
+type HealthCheckReport struct {
+	OK    bool                `json:"ok"`
+	Steps []HealthCheckResult `json:"steps"`
+}
+
+// DatabaseSchema describes the objects of a data source that queries can
+// read, as reported by its class.
+type DatabaseSchema struct {
+	Schemas []SchemaInfo `json:"schemas"`
+}
+
+// SchemaInfo is a namespace of tables. Databases without namespaces, such
+// as SQLite, report their tables under a single schema like "main".
+type SchemaInfo struct {
+	Name   string      `json:"name"`
+	Tables []TableInfo `json:"tables"`
+}
+
+type TableKind string
+
+const (
+	TableKindTable TableKind = "table"
+	TableKindView  TableKind = "view"
+)
+
+type TableInfo struct {
+	Name    string       `json:"name"`
+	Kind    TableKind    `json:"kind"`
+	Comment string       `json:"comment,omitempty"`
+	Columns []ColumnInfo `json:"columns"`
+}
+
+// ColumnInfo carries the database type name as declared and the column
+// type it maps onto, which is empty when only values can tell.
+type ColumnInfo struct {
+	Name         string       `json:"name"`
+	DatabaseType string       `json:"databaseType"`
+	Type         PropertyType `json:"type,omitempty"`
+	Nullable     bool         `json:"nullable"`
+	Comment      string       `json:"comment,omitempty"`
+}
+
 // DataSourceClass defines how to turn properties and queries into TableData.
 type DataSourceClass struct {
 	ID                  DataSourceClassID    `json:"id"`
@@ -0,0 +1,163 @@
+package domain
+
+import "time"
+
+// ContactPointType describes a kind of notifier compiled into the server,
+// such as webhook or email, and the settings its contact points take.
+type ContactPointType struct {
+	ID                  ContactPointTypeID   `json:"id"`
+	Name                Name                 `json:"name"`
+	PropertyDescriptors []PropertyDescriptor `json:"propertyDescriptors"`
+}
+
+// ContactPoint is somewhere notifications are delivered: a notifier of
+// Type configured by Settings, e.g. the URL of a webhook. Names are
+// unique.
+type ContactPoint struct {
+	ID        ContactPointID                `json:"id"`
+	Name      Name                          `json:"name"`
+	Type      ContactPointTypeID            `json:"type"`
+	Settings  map[PropertyKey]PropertyValue `json:"settings"`
+	UpdatedAt time.Time                     `json:"updatedAt"`
+}
+
+type CreateContactPointOptions struct {
+	Name     Name                          `json:"name" validate:"required,max=256"`
+	Type     ContactPointTypeID            `json:"type" validate:"required,max=64"`
+	Settings map[PropertyKey]PropertyValue `json:"settings"`
+}
+
+type UpdateContactPointOptions CreateContactPointOptions
+
+// Notification is what a contact point delivers: one or more alerts, with
+// a Title and a plain text Message summing them up. Status is firing when
+// any of the alerts fires and resolved otherwise. Test marks
+// notifications sent to try a contact point out.
+//
+// Notifications of reports carry no alerts; HTML, when set, is the
+// Message as a page for notifiers that can show one, and Attachments the
+// files that go with it.
+type Notification struct {
+	Title       string          `json:"title"`
+	Message     string          `json:"message"`
+	HTML        string          `json:"html,omitempty"`
+	Status      AlertState      `json:"status,omitempty"`
+	Alerts      []NotifiedAlert `json:"alerts"`
+	Attachments []Attachment    `json:"attachments,omitempty"`
+	Test        bool            `json:"test,omitempty"`
+}
+
+// Attachment is a file sent with a notification. Inline attachments are
+// shown within the HTML of the notification, which refers to them as
+// cid:Name, instead of being offered for download.
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+	Inline      bool   `json:"inline,omitempty"`
+}
+
+// NotifiedAlert is an alert rule as a notification reports it, in the
+// State it entered at Since. Labels and annotations are keyed by plain
+// strings so that payload templates can index them.
+type NotifiedAlert struct {
+	RuleID      AlertRuleID       `json:"ruleId"`
+	Name        Name              `json:"name"`
+	State       AlertState        `json:"state"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Since       time.Time         `json:"since"`
+}
+
+// MatchOp is how a LabelMatcher compares a label with its value.
+type MatchOp string
+
+const (
+	MatchEqual     MatchOp = "="
+	MatchNotEqual  MatchOp = "!="
+	MatchRegexp    MatchOp = "=~"
+	MatchNotRegexp MatchOp = "!~"
+)
+
+// LabelMatcher selects alerts by one of their labels. Regular expressions
+// match the whole value, and a missing label matches as the empty string.
+// Besides their own labels, alerts carry alertname, the name of their
+// rule.
+type LabelMatcher struct {
+	Label Name    `json:"label" validate:"required,max=256"`
+	Op    MatchOp `json:"op" validate:"required"`
+	Value string  `json:"value" validate:"max=1024"`
+}
+
+// NotificationRoute is a node of the notification policy tree. Alerts
+// enter at the root, which matches every alert, and descend into the
+// first child whose Matchers all match, or into every such child up to
+// the first one without Continue. The deepest matching routes deliver the
+// alert to their ContactPointID, in groups of alerts sharing the labels
+// of GroupBy, where "..." groups by every label.
+//
+// A group is notified GroupWait after its first alert, then at most every
+// GroupInterval while its alerts change, and every RepeatInterval while
+// they keep firing. Routes inherit unset fields from their parent.
+type NotificationRoute struct {
+	Matchers       []LabelMatcher      `json:"matchers,omitempty" validate:"dive"`
+	ContactPointID *ContactPointID     `json:"contactPointId,omitempty"`
+	GroupBy        []Name              `json:"groupBy,omitempty"`
+	GroupWait      Duration            `json:"groupWait,omitempty"`
+	GroupInterval  Duration            `json:"groupInterval,omitempty"`
+	RepeatInterval Duration            `json:"repeatInterval,omitempty"`
+	Continue       bool                `json:"continue,omitempty"`
+	Routes         []NotificationRoute `json:"routes,omitempty" validate:"dive"`
+}
+
+// NotificationPolicy is the tree alerts are routed through.
+type NotificationPolicy struct {
+	Root      NotificationRoute `json:"root"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+type SilenceState string
+
+const (
+	SilencePending SilenceState = "pending"
+	SilenceActive  SilenceState = "active"
+	SilenceExpired SilenceState = "expired"
+)
+
+// Silence mutes the notifications of the alerts matching all of its
+// Matchers from StartsAt until EndsAt. State is derived from the time it
+// is read at.
+type Silence struct {
+	ID        SilenceID      `json:"id"`
+	Matchers  []LabelMatcher `json:"matchers"`
+	StartsAt  time.Time      `json:"startsAt"`
+	EndsAt    time.Time      `json:"endsAt"`
+	Comment   string         `json:"comment,omitempty"`
+	CreatedBy Name           `json:"createdBy,omitempty"`
+	State     SilenceState   `json:"state"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// StateAt is the state of the silence at t.
+func (s Silence) StateAt(t time.Time) SilenceState {
+	switch {
+	case t.Before(s.StartsAt):
+		return SilencePending
+	case t.Before(s.EndsAt):
+		return SilenceActive
+	}
+	return SilenceExpired
+}
+
+// CreateSilenceOptions bound a silence by EndsAt, or by Duration from
+// StartsAt, which defaults to now.
+type CreateSilenceOptions struct {
+	Matchers  []LabelMatcher `json:"matchers" validate:"required,min=1,dive"`
+	StartsAt  *time.Time     `json:"startsAt"`
+	EndsAt    *time.Time     `json:"endsAt"`
+	Duration  Duration       `json:"duration"`
+	Comment   string         `json:"comment" validate:"max=1024"`
+	CreatedBy Name           `json:"createdBy" validate:"max=256"`
+}
+
+type UpdateSilenceOptions CreateSilenceOptions
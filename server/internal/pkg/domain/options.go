@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// DataOptions narrows the rows of a component's TableData. Filters are
+// applied first, then sorting, then Offset and Limit. A zero Limit means
+// no limit.
+type DataOptions struct {
+	Limit   int
+	Offset  int
+	Sort    []SortKey
+	Filters []Filter
+}
+
+func (o DataOptions) IsZero() bool {
+	return o.Limit == 0 && o.Offset == 0 && len(o.Sort) == 0 && len(o.Filters) == 0
+}
+
+type SortKey struct {
+	Column     Name
+	Descending bool
+}
+
+type FilterOp string
+
+const (
+	FilterEq       FilterOp = "eq"
+	FilterNe       FilterOp = "ne"
+	FilterGt       FilterOp = "gt"
+	FilterGe       FilterOp = "ge"
+	FilterLt       FilterOp = "lt"
+	FilterLe       FilterOp = "le"
+	FilterContains FilterOp = "contains"
+	FilterIsNull   FilterOp = "isnull"
+	FilterNotNull  FilterOp = "notnull"
+)
+
+func (op FilterOp) Valid() bool {
+	switch op {
+	case FilterEq, FilterNe, FilterGt, FilterGe, FilterLt, FilterLe, FilterContains, FilterIsNull, FilterNotNull:
+		return true
+	}
+	return false
+}
+
+// Filter compares a column against Value, which is parsed according to the
+// column type. Null rows never match comparisons; use isnull and notnull.
+type Filter struct {
+	Column Name
+	Op     FilterOp
+	Value  PropertyValue
+}
+
+// RowCount is the number of rows matching the filters before paging.
+type RowCount struct {
+	Rows      int64 `json:"rows"`
+	Estimated bool  `json:"estimated"`
+}
+
+const cursorPrefix = "o:"
+
+// EncodeCursor returns an opaque cursor resuming at offset.
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeCursor returns the offset encoded by EncodeCursor.
+func DecodeCursor(cursor string) (int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
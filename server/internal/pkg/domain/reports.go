@@ -0,0 +1,88 @@
+package domain
+
+import "time"
+
+type ReportFormat string
+
+const (
+	// ReportHTML shows tables within the message.
+	ReportHTML ReportFormat = "html"
+	// ReportCSV attaches tables as CSV files.
+	ReportCSV ReportFormat = "csv"
+)
+
+// Report runs the queries of Components on Schedule, a cron expression
+// read in TimeZone, and delivers their results to ContactPoints: charts as
+// images and tables in Format. Page and Variables select the variables
+// the queries see, as for a dashboard; From and To, when set, are the
+// time range of each run, relative to it, as in now-7d and now.
+type Report struct {
+	ID            ReportID                 `json:"id"`
+	Name          Name                     `json:"name"`
+	Schedule      string                   `json:"schedule"`
+	TimeZone      string                   `json:"timeZone"`
+	Components    []ComponentID            `json:"components"`
+	Page          Name                     `json:"page,omitempty"`
+	Variables     map[Name][]PropertyValue `json:"variables,omitempty"`
+	From          string                   `json:"from,omitempty"`
+	To            string                   `json:"to,omitempty"`
+	Format        ReportFormat             `json:"format"`
+	ContactPoints []ContactPointID         `json:"contactPoints"`
+	Paused        bool                     `json:"paused,omitempty"`
+	// NextRunAt is when the schedule runs the report next.
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+type CreateReportOptions struct {
+	Name          Name                     `json:"name" validate:"required,max=256"`
+	Schedule      string                   `json:"schedule" validate:"required,max=128"`
+	TimeZone      string                   `json:"timeZone" validate:"max=64"`
+	Components    []ComponentID            `json:"components" validate:"required,min=1"`
+	Page          Name                     `json:"page" validate:"max=256"`
+	Variables     map[Name][]PropertyValue `json:"variables"`
+	From          string                   `json:"from" validate:"max=64"`
+	To            string                   `json:"to" validate:"max=64"`
+	Format        ReportFormat             `json:"format" validate:"max=16"`
+	ContactPoints []ContactPointID         `json:"contactPoints" validate:"required,min=1"`
+	Paused        bool                     `json:"paused"`
+}
+
+type UpdateReportOptions CreateReportOptions
+
+type ReportRunStatus string
+
+const (
+	ReportSucceeded ReportRunStatus = "succeeded"
+	// ReportPartial is the status of runs that delivered the report
+	// without some of its components or to some of its contact points.
+	ReportPartial ReportRunStatus = "partial"
+	ReportFailed  ReportRunStatus = "failed"
+)
+
+type ReportTrigger string
+
+const (
+	ReportScheduled ReportTrigger = "schedule"
+	ReportManual    ReportTrigger = "manual"
+)
+
+// ReportRun records a run of a report and its outcome. Error sums up what
+// went wrong; Deliveries tell how each contact point fared.
+type ReportRun struct {
+	ID         ReportRunID      `json:"id"`
+	ReportID   ReportID         `json:"reportId"`
+	Trigger    ReportTrigger    `json:"trigger"`
+	Status     ReportRunStatus  `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	Deliveries []ReportDelivery `json:"deliveries"`
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt time.Time        `json:"finishedAt"`
+}
+
+// ReportDelivery is the outcome of sending a run to a contact point; Error
+// is empty when it was delivered.
+type ReportDelivery struct {
+	ContactPointID ContactPointID `json:"contactPointId"`
+	Error          string         `json:"error,omitempty"`
+}
@@ -0,0 +1,568 @@
+package domain
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NullBitmap flags null rows, one bit per row (bit i of byte i/8).
+// Rows beyond its length are not null, so columns without nulls keep it nil.
+type NullBitmap []byte
+
+func (b NullBitmap) IsNull(i int) bool {
+	if i/8 >= len(b) {
+		return false
+	}
+	return b[i/8]&(1<<(i%8)) != 0
+}
+
+func (b *NullBitmap) Set(i int) {
+	for len(*b) <= i/8 {
+		*b = append(*b, 0)
+	}
+	(*b)[i/8] |= 1 << (i % 8)
+}
+
+// NewColumnData returns an empty column of the given type.
+func NewColumnData(name Name, typ PropertyType) ColumnData {
+	return ColumnData{Name: name, Type: typ}
+}
+
+// Len reports the number of rows held by the column.
+func (c ColumnData) Len() int {
+	switch c.Type {
+	case PropertyTypeInteger:
+		return len(c.Ints)
+	case PropertyTypeNumber:
+		return len(c.Floats)
+	case PropertyTypeBoolean:
+		return len(c.Bools)
+	case PropertyTypeTime:
+		return len(c.Times)
+	case PropertyTypeJSON:
+		return len(c.JSON)
+	default:
+		return len(c.Values)
+	}
+}
+
+func (c ColumnData) IsNull(i int) bool {
+	return c.Nulls.IsNull(i)
+}
+
+// Value returns row i as int64, float64, bool, time.Time, json.RawMessage
+// or PropertyValue depending on the column type, and nil for null rows.
+func (c ColumnData) Value(i int) any {
+	if c.IsNull(i) {
+		return nil
+	}
+	switch c.Type {
+	case PropertyTypeInteger:
+		return c.Ints[i]
+	case PropertyTypeNumber:
+		return c.Floats[i]
+	case PropertyTypeBoolean:
+		return c.Bools[i]
+	case PropertyTypeTime:
+		return c.Times[i]
+	case PropertyTypeJSON:
+		return c.JSON[i]
+	default:
+		return c.Values[i]
+	}
+}
+
+// String renders row i the way string-only clients expect it.
+// Null rows render as the empty string.
+func (c ColumnData) String(i int) string {
+	if c.IsNull(i) {
+		return ""
+	}
+	switch c.Type {
+	case PropertyTypeInteger:
+		return strconv.FormatInt(c.Ints[i], 10)
+	case PropertyTypeNumber:
+		return strconv.FormatFloat(c.Floats[i], 'f', -1, 64)
+	case PropertyTypeBoolean:
+		return strconv.FormatBool(c.Bools[i])
+	case PropertyTypeTime:
+		return c.Times[i].Format(time.RFC3339Nano)
+	case PropertyTypeJSON:
+		return string(c.JSON[i])
+	default:
+		return string(c.Values[i])
+	}
+}
+
+// AppendNull adds a null row, padding the typed slice with its zero value.
+func (c *ColumnData) AppendNull() {
+	c.Nulls.Set(c.Len())
+	switch c.Type {
+	case PropertyTypeInteger:
+		c.Ints = append(c.Ints, 0)
+	case PropertyTypeNumber:
+		c.Floats = append(c.Floats, 0)
+	case PropertyTypeBoolean:
+		c.Bools = append(c.Bools, false)
+	case PropertyTypeTime:
+		c.Times = append(c.Times, time.Time{})
+	case PropertyTypeJSON:
+		c.JSON = append(c.JSON, nil)
+	default:
+		c.Values = append(c.Values, "")
+	}
+}
+
+// Append converts a driver value to the column type and adds it as a row.
+// nil appends a null. The column is left unchanged when v cannot be converted.
+func (c *ColumnData) Append(v any) error {
+	if v == nil {
+		c.AppendNull()
+		return nil
+	}
+	switch c.Type {
+	case PropertyTypeInteger:
+		n, err := toInt64(v)
+		if err != nil {
+			return err
+		}
+		c.Ints = append(c.Ints, n)
+	case PropertyTypeNumber:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		c.Floats = append(c.Floats, f)
+	case PropertyTypeBoolean:
+		b, err := toBool(v)
+		if err != nil {
+			return err
+		}
+		c.Bools = append(c.Bools, b)
+	case PropertyTypeTime:
+		t, err := toTime(v)
+		if err != nil {
+			return err
+		}
+		c.Times = append(c.Times, t)
+	case PropertyTypeJSON:
+		raw, err := toJSON(v)
+		if err != nil {
+			return err
+		}
+		c.JSON = append(c.JSON, raw)
+	default:
+		c.Values = append(c.Values, PropertyValue(toString(v)))
+	}
+	return nil
+}
+
+// Take returns a column holding the given rows of c, in that order.
+func (c ColumnData) Take(rows []int) ColumnData {
+	out := NewColumnData(c.Name, c.Type)
+	out.Meta = c.Meta
+	for _, i := range rows {
+		if c.IsNull(i) {
+			out.AppendNull()
+			continue
+		}
+		switch c.Type {
+		case PropertyTypeInteger:
+			out.Ints = append(out.Ints, c.Ints[i])
+		case PropertyTypeNumber:
+			out.Floats = append(out.Floats, c.Floats[i])
+		case PropertyTypeBoolean:
+			out.Bools = append(out.Bools, c.Bools[i])
+		case PropertyTypeTime:
+			out.Times = append(out.Times, c.Times[i])
+		case PropertyTypeJSON:
+			out.JSON = append(out.JSON, c.JSON[i])
+		default:
+			out.Values = append(out.Values, c.Values[i])
+		}
+	}
+	return out
+}
+
+// ParseValue converts s to the Go type Value returns for this column.
+// Integer columns also accept fractional numbers, returned as float64.
+func (c ColumnData) ParseValue(s string) (any, error) {
+	tmp := NewColumnData(c.Name, c.Type)
+	if err := tmp.Append(s); err != nil {
+		if c.Type == PropertyTypeInteger {
+			return toFloat64(s)
+		}
+		return nil, err
+	}
+	return tmp.Value(0), nil
+}
+
+// CompareValues orders two non-null values as returned by Value or
+// ParseValue for the same column. Integers and floats compare numerically.
+func CompareValues(a, b any) int {
+	switch x := a.(type) {
+	case int64:
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, y)
+		}
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(float64(x), y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return cmp.Compare(x, y)
+		}
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, float64(y))
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+// Merge returns m with every field set in override replacing its own.
+func (m ColumnMeta) Merge(override ColumnMeta) ColumnMeta {
+	if override.Unit != "" {
+		m.Unit = override.Unit
+	}
+	if override.Decimals != nil {
+		m.Decimals = override.Decimals
+	}
+	if override.DisplayName != "" {
+		m.DisplayName = override.DisplayName
+	}
+	if override.Description != "" {
+		m.Description = override.Description
+	}
+	if override.Path != "" {
+		m.Path = override.Path
+	}
+	return m
+}
+
+// ApplyColumnMeta merges overrides, keyed by column name, into the
+// metadata of matching columns. Unknown column names are ignored.
+func (t *TableData) ApplyColumnMeta(overrides map[Name]ColumnMeta) {
+	for i := range t.Columns {
+		override, ok := overrides[t.Columns[i].Name]
+		if !ok {
+			continue
+		}
+		var meta ColumnMeta
+		if t.Columns[i].Meta != nil {
+			meta = *t.Columns[i].Meta
+		}
+		meta = meta.Merge(override)
+		t.Columns[i].Meta = &meta
+	}
+}
+
+// RowSize approximates the bytes row i occupies: 8 for numbers and times,
+// 1 for booleans and the length of strings and JSON documents.
+func (t TableData) RowSize(i int) int {
+	size := 0
+	for _, c := range t.Columns {
+		switch {
+		case c.IsNull(i):
+		case c.Type == PropertyTypeBoolean:
+			size++
+		case c.Type == PropertyTypeInteger, c.Type == PropertyTypeNumber, c.Type == PropertyTypeTime:
+			size += 8
+		case c.Type == PropertyTypeJSON:
+			size += len(c.JSON[i])
+		default:
+			size += len(c.Values[i])
+		}
+	}
+	return size
+}
+
+// NumRows reports the row count, taken from the first column.
+func (t TableData) NumRows() int {
+	if len(t.Columns) == 0 {
+		return 0
+	}
+	return t.Columns[0].Len()
+}
+
+// columnWire is the JSON shape of ColumnData. Values always carries the
+// string rendering so existing clients keep working. Non-string columns add
+// Data with native JSON values (null for null rows, RFC 3339 for times,
+// embedded documents for JSON) and Nulls carries the base64 bitmap.
+type columnWire struct {
+	Name   Name              `json:"name"`
+	Type   PropertyType      `json:"type"`
+	Values []PropertyValue   `json:"values"`
+	Nulls  NullBitmap        `json:"nulls,omitempty"`
+	Data   []json.RawMessage `json:"data,omitempty"`
+	Meta   *ColumnMeta       `json:"meta,omitempty"`
+}
+
+func (c ColumnData) MarshalJSON() ([]byte, error) {
+	n := c.Len()
+	w := columnWire{Name: c.Name, Type: c.Type, Nulls: c.Nulls, Meta: c.Meta}
+	if isStringType(c.Type) {
+		w.Values = c.Values
+		if w.Values == nil {
+			w.Values = []PropertyValue{}
+		}
+		return json.Marshal(w)
+	}
+
+	w.Values = make([]PropertyValue, n)
+	w.Data = make([]json.RawMessage, n)
+	for i := 0; i < n; i++ {
+		w.Values[i] = PropertyValue(c.String(i))
+		raw, err := c.marshalValue(i)
+		if err != nil {
+			return nil, err
+		}
+		w.Data[i] = raw
+	}
+	return json.Marshal(w)
+}
+
+func (c ColumnData) marshalValue(i int) (json.RawMessage, error) {
+	if c.IsNull(i) {
+		return json.RawMessage("null"), nil
+	}
+	switch c.Type {
+	case PropertyTypeNumber:
+		// JSON has no NaN or infinities; send them as strings.
+		if f := c.Floats[i]; math.IsNaN(f) || math.IsInf(f, 0) {
+			return json.Marshal(strconv.FormatFloat(f, 'f', -1, 64))
+		}
+	case PropertyTypeJSON:
+		if !json.Valid(c.JSON[i]) {
+			return nil, fmt.Errorf("column %s row %d: invalid json", c.Name, i)
+		}
+		return c.JSON[i], nil
+	}
+	return json.Marshal(c.Value(i))
+}
+
+// UnmarshalJSON accepts both typed payloads and legacy ones that only
+// carry string values, which are parsed according to Type.
+func (c *ColumnData) UnmarshalJSON(data []byte) error {
+	var w columnWire
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	col := NewColumnData(w.Name, w.Type)
+	col.Meta = w.Meta
+	if isStringType(w.Type) {
+		col.Values = w.Values
+		col.Nulls = w.Nulls
+		*c = col
+		return nil
+	}
+
+	if w.Data == nil {
+		for i, v := range w.Values {
+			if w.Nulls.IsNull(i) {
+				col.AppendNull()
+				continue
+			}
+			if err := col.Append(string(v)); err != nil {
+				return fmt.Errorf("column %s row %d: %w", w.Name, i, err)
+			}
+		}
+		*c = col
+		return nil
+	}
+
+	for i, raw := range w.Data {
+		if string(raw) == "null" {
+			col.AppendNull()
+			continue
+		}
+		var v any
+		if w.Type == PropertyTypeJSON {
+			v = raw
+		} else {
+			// UseNumber keeps int64 values above 2^53 exact.
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				return err
+			}
+		}
+		if err := col.Append(v); err != nil {
+			return fmt.Errorf("column %s row %d: %w", w.Name, i, err)
+		}
+	}
+	*c = col
+	return nil
+}
+
+func isStringType(t PropertyType) bool {
+	switch t {
+	case PropertyTypeInteger, PropertyTypeNumber, PropertyTypeBoolean, PropertyTypeTime, PropertyTypeJSON:
+		return false
+	}
+	return true
+}
+
+func toInt64(v any) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case json.Number:
+		return n.Int64()
+	case int:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("integer %d overflows int64", n)
+		}
+		return int64(n), nil
+	case float64:
+		if n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+			return 0, fmt.Errorf("number %v is not an integer", n)
+		}
+		return int64(n), nil
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(n), 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to integer", v)
+}
+
+func toFloat64(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	case []byte:
+		return strconv.ParseFloat(string(n), 64)
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return 0, fmt.Errorf("cannot convert %T to number", v)
+	}
+	return float64(i), nil
+}
+
+func toBool(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(b)
+	case []byte:
+		return strconv.ParseBool(string(b))
+	}
+	// SQLite and MySQL store booleans as integers.
+	n, err := toInt64(v)
+	if err != nil {
+		return false, fmt.Errorf("cannot convert %T to boolean", v)
+	}
+	return n != 0, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func toTime(v any) (time.Time, error) {
+	var s string
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		s = t
+	case []byte:
+		s = string(t)
+	default:
+		return time.Time{}, fmt.Errorf("cannot convert %T to time", v)
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
+
+func toJSON(v any) (json.RawMessage, error) {
+	switch j := v.(type) {
+	case json.RawMessage:
+		return j, nil
+	case []byte:
+		if json.Valid(j) {
+			return json.RawMessage(append([]byte(nil), j...)), nil
+		}
+		return json.Marshal(string(j))
+	case string:
+		if json.Valid([]byte(j)) {
+			return json.RawMessage(j), nil
+		}
+		return json.Marshal(j)
+	}
+	return json.Marshal(v)
+}
+
+func toString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case json.RawMessage:
+		return string(s)
+	case PropertyValue:
+		return string(s)
+	case []byte:
+		return string(s)
+	case time.Time:
+		return s.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -1,13 +1,22 @@
 package domain
 
-import "github.com/rushysloth/go-tsid"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rushysloth/go-tsid"
+)
 
 // Common value objects and descriptors used across the server.
-// Validation tags follow the specification document.
+// Validation tags follow the specification document. Go cannot attach tags
+// to type declarations, so the limits below are applied through the
+// `validate` tags of every struct field using these types.
 
 // Name and Alias
-type Name string
-type Alias string
+type Name string  // validate:"max=256"
+type Alias string // validate:"max=64"
 
 // Rect and Coordination
 type Rect struct {
@@ -33,28 +42,184 @@ func (id GeneratedID) Int64() int64 {
 	return id.tsid.ToNumber()
 }
 
+// String renders the ID in its canonical 13 character TSID form.
+func (id GeneratedID) String() string {
+	return id.tsid.ToString()
+}
+
+func (id GeneratedID) IsZero() bool {
+	return id.Int64() == 0
+}
+
+var ErrInvalidID = errors.New("invalid id")
+
+// ParseGeneratedID parses the canonical TSID form produced by String.
+func ParseGeneratedID(s string) (GeneratedID, error) {
+	if !tsid.IsValidRuneArray([]rune(s)) {
+		return GeneratedID{}, ErrInvalidID
+	}
+	return GeneratedID{tsid: *tsid.FromString(s)}, nil
+}
+
+// IDs travel as strings so that JavaScript clients do not lose int64 precision.
+func (id GeneratedID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+func (id *GeneratedID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*id = GeneratedID{}
+		return nil
+	}
+	parsed, err := ParseGeneratedID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
 type ComponentID struct{ GeneratedID }
 
 func NewComponentID(v int64) ComponentID {
 	return ComponentID{GeneratedID: NewGeneratedID(v)}
 }
 
+func ParseComponentID(s string) (ComponentID, error) {
+	id, err := ParseGeneratedID(s)
+	return ComponentID{GeneratedID: id}, err
+}
+
 type DataSourceID struct{ GeneratedID }
 
 func NewDataSourceID(v int64) DataSourceID {
 	return DataSourceID{GeneratedID: NewGeneratedID(v)}
 }
 
+func ParseDataSourceID(s string) (DataSourceID, error) {
+	id, err := ParseGeneratedID(s)
+	return DataSourceID{GeneratedID: id}, err
+}
+
+// QueryRunID identifies one execution of a query so that it can be
+// cancelled while it runs.
+type QueryRunID struct{ GeneratedID }
+
+func NewQueryRunID(v int64) QueryRunID {
+	return QueryRunID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseQueryRunID(s string) (QueryRunID, error) {
+	id, err := ParseGeneratedID(s)
+	return QueryRunID{GeneratedID: id}, err
+}
+
+// QueryJobID identifies an asynchronous query job. A running job can be
+// cancelled as the query run with the same ID.
+type QueryJobID struct{ GeneratedID }
+
+func NewQueryJobID(v int64) QueryJobID {
+	return QueryJobID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseQueryJobID(s string) (QueryJobID, error) {
+	id, err := ParseGeneratedID(s)
+	return QueryJobID{GeneratedID: id}, err
+}
+
+type VariableID struct{ GeneratedID }
+
+func NewVariableID(v int64) VariableID {
+	return VariableID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseVariableID(s string) (VariableID, error) {
+	id, err := ParseGeneratedID(s)
+	return VariableID{GeneratedID: id}, err
+}
+
+type TransformerID struct{ GeneratedID }
+
+func NewTransformerID(v int64) TransformerID {
+	return TransformerID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseTransformerID(s string) (TransformerID, error) {
+	id, err := ParseGeneratedID(s)
+	return TransformerID{GeneratedID: id}, err
+}
+
+type AlertRuleID struct{ GeneratedID }
+
+func NewAlertRuleID(v int64) AlertRuleID {
+	return AlertRuleID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseAlertRuleID(s string) (AlertRuleID, error) {
+	id, err := ParseGeneratedID(s)
+	return AlertRuleID{GeneratedID: id}, err
+}
+
+type ContactPointID struct{ GeneratedID }
+
+func NewContactPointID(v int64) ContactPointID {
+	return ContactPointID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseContactPointID(s string) (ContactPointID, error) {
+	id, err := ParseGeneratedID(s)
+	return ContactPointID{GeneratedID: id}, err
+}
+
+type SilenceID struct{ GeneratedID }
+
+func NewSilenceID(v int64) SilenceID {
+	return SilenceID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseSilenceID(s string) (SilenceID, error) {
+	id, err := ParseGeneratedID(s)
+	return SilenceID{GeneratedID: id}, err
+}
+
+type ReportID struct{ GeneratedID }
+
+func NewReportID(v int64) ReportID {
+	return ReportID{GeneratedID: NewGeneratedID(v)}
+}
+
+func ParseReportID(s string) (ReportID, error) {
+	id, err := ParseGeneratedID(s)
+	return ReportID{GeneratedID: id}, err
+}
+
+type ReportRunID struct{ GeneratedID }
+
+func NewReportRunID(v int64) ReportRunID {
+	return ReportRunID{GeneratedID: NewGeneratedID(v)}
+}
+
 type DesignatedID string
 type VisualisationID DesignatedID
 type DataSourceClassID DesignatedID
+type ContactPointTypeID DesignatedID
 
 // Properties
 type PropertyType string
 
 const (
 	PropertyTypeString PropertyType = "string"
-	PropertyTypeNumber PropertyType = "number"
+	// PropertyTypeNumber is a float64 in table data.
+	PropertyTypeNumber  PropertyType = "number"
+	PropertyTypeInteger PropertyType = "integer"
+	PropertyTypeBoolean PropertyType = "boolean"
+	// PropertyTypeTime is an instant that keeps its timezone offset.
+	PropertyTypeTime PropertyType = "time"
+	PropertyTypeJSON PropertyType = "json"
 )
 
 type PropertyKey string
@@ -70,3 +235,68 @@ type PropertyDescriptor struct {
 	IsSecret   bool
 	Candidates []PropertyValue
 }
+
+// SecretPlaceholder stands in for the values of secret properties in API
+// responses. Sending it back in an update keeps the stored value.
+const SecretPlaceholder PropertyValue = "********"
+
+// RedactSecrets returns a copy of props with the set values of the secret
+// properties among descs replaced by SecretPlaceholder.
+func RedactSecrets(props map[PropertyKey]PropertyValue, descs []PropertyDescriptor) map[PropertyKey]PropertyValue {
+	if props == nil {
+		return nil
+	}
+	out := make(map[PropertyKey]PropertyValue, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	for _, d := range descs {
+		if d.IsSecret && out[d.Key] != "" {
+			out[d.Key] = SecretPlaceholder
+		}
+	}
+	return out
+}
+
+// KeepSecrets returns a copy of props with the secret properties among
+// descs that hold SecretPlaceholder set back to their values in stored.
+func KeepSecrets(props, stored map[PropertyKey]PropertyValue, descs []PropertyDescriptor) map[PropertyKey]PropertyValue {
+	if props == nil {
+		return nil
+	}
+	out := make(map[PropertyKey]PropertyValue, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	for _, d := range descs {
+		if d.IsSecret && out[d.Key] == SecretPlaceholder {
+			out[d.Key] = stored[d.Key]
+		}
+	}
+	return out
+}
+
+// Duration is a time.Duration that travels as a Go duration string, e.g.
+// "1m30s", in the configuration of entities.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
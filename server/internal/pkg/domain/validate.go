@@ -0,0 +1,13 @@
+package domain
+
+import "github.com/go-playground/validator/v10"
+
+// validate is safe for concurrent use and caches struct metadata,
+// so a single instance is shared by the whole process.
+var validate = validator.New(validator.WithRequiredStructEnabled())
+
+// Validate checks the `validate` tags of a struct such as
+// CreateComponentOptions or CreateDataSourceOptions.
+func Validate(v any) error {
+	return validate.Struct(v)
+}
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type VariableType string
+
+const (
+	// VariableConstant always has its Value; requests cannot change it.
+	VariableConstant VariableType = "constant"
+	// VariableCustom takes one of its Options.
+	VariableCustom VariableType = "custom"
+	// VariableTextBox takes any text.
+	VariableTextBox VariableType = "textbox"
+	// VariableQuery takes one of the values in the first column of Query,
+	// which may reference other variables.
+	VariableQuery VariableType = "query"
+)
+
+// VariableAll selects every option of a variable that includes "All".
+const VariableAll PropertyValue = "$__all"
+
+func (t VariableType) Valid() bool {
+	switch t {
+	case VariableConstant, VariableCustom, VariableTextBox, VariableQuery:
+		return true
+	}
+	return false
+}
+
+// Variable is a dashboard variable that query properties reference as
+// $name or ${name}. Variables with a Page apply to that page and shadow
+// project variables, which have none, of the same name.
+type Variable struct {
+	ID    VariableID   `json:"id"`
+	Name  Name         `json:"name"`
+	Label Name         `json:"label,omitempty"`
+	Type  VariableType `json:"type"`
+	Page  Name         `json:"page,omitempty"`
+	// Value is the value of constants and the default of other types.
+	Value   PropertyValue   `json:"value,omitempty"`
+	Options []PropertyValue `json:"options,omitempty"`
+	Query   *Query          `json:"query,omitempty"`
+	// Multi lets custom and query variables take several options, and
+	// IncludeAll lets them take VariableAll, which stands for AllValue
+	// when set and for every option otherwise.
+	Multi      bool          `json:"multi,omitempty"`
+	IncludeAll bool          `json:"includeAll,omitempty"`
+	AllValue   PropertyValue `json:"allValue,omitempty"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+type CreateVariableOptions struct {
+	Name    Name            `json:"name" validate:"required,max=64"`
+	Label   Name            `json:"label" validate:"max=256"`
+	Type    VariableType    `json:"type" validate:"required"`
+	Page    Name            `json:"page" validate:"max=256"`
+	Value   PropertyValue   `json:"value"`
+	Options []PropertyValue `json:"options"`
+	Query   *Query          `json:"query"`
+
+	Multi      bool          `json:"multi"`
+	IncludeAll bool          `json:"includeAll"`
+	AllValue   PropertyValue `json:"allValue"`
+}
+
+type UpdateVariableOptions CreateVariableOptions
+
+// VariableValue is what a variable resolved to. Raw marks a custom all
+// value, which formats insert as written rather than escaped.
+type VariableValue struct {
+	Values []PropertyValue `json:"values"`
+	Raw    bool            `json:"raw,omitempty"`
+}
+
+// ResolvedVariable is a variable with its options and value evaluated for
+// a VariableSelection. Selected is what was asked for, such as
+// VariableAll, and Value what it stands for.
+type ResolvedVariable struct {
+	Name       Name            `json:"name"`
+	Label      Name            `json:"label,omitempty"`
+	Type       VariableType    `json:"type"`
+	Multi      bool            `json:"multi,omitempty"`
+	IncludeAll bool            `json:"includeAll,omitempty"`
+	Options    []PropertyValue `json:"options,omitempty"`
+	Selected   []PropertyValue `json:"selected"`
+	Value      VariableValue   `json:"value"`
+	// DependsOn lists the variables the query of the variable references.
+	DependsOn []Name `json:"dependsOn,omitempty"`
+}
+
+// VariableSelection picks the page whose variables apply to a request and
+// overrides the values of variables by name. Range, when set, is the time
+// range of the dashboard, to be split into at most MaxDataPoints points.
+type VariableSelection struct {
+	Page          Name
+	Values        map[Name][]PropertyValue
+	Range         *TimeRange
+	MaxDataPoints int
+}
+
+// TimeRange is the span of time a dashboard shows. Interval is the
+// spacing of points that suits the span and the query.
+type TimeRange struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval time.Duration `json:"interval,omitempty"`
+}
+
+// Built-in variables, set from the time range of a request. Names starting
+// with two underscores are reserved for them.
+const (
+	VariableTimeFrom   Name = "__timeFrom"
+	VariableTimeTo     Name = "__timeTo"
+	VariableInterval   Name = "__interval"
+	VariableIntervalMS Name = "__interval_ms"
+)
+
+// BuiltinVariable reports whether name is reserved for built-in variables.
+func BuiltinVariable(name Name) bool {
+	return strings.HasPrefix(string(name), "__")
+}
+
+// VariableFormat tells how ${name:format} writes the values of a variable.
+type VariableFormat string
+
+const (
+	// VariableFormatCSV separates values with commas; it is the default.
+	VariableFormatCSV VariableFormat = "csv"
+	// VariableFormatPipe separates values with pipes.
+	VariableFormatPipe VariableFormat = "pipe"
+	// VariableFormatRegex quotes values for regular expressions and makes
+	// several of them an alternation, (a|b). Values are quoted as
+	// regexp.QuoteMeta does, with a backslash before each metacharacter of
+	// Go's syntax. That is right for the POSIX-style syntaxes of the SQL
+	// databases, as long as the result is bound as a parameter; written
+	// into a string literal that itself treats backslashes as escapes, or
+	// read by a syntax with other metacharacters, it needs quoting again.
+	VariableFormatRegex VariableFormat = "regex"
+)
+
+func (f VariableFormat) Valid() bool {
+	switch f {
+	case "", VariableFormatCSV, VariableFormatPipe, VariableFormatRegex:
+		return true
+	}
+	return false
+}
+
+// VariableRef is a reference to a variable in a property.
+type VariableRef struct {
+	Name   Name
+	Format VariableFormat
+}
+
+var variableRef = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)(?::([a-z]+))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+func parseVariableRef(m []string) VariableRef {
+	return VariableRef{Name: Name(m[1] + m[3]), Format: VariableFormat(m[2])}
+}
+
+// ValidVariableName reports whether name can be referenced as $name.
+func ValidVariableName(name Name) bool {
+	loc := variableRef.FindStringIndex("$" + string(name))
+	return loc != nil && loc[0] == 0 && loc[1] == len(name)+1
+}
+
+// VariableRefs returns the references in text, in order of appearance and
+// with repetitions.
+func VariableRefs(text string) []VariableRef {
+	var refs []VariableRef
+	for _, m := range variableRef.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, parseVariableRef(m))
+	}
+	return refs
+}
+
+// ReplaceVariables substitutes the references in text for which replace
+// reports true and leaves the others as written.
+func ReplaceVariables(text string, replace func(ref VariableRef) (string, bool)) string {
+	return variableRef.ReplaceAllStringFunc(text, func(ref string) string {
+		if s, ok := replace(parseVariableRef(variableRef.FindStringSubmatch(ref))); ok {
+			return s
+		}
+		return ref
+	})
+}
+
+// FormatVariable writes v as format asks. A raw value is written as is.
+func FormatVariable(v VariableValue, format VariableFormat) string {
+	values := make([]string, len(v.Values))
+	for i, val := range v.Values {
+		values[i] = string(val)
+	}
+	if v.Raw {
+		return strings.Join(values, ",")
+	}
+	switch format {
+	case VariableFormatPipe:
+		return strings.Join(values, "|")
+	case VariableFormatRegex:
+		for i, val := range values {
+			values[i] = regexp.QuoteMeta(val)
+		}
+		if len(values) == 1 {
+			return values[0]
+		}
+		return "(" + strings.Join(values, "|") + ")"
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,121 @@
+// Package expression compiles the expressions of components, written in
+// the expr language (https://expr-lang.org), e.g. revenue - cost or
+// cpu > 90 ? "critical" : "ok". Expressions have no side effects and run
+// within a memory budget; they see only the values they are given.
+package expression
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/vm"
+)
+
+// maxNodes bounds the size of an expression.
+const maxNodes = 1000
+
+// Error reports an expression that does not compile or fails to run.
+// Field locates the expression in the request that carried it, as in
+// properties.transformations[0].options.expression; Line and Column point
+// into the expression.
+type Error struct {
+	Field      string `json:"field,omitempty"`
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+	Line       int    `json:"line,omitempty"`
+	Column     int    `json:"column,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Line > 0 {
+		msg = fmt.Sprintf("%s (%d:%d)", msg, e.Line, e.Column)
+	}
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s", e.Field, msg)
+	}
+	return msg
+}
+
+func (e *Error) ErrorCode() string { return "invalid_expression" }
+
+func (e *Error) ErrorDetails() any { return e }
+
+// At prefixes the field of the Error in err, if any, with path, so that
+// each layer names the part of the request it knows about.
+func At(err error, path string) error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Field == "" || e.Field[0] == '[' {
+			e.Field = path + e.Field
+		} else {
+			e.Field = path + "." + e.Field
+		}
+	}
+	return err
+}
+
+// Program is a compiled expression.
+type Program struct {
+	src     string
+	program *vm.Program
+}
+
+// Compile parses src. Names are resolved when it runs, since the columns
+// and variables it may reference are only known then.
+func Compile(src string) (Program, error) {
+	if src == "" {
+		return Program{}, &Error{Message: "expression is empty"}
+	}
+	p, err := expr.Compile(src, expr.MaxNodes(maxNodes))
+	if err != nil {
+		return Program{}, newError(src, err)
+	}
+	return Program{src: src, program: p}, nil
+}
+
+func newError(src string, err error) *Error {
+	e := &Error{Expression: src, Message: err.Error()}
+	var fe *file.Error
+	if errors.As(err, &fe) {
+		e.Message, e.Line, e.Column = fe.Message, fe.Line, fe.Column
+	}
+	return e
+}
+
+func (p Program) String() string { return p.src }
+
+// Run evaluates the program with env giving the value of each name. Names
+// env lacks are nil; names that are not identifiers are reachable as
+// $env["total (usd)"].
+func (p Program) Run(env map[string]any) (any, error) {
+	out, err := expr.Run(p.program, env)
+	if err != nil {
+		return nil, newError(p.src, err)
+	}
+	return out, nil
+}
+
+// Names returns the distinct identifiers the program references, in order
+// of appearance, leaving out those reached through $env.
+func (p Program) Names() []string {
+	v := &identifiers{seen: make(map[string]bool)}
+	node := p.program.Node()
+	ast.Walk(&node, v)
+	return v.names
+}
+
+type identifiers struct {
+	seen  map[string]bool
+	names []string
+}
+
+func (v *identifiers) Visit(node *ast.Node) {
+	if id, ok := (*node).(*ast.IdentifierNode); ok && id.Value != "$env" && !v.seen[id.Value] {
+		v.seen[id.Value] = true
+		v.names = append(v.names, id.Value)
+	}
+}
@@ -0,0 +1,66 @@
+package expression_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+)
+
+func TestCompileAndRun(t *testing.T) {
+	p, err := expression.Compile(`cpu > limit ? "critical" : $env["host name"]`)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if names := p.Names(); !slices.Equal(names, []string{"cpu", "limit"}) {
+		t.Fatalf("Names = %v, want [cpu limit]", names)
+	}
+	out, err := p.Run(map[string]any{"cpu": 50, "limit": 90, "host name": "a"})
+	if err != nil || out != "a" {
+		t.Fatalf("Run = %v, %v, want a", out, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := expression.Compile("cpu >\n  ")
+	var e *expression.Error
+	if !errors.As(err, &e) || e.Line != 2 || e.Expression != "cpu >\n  " {
+		t.Fatalf("err = %#v, want a position on line 2", e)
+	}
+	err = expression.At(expression.At(err, "[0].options.expression"), "properties.transformations")
+	if e.Field != "properties.transformations[0].options.expression" {
+		t.Fatalf("Field = %q", e.Field)
+	}
+	if _, err := expression.Compile(""); !errors.As(err, &e) {
+		t.Fatalf("empty: err = %v", err)
+	}
+}
+
+func TestTable(t *testing.T) {
+	host := domain.NewColumnData("host", domain.PropertyTypeString)
+	cpu := domain.NewColumnData("cpu", domain.PropertyTypeNumber)
+	for _, r := range []struct {
+		host string
+		cpu  any
+	}{{"a", 40.0}, {"b", 95.5}, {"c", nil}} {
+		_ = host.Append(r.host)
+		_ = cpu.Append(r.cpu)
+	}
+	env := expression.Table(domain.TableData{Columns: []domain.ColumnData{host, cpu}})
+	for src, want := range map[string]any{
+		`max(filter(cpu, # != nil))`:          95.5,
+		`len(rows)`:                           3,
+		`any(rows, .cpu != nil && .cpu > 90)`: true,
+		`rows[1].host`:                        "b",
+	} {
+		p, err := expression.Compile(src)
+		if err != nil {
+			t.Fatalf("Compile %s: %v", src, err)
+		}
+		if got, err := p.Run(env); err != nil || got != want {
+			t.Errorf("%s = %v, %v, want %v", src, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package expression
+
+import (
+	"encoding/json"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// Row maps the columns of t to their values at row i: numbers, strings,
+// booleans, times, decoded JSON or nil.
+func Row(t domain.TableData, i int) map[string]any {
+	env := make(map[string]any, len(t.Columns))
+	for _, c := range t.Columns {
+		env[string(c.Name)] = value(c, i)
+	}
+	return env
+}
+
+// Table maps the columns of t to the lists of their values, as Row gives
+// them, and rows to the list of its rows. A column named rows is only
+// reachable through the rows.
+func Table(t domain.TableData) map[string]any {
+	n := t.NumRows()
+	env := make(map[string]any, len(t.Columns)+1)
+	for _, c := range t.Columns {
+		values := make([]any, n)
+		for i := range values {
+			values[i] = value(c, i)
+		}
+		env[string(c.Name)] = values
+	}
+	rows := make([]any, n)
+	for i := range rows {
+		rows[i] = Row(t, i)
+	}
+	env["rows"] = rows
+	return env
+}
+
+func value(c domain.ColumnData, i int) any {
+	switch v := c.Value(i).(type) {
+	case domain.PropertyValue:
+		return string(v)
+	case json.RawMessage:
+		var decoded any
+		if json.Unmarshal(v, &decoded) == nil {
+			return decoded
+		}
+		return string(v)
+	default:
+		return v
+	}
+}
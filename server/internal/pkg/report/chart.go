@@ -0,0 +1,252 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ErrNotChartable is returned for tables that hold nothing to plot.
+var ErrNotChartable = errors.New("nothing to chart")
+
+// Default size of chart images, in pixels.
+const (
+	DefaultChartWidth  = 800
+	DefaultChartHeight = 300
+)
+
+// palette colours series in order, wrapping around.
+var palette = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff},
+}
+
+var (
+	gridColor = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	axisColor = color.RGBA{0x60, 0x60, 0x60, 0xff}
+)
+
+// Chart is the image of a table with what it takes to read it: images
+// carry no text, so the legend and the ranges of the axes are left to the
+// document around them.
+type Chart struct {
+	PNG    []byte
+	Series []Series
+	// Min and Max are the values at the bottom and the top of the plot;
+	// From and To label its left and right ends.
+	Min, Max float64
+	From, To string
+}
+
+// Series is a plotted column and its colour, as #rrggbb.
+type Series struct {
+	Name  string
+	Color string
+}
+
+// RenderChart plots the numeric columns of t against its first column:
+// as lines over time or numbers, and as bars over anything else, one group
+// per row. Null values leave gaps.
+func RenderChart(t domain.TableData, width, height int) (Chart, error) {
+	if len(t.Columns) < 2 || t.NumRows() == 0 {
+		return Chart{}, ErrNotChartable
+	}
+	x := t.Columns[0]
+	var ys []domain.ColumnData
+	for _, c := range t.Columns[1:] {
+		if isNumeric(c.Type) {
+			ys = append(ys, c)
+		}
+	}
+	if len(ys) == 0 {
+		return Chart{}, ErrNotChartable
+	}
+	if width <= 0 || height <= 0 {
+		width, height = DefaultChartWidth, DefaultChartHeight
+	}
+
+	ch := Chart{Min: math.Inf(1), Max: math.Inf(-1)}
+	for i, c := range ys {
+		ch.Series = append(ch.Series, Series{Name: columnTitle(c), Color: hex(palette[i%len(palette)])})
+		for row := range c.Len() {
+			if v, ok := number(c, row); ok {
+				ch.Min, ch.Max = math.Min(ch.Min, v), math.Max(ch.Max, v)
+			}
+		}
+	}
+	if math.IsInf(ch.Min, 1) {
+		return Chart{}, ErrNotChartable
+	}
+	// Bars and most readers expect zero in sight.
+	ch.Min, ch.Max = math.Min(ch.Min, 0), math.Max(ch.Max, 0)
+	if ch.Min == ch.Max {
+		ch.Max = ch.Min + 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	const margin = 10
+	plot := image.Rect(margin, margin, width-margin, height-margin)
+	for i := 0; i <= 4; i++ {
+		y := plot.Max.Y - i*plot.Dy()/4
+		line(img, plot.Min.X, y, plot.Max.X, y, gridColor, 1)
+	}
+	toY := func(v float64) int {
+		return plot.Max.Y - int(math.Round((v-ch.Min)/(ch.Max-ch.Min)*float64(plot.Dy())))
+	}
+
+	rows := t.NumRows()
+	ch.From, ch.To = x.String(0), x.String(rows-1)
+	if xs, ok := positions(x); ok {
+		lo, hi := xs[0], xs[0]
+		for _, v := range xs {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		if lo == hi {
+			hi = lo + 1
+		}
+		toX := func(v float64) int {
+			return plot.Min.X + int(math.Round((v-lo)/(hi-lo)*float64(plot.Dx())))
+		}
+		for i, c := range ys {
+			col := palette[i%len(palette)]
+			prev, havePrev := image.Point{}, false
+			for row := range rows {
+				v, ok := number(c, row)
+				if !ok || x.IsNull(row) {
+					havePrev = false
+					continue
+				}
+				p := image.Pt(toX(xs[row]), toY(v))
+				if havePrev {
+					line(img, prev.X, prev.Y, p.X, p.Y, col, 2)
+				} else {
+					line(img, p.X, p.Y, p.X, p.Y, col, 2)
+				}
+				prev, havePrev = p, true
+			}
+		}
+	} else {
+		slot := float64(plot.Dx()) / float64(rows)
+		bar := math.Max(1, slot*0.8/float64(len(ys)))
+		zero := toY(0)
+		for row := range rows {
+			left := float64(plot.Min.X) + float64(row)*slot + slot*0.1
+			for i, c := range ys {
+				v, ok := number(c, row)
+				if !ok {
+					continue
+				}
+				x0 := int(left + float64(i)*bar)
+				r := image.Rect(x0, min(zero, toY(v)), x0+max(1, int(bar)), max(zero, toY(v))+1)
+				draw.Draw(img, r, image.NewUniform(palette[i%len(palette)]), image.Point{}, draw.Src)
+			}
+		}
+	}
+	line(img, plot.Min.X, plot.Min.Y, plot.Min.X, plot.Max.Y, axisColor, 1)
+	line(img, plot.Min.X, toY(0), plot.Max.X, toY(0), axisColor, 1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Chart{}, err
+	}
+	ch.PNG = buf.Bytes()
+	return ch, nil
+}
+
+func isNumeric(t domain.PropertyType) bool {
+	return t == domain.PropertyTypeInteger || t == domain.PropertyTypeNumber
+}
+
+// number reads row of a numeric column, reporting false for nulls and
+// values that cannot be plotted.
+func number(c domain.ColumnData, row int) (float64, bool) {
+	switch v := c.Value(row).(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+	return 0, false
+}
+
+// positions places the rows of x on a continuous axis, reporting false
+// for columns that are better shown as categories.
+func positions(x domain.ColumnData) ([]float64, bool) {
+	if x.Type != domain.PropertyTypeTime && !isNumeric(x.Type) {
+		return nil, false
+	}
+	out := make([]float64, x.Len())
+	for row := range out {
+		switch v := x.Value(row).(type) {
+		case time.Time:
+			out[row] = float64(v.UnixMilli())
+		case nil:
+		default:
+			out[row], _ = number(x, row)
+		}
+	}
+	return out, true
+}
+
+// line draws from (x0, y0) to (x1, y1) with Bresenham's algorithm, in
+// squares of width pixels.
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA, width int) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	e := dx + dy
+	for {
+		for i := range width {
+			for j := range width {
+				img.SetRGBA(x0+i, y0+j, c)
+			}
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
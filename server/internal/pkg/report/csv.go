@@ -0,0 +1,40 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// CSV writes t with a header of its column titles. Nulls are empty fields
+// and times are in RFC 3339.
+func CSV(t domain.TableData) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	record := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		record[i] = columnTitle(c)
+	}
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	for row := range t.NumRows() {
+		for i, c := range t.Columns {
+			record[i] = c.String(row)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// columnTitle is the display name of c, or its name.
+func columnTitle(c domain.ColumnData) string {
+	if c.Meta != nil && c.Meta.DisplayName != "" {
+		return string(c.Meta.DisplayName)
+	}
+	return string(c.Name)
+}
@@ -0,0 +1,126 @@
+// Package report renders the results of dashboard components for people
+// who do not open the dashboard: tables as HTML or CSV, charts as PNG
+// images, and documents gathering them for delivery by mail or webhook.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// MaxHTMLRows bounds the rows of a table shown in a document; the rest are
+// only counted.
+const MaxHTMLRows = 500
+
+// Document is a titled list of sections, one per component.
+type Document struct {
+	Title string
+	// Subtitle says what the document covers, e.g. its time range.
+	Subtitle string
+	Sections []Section
+}
+
+// Section shows one result: a chart, whose image is at ChartSrc, a table
+// inline, or a table in the attachment named Attachment. Error replaces
+// the result of a component that failed.
+type Section struct {
+	Name       string
+	Table      *domain.TableData
+	Chart      *Chart
+	ChartSrc   string
+	Attachment string
+	Error      string
+}
+
+var documentTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"title": columnTitle,
+	"rows":  func(t *domain.TableData) int { return t.NumRows() },
+	"shown": shownRows,
+	// chart sources are set by the caller, e.g. to cid: URLs, which
+	// html/template would not let through
+	"src": func(s string) template.URL { return template.URL(s) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #212121;">
+<h1 style="font-size: 20px;">{{.Title}}</h1>
+{{- with .Subtitle}}
+<p style="color: #757575;">{{.}}</p>
+{{- end}}
+{{- range .Sections}}
+<h2 style="font-size: 16px; margin-top: 24px;">{{.Name}}</h2>
+{{- if .Error}}
+<p style="color: #d32f2f;">Failed: {{.Error}}</p>
+{{- else if .Chart}}
+<img src="{{src .ChartSrc}}" alt="{{.Name}}" style="max-width: 100%;">
+<p style="font-size: 12px; color: #757575;">
+{{- range .Chart.Series}}<span style="color: {{.Color}};">&#9632;</span> {{.Name}} {{end}}<br>
+{{.Chart.From}} &ndash; {{.Chart.To}}, values from {{printf "%g" .Chart.Min}} to {{printf "%g" .Chart.Max}}</p>
+{{- else if .Attachment}}
+<p>{{rows .Table}} rows, attached as {{.Attachment}}.</p>
+{{- else if .Table}}
+<table style="border-collapse: collapse; font-size: 13px;">
+<tr>{{range .Table.Columns}}<th style="border: 1px solid #e0e0e0; padding: 4px 8px; background: #f5f5f5; text-align: left;">{{title .}}</th>{{end}}</tr>
+{{- $t := .Table}}
+{{- range $row := shown $t}}
+<tr>{{range $t.Columns}}<td style="border: 1px solid #e0e0e0; padding: 4px 8px;">{{.String $row}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- if gt (rows $t) (len (shown $t))}}
+<p style="color: #757575;">Showing {{len (shown $t)}} of {{rows $t}} rows.</p>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// shownRows lists the indexes of the rows of t a document shows.
+func shownRows(t *domain.TableData) []int {
+	out := make([]int, min(t.NumRows(), MaxHTMLRows))
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}
+
+// HTML renders d as a standalone page with inline styles, as mail clients
+// ignore style sheets.
+func HTML(d Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := documentTemplate.Execute(&buf, d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Text sums up d in plain text, for readers that do not render HTML.
+func Text(d Document) string {
+	var b strings.Builder
+	b.WriteString(d.Title)
+	if d.Subtitle != "" {
+		fmt.Fprintf(&b, "\n%s", d.Subtitle)
+	}
+	for _, s := range d.Sections {
+		fmt.Fprintf(&b, "\n- %s: ", s.Name)
+		switch {
+		case s.Error != "":
+			fmt.Fprintf(&b, "failed: %s", s.Error)
+		case s.Chart != nil:
+			b.WriteString("chart")
+			if s.Attachment != "" {
+				fmt.Fprintf(&b, " attached as %s", s.Attachment)
+			}
+		case s.Table != nil:
+			fmt.Fprintf(&b, "%d rows", s.Table.NumRows())
+			if s.Attachment != "" {
+				fmt.Fprintf(&b, " attached as %s", s.Attachment)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package report_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/report"
+)
+
+func column(t *testing.T, name domain.Name, typ domain.PropertyType, values ...any) domain.ColumnData {
+	t.Helper()
+	c := domain.NewColumnData(name, typ)
+	for _, v := range values {
+		if v == nil {
+			c.AppendNull()
+			continue
+		}
+		if err := c.Append(v); err != nil {
+			t.Fatalf("append %v: %v", v, err)
+		}
+	}
+	return c
+}
+
+func sales(t *testing.T) domain.TableData {
+	t0 := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	table := domain.TableData{Columns: []domain.ColumnData{
+		column(t, "day", domain.PropertyTypeTime, t0, t0.Add(24*time.Hour), t0.Add(48*time.Hour)),
+		column(t, "orders", domain.PropertyTypeInteger, int64(12), int64(30), nil),
+		column(t, "revenue", domain.PropertyTypeNumber, 120.5, 310.0, 95.25),
+	}}
+	table.ApplyColumnMeta(map[domain.Name]domain.ColumnMeta{"revenue": {DisplayName: "Revenue, €"}})
+	return table
+}
+
+func TestCSV(t *testing.T) {
+	got, err := report.CSV(sales(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "day,orders,\"Revenue, €\"\n" +
+		"2024-03-11T00:00:00Z,12,120.5\n" +
+		"2024-03-12T00:00:00Z,30,310\n" +
+		"2024-03-13T00:00:00Z,,95.25\n"
+	if string(got) != want {
+		t.Fatalf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderChart(t *testing.T) {
+	ch, err := report.RenderChart(sales(t), 200, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(ch.PNG))
+	if err != nil || img.Bounds().Dx() != 200 || img.Bounds().Dy() != 100 {
+		t.Fatalf("image = %v, %v", img.Bounds(), err)
+	}
+	if len(ch.Series) != 2 || ch.Series[1].Name != "Revenue, €" || ch.Series[0].Color == ch.Series[1].Color {
+		t.Fatalf("series = %+v", ch.Series)
+	}
+	if ch.Min != 0 || ch.Max != 310 || ch.From != "2024-03-11T00:00:00Z" || ch.To != "2024-03-13T00:00:00Z" {
+		t.Fatalf("axes = %v..%v, %s..%s", ch.Min, ch.Max, ch.From, ch.To)
+	}
+
+	// Categories are drawn as bars.
+	bars := domain.TableData{Columns: []domain.ColumnData{
+		column(t, "region", domain.PropertyTypeString, domain.PropertyValue("eu"), domain.PropertyValue("us")),
+		column(t, "orders", domain.PropertyTypeInteger, int64(-3), int64(8)),
+	}}
+	ch, err = report.RenderChart(bars, 0, 0)
+	if err != nil || ch.Min != -3 || ch.Max != 8 {
+		t.Fatalf("bars = %+v, %v", ch, err)
+	}
+	img, _ = png.Decode(bytes.NewReader(ch.PNG))
+	if img.Bounds().Dx() != report.DefaultChartWidth {
+		t.Fatalf("default width = %d", img.Bounds().Dx())
+	}
+
+	for _, table := range []domain.TableData{
+		{},
+		{Columns: []domain.ColumnData{column(t, "orders", domain.PropertyTypeInteger, int64(1))}},
+		{Columns: []domain.ColumnData{
+			column(t, "region", domain.PropertyTypeString, domain.PropertyValue("eu")),
+			column(t, "note", domain.PropertyTypeString, domain.PropertyValue("x")),
+		}},
+		{Columns: []domain.ColumnData{
+			column(t, "region", domain.PropertyTypeString, domain.PropertyValue("eu")),
+			column(t, "orders", domain.PropertyTypeInteger, nil),
+		}},
+	} {
+		if _, err := report.RenderChart(table, 0, 0); !errors.Is(err, report.ErrNotChartable) {
+			t.Errorf("RenderChart(%d columns) error = %v, want ErrNotChartable", len(table.Columns), err)
+		}
+	}
+}
+
+func TestHTMLAndText(t *testing.T) {
+	table := sales(t)
+	ch, err := report.RenderChart(table, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	many := domain.TableData{Columns: []domain.ColumnData{domain.NewColumnData("n", domain.PropertyTypeInteger)}}
+	for i := range report.MaxHTMLRows + 1 {
+		_ = many.Columns[0].Append(int64(i))
+	}
+	doc := report.Document{
+		Title:    "Weekly <sales>",
+		Subtitle: "Last 7 days",
+		Sections: []report.Section{
+			{Name: "Orders", Table: &table},
+			{Name: "Trend", Table: &table, Chart: &ch, ChartSrc: "cid:trend.png"},
+			{Name: "Export", Table: &table, Attachment: "export.csv"},
+			{Name: "All", Table: &many},
+			{Name: "Broken", Error: "no such table"},
+		},
+	}
+	out, err := report.HTML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		"Weekly &lt;sales&gt;",
+		"<th style=\"border: 1px solid #e0e0e0; padding: 4px 8px; background: #f5f5f5; text-align: left;\">Revenue, €</th>",
+		">310</td>",
+		`src="cid:trend.png"`,
+		"Revenue, €",
+		"3 rows, attached as export.csv.",
+		"Showing 500 of 501 rows.",
+		"Failed: no such table",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+
+	want := "Weekly <sales>\nLast 7 days\n- Orders: 3 rows\n- Trend: chart\n- Export: 3 rows attached as export.csv\n" +
+		"- All: 501 rows\n- Broken: failed: no such table"
+	if got := report.Text(doc); got != want {
+		t.Fatalf("Text =\n%s\nwant\n%s", got, want)
+	}
+}
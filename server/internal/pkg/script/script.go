@@ -0,0 +1,446 @@
+// Package script runs JavaScript transformers over TableData in a
+// sandboxed goja runtime. A script is the body of a function of table,
+// which holds columns, [{name, type}], and rows, one object per row keyed
+// by column name, and returns the table to use instead:
+//
+//	return table.rows.filter(r => r.total > 10)
+//
+// It may return an array of rows or an object with rows and, to fix the
+// order and types of the columns, columns. Each run gets a fresh runtime
+// with no I/O: nothing but the ECMAScript built-ins is defined.
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+var (
+	// ErrTimeout is returned when a run exceeds Limits.Time.
+	ErrTimeout = errors.New("script exceeded its time limit")
+	// ErrMemory is returned when a run exceeds Limits.Memory.
+	ErrMemory = errors.New("script exceeded its memory limit")
+)
+
+// Limits bound a run. Time is wall-clock time, which a script spends
+// running, as it cannot wait on anything. Memory bounds what the run
+// allocates: the built-ins that make a string, array or buffer of a size
+// given by their arguments charge the run for it before allocating, so no
+// single call can overshoot, and the run stops once its charges exceed
+// Memory. Growth the charges cannot see, such as objects built in a loop,
+// is caught by sampling the Go heap while the run lasts. The heap is that
+// of the whole process, so the sample is a backstop rather than an account
+// of the run: growth from concurrent runs and requests counts against
+// every run being watched and may stop one early.
+type Limits struct {
+	Time   time.Duration
+	Memory uint64
+}
+
+// DefaultLimits apply to the runs of transformation steps.
+var DefaultLimits = Limits{Time: time.Second, Memory: 64 << 20}
+
+// maxCallStackSize bounds the recursion of a script.
+const maxCallStackSize = 1000
+
+// memoryPoll is how often the heap is sampled during a run.
+const memoryPoll = 5 * time.Millisecond
+
+// Program is a compiled script.
+type Program struct {
+	src     string
+	program *goja.Program
+}
+
+// Compile parses src as the body of a function of table.
+func Compile(src string) (Program, error) {
+	if src == "" {
+		return Program{}, errors.New("script is empty")
+	}
+	p, err := goja.Compile("script", "(function (table) {"+src+"\n})", true)
+	if err != nil {
+		return Program{}, err
+	}
+	return Program{src: src, program: p}, nil
+}
+
+func (p Program) String() string { return p.src }
+
+// Run calls the script with t and converts what it returns back to
+// TableData. The run stops when ctx is done or a limit is exceeded.
+func (p Program) Run(ctx context.Context, t domain.TableData, l Limits) (domain.TableData, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxCallStackSize)
+	in, err := toJS(vm, t)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+
+	if l.Memory > 0 {
+		if err := guard(vm, l.Memory); err != nil {
+			return domain.TableData{}, err
+		}
+	}
+
+	// The watch starts once the input is built, which the limits are not
+	// meant to account for.
+	stop := make(chan struct{})
+	defer close(stop)
+	go watch(ctx, vm, l, stop)
+
+	fn, err := vm.RunProgram(p.program)
+	if err != nil {
+		return domain.TableData{}, runError(err)
+	}
+	call, ok := goja.AssertFunction(fn)
+	if !ok {
+		return domain.TableData{}, errors.New("script is not a function")
+	}
+	out, err := call(goja.Undefined(), in)
+	if err != nil {
+		return domain.TableData{}, runError(err)
+	}
+	table, err := fromJS(vm, out)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	table.Truncated = t.Truncated
+	return table, nil
+}
+
+// watch interrupts vm when ctx is done or a limit is exceeded, until stop
+// is closed.
+func watch(ctx context.Context, vm *goja.Runtime, l Limits, stop <-chan struct{}) {
+	var deadline <-chan time.Time
+	if l.Time > 0 {
+		timer := time.NewTimer(l.Time)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var poll <-chan time.Time
+	var base uint64
+	if l.Memory > 0 {
+		ticker := time.NewTicker(memoryPoll)
+		defer ticker.Stop()
+		poll, base = ticker.C, heapBytes()
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			vm.Interrupt(ctx.Err())
+			return
+		case <-deadline:
+			vm.Interrupt(ErrTimeout)
+			return
+		case <-poll:
+			if n := heapBytes(); n > base && n-base > l.Memory {
+				vm.Interrupt(ErrMemory)
+				return
+			}
+		}
+	}
+}
+
+// guardProgram wraps the built-ins that allocate in proportion to their
+// arguments so that they call charge with the bytes they are about to
+// take: two a character for strings and sixteen a slot for arrays, the
+// most goja uses. It keeps its own references to what it calls, so that
+// scripts replacing globals cannot reach the unwrapped built-ins.
+var guardProgram = goja.MustCompile("guard", `(function (charge) {
+	"use strict";
+	const apply = Reflect.apply, construct = Reflect.construct, get = Reflect.get, define = Object.defineProperty;
+	const str = String, global = globalThis, hasInstance = Symbol.hasInstance;
+	const wrap = (obj, name, size) => {
+		const f = obj[name];
+		const wrapped = {[name](...args) { charge(size(this, args)); return apply(f, this, args); }}[name];
+		define(obj, name, {value: wrapped, writable: true, configurable: true});
+	};
+	wrap(String.prototype, "repeat", (s, [n]) => str(s).length * n * 2);
+	wrap(String.prototype, "padStart", (s, [n]) => n * 2);
+	wrap(String.prototype, "padEnd", (s, [n]) => n * 2);
+	wrap(Array.prototype, "join", (a, [sep]) => a.length * (sep === undefined ? 1 : str(sep).length) * 2);
+	wrap(Array.prototype, "fill", (a) => a.length * 16);
+	wrap(Array, "from", (_, [src]) => (src != null && src.length) * 16);
+	for (const name of ["ArrayBuffer", "SharedArrayBuffer", "Int8Array", "Uint8Array", "Uint8ClampedArray",
+		"Int16Array", "Uint16Array", "Int32Array", "Uint32Array", "Float32Array", "Float64Array",
+		"BigInt64Array", "BigUint64Array"]) {
+		const C = global[name];
+		if (C === undefined) {
+			continue;
+		}
+		const unit = C.BYTES_PER_ELEMENT || 1;
+		// goja's instanceof rejects proxies unless they answer it themselves
+		const isInstance = (v) => v instanceof C;
+		const guarded = new Proxy(C, {
+			construct: (target, args, newTarget) => {
+				charge(typeof args[0] === "number" ? args[0] * unit : 0);
+				return construct(target, args, newTarget);
+			},
+			get: (target, key, receiver) => key === hasInstance ? isInstance : get(target, key, receiver),
+		});
+		global[name] = guarded;
+		// instances would otherwise lead back to C through their prototype
+		define(C.prototype, "constructor", {value: guarded, writable: true, configurable: true});
+	}
+})`, true)
+
+// guard makes vm charge its allocations against limit, interrupting it
+// with ErrMemory once they exceed it. The interrupt takes effect before
+// the charged built-in runs.
+func guard(vm *goja.Runtime, limit uint64) error {
+	fn, err := vm.RunProgram(guardProgram)
+	if err != nil {
+		return err
+	}
+	install, _ := goja.AssertFunction(fn)
+	var used float64
+	charge := func(bytes float64) {
+		if bytes > 0 {
+			if used += bytes; used > float64(limit) {
+				vm.Interrupt(ErrMemory)
+			}
+		}
+	}
+	_, err = install(goja.Undefined(), vm.ToValue(charge))
+	return err
+}
+
+// heapBytes is the memory occupied by live and not yet swept heap objects
+// of the process. Tests replace it.
+var heapBytes = func() uint64 {
+	s := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(s)
+	if s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s[0].Value.Uint64()
+}
+
+// runError unwraps the reason of an interrupt, so that callers can match
+// ErrTimeout, ErrMemory and context errors.
+func runError(err error) error {
+	var ie *goja.InterruptedError
+	if errors.As(err, &ie) {
+		if reason, ok := ie.Value().(error); ok {
+			return reason
+		}
+	}
+	return err
+}
+
+// toJS builds the table argument as native JavaScript objects, so that
+// scripts see ordinary arrays, objects and Dates.
+func toJS(vm *goja.Runtime, t domain.TableData) (goja.Value, error) {
+	date, _ := goja.AssertConstructor(vm.Get("Date"))
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	columns := make([]any, len(t.Columns))
+	for i, c := range t.Columns {
+		col := vm.NewObject()
+		_ = col.Set("name", string(c.Name))
+		_ = col.Set("type", string(c.Type))
+		columns[i] = col
+	}
+	rows := make([]any, t.NumRows())
+	for i := range rows {
+		row := vm.NewObject()
+		for _, c := range t.Columns {
+			var v any
+			switch x := c.Value(i).(type) {
+			case domain.PropertyValue:
+				v = string(x)
+			case time.Time:
+				d, err := date(nil, vm.ToValue(x.UnixMilli()))
+				if err != nil {
+					return nil, err
+				}
+				v = d
+			case json.RawMessage:
+				parsed, err := parse(goja.Undefined(), vm.ToValue(string(x)))
+				if err != nil {
+					v = string(x)
+				} else {
+					v = parsed
+				}
+			default:
+				v = x
+			}
+			_ = row.Set(string(c.Name), v)
+		}
+		rows[i] = row
+	}
+	table := vm.NewObject()
+	_ = table.Set("columns", vm.NewArray(columns...))
+	_ = table.Set("rows", vm.NewArray(rows...))
+	return table, nil
+}
+
+// fromJS converts what a script returned: an array of rows or an object
+// with rows and optional columns, each a name or {name, type}. Columns
+// without a type take that of their values.
+func fromJS(vm *goja.Runtime, v goja.Value) (domain.TableData, error) {
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return domain.TableData{}, errors.New("script returned no table")
+	}
+	obj := v.ToObject(vm)
+	rowsVal := v
+	var declared []domain.ColumnData
+	if obj.ClassName() != "Array" {
+		rowsVal = obj.Get("rows")
+		if rowsVal == nil || goja.IsUndefined(rowsVal) {
+			return domain.TableData{}, errors.New("script returned neither rows nor an object with rows")
+		}
+		if cols := obj.Get("columns"); cols != nil && !goja.IsUndefined(cols) {
+			var err error
+			if declared, err = declaredColumns(vm, cols); err != nil {
+				return domain.TableData{}, err
+			}
+		}
+	}
+	rowsObj := rowsVal.ToObject(vm)
+	if rowsObj.ClassName() != "Array" {
+		return domain.TableData{}, errors.New("rows is not an array")
+	}
+	n := int(rowsObj.Get("length").ToInteger())
+	rows := make([]*goja.Object, n)
+	for i := range rows {
+		r, ok := rowsObj.Get(fmt.Sprint(i)).(*goja.Object)
+		if !ok {
+			return domain.TableData{}, fmt.Errorf("row %d is not an object", i)
+		}
+		rows[i] = r
+	}
+	if declared == nil {
+		seen := make(map[string]bool)
+		for _, r := range rows {
+			for _, k := range r.Keys() {
+				if !seen[k] {
+					seen[k] = true
+					declared = append(declared, domain.ColumnData{Name: domain.Name(k)})
+				}
+			}
+		}
+	}
+
+	out := domain.TableData{Columns: make([]domain.ColumnData, len(declared))}
+	for i, d := range declared {
+		values := make([]any, n)
+		for j, r := range rows {
+			values[j] = export(r.Get(string(d.Name)))
+		}
+		typ := d.Type
+		if typ == "" {
+			var err error
+			if typ, err = typeOf(values); err != nil {
+				return domain.TableData{}, fmt.Errorf("column %s: %w", d.Name, err)
+			}
+		}
+		col := domain.NewColumnData(d.Name, typ)
+		for j, v := range values {
+			if err := col.Append(v); err != nil {
+				return domain.TableData{}, fmt.Errorf("column %s, row %d: %w", d.Name, j, err)
+			}
+		}
+		out.Columns[i] = col
+	}
+	return out, nil
+}
+
+func declaredColumns(vm *goja.Runtime, v goja.Value) ([]domain.ColumnData, error) {
+	var raw []any
+	if err := vm.ExportTo(v, &raw); err != nil {
+		return nil, fmt.Errorf("columns: %w", err)
+	}
+	out := make([]domain.ColumnData, 0, len(raw))
+	for _, c := range raw {
+		switch c := c.(type) {
+		case string:
+			out = append(out, domain.ColumnData{Name: domain.Name(c)})
+		case map[string]any:
+			name, _ := c["name"].(string)
+			typ, _ := c["type"].(string)
+			if name == "" {
+				return nil, errors.New("columns: every column needs a name")
+			}
+			t := domain.PropertyType(typ)
+			switch t {
+			case "", domain.PropertyTypeString, domain.PropertyTypeNumber, domain.PropertyTypeInteger,
+				domain.PropertyTypeBoolean, domain.PropertyTypeTime, domain.PropertyTypeJSON:
+			default:
+				return nil, fmt.Errorf("columns: %s has unknown type %q", name, typ)
+			}
+			out = append(out, domain.ColumnData{Name: domain.Name(name), Type: t})
+		default:
+			return nil, errors.New("columns: each column is a name or {name, type}")
+		}
+	}
+	return out, nil
+}
+
+// export converts a JavaScript value to a Go value Append accepts.
+func export(v goja.Value) any {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	switch x := v.Export().(type) {
+	case int64, float64, bool, string, time.Time:
+		return x
+	case nil:
+		return nil
+	default:
+		raw, err := json.Marshal(x)
+		if err != nil {
+			return v.String()
+		}
+		return json.RawMessage(raw)
+	}
+}
+
+// typeOf returns the column type holding values: integer, number when
+// integers and floats mix, and string when every value is null.
+func typeOf(values []any) (domain.PropertyType, error) {
+	var typ domain.PropertyType
+	for _, v := range values {
+		var t domain.PropertyType
+		switch v.(type) {
+		case nil:
+			continue
+		case int64:
+			t = domain.PropertyTypeInteger
+		case float64:
+			t = domain.PropertyTypeNumber
+		case bool:
+			t = domain.PropertyTypeBoolean
+		case string:
+			t = domain.PropertyTypeString
+		case time.Time:
+			t = domain.PropertyTypeTime
+		default:
+			t = domain.PropertyTypeJSON
+		}
+		numeric := func(t domain.PropertyType) bool {
+			return t == domain.PropertyTypeInteger || t == domain.PropertyTypeNumber
+		}
+		switch {
+		case typ == "" || typ == t:
+			typ = t
+		case numeric(typ) && numeric(t):
+			typ = domain.PropertyTypeNumber
+		default:
+			return "", fmt.Errorf("holds both %s and %s values", typ, t)
+		}
+	}
+	if typ == "" {
+		typ = domain.PropertyTypeString
+	}
+	return typ, nil
+}
@@ -0,0 +1,125 @@
+package script_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/script"
+)
+
+func newTable(t *testing.T) domain.TableData {
+	t.Helper()
+	host := domain.NewColumnData("host", domain.PropertyTypeString)
+	cpu := domain.NewColumnData("cpu", domain.PropertyTypeInteger)
+	at := domain.NewColumnData("at", domain.PropertyTypeTime)
+	at0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, r := range []struct {
+		host string
+		cpu  any
+	}{{"a", int64(10)}, {"b", int64(95)}, {"c", nil}} {
+		if err := host.Append(r.host); err != nil {
+			t.Fatal(err)
+		}
+		if err := cpu.Append(r.cpu); err != nil {
+			t.Fatal(err)
+		}
+		if err := at.Append(at0.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return domain.TableData{Columns: []domain.ColumnData{host, cpu, at}, Truncated: true}
+}
+
+func run(t *testing.T, src string, l script.Limits) (domain.TableData, error) {
+	t.Helper()
+	p, err := script.Compile(src)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return p.Run(context.Background(), newTable(t), l)
+}
+
+func TestRun(t *testing.T) {
+	out, err := run(t, `
+		return table.rows
+			.filter(r => r.cpu !== null)
+			.map(r => ({host: r.host.toUpperCase(), load: r.cpu / 100, minute: r.at.getUTCMinutes(), tags: [r.host]}))
+	`, script.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(out.Columns) != 4 || out.NumRows() != 2 || !out.Truncated {
+		t.Fatalf("out = %+v", out)
+	}
+	host, load, minute, tags := out.Columns[0], out.Columns[1], out.Columns[2], out.Columns[3]
+	if host.Values[1] != "B" || load.Type != domain.PropertyTypeNumber || load.Floats[1] != 0.95 {
+		t.Fatalf("host = %v, load = %+v", host.Values, load)
+	}
+	if minute.Type != domain.PropertyTypeInteger || minute.Ints[1] != 5 {
+		t.Fatalf("minute = %+v", minute)
+	}
+	if tags.Type != domain.PropertyTypeJSON || string(tags.JSON[0]) != `["a"]` {
+		t.Fatalf("tags = %+v", tags)
+	}
+
+	// declared columns fix order and type; the input passes through as is
+	out, err = run(t, `return {columns: [{name: "cpu", type: "number"}, "host"], rows: table.rows}`, script.DefaultLimits)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out.Columns[0].Type != domain.PropertyTypeNumber || out.Columns[0].Floats[1] != 95 || !out.Columns[0].IsNull(2) || out.Columns[1].Name != "host" {
+		t.Fatalf("out = %+v", out)
+	}
+	out, err = run(t, `return table`, script.DefaultLimits)
+	if err != nil || out.Columns[2].Type != domain.PropertyTypeTime || !out.Columns[2].Times[1].Equal(newTable(t).Columns[2].Times[1]) {
+		t.Fatalf("identity: out = %+v, err = %v", out, err)
+	}
+}
+
+func TestLimitsAndErrors(t *testing.T) {
+	if _, err := run(t, `for (;;) {}`, script.Limits{Time: 50 * time.Millisecond}); !errors.Is(err, script.ErrTimeout) {
+		t.Fatalf("loop: err = %v, want ErrTimeout", err)
+	}
+	_, err := run(t, `const a = []; for (;;) { a.push("x".repeat(1024)) }`, script.Limits{Time: 10 * time.Second, Memory: 8 << 20})
+	if !errors.Is(err, script.ErrMemory) {
+		t.Fatalf("allocation: err = %v, want ErrMemory", err)
+	}
+	// a single call asking for more than the limit fails before it allocates
+	for _, src := range []string{
+		`return [{s: "x".repeat(4e8)}]`,
+		`return [{s: "".padEnd(4e8, "x")}]`,
+		`return [{n: new Float64Array(1e8).length}]`,
+		`return [{s: new Array(4e8).join("x")}]`,
+		// constructors reached through instances are guarded too
+		`return [{n: new (new Uint8Array(1).constructor)(4e8).length}]`,
+		`return [{n: new Uint8Array.prototype.constructor(4e8).length}]`,
+		`return [{n: new (Object.getPrototypeOf(new ArrayBuffer(1)).constructor)(4e8).byteLength}]`,
+		`return [{n: new Float64Array(1).subarray(0).constructor.from({length: 1e8}).length}]`,
+	} {
+		if _, err := run(t, src, script.Limits{Time: 10 * time.Second, Memory: 64 << 20}); !errors.Is(err, script.ErrMemory) {
+			t.Fatalf("%s: err = %v, want ErrMemory", src, err)
+		}
+	}
+	table, err := run(t, `return [{s: "ab".repeat(2).padStart(6, "-"), b: new Uint8Array(4) instanceof Uint8Array}]`, script.DefaultLimits)
+	if err != nil || table.Columns[0].Values[0] != "--abab" || !table.Columns[1].Bools[0] {
+		t.Fatalf("guarded built-ins: %v, %+v", err, table)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p, _ := script.Compile(`for (;;) {}`)
+	if _, err := p.Run(ctx, newTable(t), script.Limits{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled: err = %v", err)
+	}
+	for _, src := range []string{`return 1`, `return {rows: [1]}`, `return [{a: 1}, {a: "x"}]`, `throw new Error("boom")`, `return require("fs")`} {
+		if _, err := run(t, src, script.DefaultLimits); err == nil {
+			t.Errorf("%s: no error", src)
+		}
+	}
+	if _, err := script.Compile(`return (`); err == nil {
+		t.Fatal("Compile: no error for a syntax error")
+	}
+}
@@ -0,0 +1,30 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// The heap sample sees the whole process: growth from elsewhere stops a
+// run that allocates nothing itself.
+func TestWatchSamplesTheProcessHeap(t *testing.T) {
+	var heap atomic.Uint64
+	heap.Store(1 << 30)
+	sample := heapBytes
+	t.Cleanup(func() { heapBytes = sample })
+	heapBytes = func() uint64 { return heap.Add(1 << 20) }
+
+	p, err := Compile(`for (;;) {}`)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	_, err = p.Run(context.Background(), domain.TableData{}, Limits{Time: 10 * time.Second, Memory: 8 << 20})
+	if !errors.Is(err, ErrMemory) {
+		t.Fatalf("err = %v, want ErrMemory", err)
+	}
+}
@@ -0,0 +1,171 @@
+// Package tablearrow encodes TableData as an Apache Arrow IPC stream.
+package tablearrow
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ContentType is the media type of the Arrow IPC streaming format.
+const ContentType = "application/vnd.apache.arrow.stream"
+
+// Field metadata keys. Arrow strings cannot tell JSON documents apart, so
+// the TableData column type travels alongside the ColumnMeta fields.
+const (
+	MetaType        = "refana.type"
+	MetaUnit        = "refana.unit"
+	MetaDecimals    = "refana.decimals"
+	MetaDisplayName = "refana.displayName"
+	MetaDescription = "refana.description"
+	MetaPath        = "refana.path"
+)
+
+var ErrSchemaMismatch = errors.New("batch columns differ from the stream schema")
+
+// Writer writes TableData batches as Arrow record batches. The first batch
+// fixes the schema; later batches must have the same columns. A batch
+// whose column types differ, as when a streaming class widens a column,
+// ends the stream and starts another with its schema, so readers of such
+// results read streams until the body ends.
+type Writer struct {
+	out    io.Writer
+	mem    memory.Allocator
+	schema *arrow.Schema
+	types  []domain.PropertyType
+	ipc    *ipc.Writer
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out, mem: memory.DefaultAllocator}
+}
+
+func (w *Writer) Write(batch domain.TableData) error {
+	if w.ipc != nil {
+		if len(batch.Columns) != len(w.types) {
+			return ErrSchemaMismatch
+		}
+		for i, col := range batch.Columns {
+			if normalizeType(col.Type) != w.types[i] {
+				if err := w.ipc.Close(); err != nil {
+					return err
+				}
+				w.ipc = nil
+				break
+			}
+		}
+	}
+	if w.ipc == nil {
+		w.schema, w.types = schemaOf(batch)
+		w.ipc = ipc.NewWriter(w.out, ipc.WithSchema(w.schema), ipc.WithAllocator(w.mem))
+	}
+
+	b := array.NewRecordBuilder(w.mem, w.schema)
+	defer b.Release()
+	for i, col := range batch.Columns {
+		appendColumn(b.Field(i), col)
+	}
+	rec := b.NewRecordBatch()
+	defer rec.Release()
+	return w.ipc.Write(rec)
+}
+
+// Close ends the stream. A stream that never saw a batch still carries an
+// empty schema so readers do not fail.
+func (w *Writer) Close() error {
+	if w.ipc == nil {
+		w.schema = arrow.NewSchema(nil, nil)
+		w.ipc = ipc.NewWriter(w.out, ipc.WithSchema(w.schema), ipc.WithAllocator(w.mem))
+	}
+	return w.ipc.Close()
+}
+
+func schemaOf(t domain.TableData) (*arrow.Schema, []domain.PropertyType) {
+	fields := make([]arrow.Field, len(t.Columns))
+	types := make([]domain.PropertyType, len(t.Columns))
+	for i, col := range t.Columns {
+		types[i] = normalizeType(col.Type)
+		fields[i] = arrow.Field{
+			Name:     string(col.Name),
+			Type:     arrowType(types[i]),
+			Nullable: true,
+			Metadata: fieldMetadata(types[i], col.Meta),
+		}
+	}
+	return arrow.NewSchema(fields, nil), types
+}
+
+func normalizeType(t domain.PropertyType) domain.PropertyType {
+	switch t {
+	case domain.PropertyTypeInteger, domain.PropertyTypeNumber, domain.PropertyTypeBoolean,
+		domain.PropertyTypeTime, domain.PropertyTypeJSON:
+		return t
+	}
+	return domain.PropertyTypeString
+}
+
+func arrowType(t domain.PropertyType) arrow.DataType {
+	switch t {
+	case domain.PropertyTypeInteger:
+		return arrow.PrimitiveTypes.Int64
+	case domain.PropertyTypeNumber:
+		return arrow.PrimitiveTypes.Float64
+	case domain.PropertyTypeBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case domain.PropertyTypeTime:
+		// Arrow keeps one zone per column; instants are preserved in UTC.
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	}
+	return arrow.BinaryTypes.String
+}
+
+func fieldMetadata(t domain.PropertyType, meta *domain.ColumnMeta) arrow.Metadata {
+	keys := []string{MetaType}
+	values := []string{string(t)}
+	if meta != nil {
+		add := func(k, v string) {
+			if v != "" {
+				keys = append(keys, k)
+				values = append(values, v)
+			}
+		}
+		add(MetaUnit, meta.Unit)
+		if meta.Decimals != nil {
+			add(MetaDecimals, strconv.Itoa(int(*meta.Decimals)))
+		}
+		add(MetaDisplayName, string(meta.DisplayName))
+		add(MetaDescription, meta.Description)
+		add(MetaPath, meta.Path)
+	}
+	return arrow.NewMetadata(keys, values)
+}
+
+func appendColumn(b array.Builder, col domain.ColumnData) {
+	n := col.Len()
+	b.Reserve(n)
+	for i := 0; i < n; i++ {
+		if col.IsNull(i) {
+			b.AppendNull()
+			continue
+		}
+		switch bb := b.(type) {
+		case *array.Int64Builder:
+			bb.Append(col.Ints[i])
+		case *array.Float64Builder:
+			bb.Append(col.Floats[i])
+		case *array.BooleanBuilder:
+			bb.Append(col.Bools[i])
+		case *array.TimestampBuilder:
+			bb.Append(arrow.Timestamp(col.Times[i].UnixMicro()))
+		case *array.StringBuilder:
+			bb.Append(col.String(i))
+		}
+	}
+}
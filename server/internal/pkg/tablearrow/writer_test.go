@@ -0,0 +1,154 @@
+package tablearrow_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tablearrow"
+)
+
+func TestWriterEncodesTypedBatches(t *testing.T) {
+	var buf bytes.Buffer
+	w := tablearrow.NewWriter(&buf)
+
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("KST", 9*60*60))
+	if err := w.Write(batch(t, []any{int64(1), nil}, []any{at, nil}, []any{`{"a":1}`, "x"})); err != nil {
+		t.Fatalf("Write first: %v", err)
+	}
+	if err := w.Write(batch(t, []any{int64(3)}, []any{at}, []any{nil})); err != nil {
+		t.Fatalf("Write second: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	defer r.Release()
+
+	schema := r.Schema()
+	if got := schema.Field(0).Type.ID(); got != arrow.INT64 {
+		t.Fatalf("n type = %s, want int64", got)
+	}
+	if got := schema.Field(1).Type.ID(); got != arrow.TIMESTAMP {
+		t.Fatalf("at type = %s, want timestamp", got)
+	}
+	if v, _ := schema.Field(2).Metadata.GetValue(tablearrow.MetaType); v != "json" {
+		t.Fatalf("doc refana.type = %q, want json", v)
+	}
+	if v, _ := schema.Field(0).Metadata.GetValue(tablearrow.MetaUnit); v != "short" {
+		t.Fatalf("n refana.unit = %q, want short", v)
+	}
+
+	var rows int
+	for r.Next() {
+		rec := r.RecordBatch()
+		if rows == 0 {
+			n := rec.Column(0).(*array.Int64)
+			if n.Value(0) != 1 || !n.IsNull(1) {
+				t.Fatalf("first batch n = %v", n)
+			}
+			ts := rec.Column(1).(*array.Timestamp)
+			if got := ts.Value(0).ToTime(arrow.Microsecond); !got.Equal(at) {
+				t.Fatalf("at = %v, want %v", got, at)
+			}
+		}
+		rows += int(rec.NumRows())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if rows != 3 {
+		t.Fatalf("rows = %d, want 3", rows)
+	}
+}
+
+func TestWriterRejectsSchemaChange(t *testing.T) {
+	w := tablearrow.NewWriter(&bytes.Buffer{})
+	if err := w.Write(batch(t, []any{int64(1)}, []any{nil}, []any{nil})); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	other := domain.TableData{Columns: []domain.ColumnData{domain.NewColumnData("n", domain.PropertyTypeString)}}
+	if err := w.Write(other); !errors.Is(err, tablearrow.ErrSchemaMismatch) {
+		t.Fatalf("err = %v, want ErrSchemaMismatch", err)
+	}
+}
+
+func TestWriterStartsStreamOnWidenedColumn(t *testing.T) {
+	var buf bytes.Buffer
+	w := tablearrow.NewWriter(&buf)
+	if err := w.Write(batch(t, []any{int64(1)}, []any{nil}, []any{nil})); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	widened := batch(t, nil, []any{nil}, []any{nil})
+	widened.Columns[0] = domain.NewColumnData("n", domain.PropertyTypeString)
+	if err := widened.Columns[0].Append("n/a"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := w.Write(widened); err != nil {
+		t.Fatalf("Write widened: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, want := range []arrow.Type{arrow.INT64, arrow.STRING} {
+		r, err := ipc.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		if got := r.Schema().Field(0).Type.ID(); got != want {
+			t.Fatalf("n type = %s, want %s", got, want)
+		}
+		if !r.Next() || r.RecordBatch().NumRows() != 1 || r.Next() {
+			t.Fatalf("stream of %s does not hold one batch of one row: %v", want, r.Err())
+		}
+		r.Release()
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d bytes after the second stream", buf.Len())
+	}
+}
+
+func TestWriterEmptyStream(t *testing.T) {
+	var buf bytes.Buffer
+	if err := tablearrow.NewWriter(&buf).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	defer r.Release()
+	if r.Next() {
+		t.Fatalf("empty stream yielded a record")
+	}
+}
+
+func batch(t *testing.T, ns, ats, docs []any) domain.TableData {
+	t.Helper()
+	n := domain.NewColumnData("n", domain.PropertyTypeInteger)
+	n.Meta = &domain.ColumnMeta{Unit: "short"}
+	at := domain.NewColumnData("at", domain.PropertyTypeTime)
+	doc := domain.NewColumnData("doc", domain.PropertyTypeJSON)
+	for _, pair := range []struct {
+		col    *domain.ColumnData
+		values []any
+	}{{&n, ns}, {&at, ats}, {&doc, docs}} {
+		for _, v := range pair.values {
+			if err := pair.col.Append(v); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+	}
+	return domain.TableData{Columns: []domain.ColumnData{n, at, doc}}
+}
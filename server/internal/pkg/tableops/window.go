@@ -0,0 +1,255 @@
+// Package tableops implements in-memory operations on TableData for data
+// source classes that cannot perform them in their backend.
+package tableops
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+var (
+	ErrUnknownColumn = errors.New("unknown column")
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// Apply filters, sorts and pages t according to opts. The result carries
+// the number of rows that matched the filters, which is only a lower
+// bound, and so estimated, when t was truncated.
+func Apply(t domain.TableData, opts domain.DataOptions) (domain.TableData, error) {
+	rows, err := FilterRows(t, opts.Filters)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	if err := SortRows(t, rows, opts.Sort); err != nil {
+		return domain.TableData{}, err
+	}
+	total := len(rows)
+	rows = Page(rows, opts.Offset, opts.Limit)
+
+	out := Take(t, rows)
+	out.Total = &domain.RowCount{Rows: int64(total), Estimated: t.Truncated}
+	out.Truncated = t.Truncated
+	return out, nil
+}
+
+// Take gathers the given rows of every column.
+func Take(t domain.TableData, rows []int) domain.TableData {
+	cols := make([]domain.ColumnData, len(t.Columns))
+	for i, c := range t.Columns {
+		cols[i] = c.Take(rows)
+	}
+	return domain.TableData{Columns: cols}
+}
+
+// Page slices row indexes; a non-positive limit keeps every remaining row.
+func Page(rows []int, offset, limit int) []int {
+	if offset >= len(rows) {
+		return rows[:0]
+	}
+	rows = rows[offset:]
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// Column returns the index of the named column.
+func Column(t domain.TableData, name domain.Name) (int, error) {
+	for i, c := range t.Columns {
+		if c.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+}
+
+// FilterRows returns the indexes of rows matching every filter.
+func FilterRows(t domain.TableData, filters []domain.Filter) ([]int, error) {
+	type compiled struct {
+		col   domain.ColumnData
+		op    domain.FilterOp
+		value any
+	}
+	preds := make([]compiled, 0, len(filters))
+	for _, f := range filters {
+		idx, err := Column(t, f.Column)
+		if err != nil {
+			return nil, err
+		}
+		col := t.Columns[idx]
+		p := compiled{col: col, op: f.Op}
+		switch f.Op {
+		case domain.FilterIsNull, domain.FilterNotNull:
+		case domain.FilterContains:
+			p.value = strings.ToLower(string(f.Value))
+		default:
+			if !f.Op.Valid() {
+				return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, f.Op)
+			}
+			p.value, err = col.ParseValue(string(f.Value))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFilter, f.Column, err)
+			}
+		}
+		preds = append(preds, p)
+	}
+
+	n := t.NumRows()
+	rows := make([]int, 0, n)
+next:
+	for i := 0; i < n; i++ {
+		for _, p := range preds {
+			if !matches(p.col, i, p.op, p.value) {
+				continue next
+			}
+		}
+		rows = append(rows, i)
+	}
+	return rows, nil
+}
+
+func matches(col domain.ColumnData, i int, op domain.FilterOp, value any) bool {
+	switch op {
+	case domain.FilterIsNull:
+		return col.IsNull(i)
+	case domain.FilterNotNull:
+		return !col.IsNull(i)
+	}
+	if col.IsNull(i) {
+		return false
+	}
+	if op == domain.FilterContains {
+		return strings.Contains(strings.ToLower(col.String(i)), value.(string))
+	}
+	c := domain.CompareValues(col.Value(i), value)
+	switch op {
+	case domain.FilterEq:
+		return c == 0
+	case domain.FilterNe:
+		return c != 0
+	case domain.FilterGt:
+		return c > 0
+	case domain.FilterGe:
+		return c >= 0
+	case domain.FilterLt:
+		return c < 0
+	case domain.FilterLe:
+		return c <= 0
+	}
+	return false
+}
+
+// SortRows stably orders row indexes by keys. Nulls sort last in both
+// directions, matching what SQL classes push down.
+func SortRows(t domain.TableData, rows []int, keys []domain.SortKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cols := make([]domain.ColumnData, len(keys))
+	for k, key := range keys {
+		idx, err := Column(t, key.Column)
+		if err != nil {
+			return err
+		}
+		cols[k] = t.Columns[idx]
+	}
+	slices.SortStableFunc(rows, func(a, b int) int {
+		for k, key := range keys {
+			col := cols[k]
+			an, bn := col.IsNull(a), col.IsNull(b)
+			var c int
+			switch {
+			case an && bn:
+				continue
+			case an:
+				return 1
+			case bn:
+				return -1
+			default:
+				c = domain.CompareValues(col.Value(a), col.Value(b))
+			}
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return nil
+}
+
+// Concat appends the rows of batches sharing the columns of the first one,
+// as produced by streaming classes. A column whose type changes between
+// batches is widened for all of them: to number when integers meet
+// numbers and to string otherwise. Total, NextCursor and Truncated are
+// carried over from the batches that set them.
+func Concat(batches []domain.TableData) (domain.TableData, error) {
+	if len(batches) == 0 {
+		return domain.TableData{Columns: []domain.ColumnData{}}, nil
+	}
+	out := batches[0]
+	out.Columns = make([]domain.ColumnData, len(batches[0].Columns))
+	for i, c := range batches[0].Columns {
+		out.Columns[i] = c.Take(nil)
+	}
+	for _, b := range batches {
+		if len(b.Columns) != len(out.Columns) {
+			return domain.TableData{}, fmt.Errorf("batch has %d columns, want %d", len(b.Columns), len(out.Columns))
+		}
+		for i, c := range b.Columns {
+			if c.Type != out.Columns[i].Type {
+				out.Columns[i] = widen(out.Columns[i], c.Type)
+			}
+			for row := 0; row < c.Len(); row++ {
+				if err := appendFrom(&out.Columns[i], c, row); err != nil {
+					return domain.TableData{}, fmt.Errorf("column %s: %w", c.Name, err)
+				}
+			}
+		}
+		if b.Total != nil {
+			out.Total, out.NextCursor = b.Total, b.NextCursor
+		}
+		out.Truncated = out.Truncated || b.Truncated
+	}
+	return out, nil
+}
+
+// widen converts col to the type holding both its values and those of
+// type typ.
+func widen(col domain.ColumnData, typ domain.PropertyType) domain.ColumnData {
+	numeric := func(t domain.PropertyType) bool {
+		return t == domain.PropertyTypeInteger || t == domain.PropertyTypeNumber
+	}
+	to := domain.PropertyTypeString
+	if numeric(col.Type) && numeric(typ) {
+		to = domain.PropertyTypeNumber
+	}
+	if col.Type == to {
+		return col
+	}
+	out := domain.NewColumnData(col.Name, to)
+	out.Meta = col.Meta
+	for row := 0; row < col.Len(); row++ {
+		// values of the narrower types always fit the wider one
+		_ = appendFrom(&out, col, row)
+	}
+	return out
+}
+
+// appendFrom appends row of src to dst, as text when dst holds strings.
+func appendFrom(dst *domain.ColumnData, src domain.ColumnData, row int) error {
+	switch {
+	case src.IsNull(row):
+		dst.AppendNull()
+		return nil
+	case dst.Type == domain.PropertyTypeString && src.Type != domain.PropertyTypeString:
+		return dst.Append(src.String(row))
+	}
+	return dst.Append(src.Value(row))
+}
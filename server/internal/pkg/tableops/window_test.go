@@ -0,0 +1,130 @@
+package tableops_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+func TestApply(t *testing.T) {
+	table := newTable(t)
+
+	got, err := tableops.Apply(table, domain.DataOptions{
+		Limit:   2,
+		Sort:    []domain.SortKey{{Column: "score", Descending: true}},
+		Filters: []domain.Filter{{Column: "name", Op: domain.FilterContains, Value: "A"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got.Total == nil || got.Total.Rows != 3 {
+		t.Fatalf("total = %+v, want 3", got.Total)
+	}
+	names := got.Columns[0].Values
+	if len(names) != 2 || names[0] != "carol" || names[1] != "alice" {
+		t.Fatalf("names = %v, want [carol alice]", names)
+	}
+
+	got, err = tableops.Apply(table, domain.DataOptions{
+		Offset: 2,
+		Sort:   []domain.SortKey{{Column: "score"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	// ascending with nulls last: bob 1, alice 2, carol 3, dave null
+	if names := got.Columns[0].Values; len(names) != 2 || names[0] != "carol" || !got.Columns[1].IsNull(1) {
+		t.Fatalf("page = %+v", got.Columns)
+	}
+
+	got, err = tableops.Apply(table, domain.DataOptions{
+		Filters: []domain.Filter{{Column: "score", Op: domain.FilterLe, Value: "2.5"}},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got.Total.Rows != 2 || got.Total.Estimated {
+		t.Fatalf("le 2.5 total = %+v, want exactly 2", got.Total)
+	}
+
+	// rows cut off a truncated table may have matched too
+	table.Truncated = true
+	got, err = tableops.Apply(table, domain.DataOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !got.Total.Estimated || got.Total.Rows != 4 || !got.Truncated {
+		t.Fatalf("truncated total = %+v, truncated = %t; want an estimate of 4", got.Total, got.Truncated)
+	}
+}
+
+func TestApplyRejectsBadOptions(t *testing.T) {
+	table := newTable(t)
+	if _, err := tableops.Apply(table, domain.DataOptions{Sort: []domain.SortKey{{Column: "nope"}}}); !errors.Is(err, tableops.ErrUnknownColumn) {
+		t.Fatalf("unknown sort column: err = %v", err)
+	}
+	_, err := tableops.Apply(table, domain.DataOptions{
+		Filters: []domain.Filter{{Column: "score", Op: domain.FilterEq, Value: "high"}},
+	})
+	if !errors.Is(err, tableops.ErrInvalidFilter) {
+		t.Fatalf("invalid value: err = %v", err)
+	}
+}
+
+// Helpers
+
+func TestConcatWidensColumns(t *testing.T) {
+	column := func(typ domain.PropertyType, values ...any) domain.TableData {
+		col := domain.NewColumnData("v", typ)
+		for _, v := range values {
+			if err := col.Append(v); err != nil {
+				t.Fatalf("append: %v", err)
+			}
+		}
+		return domain.TableData{Columns: []domain.ColumnData{col}}
+	}
+
+	got, err := tableops.Concat([]domain.TableData{
+		column(domain.PropertyTypeInteger, int64(1), nil),
+		column(domain.PropertyTypeNumber, 2.5),
+	})
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	if c := got.Columns[0]; c.Type != domain.PropertyTypeNumber || c.Value(0) != 1.0 || !c.IsNull(1) || c.Value(2) != 2.5 {
+		t.Fatalf("integer and number = %+v", c)
+	}
+
+	got, err = tableops.Concat([]domain.TableData{
+		column(domain.PropertyTypeInteger, int64(1)),
+		column(domain.PropertyTypeString, "n/a"),
+		column(domain.PropertyTypeInteger, int64(3)),
+	})
+	if err != nil {
+		t.Fatalf("Concat: %v", err)
+	}
+	if c := got.Columns[0]; c.Type != domain.PropertyTypeString || c.String(0) != "1" || c.String(1) != "n/a" || c.String(2) != "3" {
+		t.Fatalf("integer and string = %+v", c)
+	}
+}
+
+func newTable(t *testing.T) domain.TableData {
+	t.Helper()
+	names := domain.NewColumnData("name", domain.PropertyTypeString)
+	scores := domain.NewColumnData("score", domain.PropertyTypeInteger)
+	rows := []struct {
+		name  string
+		score any
+	}{{"alice", int64(2)}, {"bob", int64(1)}, {"carol", int64(3)}, {"dave", nil}}
+	for _, r := range rows {
+		if err := names.Append(r.name); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := scores.Append(r.score); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	return domain.TableData{Columns: []domain.ColumnData{names, scores}}
+}
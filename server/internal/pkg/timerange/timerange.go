@@ -0,0 +1,237 @@
+// Package timerange parses the time ranges dashboards query, given as
+// absolute instants or relative to now as in now-6h or now/d, and picks
+// the interval between points that suits them.
+package timerange
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+var ErrInvalid = errors.New("invalid time")
+
+// Parse returns the range from from to to in loc, relative to now. An
+// expression rounded to a unit, such as now/d, rounds from down to the
+// start of the day and to up to its end.
+func Parse(from, to string, now time.Time, loc *time.Location) (domain.TimeRange, error) {
+	f, err := ParseTime(from, now, loc, false)
+	if err != nil {
+		return domain.TimeRange{}, err
+	}
+	t, err := ParseTime(to, now, loc, true)
+	if err != nil {
+		return domain.TimeRange{}, err
+	}
+	if !f.Before(t) {
+		return domain.TimeRange{}, fmt.Errorf("%w: from %s is not before to %s", ErrInvalid, from, to)
+	}
+	return domain.TimeRange{From: f, To: t}, nil
+}
+
+// ParseStable is Parse with now rounded up to the interval that splits
+// the range into maxPoints, so that relative ranges requested within one
+// interval resolve to the same instants, as Prometheus aligns range queries
+// to their step. Their results can then be shared. Absolute ranges are
+// returned as Parse returns them.
+func ParseStable(from, to string, now time.Time, loc *time.Location, maxPoints int) (domain.TimeRange, error) {
+	r, err := Parse(from, to, now, loc)
+	if err != nil {
+		return r, err
+	}
+	step := Interval(r.To.Sub(r.From), maxPoints, 0)
+	rounded := now.Truncate(step)
+	if rounded.Equal(now) {
+		return r, nil
+	}
+	return Parse(from, to, rounded.Add(step), loc)
+}
+
+// ParseTime parses one end of a range: now followed by any number of
+// offsets (+1h, -2d) and roundings (/d), epoch milliseconds, RFC 3339, or
+// a date and time without zone, which is taken to be in loc. roundUp
+// makes roundings go to the end of the unit instead of its start.
+func ParseTime(s string, now time.Time, loc *time.Location, roundUp bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, "now"); ok {
+		return parseMath(rest, now.In(loc), roundUp)
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms).In(loc), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+}
+
+// parseMath applies the offsets and roundings following now.
+func parseMath(s string, t time.Time, roundUp bool) (time.Time, error) {
+	for i := 0; i < len(s); {
+		op := s[i]
+		i++
+		if op == '/' {
+			if i >= len(s) {
+				return time.Time{}, fmt.Errorf("%w: rounding without a unit", ErrInvalid)
+			}
+			var err error
+			if t, err = round(t, s[i], roundUp); err != nil {
+				return time.Time{}, err
+			}
+			i++
+			continue
+		}
+		if op != '+' && op != '-' {
+			return time.Time{}, fmt.Errorf("%w: unexpected %q", ErrInvalid, op)
+		}
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		n := 1
+		if i > start {
+			n, _ = strconv.Atoi(s[start:i])
+		}
+		if i >= len(s) {
+			return time.Time{}, fmt.Errorf("%w: offset without a unit", ErrInvalid)
+		}
+		if op == '-' {
+			n = -n
+		}
+		var err error
+		if t, err = add(t, n, s[i]); err != nil {
+			return time.Time{}, err
+		}
+		i++
+	}
+	return t, nil
+}
+
+func add(t time.Time, n int, unit byte) (time.Time, error) {
+	switch unit {
+	case 's':
+		return t.Add(time.Duration(n) * time.Second), nil
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case 'h':
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'M':
+		return t.AddDate(0, n, 0), nil
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: unknown unit %q", ErrInvalid, unit)
+}
+
+// round truncates t to the start of its unit in its location, or moves it
+// to the last nanosecond of the unit when up is set. Weeks start on Monday.
+func round(t time.Time, unit byte, up bool) (time.Time, error) {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	var start time.Time
+	switch unit {
+	case 's':
+		start = t.Truncate(time.Second)
+	case 'm':
+		start = time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case 'h':
+		start = time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc)
+	case 'd':
+		start = time.Date(y, mo, d, 0, 0, 0, 0, loc)
+	case 'w':
+		start = time.Date(y, mo, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case 'M':
+		start = time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+	case 'y':
+		start = time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}, fmt.Errorf("%w: unknown unit %q", ErrInvalid, unit)
+	}
+	if !up {
+		return start, nil
+	}
+	end, _ := add(start, 1, unit)
+	return end.Add(-time.Nanosecond), nil
+}
+
+// ParseDuration accepts Go durations and the units of relative times
+// that have a fixed length, as in 1d or 2w.
+func ParseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	if len(s) > 1 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err == nil {
+			switch s[len(s)-1] {
+			case 'd':
+				return time.Duration(n) * 24 * time.Hour, nil
+			case 'w':
+				return time.Duration(n) * 7 * 24 * time.Hour, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: duration %q", ErrInvalid, s)
+}
+
+// steps are the intervals Interval rounds up to.
+var steps = []time.Duration{
+	time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 7 * 24 * time.Hour, 30 * 24 * time.Hour, 365 * 24 * time.Hour,
+}
+
+// MaxPoints is the most points Interval splits a span into, the limit
+// Prometheus puts on the points of a series in a range query.
+const MaxPoints = 11000
+
+// Interval returns the spacing that fits a span into at most maxPoints
+// points, and no more than MaxPoints, rounded up to a step people read
+// easily and at least floor. It is chosen like the step of a Prometheus
+// range query: the span divided by the points asked for, floored by the
+// scrape interval of the metrics.
+func Interval(span time.Duration, maxPoints int, floor time.Duration) time.Duration {
+	raw := span / time.Duration(min(max(maxPoints, 1), MaxPoints))
+	interval := steps[len(steps)-1]
+	for _, s := range steps {
+		if s >= raw {
+			interval = s
+			break
+		}
+	}
+	return max(interval, floor)
+}
+
+// FormatInterval writes d in the largest unit that divides it, as in 30s
+// or 1d, falling back to milliseconds.
+func FormatInterval(d time.Duration) string {
+	units := []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour},
+		{"m", time.Minute}, {"s", time.Second},
+	}
+	for _, u := range units {
+		if d >= u.size && d%u.size == 0 {
+			return strconv.FormatInt(int64(d/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
@@ -0,0 +1,104 @@
+package timerange_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/timerange"
+)
+
+func TestParseTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// a Wednesday, 10:30 in Berlin
+	now := time.Date(2024, 3, 13, 9, 30, 15, 0, time.UTC)
+
+	cases := []struct {
+		expr    string
+		roundUp bool
+		want    time.Time
+	}{
+		{"now", false, now},
+		{"now-6h", false, now.Add(-6 * time.Hour)},
+		{"now-1d+2h", false, now.Add(-22 * time.Hour)},
+		{"now/d", false, time.Date(2024, 3, 13, 0, 0, 0, 0, berlin)},
+		{"now/d", true, time.Date(2024, 3, 14, 0, 0, 0, 0, berlin).Add(-time.Nanosecond)},
+		{"now-1d/d", false, time.Date(2024, 3, 12, 0, 0, 0, 0, berlin)},
+		{"now/w", false, time.Date(2024, 3, 11, 0, 0, 0, 0, berlin)},
+		{"now-1M/M", false, time.Date(2024, 2, 1, 0, 0, 0, 0, berlin)},
+		{"2024-03-01", false, time.Date(2024, 3, 1, 0, 0, 0, 0, berlin)},
+		{"2024-03-01 12:00:00", false, time.Date(2024, 3, 1, 12, 0, 0, 0, berlin)},
+		{"2024-03-01T12:00:00Z", false, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"1709294400000", false, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		got, err := timerange.ParseTime(tc.expr, now, berlin, tc.roundUp)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s (up=%v) = %s, want %s", tc.expr, tc.roundUp, got, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"", "yesterday", "now-6", "now-6x", "now/", "now*2h"} {
+		if _, err := timerange.ParseTime(expr, now, berlin, false); !errors.Is(err, timerange.ErrInvalid) {
+			t.Errorf("%q: expected ErrInvalid, got %v", expr, err)
+		}
+	}
+	if _, err := timerange.Parse("now", "now-1h", now, berlin); !errors.Is(err, timerange.ErrInvalid) {
+		t.Errorf("reversed range: expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	cases := []struct {
+		span   time.Duration
+		points int
+		min    time.Duration
+		want   string
+	}{
+		{6 * time.Hour, 1000, 0, "30s"},
+		{24 * time.Hour, 1000, 0, "2m"},
+		{time.Hour, 100, 0, "1m"},
+		{time.Minute, 1000, 0, "100ms"},
+		{time.Hour, 1000, 15 * time.Second, "15s"},
+		{30 * 24 * time.Hour, 10, 0, "1w"},
+		// no more points than Prometheus serves
+		{24 * time.Hour, 100000, 0, "10s"},
+	}
+	for _, tc := range cases {
+		got := timerange.FormatInterval(timerange.Interval(tc.span, tc.points, tc.min))
+		if got != tc.want {
+			t.Errorf("Interval(%s, %d, %s) = %s, want %s", tc.span, tc.points, tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestParseStable(t *testing.T) {
+	start := time.Date(2024, 3, 13, 9, 30, 0, 0, time.UTC)
+	// 6h in 1000 points moves in 30s steps
+	first, err := timerange.ParseStable("now-6h", "now", start.Add(time.Second), time.UTC, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := timerange.ParseStable("now-6h", "now", start.Add(29*time.Second), time.UTC, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || !first.To.Equal(start.Add(30*time.Second)) || first.To.Sub(first.From) != 6*time.Hour {
+		t.Fatalf("ranges %v and %v, want both to end at %s", first, second, start.Add(30*time.Second))
+	}
+	aligned, err := timerange.ParseStable("now-6h", "now", start, time.UTC, 1000)
+	if err != nil || !aligned.To.Equal(start) {
+		t.Fatalf("aligned now: %v, %v", aligned, err)
+	}
+	abs, err := timerange.ParseStable("2024-03-01", "2024-03-02", start.Add(time.Second), time.UTC, 1000)
+	if err != nil || !abs.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("absolute range: %v, %v", abs, err)
+	}
+}
@@ -0,0 +1,153 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+type AggregateFunc string
+
+const (
+	// AggregateCount counts non-null values, or rows when no column is set.
+	AggregateCount AggregateFunc = "count"
+	AggregateSum   AggregateFunc = "sum"
+	AggregateAvg   AggregateFunc = "avg"
+	AggregateMin   AggregateFunc = "min"
+	AggregateMax   AggregateFunc = "max"
+	// AggregateFirst and AggregateLast take the value of the first and last
+	// row, null or not.
+	AggregateFirst AggregateFunc = "first"
+	AggregateLast  AggregateFunc = "last"
+)
+
+func (f AggregateFunc) Valid() bool {
+	switch f {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregateFirst, AggregateLast:
+		return true
+	}
+	return false
+}
+
+// resultType returns the type of the values f computes from col: integer
+// for counts, number for averages and the type of col otherwise.
+func (f AggregateFunc) resultType(col domain.ColumnData) (domain.PropertyType, error) {
+	switch f {
+	case AggregateCount:
+		return domain.PropertyTypeInteger, nil
+	case AggregateAvg, AggregateSum:
+		if col.Type != domain.PropertyTypeInteger && col.Type != domain.PropertyTypeNumber {
+			return "", fmt.Errorf("%s of %s needs a numeric column, not %s", f, col.Name, col.Type)
+		}
+		if f == AggregateAvg {
+			return domain.PropertyTypeNumber, nil
+		}
+	}
+	return col.Type, nil
+}
+
+// apply computes f over the rows of col. Sums, averages, minimums and
+// maximums of no values are null.
+func (f AggregateFunc) apply(col domain.ColumnData, rows []int) any {
+	switch f {
+	case AggregateFirst:
+		return col.Value(rows[0])
+	case AggregateLast:
+		return col.Value(rows[len(rows)-1])
+	}
+	var (
+		n     int64
+		ints  int64
+		sum   float64
+		found any
+	)
+	for _, i := range rows {
+		v := col.Value(i)
+		if v == nil {
+			continue
+		}
+		n++
+		switch x := v.(type) {
+		case int64:
+			ints += x
+			sum += float64(x)
+		case float64:
+			sum += x
+		}
+		c := 0
+		if found != nil {
+			c = domain.CompareValues(v, found)
+		}
+		if found == nil || f == AggregateMin && c < 0 || f == AggregateMax && c > 0 {
+			found = v
+		}
+	}
+	switch f {
+	case AggregateCount:
+		return n
+	case AggregateMin, AggregateMax:
+		return found
+	}
+	if n == 0 {
+		return nil
+	}
+	if f == AggregateAvg {
+		return sum / float64(n)
+	}
+	if col.Type == domain.PropertyTypeInteger {
+		return ints
+	}
+	return sum
+}
+
+// Aggregation computes Func over Column within each group, as a column
+// named As, which defaults to func(column).
+type Aggregation struct {
+	Column domain.Name   `json:"column"`
+	Func   AggregateFunc `json:"func"`
+	As     domain.Name   `json:"as"`
+}
+
+func (a *Aggregation) check() error {
+	if !a.Func.Valid() {
+		return fmt.Errorf("unknown function %q", a.Func)
+	}
+	if a.Column == "" && a.Func != AggregateCount {
+		return fmt.Errorf("%s needs a column", a.Func)
+	}
+	if a.As == "" {
+		a.As = domain.Name(string(a.Func) + "(" + string(a.Column) + ")")
+		if a.Column == "" {
+			a.As = domain.Name(a.Func)
+		}
+	}
+	return nil
+}
+
+func (a Aggregation) aggregate(t domain.TableData, groups [][]int) (domain.ColumnData, error) {
+	out := domain.NewColumnData(a.As, domain.PropertyTypeInteger)
+	if a.Column == "" {
+		for _, g := range groups {
+			out.Ints = append(out.Ints, int64(len(g)))
+		}
+		return out, nil
+	}
+	idx, err := tableops.Column(t, a.Column)
+	if err != nil {
+		return out, err
+	}
+	col := t.Columns[idx]
+	if out.Type, err = a.Func.resultType(col); err != nil {
+		return out, err
+	}
+	if a.Func != AggregateCount {
+		out.Meta = col.Meta
+	}
+	for _, g := range groups {
+		if err := out.Append(a.Func.apply(col, g)); err != nil {
+			return out, fmt.Errorf("%s: %w", a.As, err)
+		}
+	}
+	return out, nil
+}
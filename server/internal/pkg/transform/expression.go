@@ -0,0 +1,140 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+)
+
+// expressionStep adds or replaces the column As with the value of
+// Expression for each row, which sees the columns of the row by name.
+type expressionStep struct {
+	As         domain.Name `json:"as"`
+	Expression string      `json:"expression"`
+
+	program expression.Program
+}
+
+func (s *expressionStep) check() error {
+	if s.As == "" {
+		return errors.New("as is required")
+	}
+	var err error
+	s.program, err = expression.Compile(s.Expression)
+	return expression.At(err, "options.expression")
+}
+
+func (s *expressionStep) apply(t domain.TableData) (domain.TableData, error) {
+	values := make([]any, t.NumRows())
+	for i := range values {
+		v, err := s.program.Run(expression.Row(t, i))
+		if err != nil {
+			return t, err
+		}
+		values[i] = v
+	}
+	typ, err := resultType(values)
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", s.As, err)
+	}
+	col := domain.NewColumnData(s.As, typ)
+	for _, v := range values {
+		if err := col.Append(v); err != nil {
+			return t, fmt.Errorf("%s: %w", s.As, err)
+		}
+	}
+	return setColumn(t, col), nil
+}
+
+type thresholdRule struct {
+	When   string               `json:"when"`
+	Status domain.PropertyValue `json:"status"`
+
+	program expression.Program
+}
+
+// thresholdsStep adds or replaces the string column As with the Status of
+// the first rule whose When holds for the row, or Default.
+type thresholdsStep struct {
+	As      domain.Name          `json:"as"`
+	Rules   []thresholdRule      `json:"rules"`
+	Default domain.PropertyValue `json:"default"`
+}
+
+func (s *thresholdsStep) check() error {
+	if s.As == "" {
+		return errors.New("as is required")
+	}
+	if len(s.Rules) == 0 {
+		return errors.New("rules is required")
+	}
+	for i := range s.Rules {
+		var err error
+		if s.Rules[i].program, err = expression.Compile(s.Rules[i].When); err != nil {
+			return expression.At(err, fmt.Sprintf("options.rules[%d].when", i))
+		}
+	}
+	return nil
+}
+
+func (s *thresholdsStep) apply(t domain.TableData) (domain.TableData, error) {
+	col := domain.NewColumnData(s.As, domain.PropertyTypeString)
+	for i := range t.NumRows() {
+		env := expression.Row(t, i)
+		status := s.Default
+		for _, r := range s.Rules {
+			v, err := r.program.Run(env)
+			if err != nil {
+				return t, err
+			}
+			if holds, ok := v.(bool); !ok {
+				return t, fmt.Errorf("rule %q yields %T, not a boolean", r.When, v)
+			} else if holds {
+				status = r.Status
+				break
+			}
+		}
+		col.Values = append(col.Values, status)
+	}
+	return setColumn(t, col), nil
+}
+
+// resultType returns the column type holding values: integer, number when
+// integers and floats mix, and string when every value is nil.
+func resultType(values []any) (domain.PropertyType, error) {
+	var typ domain.PropertyType
+	for _, v := range values {
+		var t domain.PropertyType
+		switch v.(type) {
+		case nil:
+			continue
+		case int, int64, int32, uint64, uint32:
+			t = domain.PropertyTypeInteger
+		case float64, float32:
+			t = domain.PropertyTypeNumber
+		case bool:
+			t = domain.PropertyTypeBoolean
+		case string:
+			t = domain.PropertyTypeString
+		case time.Time:
+			t = domain.PropertyTypeTime
+		default:
+			t = domain.PropertyTypeJSON
+		}
+		switch {
+		case typ == "" || typ == t:
+			typ = t
+		case numeric(typ) && numeric(t):
+			typ = domain.PropertyTypeNumber
+		default:
+			return "", fmt.Errorf("expression yields both %s and %s values", typ, t)
+		}
+	}
+	if typ == "" {
+		typ = domain.PropertyTypeString
+	}
+	return typ, nil
+}
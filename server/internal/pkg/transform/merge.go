@@ -0,0 +1,329 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+type MergeType string
+
+const (
+	// MergeJoin joins the results on the key column On, from the first
+	// query to the last.
+	MergeJoin MergeType = "join"
+	// MergeUnion appends the rows of every result, matching columns by
+	// name. Columns missing from a result are null in its rows.
+	MergeUnion MergeType = "union"
+	// MergeTimeSeries aligns the results on the timestamp column On: an
+	// outer join ordered by time.
+	MergeTimeSeries MergeType = "timeSeries"
+)
+
+type JoinKind string
+
+const (
+	JoinInner JoinKind = "inner"
+	JoinLeft  JoinKind = "left"
+	JoinOuter JoinKind = "outer"
+)
+
+// Merge combines the results of the queries of a component into one
+// table, e.g. {"type": "join", "on": "id", "how": "left"}. Joined columns
+// whose name is taken are prefixed with the name of their query, as in
+// api.status.
+type Merge struct {
+	Type MergeType   `json:"type"`
+	On   domain.Name `json:"on,omitempty"`
+	How  JoinKind    `json:"how,omitempty"`
+}
+
+// Frame is the result of one named query.
+type Frame struct {
+	Name  domain.Name
+	Table domain.TableData
+}
+
+// ParseMerge decodes and checks a JSON Merge. How defaults to inner.
+func ParseMerge(raw string) (Merge, error) {
+	var m Merge
+	dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return Merge{}, fmt.Errorf("%w: merge: %v", ErrInvalid, err)
+	}
+	if err := m.check(); err != nil {
+		return Merge{}, fmt.Errorf("%w: merge: %v", ErrInvalid, err)
+	}
+	return m, nil
+}
+
+func (m *Merge) check() error {
+	switch m.Type {
+	case MergeUnion:
+		if m.On != "" || m.How != "" {
+			return errors.New("union takes neither on nor how")
+		}
+		return nil
+	case MergeJoin:
+		if m.How == "" {
+			m.How = JoinInner
+		}
+		switch m.How {
+		case JoinInner, JoinLeft, JoinOuter:
+		default:
+			return fmt.Errorf("unknown join %q", m.How)
+		}
+	case MergeTimeSeries:
+		if m.How != "" {
+			return errors.New("timeSeries takes no how")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", m.Type)
+	}
+	if m.On == "" {
+		return fmt.Errorf("%s needs on", m.Type)
+	}
+	return nil
+}
+
+// Apply merges frames, which must not be empty.
+func (m Merge) Apply(frames []Frame) (domain.TableData, error) {
+	var (
+		out domain.TableData
+		err error
+	)
+	switch m.Type {
+	case MergeUnion:
+		out, err = union(frames)
+	case MergeJoin:
+		out, err = joinAll(frames, m.On, m.How)
+	case MergeTimeSeries:
+		out, err = alignTimeSeries(frames, m.On)
+	default:
+		err = fmt.Errorf("unknown type %q", m.Type)
+	}
+	if err != nil {
+		return domain.TableData{}, fmt.Errorf("%w: merge: %w", ErrInvalid, err)
+	}
+	for _, f := range frames {
+		out.Truncated = out.Truncated || f.Table.Truncated
+	}
+	return out, nil
+}
+
+func union(frames []Frame) (domain.TableData, error) {
+	var out domain.TableData
+	index := make(map[domain.Name]int)
+	for _, f := range frames {
+		for _, c := range f.Table.Columns {
+			i, ok := index[c.Name]
+			if !ok {
+				index[c.Name] = len(out.Columns)
+				col := domain.NewColumnData(c.Name, c.Type)
+				col.Meta = c.Meta
+				out.Columns = append(out.Columns, col)
+				continue
+			}
+			switch typ := out.Columns[i].Type; {
+			case typ == c.Type:
+			case numeric(typ) && numeric(c.Type):
+				out.Columns[i].Type = domain.PropertyTypeNumber
+			default:
+				return domain.TableData{}, fmt.Errorf("column %s is %s in %s but %s before", c.Name, c.Type, f.Name, typ)
+			}
+		}
+	}
+	for _, f := range frames {
+		n := f.Table.NumRows()
+		for i := range out.Columns {
+			src, err := tableops.Column(f.Table, out.Columns[i].Name)
+			for row := range n {
+				if err != nil {
+					out.Columns[i].AppendNull()
+					continue
+				}
+				if err := out.Columns[i].Append(f.Table.Columns[src].Value(row)); err != nil {
+					return domain.TableData{}, fmt.Errorf("column %s of %s: %w", out.Columns[i].Name, f.Name, err)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func numeric(t domain.PropertyType) bool {
+	return t == domain.PropertyTypeInteger || t == domain.PropertyTypeNumber
+}
+
+func joinAll(frames []Frame, on domain.Name, how JoinKind) (domain.TableData, error) {
+	out := frames[0].Table
+	if _, err := tableops.Column(out, on); err != nil {
+		return domain.TableData{}, fmt.Errorf("%s: %w", frames[0].Name, err)
+	}
+	for _, f := range frames[1:] {
+		var err error
+		if out, err = join(out, f, on, how); err != nil {
+			return domain.TableData{}, err
+		}
+	}
+	return out, nil
+}
+
+// join joins the rows of right to those of left sharing their key. Null
+// keys match nothing. The key column is left's, holding right's key in
+// rows only right has.
+func join(left domain.TableData, right Frame, on domain.Name, how JoinKind) (domain.TableData, error) {
+	li, _ := tableops.Column(left, on)
+	ri, err := tableops.Column(right.Table, on)
+	if err != nil {
+		return domain.TableData{}, fmt.Errorf("%s: %w", right.Name, err)
+	}
+	lk, rk := left.Columns[li], right.Table.Columns[ri]
+
+	index := make(map[string][]int)
+	for r := range right.Table.NumRows() {
+		if k, ok := joinKey(rk, r); ok {
+			index[k] = append(index[k], r)
+		}
+	}
+	var ls, rs []int
+	matched := make([]bool, right.Table.NumRows())
+	for l := range left.NumRows() {
+		var rows []int
+		if k, ok := joinKey(lk, l); ok {
+			rows = index[k]
+		}
+		for _, r := range rows {
+			ls, rs = append(ls, l), append(rs, r)
+			matched[r] = true
+		}
+		if len(rows) == 0 && how != JoinInner {
+			ls, rs = append(ls, l), append(rs, -1)
+		}
+	}
+	if how == JoinOuter {
+		for r, ok := range matched {
+			if !ok {
+				ls, rs = append(ls, -1), append(rs, r)
+			}
+		}
+	}
+
+	out := domain.TableData{Columns: make([]domain.ColumnData, 0, len(left.Columns)+len(right.Table.Columns)-1)}
+	taken := make(map[domain.Name]bool, cap(out.Columns))
+	for i, c := range left.Columns {
+		col, err := takeOrNull(c, ls)
+		if err == nil && i == li {
+			col, err = fillKey(col, rk, ls, rs)
+		}
+		if err != nil {
+			return domain.TableData{}, err
+		}
+		out.Columns = append(out.Columns, col)
+		taken[c.Name] = true
+	}
+	for i, c := range right.Table.Columns {
+		if i == ri {
+			continue
+		}
+		col, err := takeOrNull(c, rs)
+		if err != nil {
+			return domain.TableData{}, err
+		}
+		if taken[col.Name] {
+			col.Name = right.Name + "." + col.Name
+		}
+		out.Columns = append(out.Columns, col)
+		taken[col.Name] = true
+	}
+	return out, nil
+}
+
+// joinKey identifies the key at row i across columns of different types.
+// Times compare by instant whatever their zone.
+func joinKey(c domain.ColumnData, i int) (string, bool) {
+	if c.IsNull(i) {
+		return "", false
+	}
+	if c.Type == domain.PropertyTypeTime {
+		return strconv.FormatInt(c.Times[i].UnixNano(), 10), true
+	}
+	return c.String(i), true
+}
+
+// takeOrNull is ColumnData.Take where negative rows are null.
+func takeOrNull(c domain.ColumnData, rows []int) (domain.ColumnData, error) {
+	out := c.Take(nil)
+	for _, r := range rows {
+		if r < 0 {
+			out.AppendNull()
+			continue
+		}
+		if err := out.Append(c.Value(r)); err != nil {
+			return out, fmt.Errorf("column %s: %w", c.Name, err)
+		}
+	}
+	return out, nil
+}
+
+// fillKey sets the rows of key that only right has to right's key.
+func fillKey(key, rk domain.ColumnData, ls, rs []int) (domain.ColumnData, error) {
+	out := key.Take(nil)
+	for j, l := range ls {
+		v := key.Value(j)
+		if l < 0 {
+			v = plain(rk.Value(rs[j]))
+		}
+		if err := out.Append(v); err != nil {
+			return out, fmt.Errorf("column %s: %w", key.Name, err)
+		}
+	}
+	return out, nil
+}
+
+// plain returns string values as strings, which Append converts to
+// other types.
+func plain(v any) any {
+	if s, ok := v.(domain.PropertyValue); ok {
+		return string(s)
+	}
+	return v
+}
+
+func alignTimeSeries(frames []Frame, on domain.Name) (domain.TableData, error) {
+	aligned := make([]Frame, len(frames))
+	for i, f := range frames {
+		idx, err := tableops.Column(f.Table, on)
+		if err != nil {
+			return domain.TableData{}, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		t := f.Table
+		if c := t.Columns[idx]; c.Type != domain.PropertyTypeTime {
+			times := domain.NewColumnData(c.Name, domain.PropertyTypeTime)
+			times.Meta = c.Meta
+			for row := range c.Len() {
+				if err := times.Append(plain(c.Value(row))); err != nil {
+					return domain.TableData{}, fmt.Errorf("%s: column %s: %w", f.Name, on, err)
+				}
+			}
+			t.Columns = append([]domain.ColumnData(nil), t.Columns...)
+			t.Columns[idx] = times
+		}
+		aligned[i] = Frame{Name: f.Name, Table: t}
+	}
+	out, err := joinAll(aligned, on, JoinOuter)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	rows := allRows(out)
+	if err := tableops.SortRows(out, rows, []domain.SortKey{{Column: on}}); err != nil {
+		return domain.TableData{}, err
+	}
+	return tableops.Take(out, rows), nil
+}
@@ -0,0 +1,120 @@
+package transform_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/transform"
+)
+
+func column(t *testing.T, name domain.Name, typ domain.PropertyType, values ...any) domain.ColumnData {
+	t.Helper()
+	c := domain.NewColumnData(name, typ)
+	for _, v := range values {
+		if err := c.Append(v); err != nil {
+			t.Fatalf("Append %v: %v", v, err)
+		}
+	}
+	return c
+}
+
+func merge(t *testing.T, raw string, frames ...transform.Frame) domain.TableData {
+	t.Helper()
+	m, err := transform.ParseMerge(raw)
+	if err != nil {
+		t.Fatalf("ParseMerge: %v", err)
+	}
+	out, err := m.Apply(frames)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return out
+}
+
+func TestMergeJoin(t *testing.T) {
+	users := transform.Frame{Name: "db", Table: domain.TableData{Columns: []domain.ColumnData{
+		column(t, "id", domain.PropertyTypeInteger, int64(1), int64(2), nil),
+		column(t, "status", domain.PropertyTypeString, "active", "banned", "ghost"),
+	}}}
+	// the REST API reports ids as strings
+	api := transform.Frame{Name: "api", Table: domain.TableData{Columns: []domain.ColumnData{
+		column(t, "id", domain.PropertyTypeString, "2", "3", "2"),
+		column(t, "status", domain.PropertyTypeString, "late", "ok", "paid"),
+	}}}
+
+	inner := merge(t, `{"type": "join", "on": "id"}`, users, api)
+	if inner.NumRows() != 2 || inner.Columns[2].Name != "api.status" {
+		t.Fatalf("inner = %+v, want user 2 twice with api.status", inner.Columns)
+	}
+	if s := inner.Columns[2].Values; s[0] != "late" || s[1] != "paid" {
+		t.Fatalf("api.status = %v", s)
+	}
+
+	left := merge(t, `{"type": "join", "on": "id", "how": "left"}`, users, api)
+	if left.NumRows() != 4 || !left.Columns[2].IsNull(0) || !left.Columns[2].IsNull(3) {
+		t.Fatalf("left = %+v, want unmatched users with null api.status", left.Columns)
+	}
+
+	outer := merge(t, `{"type": "join", "on": "id", "how": "outer"}`, users, api)
+	if outer.NumRows() != 5 {
+		t.Fatalf("outer rows = %d, want 5", outer.NumRows())
+	}
+	// the api-only id 3 fills the integer key
+	if id := outer.Columns[0]; id.Ints[4] != 3 || !outer.Columns[1].IsNull(4) {
+		t.Fatalf("outer = %+v", outer.Columns)
+	}
+}
+
+func TestMergeUnionAndTimeSeries(t *testing.T) {
+	a := transform.Frame{Name: "a", Table: domain.TableData{Columns: []domain.ColumnData{
+		column(t, "host", domain.PropertyTypeString, "a1"),
+		column(t, "load", domain.PropertyTypeInteger, int64(3)),
+	}}}
+	b := transform.Frame{Name: "b", Table: domain.TableData{Truncated: true, Columns: []domain.ColumnData{
+		column(t, "load", domain.PropertyTypeNumber, 0.5),
+		column(t, "zone", domain.PropertyTypeString, "z"),
+	}}}
+	u := merge(t, `{"type": "union"}`, a, b)
+	if len(u.Columns) != 3 || u.NumRows() != 2 || !u.Truncated {
+		t.Fatalf("union = %+v", u)
+	}
+	if load := u.Columns[1]; load.Type != domain.PropertyTypeNumber || load.Floats[0] != 3 || load.Floats[1] != 0.5 || !u.Columns[2].IsNull(0) {
+		t.Fatalf("union columns = %+v", u.Columns)
+	}
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cpu := transform.Frame{Name: "cpu", Table: domain.TableData{Columns: []domain.ColumnData{
+		column(t, "time", domain.PropertyTypeTime, t0.Add(time.Minute), t0),
+		column(t, "value", domain.PropertyTypeNumber, 0.2, 0.1),
+	}}}
+	mem := transform.Frame{Name: "mem", Table: domain.TableData{Columns: []domain.ColumnData{
+		column(t, "time", domain.PropertyTypeString, "2024-01-01T09:02:00+09:00", "2024-01-01T00:01:00Z"),
+		column(t, "value", domain.PropertyTypeInteger, int64(7), int64(8)),
+	}}}
+	ts := merge(t, `{"type": "timeSeries", "on": "time"}`, cpu, mem)
+	if ts.NumRows() != 3 || ts.Columns[2].Name != "mem.value" {
+		t.Fatalf("aligned = %+v", ts.Columns)
+	}
+	times, mv := ts.Columns[0].Times, ts.Columns[2]
+	if !times[0].Equal(t0) || !times[2].Equal(t0.Add(2*time.Minute)) || !mv.IsNull(0) || mv.Ints[1] != 8 || !ts.Columns[1].IsNull(2) {
+		t.Fatalf("aligned = %+v", ts.Columns)
+	}
+}
+
+func TestMergeRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{`{"type": "zip"}`, `{"type": "join"}`, `{"type": "join", "on": "id", "how": "cross"}`, `{"type": "union", "on": "id"}`} {
+		if _, err := transform.ParseMerge(raw); !errors.Is(err, transform.ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", raw, err)
+		}
+	}
+	m, _ := transform.ParseMerge(`{"type": "union"}`)
+	_, err := m.Apply([]transform.Frame{
+		{Name: "a", Table: domain.TableData{Columns: []domain.ColumnData{column(t, "x", domain.PropertyTypeString, "s")}}},
+		{Name: "b", Table: domain.TableData{Columns: []domain.ColumnData{column(t, "x", domain.PropertyTypeBoolean, true)}}},
+	})
+	if !errors.Is(err, transform.ErrInvalid) {
+		t.Fatalf("mismatched union: err = %v", err)
+	}
+}
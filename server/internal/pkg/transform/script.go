@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/script"
+)
+
+// scriptStep replaces the table with what a JavaScript transformer makes
+// of it: Script inline, or the saved transformer named Transformer, whose
+// program Pipeline.Bind provides.
+type scriptStep struct {
+	Script      string      `json:"script"`
+	Transformer domain.Name `json:"transformer"`
+
+	program script.Program
+	bound   bool
+}
+
+func (s *scriptStep) check() error {
+	switch {
+	case (s.Script == "") == (s.Transformer == ""):
+		return errors.New("exactly one of script and transformer is required")
+	case s.Script != "":
+		var err error
+		if s.program, err = script.Compile(s.Script); err != nil {
+			return fmt.Errorf("options.script: %w", err)
+		}
+		s.bound = true
+	}
+	return nil
+}
+
+// apply runs the script within script.DefaultLimits, whose time limit
+// also bounds runs nobody waits for any more.
+func (s *scriptStep) apply(t domain.TableData) (domain.TableData, error) {
+	if !s.bound {
+		return t, fmt.Errorf("transformer %s is not available", s.Transformer)
+	}
+	out, err := s.program.Run(context.Background(), t, script.DefaultLimits)
+	if err != nil && s.Transformer != "" {
+		return t, fmt.Errorf("transformer %s: %w", s.Transformer, err)
+	}
+	return out, err
+}
+
+// Transformers returns the names of the saved transformers the pipeline
+// uses, in order, each once.
+func (p Pipeline) Transformers() []domain.Name {
+	var names []domain.Name
+	seen := make(map[domain.Name]bool)
+	for _, s := range p.steps {
+		if s, ok := s.(*scriptStep); ok && s.Transformer != "" && !seen[s.Transformer] {
+			seen[s.Transformer] = true
+			names = append(names, s.Transformer)
+		}
+	}
+	return names
+}
+
+// Bind gives the steps using saved transformers their programs, as lookup
+// finds them by name. Errors of lookup are returned as they are, with the
+// step they occurred for.
+func (p Pipeline) Bind(lookup func(domain.Name) (script.Program, error)) error {
+	for i, s := range p.steps {
+		s, ok := s.(*scriptStep)
+		if !ok || s.Transformer == "" {
+			continue
+		}
+		program, err := lookup(s.Transformer)
+		if err != nil {
+			return fmt.Errorf("transformation %d (%s): %w", i+1, p.types[i], err)
+		}
+		s.program, s.bound = program, true
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package transform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+type renameStep struct {
+	Columns map[domain.Name]domain.Name `json:"columns"`
+}
+
+func (s *renameStep) check() error {
+	if len(s.Columns) == 0 {
+		return errors.New("columns is required")
+	}
+	for from, to := range s.Columns {
+		if to == "" {
+			return fmt.Errorf("new name of %s is empty", from)
+		}
+	}
+	return nil
+}
+
+func (s *renameStep) apply(t domain.TableData) (domain.TableData, error) {
+	for from := range s.Columns {
+		if _, err := tableops.Column(t, from); err != nil {
+			return t, err
+		}
+	}
+	cols := make([]domain.ColumnData, len(t.Columns))
+	for i, c := range t.Columns {
+		if to, ok := s.Columns[c.Name]; ok {
+			c.Name = to
+		}
+		cols[i] = c
+	}
+	t.Columns = cols
+	return t, nil
+}
+
+type filterOption struct {
+	Column domain.Name          `json:"column"`
+	Op     domain.FilterOp      `json:"op"`
+	Value  domain.PropertyValue `json:"value"`
+}
+
+type filterStep struct {
+	Filters []filterOption `json:"filters"`
+}
+
+func (s *filterStep) check() error {
+	if len(s.Filters) == 0 {
+		return errors.New("filters is required")
+	}
+	for _, f := range s.Filters {
+		if f.Column == "" {
+			return errors.New("filter column is required")
+		}
+		if !f.Op.Valid() {
+			return fmt.Errorf("unknown operator %q", f.Op)
+		}
+	}
+	return nil
+}
+
+func (s *filterStep) apply(t domain.TableData) (domain.TableData, error) {
+	filters := make([]domain.Filter, len(s.Filters))
+	for i, f := range s.Filters {
+		filters[i] = domain.Filter{Column: f.Column, Op: f.Op, Value: f.Value}
+	}
+	rows, err := tableops.FilterRows(t, filters)
+	if err != nil {
+		return t, err
+	}
+	return tableops.Take(t, rows), nil
+}
+
+type sortOption struct {
+	Column domain.Name `json:"column"`
+	Desc   bool        `json:"desc"`
+}
+
+type sortStep struct {
+	Keys []sortOption `json:"keys"`
+}
+
+func (s *sortStep) check() error {
+	if len(s.Keys) == 0 {
+		return errors.New("keys is required")
+	}
+	for _, k := range s.Keys {
+		if k.Column == "" {
+			return errors.New("sort column is required")
+		}
+	}
+	return nil
+}
+
+func (s *sortStep) apply(t domain.TableData) (domain.TableData, error) {
+	keys := make([]domain.SortKey, len(s.Keys))
+	for i, k := range s.Keys {
+		keys[i] = domain.SortKey{Column: k.Column, Descending: k.Desc}
+	}
+	rows := allRows(t)
+	if err := tableops.SortRows(t, rows, keys); err != nil {
+		return t, err
+	}
+	return tableops.Take(t, rows), nil
+}
+
+type limitStep struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+func (s *limitStep) check() error {
+	if s.Limit < 0 || s.Offset < 0 {
+		return errors.New("limit and offset must not be negative")
+	}
+	if s.Limit == 0 && s.Offset == 0 {
+		return errors.New("limit or offset is required")
+	}
+	return nil
+}
+
+func (s *limitStep) apply(t domain.TableData) (domain.TableData, error) {
+	return tableops.Take(t, tableops.Page(allRows(t), s.Offset, s.Limit)), nil
+}
+
+type groupByStep struct {
+	By           []domain.Name `json:"by"`
+	Aggregations []Aggregation `json:"aggregations"`
+}
+
+func (s *groupByStep) check() error {
+	if len(s.By) == 0 && len(s.Aggregations) == 0 {
+		return errors.New("by or aggregations is required")
+	}
+	for i := range s.Aggregations {
+		if err := s.Aggregations[i].check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *groupByStep) apply(t domain.TableData) (domain.TableData, error) {
+	by := make([]domain.ColumnData, len(s.By))
+	for i, name := range s.By {
+		idx, err := tableops.Column(t, name)
+		if err != nil {
+			return t, err
+		}
+		by[i] = t.Columns[idx]
+	}
+	groups := groupRows(allRows(t), by)
+
+	firsts := make([]int, len(groups))
+	for i, g := range groups {
+		firsts[i] = g[0]
+	}
+	out := domain.TableData{Columns: make([]domain.ColumnData, 0, len(by)+len(s.Aggregations))}
+	for _, c := range by {
+		out.Columns = append(out.Columns, c.Take(firsts))
+	}
+	for _, a := range s.Aggregations {
+		col, err := a.aggregate(t, groups)
+		if err != nil {
+			return t, err
+		}
+		out.Columns = append(out.Columns, col)
+	}
+	return out, nil
+}
+
+type pivotStep struct {
+	Row    domain.Name   `json:"row"`
+	Column domain.Name   `json:"column"`
+	Value  domain.Name   `json:"value"`
+	Func   AggregateFunc `json:"func"`
+}
+
+func (s *pivotStep) check() error {
+	if s.Row == "" || s.Column == "" || s.Value == "" {
+		return errors.New("row, column and value are required")
+	}
+	if s.Func == "" {
+		s.Func = AggregateFirst
+	}
+	if !s.Func.Valid() {
+		return fmt.Errorf("unknown function %q", s.Func)
+	}
+	return nil
+}
+
+func (s *pivotStep) apply(t domain.TableData) (domain.TableData, error) {
+	var cols [3]domain.ColumnData
+	for i, name := range []domain.Name{s.Row, s.Column, s.Value} {
+		idx, err := tableops.Column(t, name)
+		if err != nil {
+			return t, err
+		}
+		cols[i] = t.Columns[idx]
+	}
+	rowCol, keyCol, valueCol := cols[0], cols[1], cols[2]
+	typ, err := s.Func.resultType(valueCol)
+	if err != nil {
+		return t, err
+	}
+
+	rowGroups := groupRows(allRows(t), []domain.ColumnData{rowCol})
+	keyGroups := groupRows(allRows(t), []domain.ColumnData{keyCol})
+	keyOf := make([]int, t.NumRows())
+	for k, g := range keyGroups {
+		for _, i := range g {
+			keyOf[i] = k
+		}
+	}
+	// cells[r][k] holds the rows of row group r and key group k.
+	cells := make([][][]int, len(rowGroups))
+	firsts := make([]int, len(rowGroups))
+	for r, g := range rowGroups {
+		firsts[r] = g[0]
+		cells[r] = make([][]int, len(keyGroups))
+		for _, i := range g {
+			cells[r][keyOf[i]] = append(cells[r][keyOf[i]], i)
+		}
+	}
+
+	out := domain.TableData{Columns: []domain.ColumnData{rowCol.Take(firsts)}}
+	for k, g := range keyGroups {
+		name := domain.Name(keyCol.String(g[0]))
+		if keyCol.IsNull(g[0]) {
+			name = "null"
+		}
+		col := domain.NewColumnData(name, typ)
+		if s.Func != AggregateCount {
+			col.Meta = valueCol.Meta
+		}
+		for r := range rowGroups {
+			if len(cells[r][k]) == 0 {
+				col.AppendNull()
+				continue
+			}
+			if err := col.Append(s.Func.apply(valueCol, cells[r][k])); err != nil {
+				return t, err
+			}
+		}
+		out.Columns = append(out.Columns, col)
+	}
+	return out, nil
+}
+
+// operand is a column name or a number.
+type operand struct {
+	Column domain.Name
+	Number float64
+}
+
+func (o *operand) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		o.Column = domain.Name(name)
+		return nil
+	}
+	if err := json.Unmarshal(data, &o.Number); err != nil {
+		return errors.New("operand must be a column name or a number")
+	}
+	return nil
+}
+
+// value returns the operand at row i, with false for nulls.
+func (o operand) value(col *domain.ColumnData, i int) (float64, bool) {
+	if col == nil {
+		return o.Number, true
+	}
+	switch v := col.Value(i).(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// column returns the column the operand names, or nil for a number.
+func (o operand) column(t domain.TableData) (*domain.ColumnData, error) {
+	if o.Column == "" {
+		return nil, nil
+	}
+	idx, err := tableops.Column(t, o.Column)
+	if err != nil {
+		return nil, err
+	}
+	col := &t.Columns[idx]
+	if col.Type != domain.PropertyTypeInteger && col.Type != domain.PropertyTypeNumber {
+		return nil, fmt.Errorf("column %s is %s, not numeric", o.Column, col.Type)
+	}
+	return col, nil
+}
+
+type computeStep struct {
+	As    domain.Name `json:"as"`
+	Left  operand     `json:"left"`
+	Op    string      `json:"op"`
+	Right operand     `json:"right"`
+}
+
+func (s *computeStep) check() error {
+	if s.As == "" {
+		return errors.New("as is required")
+	}
+	switch s.Op {
+	case "+", "-", "*", "/":
+		return nil
+	}
+	return fmt.Errorf("unknown operator %q", s.Op)
+}
+
+// apply computes a number for each row; nulls and divisions by zero give
+// null.
+func (s *computeStep) apply(t domain.TableData) (domain.TableData, error) {
+	left, err := s.Left.column(t)
+	if err != nil {
+		return t, err
+	}
+	right, err := s.Right.column(t)
+	if err != nil {
+		return t, err
+	}
+	col := domain.NewColumnData(s.As, domain.PropertyTypeNumber)
+	for i := range t.NumRows() {
+		a, aok := s.Left.value(left, i)
+		b, bok := s.Right.value(right, i)
+		if !aok || !bok {
+			col.AppendNull()
+			continue
+		}
+		var v float64
+		switch s.Op {
+		case "+":
+			v = a + b
+		case "-":
+			v = a - b
+		case "*":
+			v = a * b
+		case "/":
+			v = a / b
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			col.AppendNull()
+			continue
+		}
+		col.Floats = append(col.Floats, v)
+	}
+	return setColumn(t, col), nil
+}
+
+// setColumn replaces the column of t named like col, or appends col.
+func setColumn(t domain.TableData, col domain.ColumnData) domain.TableData {
+	cols := make([]domain.ColumnData, 0, len(t.Columns)+1)
+	replaced := false
+	for _, c := range t.Columns {
+		if c.Name == col.Name {
+			c, replaced = col, true
+		}
+		cols = append(cols, c)
+	}
+	if !replaced {
+		cols = append(cols, col)
+	}
+	t.Columns = cols
+	return t
+}
+
+func allRows(t domain.TableData) []int {
+	rows := make([]int, t.NumRows())
+	for i := range rows {
+		rows[i] = i
+	}
+	return rows
+}
+
+// groupRows splits rows into groups sharing the values of cols, in the
+// order groups first appear. Nulls group together.
+func groupRows(rows []int, cols []domain.ColumnData) [][]int {
+	index := make(map[string]int)
+	var groups [][]int
+	var key []byte
+	for _, i := range rows {
+		key = key[:0]
+		for _, c := range cols {
+			if c.IsNull(i) {
+				key = append(key, 0)
+			} else {
+				key = append(key, 1)
+				key = append(key, c.String(i)...)
+			}
+			key = append(key, 0xff)
+		}
+		g, ok := index[string(key)]
+		if !ok {
+			g = len(groups)
+			index[string(key)] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
@@ -0,0 +1,151 @@
+// Package transform implements the pipelines of transformations that
+// components apply to the TableData of their queries, whatever the class
+// that produced it.
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+)
+
+// ErrInvalid is wrapped by errors about transformations that are
+// malformed or do not fit the table they are applied to.
+var ErrInvalid = errors.New("invalid transformation")
+
+type Type string
+
+const (
+	// TypeRename renames columns: {"columns": {"old": "new"}}.
+	TypeRename Type = "rename"
+	// TypeFilter keeps the rows matching every filter:
+	// {"filters": [{"column": "status", "op": "eq", "value": "open"}]}.
+	TypeFilter Type = "filter"
+	// TypeGroupBy aggregates the rows sharing the values of the by columns:
+	// {"by": ["region"], "aggregations": [{"column": "total", "func": "sum"}]}.
+	TypeGroupBy Type = "groupBy"
+	// TypePivot spreads the distinct values of column into columns, one
+	// row per value of row, with cells aggregated from value:
+	// {"row": "day", "column": "region", "value": "total", "func": "sum"}.
+	TypePivot Type = "pivot"
+	// TypeSort orders rows: {"keys": [{"column": "total", "desc": true}]}.
+	TypeSort Type = "sort"
+	// TypeLimit pages rows: {"limit": 10, "offset": 0}.
+	TypeLimit Type = "limit"
+	// TypeCompute adds or replaces a numeric column computed from two
+	// operands, each a column name or a number:
+	// {"as": "margin", "left": "revenue", "op": "-", "right": "cost"}.
+	TypeCompute Type = "compute"
+	// TypeExpression adds or replaces a column with the value of an
+	// expression over the columns of each row:
+	// {"as": "margin", "expression": "(revenue - cost) / revenue"}.
+	TypeExpression Type = "expression"
+	// TypeThresholds adds or replaces a string column with the status of
+	// the first rule that holds for each row:
+	// {"as": "status", "rules": [{"when": "cpu > 90", "status": "critical"}],
+	// "default": "ok"}.
+	TypeThresholds Type = "thresholds"
+	// TypeScript replaces the table with what a JavaScript transformer
+	// returns for it, given inline or as the name of a saved transformer:
+	// {"script": "return table.rows.filter(r => r.cpu > 90)"} or
+	// {"transformer": "topHosts"}. See package script.
+	TypeScript Type = "script"
+)
+
+// Transformation is a step as configured: its type and the options of
+// that type.
+type Transformation struct {
+	Type    Type            `json:"type"`
+	Options json.RawMessage `json:"options"`
+}
+
+type step interface {
+	apply(t domain.TableData) (domain.TableData, error)
+}
+
+// Pipeline applies its steps in order. The zero Pipeline does nothing.
+type Pipeline struct {
+	types []Type
+	steps []step
+}
+
+// Parse decodes a JSON array of Transformations and checks their options.
+// Expressions that do not compile are reported as *expression.Error,
+// with the field of the expression within the array.
+func Parse(raw string) (Pipeline, error) {
+	var ts []Transformation
+	if err := json.Unmarshal([]byte(raw), &ts); err != nil {
+		return Pipeline{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var p Pipeline
+	for i, t := range ts {
+		s, err := newStep(t)
+		if err != nil {
+			err = expression.At(err, fmt.Sprintf("[%d]", i))
+			return Pipeline{}, fmt.Errorf("%w: transformation %d (%s): %w", ErrInvalid, i+1, t.Type, err)
+		}
+		p.types = append(p.types, t.Type)
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+func newStep(t Transformation) (step, error) {
+	var s interface {
+		step
+		check() error
+	}
+	switch t.Type {
+	case TypeRename:
+		s = &renameStep{}
+	case TypeFilter:
+		s = &filterStep{}
+	case TypeGroupBy:
+		s = &groupByStep{}
+	case TypePivot:
+		s = &pivotStep{}
+	case TypeSort:
+		s = &sortStep{}
+	case TypeLimit:
+		s = &limitStep{}
+	case TypeCompute:
+		s = &computeStep{}
+	case TypeExpression:
+		s = &expressionStep{}
+	case TypeThresholds:
+		s = &thresholdsStep{}
+	case TypeScript:
+		s = &scriptStep{}
+	default:
+		return nil, fmt.Errorf("unknown type %q", t.Type)
+	}
+	if len(t.Options) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(t.Options))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, s.check()
+}
+
+func (p Pipeline) Empty() bool { return len(p.steps) == 0 }
+
+// Apply runs the steps on t. Truncated carries over; Total and NextCursor,
+// which describe the untransformed rows, do not.
+func (p Pipeline) Apply(t domain.TableData) (domain.TableData, error) {
+	truncated := t.Truncated
+	for i, s := range p.steps {
+		var err error
+		if t, err = s.apply(t); err != nil {
+			err = expression.At(err, fmt.Sprintf("[%d]", i))
+			return domain.TableData{}, fmt.Errorf("%w: transformation %d (%s): %w", ErrInvalid, i+1, p.types[i], err)
+		}
+	}
+	t.Total, t.NextCursor, t.Truncated = nil, "", truncated
+	return t, nil
+}
@@ -0,0 +1,220 @@
+package transform_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/pkg/script"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+	"github.com/smilu97/refana/internal/pkg/transform"
+)
+
+func newSales(t *testing.T) domain.TableData {
+	t.Helper()
+	day := domain.NewColumnData("day", domain.PropertyTypeString)
+	region := domain.NewColumnData("region", domain.PropertyTypeString)
+	total := domain.NewColumnData("total", domain.PropertyTypeInteger)
+	cost := domain.NewColumnData("cost", domain.PropertyTypeNumber)
+	for _, r := range []struct {
+		day, region string
+		total       any
+		cost        float64
+	}{
+		{"mon", "eu", int64(10), 4},
+		{"mon", "us", int64(20), 5},
+		{"tue", "eu", int64(30), 6},
+		{"tue", "eu", nil, 1},
+		{"tue", "us", int64(5), 0},
+	} {
+		for col, v := range map[*domain.ColumnData]any{&day: r.day, &region: r.region, &total: r.total, &cost: r.cost} {
+			if err := col.Append(v); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+	}
+	return domain.TableData{Columns: []domain.ColumnData{day, region, total, cost}}
+}
+
+func apply(t *testing.T, raw string, table domain.TableData) domain.TableData {
+	t.Helper()
+	p, err := transform.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	out, err := p.Apply(table)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return out
+}
+
+func TestGroupByAndSort(t *testing.T) {
+	out := apply(t, `[
+		{"type": "filter", "options": {"filters": [{"column": "cost", "op": "gt", "value": "0"}]}},
+		{"type": "groupBy", "options": {"by": ["region"], "aggregations": [
+			{"column": "total", "func": "sum"},
+			{"column": "total", "func": "count", "as": "n"},
+			{"column": "cost", "func": "avg"}
+		]}},
+		{"type": "sort", "options": {"keys": [{"column": "sum(total)", "desc": true}]}},
+		{"type": "rename", "options": {"columns": {"region": "r"}}}
+	]`, newSales(t))
+
+	if len(out.Columns) != 4 || out.Columns[0].Name != "r" || out.Columns[1].Name != "sum(total)" {
+		t.Fatalf("columns = %+v", out.Columns)
+	}
+	// eu: 10 + 30 with a null total; us: 20, its 5 filtered out by cost
+	if r := out.Columns[0].Values; len(r) != 2 || r[0] != "eu" || r[1] != "us" {
+		t.Fatalf("regions = %v, want [eu us]", r)
+	}
+	if s := out.Columns[1]; s.Type != domain.PropertyTypeInteger || s.Ints[0] != 40 || s.Ints[1] != 20 {
+		t.Fatalf("sums = %+v, want integers 40, 20", s)
+	}
+	if n := out.Columns[2].Ints; n[0] != 2 || n[1] != 1 {
+		t.Fatalf("counts = %v, want [2 1]", n)
+	}
+	if avg := out.Columns[3].Floats; avg[0] != 11.0/3 || avg[1] != 5 {
+		t.Fatalf("avg = %v", avg)
+	}
+}
+
+func TestPivotComputeLimit(t *testing.T) {
+	out := apply(t, `[{"type": "pivot", "options": {"row": "day", "column": "region", "value": "total", "func": "sum"}}]`, newSales(t))
+	if len(out.Columns) != 3 || out.Columns[1].Name != "eu" || out.Columns[2].Name != "us" {
+		t.Fatalf("columns = %+v", out.Columns)
+	}
+	if eu := out.Columns[1].Ints; eu[0] != 10 || eu[1] != 30 {
+		t.Fatalf("eu = %v, want [10 30]", eu)
+	}
+
+	out = apply(t, `[
+		{"type": "compute", "options": {"as": "ratio", "left": "total", "op": "/", "right": "cost"}},
+		{"type": "compute", "options": {"as": "cost", "left": "cost", "op": "*", "right": 2}},
+		{"type": "limit", "options": {"offset": 3, "limit": 5}}
+	]`, newSales(t))
+	if len(out.Columns) != 5 || out.NumRows() != 2 {
+		t.Fatalf("table = %+v, want 5 columns and 2 rows", out)
+	}
+	// total null, then 5 / 0: both null
+	ratio := out.Columns[4]
+	if !ratio.IsNull(0) || !ratio.IsNull(1) {
+		t.Fatalf("ratio = %+v, want nulls", ratio)
+	}
+	if cost := out.Columns[3].Floats; cost[0] != 2 || cost[1] != 0 {
+		t.Fatalf("cost = %v, want [2 0]", cost)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`{}`,
+		`[{"type": "explode"}]`,
+		`[{"type": "rename"}]`,
+		`[{"type": "limit", "options": {"limt": 5}}]`,
+		`[{"type": "groupBy", "options": {"aggregations": [{"func": "median", "column": "total"}]}}]`,
+		`[{"type": "groupBy", "options": {"aggregations": [{"func": "sum"}]}}]`,
+		`[{"type": "compute", "options": {"as": "x", "left": "a", "op": "%", "right": 1}}]`,
+		`[{"type": "compute", "options": {"as": "x", "left": true, "op": "+", "right": 1}}]`,
+	} {
+		if _, err := transform.Parse(raw); !errors.Is(err, transform.ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", raw, err)
+		}
+	}
+
+	p, err := transform.Parse(`[{"type": "groupBy", "options": {"by": ["nope"]}}]`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	_, err = p.Apply(newSales(t))
+	if !errors.Is(err, transform.ErrInvalid) || !errors.Is(err, tableops.ErrUnknownColumn) {
+		t.Fatalf("unknown column: err = %v", err)
+	}
+	p, _ = transform.Parse(`[{"type": "groupBy", "options": {"aggregations": [{"column": "day", "func": "sum"}]}}]`)
+	if _, err := p.Apply(newSales(t)); !errors.Is(err, transform.ErrInvalid) {
+		t.Fatalf("sum of strings: err = %v", err)
+	}
+}
+
+func TestExpressionsAndThresholds(t *testing.T) {
+	out := apply(t, `[
+		{"type": "expression", "options": {"as": "margin", "expression": "total == nil ? nil : total - cost"}},
+		{"type": "expression", "options": {"as": "label", "expression": "upper(region) + '/' + day"}},
+		{"type": "thresholds", "options": {"as": "status", "default": "ok", "rules": [
+			{"when": "margin == nil", "status": "unknown"},
+			{"when": "margin > 20", "status": "high"}
+		]}}
+	]`, newSales(t))
+	margin, label, status := out.Columns[4], out.Columns[5], out.Columns[6]
+	if margin.Type != domain.PropertyTypeNumber || margin.Floats[0] != 6 || !margin.IsNull(3) {
+		t.Fatalf("margin = %+v", margin)
+	}
+	if label.Values[0] != "EU/mon" {
+		t.Fatalf("label = %v", label.Values)
+	}
+	want := []domain.PropertyValue{"ok", "ok", "high", "unknown", "ok"}
+	for i, w := range want {
+		if status.Values[i] != w {
+			t.Fatalf("status = %v, want %v", status.Values, want)
+		}
+	}
+}
+
+func TestExpressionErrorsNameTheirField(t *testing.T) {
+	_, err := transform.Parse(`[
+		{"type": "limit", "options": {"limit": 1}},
+		{"type": "thresholds", "options": {"as": "s", "rules": [{"when": "a > 1", "status": "x"}, {"when": "a >", "status": "y"}]}}
+	]`)
+	var e *expression.Error
+	if !errors.As(err, &e) || e.Field != "[1].options.rules[1].when" || e.Line != 1 {
+		t.Fatalf("err = %#v, want the field of the second rule", e)
+	}
+
+	p, err := transform.Parse(`[{"type": "thresholds", "options": {"as": "s", "rules": [{"when": "region", "status": "x"}]}}]`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := p.Apply(newSales(t)); !errors.Is(err, transform.ErrInvalid) {
+		t.Fatalf("non-boolean rule: err = %v", err)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	out := apply(t, `[
+		{"type": "script", "options": {"script": "return table.rows.filter(r => r.region === 'us').map(r => ({day: r.day, cost: r.cost * 2}))"}},
+		{"type": "sort", "options": {"keys": [{"column": "cost"}]}}
+	]`, newSales(t))
+	if len(out.Columns) != 2 || out.NumRows() != 2 || out.Columns[1].Ints[0] != 0 || out.Columns[1].Ints[1] != 10 {
+		t.Fatalf("out = %+v", out)
+	}
+
+	p, err := transform.Parse(`[{"type": "script", "options": {"transformer": "double"}}, {"type": "script", "options": {"transformer": "double"}}]`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if names := p.Transformers(); len(names) != 1 || names[0] != "double" {
+		t.Fatalf("Transformers = %v", names)
+	}
+	if _, err := p.Apply(newSales(t)); !errors.Is(err, transform.ErrInvalid) {
+		t.Fatalf("unbound: err = %v", err)
+	}
+	double, _ := script.Compile(`return table.rows.map(r => ({total: r.total * 2}))`)
+	if err := p.Bind(func(domain.Name) (script.Program, error) { return double, nil }); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	out, err = p.Apply(newSales(t))
+	if err != nil || out.Columns[0].Ints[0] != 40 {
+		t.Fatalf("bound: out = %+v, err = %v", out, err)
+	}
+
+	for _, raw := range []string{
+		`[{"type": "script", "options": {}}]`,
+		`[{"type": "script", "options": {"script": "return (", "transformer": "x"}}]`,
+		`[{"type": "script", "options": {"script": "return ("}}]`,
+	} {
+		if _, err := transform.Parse(raw); !errors.Is(err, transform.ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", raw, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type AlertRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRuleRepository(db *gorm.DB) *AlertRuleRepository {
+	return &AlertRuleRepository{db: db}
+}
+
+func (r *AlertRuleRepository) Create(ctx context.Context, rule domain.AlertRule) error {
+	model, err := toAlertRuleModel(rule)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&model).Error
+}
+
+func (r *AlertRuleRepository) Get(ctx context.Context, id domain.AlertRuleID) (domain.AlertRule, error) {
+	var model alertRuleModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.AlertRule{}, err
+	}
+	return toAlertRuleDomain(model)
+}
+
+// List returns every rule ordered by name.
+func (r *AlertRuleRepository) List(ctx context.Context) ([]domain.AlertRule, error) {
+	var models []alertRuleModel
+	if err := r.db.WithContext(ctx).Order("name, id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.AlertRule, 0, len(models))
+	for _, m := range models {
+		rule, err := toAlertRuleDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// alertStatusColumns are written by UpdateStatus only.
+var alertStatusColumns = []string{"state", "state_since", "evaluated_at", "last_error"}
+
+// Update overwrites the configuration of the rule, leaving its status.
+func (r *AlertRuleRepository) Update(ctx context.Context, rule domain.AlertRule) error {
+	model, err := toAlertRuleModel(rule)
+	if err != nil {
+		return err
+	}
+	res := r.db.WithContext(ctx).Select("*").Omit(append([]string{"created_at"}, alertStatusColumns...)...).Updates(&model)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateStatus writes the status of a rule and appends events to its
+// history, atomically.
+func (r *AlertRuleRepository) UpdateStatus(ctx context.Context, id domain.AlertRuleID, status domain.AlertStatus, events []domain.AlertEvent) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&alertRuleModel{}).Where("id = ?", id.Int64()).Updates(map[string]any{
+			"state":        string(status.State),
+			"state_since":  status.Since,
+			"evaluated_at": status.EvaluatedAt,
+			"last_error":   status.Error,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, e := range events {
+			model := alertEventModel{
+				RuleID:    e.RuleID.Int64(),
+				FromState: string(e.From),
+				ToState:   string(e.To),
+				Error:     e.Error,
+				At:        e.At,
+			}
+			if err := tx.Create(&model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes the rule and its history.
+func (r *AlertRuleRepository) Delete(ctx context.Context, id domain.AlertRuleID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&alertRuleModel{}, "id = ?", id.Int64())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&alertEventModel{}, "rule_id = ?", id.Int64()).Error
+	})
+}
+
+// History returns the latest limit events of the rule, newest first.
+func (r *AlertRuleRepository) History(ctx context.Context, id domain.AlertRuleID, limit int) ([]domain.AlertEvent, error) {
+	var models []alertEventModel
+	err := r.db.WithContext(ctx).
+		Where("rule_id = ?", id.Int64()).
+		Order("at DESC, id DESC").
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.AlertEvent, len(models))
+	for i, m := range models {
+		out[i] = domain.AlertEvent{
+			RuleID: domain.NewAlertRuleID(m.RuleID),
+			From:   domain.AlertState(m.FromState),
+			To:     domain.AlertState(m.ToState),
+			Error:  m.Error,
+			At:     m.At,
+		}
+	}
+	return out, nil
+}
+
+type alertRuleModel struct {
+	ID              int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name            string
+	ComponentID     int64
+	QueryJSON       string
+	RangeFrom       string
+	RangeTo         string
+	Condition       string
+	IntervalNs      int64
+	ForNs           int64
+	LabelsJSON      string
+	AnnotationsJSON string
+	Paused          bool
+	State           string
+	StateSince      time.Time
+	EvaluatedAt     *time.Time
+	LastError       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (alertRuleModel) TableName() string { return "alert_rules" }
+
+type alertEventModel struct {
+	ID        int64 `gorm:"primaryKey"`
+	RuleID    int64
+	FromState string
+	ToState   string
+	Error     string
+	At        time.Time
+}
+
+func (alertEventModel) TableName() string { return "alert_events" }
+
+func toAlertRuleModel(r domain.AlertRule) (alertRuleModel, error) {
+	model := alertRuleModel{
+		ID:          r.ID.Int64(),
+		Name:        string(r.Name),
+		RangeFrom:   r.From,
+		RangeTo:     r.To,
+		Condition:   r.Condition,
+		IntervalNs:  int64(r.Interval),
+		ForNs:       int64(r.For),
+		Paused:      r.Paused,
+		State:       string(r.Status.State),
+		StateSince:  r.Status.Since,
+		EvaluatedAt: r.Status.EvaluatedAt,
+		LastError:   r.Status.Error,
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if r.ComponentID != nil {
+		model.ComponentID = r.ComponentID.Int64()
+	}
+	for dst, v := range map[*string]any{&model.QueryJSON: r.Query, &model.LabelsJSON: r.Labels, &model.AnnotationsJSON: r.Annotations} {
+		raw, err := marshalOptional(v)
+		if err != nil {
+			return alertRuleModel{}, err
+		}
+		*dst = raw
+	}
+	return model, nil
+}
+
+func toAlertRuleDomain(m alertRuleModel) (domain.AlertRule, error) {
+	r := domain.AlertRule{
+		ID:        domain.NewAlertRuleID(m.ID),
+		Name:      domain.Name(m.Name),
+		From:      m.RangeFrom,
+		To:        m.RangeTo,
+		Condition: m.Condition,
+		Interval:  domain.Duration(m.IntervalNs),
+		For:       domain.Duration(m.ForNs),
+		Paused:    m.Paused,
+		Status: domain.AlertStatus{
+			State:       domain.AlertState(m.State),
+			Since:       m.StateSince,
+			EvaluatedAt: m.EvaluatedAt,
+			Error:       m.LastError,
+		},
+		UpdatedAt: m.UpdatedAt,
+	}
+	if m.ComponentID != 0 {
+		id := domain.NewComponentID(m.ComponentID)
+		r.ComponentID = &id
+	}
+	for _, f := range []struct {
+		raw string
+		dst any
+	}{{m.QueryJSON, &r.Query}, {m.LabelsJSON, &r.Labels}, {m.AnnotationsJSON, &r.Annotations}} {
+		if f.raw == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(f.raw), f.dst); err != nil {
+			return domain.AlertRule{}, err
+		}
+	}
+	return r, nil
+}
+
+// marshalOptional writes v as JSON, or as the empty string when it is nil
+// or empty.
+func marshalOptional(v any) (string, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	switch string(raw) {
+	case "null", "{}", "[]":
+		return "", nil
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,73 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestAlertRuleRepository(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewAlertRuleRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	comp := domain.NewComponentID(7)
+	rule := domain.AlertRule{
+		ID:          domain.NewAlertRuleID(1),
+		Name:        "cpu",
+		ComponentID: &comp,
+		Condition:   "max(cpu) > 90",
+		Interval:    domain.Duration(time.Minute),
+		For:         domain.Duration(5 * time.Minute),
+		Labels:      map[domain.Name]domain.PropertyValue{"team": "sre"},
+		Status:      domain.AlertStatus{State: domain.AlertNormal, Since: now},
+		UpdatedAt:   now,
+	}
+	if err := repo.Create(ctx, rule); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.Get(ctx, rule.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.ComponentID == nil || *got.ComponentID != comp || got.Query != nil || got.For != rule.For || got.Labels["team"] != "sre" || got.Annotations != nil {
+		t.Fatalf("Get = %+v", got)
+	}
+
+	evaluated := now.Add(time.Minute)
+	status := domain.AlertStatus{State: domain.AlertFiring, Since: evaluated, EvaluatedAt: &evaluated}
+	events := []domain.AlertEvent{{RuleID: rule.ID, From: domain.AlertNormal, To: domain.AlertFiring, At: evaluated}}
+	if err := repo.UpdateStatus(ctx, rule.ID, status, events); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	// configuration updates leave the status alone
+	rule.Condition, rule.Labels = "true", nil
+	if err := repo.Update(ctx, rule); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, _ = repo.Get(ctx, rule.ID)
+	if got.Condition != "true" || got.Labels != nil || got.Status.State != domain.AlertFiring || got.Status.EvaluatedAt == nil || !got.Status.EvaluatedAt.Equal(evaluated) {
+		t.Fatalf("after Update = %+v", got)
+	}
+	history, err := repo.History(ctx, rule.ID, 10)
+	if err != nil || len(history) != 1 || history[0].To != domain.AlertFiring {
+		t.Fatalf("History = %+v, %v", history, err)
+	}
+
+	if err := repo.Delete(ctx, rule.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if history, _ := repo.History(ctx, rule.ID, 10); len(history) != 0 {
+		t.Fatalf("history outlived its rule: %+v", history)
+	}
+	if err := repo.UpdateStatus(ctx, rule.ID, status, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("UpdateStatus deleted = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -24,7 +24,12 @@ func (r *ComponentRepository) Create(ctx context.Context, comp domain.Component)
 	if err := toComponentModel(comp, &model); err != nil {
 		return err
 	}
-	return r.db.WithContext(ctx).Create(&model).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&model).Error; err != nil {
+			return err
+		}
+		return replaceDataSourceRefs(tx, comp)
+	})
 }
 
 func (r *ComponentRepository) Get(ctx context.Context, id domain.ComponentID) (domain.Component, error) {
@@ -51,12 +56,20 @@ func (r *ComponentRepository) Update(ctx context.Context, comp domain.Component)
 		if err := toComponentModel(comp, &updated); err != nil {
 			return err
 		}
-		return tx.Model(&existing).Updates(updated).Error
+		if err := tx.Model(&existing).Updates(updated).Error; err != nil {
+			return err
+		}
+		return replaceDataSourceRefs(tx, comp)
 	})
 }
 
 func (r *ComponentRepository) Delete(ctx context.Context, id domain.ComponentID) error {
-	return r.db.WithContext(ctx).Delete(&componentModel{}, "id = ?", id.Int64()).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&componentDataSourceModel{}, "component_id = ?", id.Int64()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&componentModel{}, "id = ?", id.Int64()).Error
+	})
 }
 
 func (r *ComponentRepository) List(ctx context.Context) ([]domain.Component, error) {
@@ -80,6 +93,7 @@ type componentModel struct {
 	ID               int64 `gorm:"primaryKey;autoIncrement:false"`
 	VisualisationID  string
 	QueryJSON        string
+	QueriesJSON      string
 	Name             string
 	CoordinationJSON string
 	PropertiesJSON   string
@@ -94,6 +108,10 @@ func toComponentModel(src domain.Component, dst *componentModel) error {
 	if err != nil {
 		return err
 	}
+	queriesBytes, err := json.Marshal(componentQueries(src))
+	if err != nil {
+		return err
+	}
 	coordBytes, err := json.Marshal(src.Coordination)
 	if err != nil {
 		return err
@@ -106,6 +124,7 @@ func toComponentModel(src domain.Component, dst *componentModel) error {
 	dst.ID = src.ID.Int64()
 	dst.VisualisationID = string(src.VisualisationID)
 	dst.QueryJSON = string(queryBytes)
+	dst.QueriesJSON = string(queriesBytes)
 	dst.Name = string(src.Name)
 	dst.CoordinationJSON = string(coordBytes)
 	dst.PropertiesJSON = string(propsBytes)
@@ -114,11 +133,32 @@ func toComponentModel(src domain.Component, dst *componentModel) error {
 	return nil
 }
 
+// componentQueries returns the queries of comp, falling back to Query for
+// callers that only set that.
+func componentQueries(comp domain.Component) []domain.Query {
+	if len(comp.Queries) > 0 {
+		return comp.Queries
+	}
+	if q := comp.Query; !q.DataSourceID.IsZero() || q.DataSourceAlias != "" {
+		return []domain.Query{q}
+	}
+	return nil
+}
+
 func toComponentDomain(m componentModel) (domain.Component, error) {
 	var query domain.Query
 	if err := json.Unmarshal([]byte(m.QueryJSON), &query); err != nil {
 		return domain.Component{}, err
 	}
+	// rows written before components had several queries only hold Query
+	var queries []domain.Query
+	if m.QueriesJSON != "" {
+		if err := json.Unmarshal([]byte(m.QueriesJSON), &queries); err != nil {
+			return domain.Component{}, err
+		}
+	} else if !query.DataSourceID.IsZero() || query.DataSourceAlias != "" {
+		queries = []domain.Query{query}
+	}
 	var coord domain.Coordination
 	if err := json.Unmarshal([]byte(m.CoordinationJSON), &coord); err != nil {
 		return domain.Component{}, err
@@ -132,6 +172,7 @@ func toComponentDomain(m componentModel) (domain.Component, error) {
 		ID:              domain.NewComponentID(m.ID),
 		VisualisationID: domain.VisualisationID(m.VisualisationID),
 		Query:           query,
+		Queries:         queries,
 		Name:            domain.Name(m.Name),
 		Coordination:    coord,
 		Properties:      props,
@@ -139,5 +180,42 @@ func toComponentDomain(m componentModel) (domain.Component, error) {
 	}, nil
 }
 
+// Storage model for component_data_sources, kept in sync with QueriesJSON.
+type componentDataSourceModel struct {
+	ID              int64 `gorm:"primaryKey"`
+	ComponentID     int64
+	DataSourceID    int64
+	DataSourceAlias string
+}
+
+func (componentDataSourceModel) TableName() string { return "component_data_sources" }
+
+// replaceDataSourceRefs rewrites the reference rows of comp from its
+// queries, one row per distinct data source.
+func replaceDataSourceRefs(tx *gorm.DB, comp domain.Component) error {
+	if err := tx.Delete(&componentDataSourceModel{}, "component_id = ?", comp.ID.Int64()).Error; err != nil {
+		return err
+	}
+	seen := make(map[componentDataSourceModel]bool)
+	for _, q := range componentQueries(comp) {
+		if q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+			continue
+		}
+		ref := componentDataSourceModel{
+			ComponentID:     comp.ID.Int64(),
+			DataSourceID:    q.DataSourceID.Int64(),
+			DataSourceAlias: string(q.DataSourceAlias),
+		}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if err := tx.Create(&ref).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Ensure interface compliance with errors.Is on not found cases.
 var ErrNotFound = errors.New("component not found")
@@ -51,6 +51,9 @@ func TestComponentRepositoryCRUD_LastWriteWins(t *testing.T) {
 	if got.Name != comp.Name {
 		t.Fatalf("Get Name = %s, want %s", got.Name, comp.Name)
 	}
+	if len(got.Queries) != 1 || got.Queries[0].Name != "main" {
+		t.Fatalf("Get Queries = %+v, want the single query", got.Queries)
+	}
 
 	// Update with newer timestamp should override
 	newer := comp
@@ -114,6 +117,51 @@ func TestComponentRepositoryList(t *testing.T) {
 	}
 }
 
+func TestComponentRepositoryQueries(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewComponentRepository(db)
+	dsRepo := repository.NewDataSourceRepository(db)
+	for _, id := range []int64{1, 2} {
+		if err := dsRepo.Create(ctx, domain.DataSource{ID: domain.NewDataSourceID(id), ClassID: "postgres", Name: "ds"}); err != nil {
+			t.Fatalf("Create ds: %v", err)
+		}
+	}
+
+	queries := []domain.Query{
+		{Name: "db", DataSourceID: domain.NewDataSourceID(1)},
+		{Name: "api", DataSourceID: domain.NewDataSourceID(2)},
+		{Name: "db2", DataSourceID: domain.NewDataSourceID(1)},
+	}
+	comp := domain.Component{
+		ID:              domain.NewComponentID(1),
+		VisualisationID: "table",
+		Query:           queries[0],
+		Queries:         queries,
+		Name:            "c",
+		UpdatedAt:       time.Now(),
+	}
+	if err := repo.Create(ctx, comp); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.Get(ctx, comp.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Queries) != 3 || got.Queries[1].Name != "api" {
+		t.Fatalf("Queries = %+v, want all three", got.Queries)
+	}
+	for _, id := range []int64{1, 2} {
+		usages, err := dsRepo.Usages(ctx, domain.NewDataSourceID(id))
+		if err != nil {
+			t.Fatalf("Usages: %v", err)
+		}
+		if len(usages) != 1 {
+			t.Fatalf("data source %d: usages = %d, want the component once", id, len(usages))
+		}
+	}
+}
+
 // Helpers
 func openTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
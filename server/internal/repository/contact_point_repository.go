@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ErrDuplicateContactPoint is returned when another contact point has the
+// same name.
+var ErrDuplicateContactPoint = errors.New("contact point name already in use")
+
+type ContactPointRepository struct {
+	db *gorm.DB
+}
+
+func NewContactPointRepository(db *gorm.DB) *ContactPointRepository {
+	return &ContactPointRepository{db: db}
+}
+
+func (r *ContactPointRepository) Create(ctx context.Context, cp domain.ContactPoint) error {
+	model, err := toContactPointModel(cp)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureContactPointNameAvailable(tx, cp); err != nil {
+			return err
+		}
+		return tx.Create(&model).Error
+	})
+}
+
+func (r *ContactPointRepository) Get(ctx context.Context, id domain.ContactPointID) (domain.ContactPoint, error) {
+	var model contactPointModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.ContactPoint{}, err
+	}
+	return toContactPointDomain(model)
+}
+
+// List returns every contact point ordered by name.
+func (r *ContactPointRepository) List(ctx context.Context) ([]domain.ContactPoint, error) {
+	var models []contactPointModel
+	if err := r.db.WithContext(ctx).Order("name").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.ContactPoint, 0, len(models))
+	for _, m := range models {
+		cp, err := toContactPointDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cp)
+	}
+	return out, nil
+}
+
+// Update overwrites every field of the contact point.
+func (r *ContactPointRepository) Update(ctx context.Context, cp domain.ContactPoint) error {
+	model, err := toContactPointModel(cp)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureContactPointNameAvailable(tx, cp); err != nil {
+			return err
+		}
+		res := tx.Select("*").Omit("created_at").Updates(&model)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *ContactPointRepository) Delete(ctx context.Context, id domain.ContactPointID) error {
+	res := r.db.WithContext(ctx).Delete(&contactPointModel{}, "id = ?", id.Int64())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ensureContactPointNameAvailable(tx *gorm.DB, cp domain.ContactPoint) error {
+	var count int64
+	err := tx.Model(&contactPointModel{}).
+		Where("name = ? AND id <> ?", string(cp.Name), cp.ID.Int64()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateContactPoint
+	}
+	return nil
+}
+
+type contactPointModel struct {
+	ID           int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name         string
+	Type         string
+	SettingsJSON string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (contactPointModel) TableName() string { return "contact_points" }
+
+func toContactPointModel(cp domain.ContactPoint) (contactPointModel, error) {
+	settings, err := json.Marshal(cp.Settings)
+	if err != nil {
+		return contactPointModel{}, err
+	}
+	return contactPointModel{
+		ID:           cp.ID.Int64(),
+		Name:         string(cp.Name),
+		Type:         string(cp.Type),
+		SettingsJSON: string(settings),
+		UpdatedAt:    cp.UpdatedAt,
+	}, nil
+}
+
+func toContactPointDomain(m contactPointModel) (domain.ContactPoint, error) {
+	var settings map[domain.PropertyKey]domain.PropertyValue
+	if err := json.Unmarshal([]byte(m.SettingsJSON), &settings); err != nil {
+		return domain.ContactPoint{}, err
+	}
+	return domain.ContactPoint{
+		ID:        domain.NewContactPointID(m.ID),
+		Name:      domain.Name(m.Name),
+		Type:      domain.ContactPointTypeID(m.Type),
+		Settings:  settings,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
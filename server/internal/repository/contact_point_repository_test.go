@@ -0,0 +1,72 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestContactPointRepositoryCRUD(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewContactPointRepository(db)
+
+	now := time.Now()
+	ops := domain.ContactPoint{
+		ID:        domain.NewContactPointID(1),
+		Name:      "ops",
+		Type:      "webhook",
+		Settings:  map[domain.PropertyKey]domain.PropertyValue{"url": "https://hooks.example.com/ops"},
+		UpdatedAt: now,
+	}
+	mail := domain.ContactPoint{
+		ID:        domain.NewContactPointID(2),
+		Name:      "mail",
+		Type:      "email",
+		Settings:  map[domain.PropertyKey]domain.PropertyValue{"host": "smtp.example.com"},
+		UpdatedAt: now,
+	}
+	for _, cp := range []domain.ContactPoint{ops, mail} {
+		if err := repo.Create(ctx, cp); err != nil {
+			t.Fatalf("Create %s: %v", cp.Name, err)
+		}
+	}
+	dup := ops
+	dup.ID = domain.NewContactPointID(3)
+	if err := repo.Create(ctx, dup); !errors.Is(err, repository.ErrDuplicateContactPoint) {
+		t.Fatalf("Create duplicate = %v, want ErrDuplicateContactPoint", err)
+	}
+
+	list, err := repo.List(ctx)
+	if err != nil || len(list) != 2 || list[0].Name != "mail" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	got, err := repo.Get(ctx, ops.ID)
+	if err != nil || got.Type != "webhook" || got.Settings["url"] != "https://hooks.example.com/ops" {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	got.Name = "mail"
+	if err := repo.Update(ctx, got); !errors.Is(err, repository.ErrDuplicateContactPoint) {
+		t.Fatalf("Update onto a taken name = %v, want ErrDuplicateContactPoint", err)
+	}
+	got.Name, got.Settings = "ops-hook", map[domain.PropertyKey]domain.PropertyValue{"url": "https://hooks.example.com/v2"}
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := repo.Get(ctx, ops.ID); got.Name != "ops-hook" || got.Settings["url"] != "https://hooks.example.com/v2" {
+		t.Fatalf("Get after Update = %+v", got)
+	}
+	if err := repo.Delete(ctx, ops.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, ops.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,6 +11,13 @@ import (
 	"github.com/smilu97/refana/internal/pkg/domain"
 )
 
+// ErrDuplicateAlias is returned when another data source already owns the alias.
+var ErrDuplicateAlias = errors.New("data source alias already in use")
+
+// ErrDataSourceInUse is returned when deleting a data source that components
+// still reference under the restrict policy.
+var ErrDataSourceInUse = errors.New("data source is referenced by components")
+
 type DataSourceRepository struct {
 	db *gorm.DB
 }
@@ -26,7 +34,12 @@ func (r *DataSourceRepository) Create(ctx context.Context, ds domain.DataSource)
 	if err := toDataSourceModel(ds, &model); err != nil {
 		return err
 	}
-	return r.db.WithContext(ctx).Create(&model).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureAliasAvailable(tx, ds); err != nil {
+			return err
+		}
+		return tx.Create(&model).Error
+	})
 }
 
 func (r *DataSourceRepository) Get(ctx context.Context, id domain.DataSourceID) (domain.DataSource, error) {
@@ -37,6 +50,14 @@ func (r *DataSourceRepository) Get(ctx context.Context, id domain.DataSourceID)
 	return toDataSourceDomain(model)
 }
 
+func (r *DataSourceRepository) GetByAlias(ctx context.Context, alias domain.Alias) (domain.DataSource, error) {
+	var model dataSourceModel
+	if err := r.db.WithContext(ctx).First(&model, "alias = ?", string(alias)).Error; err != nil {
+		return domain.DataSource{}, err
+	}
+	return toDataSourceDomain(model)
+}
+
 // Update applies last-write-wins using provided updatedAt timestamp.
 func (r *DataSourceRepository) Update(ctx context.Context, ds domain.DataSource, updatedAt time.Time) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -47,6 +68,9 @@ func (r *DataSourceRepository) Update(ctx context.Context, ds domain.DataSource,
 		if !updatedAt.After(existing.UpdatedAt) {
 			return nil
 		}
+		if err := ensureAliasAvailable(tx, ds); err != nil {
+			return err
+		}
 
 		ds.UpdatedAt = updatedAt
 		var model dataSourceModel
@@ -57,8 +81,105 @@ func (r *DataSourceRepository) Update(ctx context.Context, ds domain.DataSource,
 	})
 }
 
+// Delete removes a data source unless components still reference it.
 func (r *DataSourceRepository) Delete(ctx context.Context, id domain.DataSourceID) error {
-	return r.db.WithContext(ctx).Delete(&dataSourceModel{}, "id = ?", id.Int64()).Error
+	_, err := r.DeleteWithPolicy(ctx, id, domain.DataSourceDeleteRestrict)
+	return err
+}
+
+// DeleteWithPolicy removes a data source, treating the components that
+// reference it according to policy:
+//   - restrict: fail with ErrDataSourceInUse
+//   - cascade: delete them as well
+//   - force: keep them, leaving their references dangling
+//
+// It returns the components a cascade deleted.
+func (r *DataSourceRepository) DeleteWithPolicy(
+	ctx context.Context,
+	id domain.DataSourceID,
+	policy domain.DataSourceDeletePolicy,
+) ([]domain.ComponentID, error) {
+	var deleted []domain.ComponentID
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing dataSourceModel
+		if err := tx.First(&existing, "id = ?", id.Int64()).Error; err != nil {
+			return err
+		}
+		componentIDs, err := referencingComponentIDs(tx, existing)
+		if err != nil {
+			return err
+		}
+
+		switch policy {
+		case domain.DataSourceDeleteCascade:
+			if len(componentIDs) > 0 {
+				if err := tx.Delete(&componentDataSourceModel{}, "component_id IN ?", componentIDs).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&componentModel{}, "id IN ?", componentIDs).Error; err != nil {
+					return err
+				}
+				for _, cid := range componentIDs {
+					deleted = append(deleted, domain.NewComponentID(cid))
+				}
+			}
+		case domain.DataSourceDeleteForce:
+		default:
+			if len(componentIDs) > 0 {
+				return ErrDataSourceInUse
+			}
+		}
+		return tx.Delete(&existing).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Usages lists the components whose query references the data source
+// either by ID or by its alias.
+func (r *DataSourceRepository) Usages(ctx context.Context, id domain.DataSourceID) ([]domain.Component, error) {
+	db := r.db.WithContext(ctx)
+	var existing dataSourceModel
+	if err := db.First(&existing, "id = ?", id.Int64()).Error; err != nil {
+		return nil, err
+	}
+	componentIDs, err := referencingComponentIDs(db, existing)
+	if err != nil {
+		return nil, err
+	}
+	if len(componentIDs) == 0 {
+		return []domain.Component{}, nil
+	}
+
+	var models []componentModel
+	if err := db.Find(&models, "id IN ?", componentIDs).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.Component, 0, len(models))
+	for _, m := range models {
+		c, err := toComponentDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func referencingComponentIDs(tx *gorm.DB, ds dataSourceModel) ([]int64, error) {
+	q := tx.Model(&componentDataSourceModel{}).Distinct("component_id")
+	if ds.Alias != "" {
+		q = q.Where("data_source_id = ? OR data_source_alias = ?", ds.ID, ds.Alias)
+	} else {
+		q = q.Where("data_source_id = ?", ds.ID)
+	}
+	var ids []int64
+	if err := q.Pluck("component_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
 func (r *DataSourceRepository) List(ctx context.Context) ([]domain.DataSource, error) {
@@ -77,6 +198,25 @@ func (r *DataSourceRepository) List(ctx context.Context) ([]domain.DataSource, e
 	return out, nil
 }
 
+// ensureAliasAvailable reports ErrDuplicateAlias when a data source other
+// than ds already uses its alias. Empty aliases are never considered taken.
+func ensureAliasAvailable(tx *gorm.DB, ds domain.DataSource) error {
+	if ds.Alias == "" {
+		return nil
+	}
+	var count int64
+	err := tx.Model(&dataSourceModel{}).
+		Where("alias = ? AND id <> ?", string(ds.Alias), ds.ID.Int64()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateAlias
+	}
+	return nil
+}
+
 // Storage model for data_sources.
 type dataSourceModel struct {
 	ID             int64 `gorm:"primaryKey;autoIncrement:false"`
@@ -2,6 +2,7 @@ package repository_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -89,7 +90,7 @@ func TestDataSourceRepositoryList(t *testing.T) {
 			ID:      domain.NewDataSourceID(int64(i + 1)),
 			ClassID: "postgres",
 			Name:    domain.Name("ds"),
-			Alias:   domain.Alias("a"),
+			Alias:   domain.Alias(fmt.Sprintf("a%d", i)),
 			Properties: map[domain.PropertyKey]domain.PropertyValue{
 				"host": "localhost",
 			},
@@ -107,7 +108,128 @@ func TestDataSourceRepositoryList(t *testing.T) {
 	}
 }
 
+func TestDataSourceRepositoryAliasUnique(t *testing.T) {
+	db := openDSDB(t)
+	ctx := context.Background()
+	repo := repository.NewDataSourceRepository(db)
+
+	first := domain.DataSource{ID: domain.NewDataSourceID(1), ClassID: "postgres", Name: "one", Alias: "shared"}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create first: %v", err)
+	}
+	second := domain.DataSource{ID: domain.NewDataSourceID(2), ClassID: "postgres", Name: "two", Alias: "shared"}
+	if err := repo.Create(ctx, second); !errors.Is(err, repository.ErrDuplicateAlias) {
+		t.Fatalf("Create duplicate alias err = %v, want ErrDuplicateAlias", err)
+	}
+
+	// aliases are optional: many data sources may leave them empty
+	for i := 3; i < 5; i++ {
+		if err := repo.Create(ctx, domain.DataSource{ID: domain.NewDataSourceID(int64(i)), ClassID: "postgres", Name: "anon"}); err != nil {
+			t.Fatalf("Create without alias %d: %v", i, err)
+		}
+	}
+
+	second.Alias = "other"
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatalf("Create second: %v", err)
+	}
+	second.Alias = "shared"
+	if err := repo.Update(ctx, second, time.Now().Add(time.Minute)); !errors.Is(err, repository.ErrDuplicateAlias) {
+		t.Fatalf("Update to duplicate alias err = %v, want ErrDuplicateAlias", err)
+	}
+
+	got, err := repo.GetByAlias(ctx, "shared")
+	if err != nil {
+		t.Fatalf("GetByAlias: %v", err)
+	}
+	if got.ID.Int64() != first.ID.Int64() {
+		t.Fatalf("GetByAlias id = %d, want %d", got.ID.Int64(), first.ID.Int64())
+	}
+	if _, err := repo.GetByAlias(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("GetByAlias missing err = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestDataSourceRepositoryDeletePolicies(t *testing.T) {
+	db := openDSDB(t)
+	ctx := context.Background()
+	dsRepo := repository.NewDataSourceRepository(db)
+	compRepo := repository.NewComponentRepository(db)
+
+	ds := domain.DataSource{ID: domain.NewDataSourceID(10), ClassID: "postgres", Name: "pg", Alias: "pg"}
+	if err := dsRepo.Create(ctx, ds); err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	byID := newRefComponent(1, domain.Query{Name: "q", DataSourceID: ds.ID})
+	byAlias := newRefComponent(2, domain.Query{Name: "q", DataSourceAlias: "pg"})
+	unrelated := newRefComponent(3, domain.Query{Name: "q", DataSourceID: domain.NewDataSourceID(99)})
+	for _, c := range []domain.Component{byID, byAlias, unrelated} {
+		if err := compRepo.Create(ctx, c); err != nil {
+			t.Fatalf("Create component: %v", err)
+		}
+	}
+
+	usages, err := dsRepo.Usages(ctx, ds.ID)
+	if err != nil {
+		t.Fatalf("Usages: %v", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("Usages len = %d, want 2", len(usages))
+	}
+
+	if err := dsRepo.Delete(ctx, ds.ID); !errors.Is(err, repository.ErrDataSourceInUse) {
+		t.Fatalf("restrict delete err = %v, want ErrDataSourceInUse", err)
+	}
+
+	// repointing a component drops its reference
+	byID.Query.DataSourceID = domain.NewDataSourceID(99)
+	byID.UpdatedAt = byID.UpdatedAt.Add(time.Minute)
+	if err := compRepo.Update(ctx, byID); err != nil {
+		t.Fatalf("Update component: %v", err)
+	}
+	usages, _ = dsRepo.Usages(ctx, ds.ID)
+	if len(usages) != 1 || usages[0].ID.Int64() != byAlias.ID.Int64() {
+		t.Fatalf("Usages after repoint = %v, want only component 2", usages)
+	}
+
+	deleted, err := dsRepo.DeleteWithPolicy(ctx, ds.ID, domain.DataSourceDeleteCascade)
+	if err != nil {
+		t.Fatalf("cascade delete: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Int64() != byAlias.ID.Int64() {
+		t.Fatalf("cascade deleted %v, want only component 2", deleted)
+	}
+	if _, err := compRepo.Get(ctx, byAlias.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("dependent component survived cascade: %v", err)
+	}
+	if _, err := compRepo.Get(ctx, unrelated.ID); err != nil {
+		t.Fatalf("unrelated component removed by cascade: %v", err)
+	}
+
+	other := domain.DataSource{ID: domain.NewDataSourceID(99), ClassID: "postgres", Name: "other"}
+	if err := dsRepo.Create(ctx, other); err != nil {
+		t.Fatalf("Create other: %v", err)
+	}
+	if _, err := dsRepo.DeleteWithPolicy(ctx, other.ID, domain.DataSourceDeleteForce); err != nil {
+		t.Fatalf("force delete: %v", err)
+	}
+	if _, err := compRepo.Get(ctx, unrelated.ID); err != nil {
+		t.Fatalf("force delete removed dependent component: %v", err)
+	}
+}
+
 // Helpers
+func newRefComponent(id int64, q domain.Query) domain.Component {
+	return domain.Component{
+		ID:              domain.NewComponentID(id),
+		VisualisationID: "table",
+		Query:           q,
+		Name:            "c",
+		Properties:      map[domain.PropertyKey]domain.PropertyValue{},
+		UpdatedAt:       time.Now(),
+	}
+}
+
 func openDSDB(t *testing.T) *gorm.DB {
 	t.Helper()
 	dsn := fmt.Sprintf("file:ds-repo-%d?mode=memory&cache=shared", time.Now().UnixNano())
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// notificationPolicyRow is the ID of the only row of the policy table.
+const notificationPolicyRow = 1
+
+type NotificationPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPolicyRepository(db *gorm.DB) *NotificationPolicyRepository {
+	return &NotificationPolicyRepository{db: db}
+}
+
+// Get returns the saved policy, or gorm.ErrRecordNotFound if there is
+// none yet.
+func (r *NotificationPolicyRepository) Get(ctx context.Context) (domain.NotificationPolicy, error) {
+	var model notificationPolicyModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", notificationPolicyRow).Error; err != nil {
+		return domain.NotificationPolicy{}, err
+	}
+	var root domain.NotificationRoute
+	if err := json.Unmarshal([]byte(model.TreeJSON), &root); err != nil {
+		return domain.NotificationPolicy{}, err
+	}
+	return domain.NotificationPolicy{Root: root, UpdatedAt: model.UpdatedAt}, nil
+}
+
+// Save replaces the policy.
+func (r *NotificationPolicyRepository) Save(ctx context.Context, p domain.NotificationPolicy) error {
+	tree, err := json.Marshal(p.Root)
+	if err != nil {
+		return err
+	}
+	model := notificationPolicyModel{ID: notificationPolicyRow, TreeJSON: string(tree), CreatedAt: p.UpdatedAt, UpdatedAt: p.UpdatedAt}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tree_json", "updated_at"}),
+	}).Create(&model).Error
+}
+
+type notificationPolicyModel struct {
+	ID        int64 `gorm:"primaryKey;autoIncrement:false"`
+	TreeJSON  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (notificationPolicyModel) TableName() string { return "notification_policies" }
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type QueryJobRepository struct {
+	db *gorm.DB
+}
+
+func NewQueryJobRepository(db *gorm.DB) *QueryJobRepository {
+	return &QueryJobRepository{db: db}
+}
+
+func (r *QueryJobRepository) Create(ctx context.Context, job domain.QueryJob) error {
+	model, err := toQueryJobModel(job)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&model).Error
+}
+
+func (r *QueryJobRepository) Get(ctx context.Context, id domain.QueryJobID) (domain.QueryJob, error) {
+	var model queryJobModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.QueryJob{}, err
+	}
+	return toQueryJobDomain(model)
+}
+
+// Update overwrites every field of the job.
+func (r *QueryJobRepository) Update(ctx context.Context, job domain.QueryJob) error {
+	model, err := toQueryJobModel(job)
+	if err != nil {
+		return err
+	}
+	res := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(&model)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateProgress records the rows fetched so far by a running job.
+func (r *QueryJobRepository) UpdateProgress(ctx context.Context, id domain.QueryJobID, rows int64) error {
+	return r.db.WithContext(ctx).Model(&queryJobModel{}).
+		Where("id = ?", id.Int64()).
+		Update("rows_fetched", rows).Error
+}
+
+// FailUnfinished marks every queued or running job as failed with reason.
+// It is meant for startup, when no job of this process can be running.
+func (r *QueryJobRepository) FailUnfinished(ctx context.Context, reason string, at, expiresAt time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&queryJobModel{}).
+		Where("status IN ?", []string{string(domain.QueryJobQueued), string(domain.QueryJobRunning)}).
+		Updates(map[string]any{
+			"status":      string(domain.QueryJobFailed),
+			"error":       reason,
+			"finished_at": at,
+			"expires_at":  expiresAt,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteExpired removes jobs whose retention ended before now.
+func (r *QueryJobRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&queryJobModel{}).Error
+}
+
+type queryJobModel struct {
+	ID          int64 `gorm:"primaryKey;autoIncrement:false"`
+	ComponentID int64
+	Status      string
+	RowsFetched int64
+	Error       string
+	ResultJSON  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  *time.Time
+	ExpiresAt   *time.Time
+}
+
+func (queryJobModel) TableName() string { return "query_jobs" }
+
+func toQueryJobModel(job domain.QueryJob) (queryJobModel, error) {
+	model := queryJobModel{
+		ID:          job.ID.Int64(),
+		ComponentID: job.ComponentID.Int64(),
+		Status:      string(job.Status),
+		RowsFetched: job.RowsFetched,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		FinishedAt:  job.FinishedAt,
+		ExpiresAt:   job.ExpiresAt,
+	}
+	if job.Result != nil {
+		raw, err := json.Marshal(job.Result)
+		if err != nil {
+			return queryJobModel{}, err
+		}
+		model.ResultJSON = string(raw)
+	}
+	return model, nil
+}
+
+func toQueryJobDomain(m queryJobModel) (domain.QueryJob, error) {
+	job := domain.QueryJob{
+		ID:          domain.NewQueryJobID(m.ID),
+		ComponentID: domain.NewComponentID(m.ComponentID),
+		Status:      domain.QueryJobStatus(m.Status),
+		RowsFetched: m.RowsFetched,
+		Error:       m.Error,
+		CreatedAt:   m.CreatedAt,
+		FinishedAt:  m.FinishedAt,
+		ExpiresAt:   m.ExpiresAt,
+	}
+	if m.ResultJSON != "" {
+		var result domain.TableData
+		if err := json.Unmarshal([]byte(m.ResultJSON), &result); err != nil {
+			return domain.QueryJob{}, err
+		}
+		job.Result = &result
+	}
+	return job, nil
+}
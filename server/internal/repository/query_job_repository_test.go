@@ -0,0 +1,70 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestQueryJobRepositoryLifecycle(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewQueryJobRepository(db)
+
+	now := time.Now()
+	running := domain.QueryJob{ID: domain.NewQueryJobID(1), ComponentID: domain.NewComponentID(9), Status: domain.QueryJobRunning, CreatedAt: now}
+	done := domain.QueryJob{ID: domain.NewQueryJobID(2), ComponentID: domain.NewComponentID(9), Status: domain.QueryJobQueued, CreatedAt: now}
+	for _, job := range []domain.QueryJob{running, done} {
+		if err := repo.Create(ctx, job); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	col := domain.NewColumnData("n", domain.PropertyTypeInteger)
+	if err := col.Append(int64(5)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	expired := now.Add(-time.Minute)
+	done.Status = domain.QueryJobSucceeded
+	done.RowsFetched = 1
+	done.Result = &domain.TableData{Columns: []domain.ColumnData{col}}
+	done.FinishedAt, done.ExpiresAt = &now, &expired
+	if err := repo.Update(ctx, done); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := repo.Get(ctx, done.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != domain.QueryJobSucceeded || got.Result == nil || got.Result.Columns[0].Ints[0] != 5 {
+		t.Fatalf("got = %+v, want succeeded job with its result", got)
+	}
+
+	n, err := repo.FailUnfinished(ctx, "restarted", now, now.Add(time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("FailUnfinished = %d, %v; want 1 job", n, err)
+	}
+	got, err = repo.Get(ctx, running.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != domain.QueryJobFailed || got.Error != "restarted" || got.FinishedAt == nil {
+		t.Fatalf("got = %+v, want failed job", got)
+	}
+
+	if err := repo.DeleteExpired(ctx, now); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if _, err := repo.Get(ctx, done.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expired job: err = %v, want not found", err)
+	}
+	if _, err := repo.Get(ctx, running.ID); err != nil {
+		t.Fatalf("unexpired job: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type ReportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+func (r *ReportRepository) Create(ctx context.Context, rep domain.Report) error {
+	model, err := toReportModel(rep)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&model).Error
+}
+
+func (r *ReportRepository) Get(ctx context.Context, id domain.ReportID) (domain.Report, error) {
+	var model reportModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.Report{}, err
+	}
+	return toReportDomain(model)
+}
+
+// List returns every report ordered by name.
+func (r *ReportRepository) List(ctx context.Context) ([]domain.Report, error) {
+	return r.find(r.db.WithContext(ctx).Order("name, id"))
+}
+
+// Due returns the reports that are not paused and whose next run is at or
+// before t, earliest first.
+func (r *ReportRepository) Due(ctx context.Context, t time.Time) ([]domain.Report, error) {
+	return r.find(r.db.WithContext(ctx).
+		Where("paused = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", false, t).
+		Order("next_run_at, id"))
+}
+
+func (r *ReportRepository) find(q *gorm.DB) ([]domain.Report, error) {
+	var models []reportModel
+	if err := q.Find(&models).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.Report, 0, len(models))
+	for _, m := range models {
+		rep, err := toReportDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, nil
+}
+
+// Update overwrites the report, its next run included.
+func (r *ReportRepository) Update(ctx context.Context, rep domain.Report) error {
+	model, err := toReportModel(rep)
+	if err != nil {
+		return err
+	}
+	res := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(&model)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetNextRun moves the next run of the report to next, or unschedules it
+// when next is nil.
+func (r *ReportRepository) SetNextRun(ctx context.Context, id domain.ReportID, next *time.Time) error {
+	res := r.db.WithContext(ctx).Model(&reportModel{}).Where("id = ?", id.Int64()).Update("next_run_at", next)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete removes the report and its runs.
+func (r *ReportRepository) Delete(ctx context.Context, id domain.ReportID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&reportModel{}, "id = ?", id.Int64())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Delete(&reportRunModel{}, "report_id = ?", id.Int64()).Error
+	})
+}
+
+func (r *ReportRepository) CreateRun(ctx context.Context, run domain.ReportRun) error {
+	deliveries, err := json.Marshal(run.Deliveries)
+	if err != nil {
+		return err
+	}
+	model := reportRunModel{
+		ID:             run.ID.Int64(),
+		ReportID:       run.ReportID.Int64(),
+		Trigger:        string(run.Trigger),
+		Status:         string(run.Status),
+		Error:          run.Error,
+		DeliveriesJSON: string(deliveries),
+		StartedAt:      run.StartedAt,
+		FinishedAt:     run.FinishedAt,
+	}
+	return r.db.WithContext(ctx).Create(&model).Error
+}
+
+// Runs returns the latest limit runs of the report, newest first.
+func (r *ReportRepository) Runs(ctx context.Context, id domain.ReportID, limit int) ([]domain.ReportRun, error) {
+	var models []reportRunModel
+	err := r.db.WithContext(ctx).
+		Where("report_id = ?", id.Int64()).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.ReportRun, len(models))
+	for i, m := range models {
+		out[i] = domain.ReportRun{
+			ID:         domain.NewReportRunID(m.ID),
+			ReportID:   domain.NewReportID(m.ReportID),
+			Trigger:    domain.ReportTrigger(m.Trigger),
+			Status:     domain.ReportRunStatus(m.Status),
+			Error:      m.Error,
+			StartedAt:  m.StartedAt,
+			FinishedAt: m.FinishedAt,
+		}
+		if err := json.Unmarshal([]byte(m.DeliveriesJSON), &out[i].Deliveries); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+type reportModel struct {
+	ID                int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name              string
+	Schedule          string
+	TimeZone          string
+	ComponentsJSON    string
+	Page              string
+	VariablesJSON     string
+	RangeFrom         string
+	RangeTo           string
+	Format            string
+	ContactPointsJSON string
+	Paused            bool
+	NextRunAt         *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (reportModel) TableName() string { return "reports" }
+
+type reportRunModel struct {
+	ID             int64 `gorm:"primaryKey;autoIncrement:false"`
+	ReportID       int64
+	Trigger        string
+	Status         string
+	Error          string
+	DeliveriesJSON string
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+func (reportRunModel) TableName() string { return "report_runs" }
+
+func toReportModel(rep domain.Report) (reportModel, error) {
+	model := reportModel{
+		ID:        rep.ID.Int64(),
+		Name:      string(rep.Name),
+		Schedule:  rep.Schedule,
+		TimeZone:  rep.TimeZone,
+		Page:      string(rep.Page),
+		RangeFrom: rep.From,
+		RangeTo:   rep.To,
+		Format:    string(rep.Format),
+		Paused:    rep.Paused,
+		NextRunAt: rep.NextRunAt,
+		UpdatedAt: rep.UpdatedAt,
+	}
+	for dst, v := range map[*string]any{
+		&model.ComponentsJSON:    rep.Components,
+		&model.VariablesJSON:     rep.Variables,
+		&model.ContactPointsJSON: rep.ContactPoints,
+	} {
+		raw, err := marshalOptional(v)
+		if err != nil {
+			return reportModel{}, err
+		}
+		*dst = raw
+	}
+	return model, nil
+}
+
+func toReportDomain(m reportModel) (domain.Report, error) {
+	rep := domain.Report{
+		ID:        domain.NewReportID(m.ID),
+		Name:      domain.Name(m.Name),
+		Schedule:  m.Schedule,
+		TimeZone:  m.TimeZone,
+		Page:      domain.Name(m.Page),
+		From:      m.RangeFrom,
+		To:        m.RangeTo,
+		Format:    domain.ReportFormat(m.Format),
+		Paused:    m.Paused,
+		NextRunAt: m.NextRunAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+	for _, f := range []struct {
+		raw string
+		dst any
+	}{{m.ComponentsJSON, &rep.Components}, {m.VariablesJSON, &rep.Variables}, {m.ContactPointsJSON, &rep.ContactPoints}} {
+		if f.raw == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(f.raw), f.dst); err != nil {
+			return domain.Report{}, err
+		}
+	}
+	return rep, nil
+}
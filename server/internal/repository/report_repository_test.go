@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestReportRepository(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewReportRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	next := now.Add(time.Hour)
+	rep := domain.Report{
+		ID:            domain.NewReportID(1),
+		Name:          "weekly sales",
+		Schedule:      "0 8 * * MON",
+		TimeZone:      "UTC",
+		Components:    []domain.ComponentID{domain.NewComponentID(7), domain.NewComponentID(8)},
+		Variables:     map[domain.Name][]domain.PropertyValue{"region": {"eu"}},
+		From:          "now-7d",
+		To:            "now",
+		Format:        domain.ReportHTML,
+		ContactPoints: []domain.ContactPointID{domain.NewContactPointID(3)},
+		NextRunAt:     &next,
+		UpdatedAt:     now,
+	}
+	if err := repo.Create(ctx, rep); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	got, err := repo.Get(ctx, rep.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Components) != 2 || got.Components[1] != rep.Components[1] || got.Variables["region"][0] != "eu" ||
+		got.ContactPoints[0] != rep.ContactPoints[0] || got.NextRunAt == nil || !got.NextRunAt.Equal(next) {
+		t.Fatalf("Get = %+v", got)
+	}
+
+	paused := rep
+	paused.ID, paused.Name, paused.Paused = domain.NewReportID(2), "paused", true
+	if err := repo.Create(ctx, paused); err != nil {
+		t.Fatalf("Create paused: %v", err)
+	}
+	due, err := repo.Due(ctx, next)
+	if err != nil || len(due) != 1 || due[0].ID != rep.ID {
+		t.Fatalf("Due = %+v, %v", due, err)
+	}
+	if due, _ := repo.Due(ctx, now); len(due) != 0 {
+		t.Fatalf("Due before the next run = %+v", due)
+	}
+	if err := repo.SetNextRun(ctx, rep.ID, nil); err != nil {
+		t.Fatalf("SetNextRun: %v", err)
+	}
+	if due, _ := repo.Due(ctx, next); len(due) != 0 {
+		t.Fatalf("Due after unscheduling = %+v", due)
+	}
+
+	for i, status := range []domain.ReportRunStatus{domain.ReportFailed, domain.ReportSucceeded} {
+		started := now.Add(time.Duration(i) * time.Minute)
+		run := domain.ReportRun{
+			ID:         domain.NewReportRunID(int64(i + 1)),
+			ReportID:   rep.ID,
+			Trigger:    domain.ReportManual,
+			Status:     status,
+			Deliveries: []domain.ReportDelivery{{ContactPointID: rep.ContactPoints[0], Error: string(status)}},
+			StartedAt:  started,
+			FinishedAt: started.Add(time.Second),
+		}
+		if err := repo.CreateRun(ctx, run); err != nil {
+			t.Fatalf("CreateRun: %v", err)
+		}
+	}
+	runs, err := repo.Runs(ctx, rep.ID, 10)
+	if err != nil || len(runs) != 2 || runs[0].Status != domain.ReportSucceeded || runs[1].Deliveries[0].Error != "failed" {
+		t.Fatalf("Runs = %+v, %v", runs, err)
+	}
+
+	if err := repo.Delete(ctx, rep.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if runs, _ := repo.Runs(ctx, rep.ID, 10); len(runs) != 0 {
+		t.Fatalf("runs left after Delete: %+v", runs)
+	}
+	if err := repo.Delete(ctx, rep.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Delete again = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+type SilenceRepository struct {
+	db *gorm.DB
+}
+
+func NewSilenceRepository(db *gorm.DB) *SilenceRepository {
+	return &SilenceRepository{db: db}
+}
+
+func (r *SilenceRepository) Create(ctx context.Context, s domain.Silence) error {
+	model, err := toSilenceModel(s)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&model).Error
+}
+
+func (r *SilenceRepository) Get(ctx context.Context, id domain.SilenceID) (domain.Silence, error) {
+	var model silenceModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.Silence{}, err
+	}
+	return toSilenceDomain(model)
+}
+
+// List returns every silence, latest ending first.
+func (r *SilenceRepository) List(ctx context.Context) ([]domain.Silence, error) {
+	return r.find(r.db.WithContext(ctx).Order("ends_at DESC"))
+}
+
+// Active returns the silences in effect at t.
+func (r *SilenceRepository) Active(ctx context.Context, t time.Time) ([]domain.Silence, error) {
+	return r.find(r.db.WithContext(ctx).Where("starts_at <= ? AND ends_at > ?", t, t))
+}
+
+func (r *SilenceRepository) find(q *gorm.DB) ([]domain.Silence, error) {
+	var models []silenceModel
+	if err := q.Find(&models).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.Silence, 0, len(models))
+	for _, m := range models {
+		s, err := toSilenceDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Update overwrites every field of the silence.
+func (r *SilenceRepository) Update(ctx context.Context, s domain.Silence) error {
+	model, err := toSilenceModel(s)
+	if err != nil {
+		return err
+	}
+	res := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(&model)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *SilenceRepository) Delete(ctx context.Context, id domain.SilenceID) error {
+	res := r.db.WithContext(ctx).Delete(&silenceModel{}, "id = ?", id.Int64())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type silenceModel struct {
+	ID           int64 `gorm:"primaryKey;autoIncrement:false"`
+	MatchersJSON string
+	StartsAt     time.Time
+	EndsAt       time.Time
+	Comment      string
+	CreatedBy    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (silenceModel) TableName() string { return "silences" }
+
+func toSilenceModel(s domain.Silence) (silenceModel, error) {
+	matchers, err := json.Marshal(s.Matchers)
+	if err != nil {
+		return silenceModel{}, err
+	}
+	return silenceModel{
+		ID:           s.ID.Int64(),
+		MatchersJSON: string(matchers),
+		StartsAt:     s.StartsAt,
+		EndsAt:       s.EndsAt,
+		Comment:      s.Comment,
+		CreatedBy:    string(s.CreatedBy),
+		UpdatedAt:    s.UpdatedAt,
+	}, nil
+}
+
+func toSilenceDomain(m silenceModel) (domain.Silence, error) {
+	var matchers []domain.LabelMatcher
+	if err := json.Unmarshal([]byte(m.MatchersJSON), &matchers); err != nil {
+		return domain.Silence{}, err
+	}
+	return domain.Silence{
+		ID:        domain.NewSilenceID(m.ID),
+		Matchers:  matchers,
+		StartsAt:  m.StartsAt,
+		EndsAt:    m.EndsAt,
+		Comment:   m.Comment,
+		CreatedBy: domain.Name(m.CreatedBy),
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
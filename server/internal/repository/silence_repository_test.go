@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestSilenceRepository(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewSilenceRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	matchers := []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}}
+	silences := []domain.Silence{
+		{ID: domain.NewSilenceID(1), Matchers: matchers, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour), Comment: "maintenance"},
+		{ID: domain.NewSilenceID(2), Matchers: matchers, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+		{ID: domain.NewSilenceID(3), Matchers: matchers, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)},
+	}
+	for _, s := range silences {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	list, err := repo.List(ctx)
+	if err != nil || len(list) != 3 || list[0].ID != silences[2].ID {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	active, err := repo.Active(ctx, now)
+	if err != nil || len(active) != 1 || active[0].ID != silences[0].ID || active[0].Matchers[0] != matchers[0] {
+		t.Fatalf("Active = %+v, %v", active, err)
+	}
+
+	s := silences[0]
+	s.EndsAt, s.Comment = now, "done early"
+	if err := repo.Update(ctx, s); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if active, _ := repo.Active(ctx, now); len(active) != 0 {
+		t.Fatalf("Active after Update = %+v", active)
+	}
+	if err := repo.Delete(ctx, s.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, s.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestNotificationPolicyRepository(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewNotificationPolicyRepository(db)
+
+	if _, err := repo.Get(ctx); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Get before Save = %v, want ErrRecordNotFound", err)
+	}
+	ops := domain.NewContactPointID(1)
+	p := domain.NotificationPolicy{
+		Root: domain.NotificationRoute{
+			ContactPointID: &ops,
+			GroupBy:        []domain.Name{"alertname"},
+			Routes: []domain.NotificationRoute{{
+				Matchers:  []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}},
+				GroupWait: domain.Duration(time.Minute),
+			}},
+		},
+		UpdatedAt: time.Now(),
+	}
+	for range 2 {
+		if err := repo.Save(ctx, p); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	got, err := repo.Get(ctx)
+	if err != nil || *got.Root.ContactPointID != ops || len(got.Root.Routes) != 1 ||
+		got.Root.Routes[0].GroupWait != domain.Duration(time.Minute) {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ErrDuplicateTransformer is returned when another transformer has the
+// same name.
+var ErrDuplicateTransformer = errors.New("transformer name already in use")
+
+type TransformerRepository struct {
+	db *gorm.DB
+}
+
+func NewTransformerRepository(db *gorm.DB) *TransformerRepository {
+	return &TransformerRepository{db: db}
+}
+
+func (r *TransformerRepository) Create(ctx context.Context, t domain.Transformer) error {
+	model := toTransformerModel(t)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureTransformerNameAvailable(tx, t); err != nil {
+			return err
+		}
+		return tx.Create(&model).Error
+	})
+}
+
+func (r *TransformerRepository) Get(ctx context.Context, id domain.TransformerID) (domain.Transformer, error) {
+	var model transformerModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.Transformer{}, err
+	}
+	return toTransformerDomain(model), nil
+}
+
+func (r *TransformerRepository) GetByName(ctx context.Context, name domain.Name) (domain.Transformer, error) {
+	var model transformerModel
+	if err := r.db.WithContext(ctx).First(&model, "name = ?", string(name)).Error; err != nil {
+		return domain.Transformer{}, err
+	}
+	return toTransformerDomain(model), nil
+}
+
+// List returns every transformer ordered by name.
+func (r *TransformerRepository) List(ctx context.Context) ([]domain.Transformer, error) {
+	var models []transformerModel
+	if err := r.db.WithContext(ctx).Order("name").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	out := make([]domain.Transformer, 0, len(models))
+	for _, m := range models {
+		out = append(out, toTransformerDomain(m))
+	}
+	return out, nil
+}
+
+// Update overwrites every field of the transformer.
+func (r *TransformerRepository) Update(ctx context.Context, t domain.Transformer) error {
+	model := toTransformerModel(t)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureTransformerNameAvailable(tx, t); err != nil {
+			return err
+		}
+		res := tx.Select("*").Omit("created_at").Updates(&model)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *TransformerRepository) Delete(ctx context.Context, id domain.TransformerID) error {
+	res := r.db.WithContext(ctx).Delete(&transformerModel{}, "id = ?", id.Int64())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ensureTransformerNameAvailable(tx *gorm.DB, t domain.Transformer) error {
+	var count int64
+	err := tx.Model(&transformerModel{}).
+		Where("name = ? AND id <> ?", string(t.Name), t.ID.Int64()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateTransformer
+	}
+	return nil
+}
+
+type transformerModel struct {
+	ID          int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name        string
+	Description string
+	Script      string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (transformerModel) TableName() string { return "transformers" }
+
+func toTransformerModel(t domain.Transformer) transformerModel {
+	return transformerModel{
+		ID:          t.ID.Int64(),
+		Name:        string(t.Name),
+		Description: t.Description,
+		Script:      t.Script,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
+
+func toTransformerDomain(m transformerModel) domain.Transformer {
+	return domain.Transformer{
+		ID:          domain.NewTransformerID(m.ID),
+		Name:        domain.Name(m.Name),
+		Description: m.Description,
+		Script:      m.Script,
+		UpdatedAt:   m.UpdatedAt,
+	}
+}
@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestTransformerRepositoryCRUD(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewTransformerRepository(db)
+
+	now := time.Now()
+	top := domain.Transformer{ID: domain.NewTransformerID(1), Name: "top", Script: "return table.rows.slice(0, 5)", UpdatedAt: now}
+	for _, tr := range []domain.Transformer{top, {ID: domain.NewTransformerID(2), Name: "double", Script: "return table", UpdatedAt: now}} {
+		if err := repo.Create(ctx, tr); err != nil {
+			t.Fatalf("Create %s: %v", tr.Name, err)
+		}
+	}
+	dup := top
+	dup.ID = domain.NewTransformerID(3)
+	if err := repo.Create(ctx, dup); !errors.Is(err, repository.ErrDuplicateTransformer) {
+		t.Fatalf("Create duplicate = %v, want ErrDuplicateTransformer", err)
+	}
+
+	list, err := repo.List(ctx)
+	if err != nil || len(list) != 2 || list[0].Name != "double" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	got, err := repo.GetByName(ctx, "top")
+	if err != nil || got.ID != top.ID || got.Script != top.Script {
+		t.Fatalf("GetByName = %+v, %v", got, err)
+	}
+
+	got.Name = "double"
+	if err := repo.Update(ctx, got); !errors.Is(err, repository.ErrDuplicateTransformer) {
+		t.Fatalf("Update onto a taken name = %v, want ErrDuplicateTransformer", err)
+	}
+	got.Name, got.Description = "top5", "first five rows"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := repo.Get(ctx, top.ID); got.Name != "top5" || got.Description != "first five rows" {
+		t.Fatalf("Get after Update = %+v", got)
+	}
+	if err := repo.Delete(ctx, top.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, top.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Delete again = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// ErrDuplicateVariable is returned when the page already has a variable of
+// the same name.
+var ErrDuplicateVariable = errors.New("variable name already in use")
+
+type VariableRepository struct {
+	db *gorm.DB
+}
+
+func NewVariableRepository(db *gorm.DB) *VariableRepository {
+	return &VariableRepository{db: db}
+}
+
+func (r *VariableRepository) Create(ctx context.Context, v domain.Variable) error {
+	model, err := toVariableModel(v)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureVariableNameAvailable(tx, v); err != nil {
+			return err
+		}
+		return tx.Create(&model).Error
+	})
+}
+
+func (r *VariableRepository) Get(ctx context.Context, id domain.VariableID) (domain.Variable, error) {
+	var model variableModel
+	if err := r.db.WithContext(ctx).First(&model, "id = ?", id.Int64()).Error; err != nil {
+		return domain.Variable{}, err
+	}
+	return toVariableDomain(model)
+}
+
+// List returns the project variables followed by those of page, each
+// ordered by name. An empty page lists project variables only.
+func (r *VariableRepository) List(ctx context.Context, page domain.Name) ([]domain.Variable, error) {
+	var models []variableModel
+	err := r.db.WithContext(ctx).
+		Where("page = '' OR page = ?", string(page)).
+		Order("page, name").
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.Variable, 0, len(models))
+	for _, m := range models {
+		v, err := toVariableDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Update overwrites every field of the variable.
+func (r *VariableRepository) Update(ctx context.Context, v domain.Variable) error {
+	model, err := toVariableModel(v)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureVariableNameAvailable(tx, v); err != nil {
+			return err
+		}
+		res := tx.Select("*").Omit("created_at").Updates(&model)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *VariableRepository) Delete(ctx context.Context, id domain.VariableID) error {
+	res := r.db.WithContext(ctx).Delete(&variableModel{}, "id = ?", id.Int64())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func ensureVariableNameAvailable(tx *gorm.DB, v domain.Variable) error {
+	var count int64
+	err := tx.Model(&variableModel{}).
+		Where("page = ? AND name = ? AND id <> ?", string(v.Page), string(v.Name), v.ID.Int64()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateVariable
+	}
+	return nil
+}
+
+type variableModel struct {
+	ID          int64 `gorm:"primaryKey;autoIncrement:false"`
+	Page        string
+	Name        string
+	Label       string
+	Type        string
+	Value       string
+	OptionsJSON string
+	QueryJSON   string
+	Multi       bool
+	IncludeAll  bool
+	AllValue    string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (variableModel) TableName() string { return "variables" }
+
+func toVariableModel(v domain.Variable) (variableModel, error) {
+	model := variableModel{
+		ID:         v.ID.Int64(),
+		Page:       string(v.Page),
+		Name:       string(v.Name),
+		Label:      string(v.Label),
+		Type:       string(v.Type),
+		Value:      string(v.Value),
+		Multi:      v.Multi,
+		IncludeAll: v.IncludeAll,
+		AllValue:   string(v.AllValue),
+		UpdatedAt:  v.UpdatedAt,
+	}
+	if len(v.Options) > 0 {
+		raw, err := json.Marshal(v.Options)
+		if err != nil {
+			return variableModel{}, err
+		}
+		model.OptionsJSON = string(raw)
+	}
+	if v.Query != nil {
+		raw, err := json.Marshal(v.Query)
+		if err != nil {
+			return variableModel{}, err
+		}
+		model.QueryJSON = string(raw)
+	}
+	return model, nil
+}
+
+func toVariableDomain(m variableModel) (domain.Variable, error) {
+	v := domain.Variable{
+		ID:         domain.NewVariableID(m.ID),
+		Page:       domain.Name(m.Page),
+		Name:       domain.Name(m.Name),
+		Label:      domain.Name(m.Label),
+		Type:       domain.VariableType(m.Type),
+		Value:      domain.PropertyValue(m.Value),
+		Multi:      m.Multi,
+		IncludeAll: m.IncludeAll,
+		AllValue:   domain.PropertyValue(m.AllValue),
+		UpdatedAt:  m.UpdatedAt,
+	}
+	if m.OptionsJSON != "" {
+		if err := json.Unmarshal([]byte(m.OptionsJSON), &v.Options); err != nil {
+			return domain.Variable{}, err
+		}
+	}
+	if m.QueryJSON != "" {
+		var q domain.Query
+		if err := json.Unmarshal([]byte(m.QueryJSON), &q); err != nil {
+			return domain.Variable{}, err
+		}
+		v.Query = &q
+	}
+	return v, nil
+}
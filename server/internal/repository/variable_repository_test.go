@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+func TestVariableRepositoryScopes(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	repo := repository.NewVariableRepository(db)
+
+	now := time.Now()
+	vars := []domain.Variable{
+		{ID: domain.NewVariableID(1), Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"}, UpdatedAt: now},
+		{ID: domain.NewVariableID(2), Name: "env", Page: "sales", Type: domain.VariableTextBox, Value: "eu", UpdatedAt: now},
+		{ID: domain.NewVariableID(3), Name: "region", Page: "ops", Type: domain.VariableQuery, Query: &domain.Query{
+			Name: "regions", Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT name FROM regions"},
+		}, UpdatedAt: now},
+	}
+	for _, v := range vars {
+		if err := repo.Create(ctx, v); err != nil {
+			t.Fatalf("Create %s: %v", v.Name, err)
+		}
+	}
+	dup := vars[1]
+	dup.ID = domain.NewVariableID(4)
+	if err := repo.Create(ctx, dup); !errors.Is(err, repository.ErrDuplicateVariable) {
+		t.Fatalf("Create duplicate = %v, want ErrDuplicateVariable", err)
+	}
+
+	list, err := repo.List(ctx, "sales")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Page != "" || list[1].Page != "sales" || len(list[0].Options) != 2 {
+		t.Fatalf("List(sales) = %+v, want the project env then the page env", list)
+	}
+	got, err := repo.Get(ctx, vars[2].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Query == nil || got.Query.Properties["sql"] != "SELECT name FROM regions" {
+		t.Fatalf("Get query = %+v", got.Query)
+	}
+
+	got.Page = ""
+	got.Name = "env"
+	if err := repo.Update(ctx, got); !errors.Is(err, repository.ErrDuplicateVariable) {
+		t.Fatalf("Update onto a taken name = %v, want ErrDuplicateVariable", err)
+	}
+	if err := repo.Delete(ctx, got.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Delete(ctx, got.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Delete missing = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type adminHandlers struct {
+	queries *service.QueryService
+}
+
+func registerAdminRoutes(api *gin.RouterGroup, queries *service.QueryService) {
+	h := adminHandlers{queries: queries}
+	g := api.Group("/admin")
+	g.GET("/pools", h.pools)
+}
+
+// pools lists the connection pools kept open for data sources.
+func (h adminHandlers) pools(c *gin.Context) {
+	stats := h.queries.PoolStats()
+	if stats == nil {
+		stats = []domain.PoolStats{}
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,62 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestAdminPools(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER);`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	pools := func() []domain.PoolStats {
+		t.Helper()
+		w := doRequest(router, http.MethodGet, "/api/admin/pools", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("pools status = %d", w.Code)
+		}
+		var stats []domain.PoolStats
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return stats
+	}
+	if stats := pools(); len(stats) != 0 {
+		t.Fatalf("pools before any query = %+v", stats)
+	}
+	if w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil); w.Code != http.StatusOK {
+		t.Fatalf("data status = %d: %s", w.Code, w.Body.String())
+	}
+	if stats := pools(); len(stats) != 1 || stats[0].DataSourceID != ds.ID {
+		t.Fatalf("pools after query = %+v", stats)
+	}
+
+	if w := doRequest(router, http.MethodDelete, "/api/data-sources/"+ds.ID.String()+"?force", nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body.String())
+	}
+	if stats := pools(); len(stats) != 0 {
+		t.Fatalf("pools after delete = %+v", stats)
+	}
+}
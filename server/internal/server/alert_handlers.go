@@ -0,0 +1,136 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type alertHandlers struct {
+	svc *service.AlertService
+}
+
+func registerAlertRoutes(api *gin.RouterGroup, svc *service.AlertService) {
+	h := alertHandlers{svc: svc}
+	g := api.Group("/alert-rules")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.GET("/:id/history", h.history)
+	g.POST("/:id/evaluate", h.evaluate)
+}
+
+// alertRuleID parses the :id path parameter, writing a 400 on failure.
+func alertRuleID(c *gin.Context) (domain.AlertRuleID, bool) {
+	id, err := domain.ParseAlertRuleID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.AlertRuleID{}, false
+	}
+	return id, true
+}
+
+func (h alertHandlers) list(c *gin.Context) {
+	rules, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h alertHandlers) create(c *gin.Context) {
+	var opts domain.CreateAlertRuleOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	r, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+func (h alertHandlers) get(c *gin.Context) {
+	id, ok := alertRuleID(c)
+	if !ok {
+		return
+	}
+	r, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h alertHandlers) update(c *gin.Context) {
+	id, ok := alertRuleID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateAlertRuleOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	r, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h alertHandlers) delete(c *gin.Context) {
+	id, ok := alertRuleID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// history returns the state changes of a rule, newest first; ?limit=
+// bounds how many.
+func (h alertHandlers) history(c *gin.Context) {
+	id, ok := alertRuleID(c)
+	if !ok {
+		return
+	}
+	limit, err := nonNegative(c, "limit")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	events, err := h.svc.History(c.Request.Context(), id, limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// evaluate evaluates a rule immediately and returns it with its status.
+func (h alertHandlers) evaluate(c *gin.Context) {
+	id, ok := alertRuleID(c)
+	if !ok {
+		return
+	}
+	r, err := h.svc.Evaluate(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,92 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/server"
+)
+
+func TestAlertRules(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	// evaluations are requested explicitly, not left to the scheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	router := server.NewRouter(ctx, deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE disks (used REAL); INSERT INTO disks VALUES (0.5), (0.97);`)
+
+	w := doRequest(router, http.MethodPost, "/api/alert-rules", map[string]any{
+		"name":        "disk full",
+		"query":       map[string]any{"dataSourceId": ds.ID, "properties": map[string]string{"sql": "SELECT used FROM disks"}},
+		"condition":   "any(used, # > 0.95)",
+		"interval":    "30s",
+		"annotations": map[string]string{"summary": "a disk is almost full"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var rule domain.AlertRule
+	if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rule.Status.State != domain.AlertNormal || rule.Annotations["summary"] == "" {
+		t.Fatalf("created = %+v", rule)
+	}
+	w = doRequest(router, http.MethodPost, "/api/alert-rules", map[string]any{"name": "x", "condition": "true", "interval": "often"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad interval: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = doRequest(router, http.MethodPost, "/api/alert-rules", map[string]any{
+		"name": "x", "condition": "used >", "query": map[string]any{"dataSourceId": ds.ID},
+	})
+	var body struct {
+		Code    string `json:"code"`
+		Details struct {
+			Field string `json:"field"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusBadRequest || body.Details.Field != "condition" {
+		t.Fatalf("bad condition: status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	path := "/api/alert-rules/" + rule.ID.String()
+	w = doRequest(router, http.MethodPost, path+"/evaluate", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("evaluate: status = %d: %s", w.Code, w.Body.String())
+	}
+	if rule.Status.State != domain.AlertFiring || rule.Status.EvaluatedAt == nil {
+		t.Fatalf("status = %+v, want firing", rule.Status)
+	}
+
+	w = doRequest(router, http.MethodGet, path+"/history?limit=5", nil)
+	var history []domain.AlertEvent
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(history) != 1 || history[0].From != domain.AlertNormal || history[0].To != domain.AlertFiring {
+		t.Fatalf("history = %+v", history)
+	}
+
+	w = doRequest(router, http.MethodPut, path, map[string]any{
+		"name":      "disk full",
+		"query":     map[string]any{"dataSourceId": ds.ID, "properties": map[string]string{"sql": "SELECT used FROM disks"}},
+		"condition": "any(used, # > 0.99)",
+		"paused":    true,
+	})
+	if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	if !rule.Paused || rule.Status.State != domain.AlertFiring || rule.Interval != domain.Duration(60e9) {
+		t.Fatalf("updated = %+v", rule)
+	}
+
+	if w := doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w := doRequest(router, http.MethodGet, path+"/history", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("history of deleted: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -2,17 +2,76 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/service"
 )
 
 // Deps holds dependencies injected into the HTTP server.
 // Extend this struct as new services are implemented.
-type Deps struct{}
+// Nil services leave their routes unregistered.
+type Deps struct {
+	DataSources *service.DataSourceService
+	Queries     *service.QueryService
+	// Jobs, when set, has the jobs a previous process left unfinished
+	// failed by Start.
+	Jobs         *service.QueryJobService
+	Variables    *service.VariableService
+	Transformers *service.TransformerService
+	// Alerts, when set, also has its rules evaluated on schedule once
+	// Start is called.
+	Alerts        *service.AlertService
+	ContactPoints *service.ContactPointService
+	Silences      *service.SilenceService
+	// Notifications, when set, also has its alert groups flushed on
+	// schedule once Start is called.
+	Notifications *service.NotificationService
+	// Reports, when set, also has its reports run on schedule once Start
+	// is called.
+	Reports *service.ReportService
+}
+
+// Start fails the query jobs a previous process left unfinished and runs
+// the schedulers of deps until ctx is done or stop is called. stop waits
+// for the schedulers to return. Call it once, before serving requests.
+func Start(ctx context.Context, deps Deps) (stop func(), err error) {
+	if deps.Jobs != nil {
+		if err := deps.Jobs.Recover(ctx); err != nil {
+			return nil, fmt.Errorf("query jobs: recover: %w", err)
+		}
+	}
+	var schedulers []func(context.Context)
+	if deps.Alerts != nil {
+		schedulers = append(schedulers, deps.Alerts.Run)
+	}
+	if deps.Notifications != nil {
+		schedulers = append(schedulers, deps.Notifications.Run)
+	}
+	if deps.Reports != nil {
+		schedulers = append(schedulers, deps.Reports.Run)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, run := range schedulers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
 
 // NewRouter wires the HTTP router with common endpoints.
 // This keeps bootstrap logic in one place for tests and main.
-func NewRouter(_ context.Context, _ Deps) *gin.Engine {
+func NewRouter(_ context.Context, deps Deps) *gin.Engine {
 	r := gin.New()
 
 	// Default middleware: logging and recovery. Can be swapped if needed.
@@ -23,5 +82,36 @@ func NewRouter(_ context.Context, _ Deps) *gin.Engine {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	api := r.Group("/api")
+	if deps.DataSources != nil {
+		registerDataSourceRoutes(api, deps.DataSources, deps.Queries)
+	}
+	if deps.Queries != nil {
+		registerComponentRoutes(api, deps.Queries, deps.Jobs)
+		registerQueryRoutes(api, deps.Queries)
+		registerAdminRoutes(api, deps.Queries)
+	}
+	if deps.Variables != nil {
+		registerVariableRoutes(api, deps.Variables, deps.Queries)
+	}
+	if deps.Transformers != nil {
+		registerTransformerRoutes(api, deps.Transformers)
+	}
+	if deps.Alerts != nil {
+		registerAlertRoutes(api, deps.Alerts)
+	}
+	if deps.ContactPoints != nil {
+		registerContactPointRoutes(api, deps.ContactPoints)
+	}
+	if deps.Silences != nil {
+		registerSilenceRoutes(api, deps.Silences)
+	}
+	if deps.Notifications != nil {
+		registerNotificationRoutes(api, deps.Notifications)
+	}
+	if deps.Reports != nil {
+		registerReportRoutes(api, deps.Reports)
+	}
+
 	return r
 }
@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
 	"github.com/smilu97/refana/internal/server"
 )
 
@@ -46,3 +49,38 @@ func TestNewRouter_NotFound(t *testing.T) {
 		t.Fatalf("not-found status = %d, want %d", w.Code, http.StatusNotFound)
 	}
 }
+
+func TestStart_RecoversJobs(t *testing.T) {
+	deps, db := newTestDeps(t)
+	jobs := repository.NewQueryJobRepository(db)
+	left := domain.QueryJob{ID: domain.NewQueryJobID(1), ComponentID: domain.NewComponentID(1), Status: domain.QueryJobRunning, CreatedAt: time.Now()}
+	if err := jobs.Create(context.Background(), left); err != nil {
+		t.Fatal(err)
+	}
+
+	// routing alone starts nothing
+	server.NewRouter(context.Background(), deps)
+	if job, err := jobs.Get(context.Background(), left.ID); err != nil || job.Status != domain.QueryJobRunning {
+		t.Fatalf("job after NewRouter = %+v, %v; want it untouched", job, err)
+	}
+
+	stop, err := server.Start(context.Background(), deps)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	job, err := jobs.Get(context.Background(), left.ID)
+	if err != nil || job.Status != domain.QueryJobFailed || job.ExpiresAt == nil {
+		t.Fatalf("job = %+v, %v; want failed with an expiry", job, err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop did not return")
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tablearrow"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type componentHandlers struct {
+	queries *service.QueryService
+	jobs    *service.QueryJobService
+}
+
+// registerComponentRoutes registers the data routes; the job routes only
+// when jobs is non-nil.
+func registerComponentRoutes(api *gin.RouterGroup, queries *service.QueryService, jobs *service.QueryJobService) {
+	h := componentHandlers{queries: queries, jobs: jobs}
+	g := api.Group("/components")
+	g.GET("/:id/data", h.data)
+	g.GET("/:id/properties", h.properties)
+	if jobs != nil {
+		g.POST("/:id/data/jobs", h.submitJob)
+		g.GET("/:id/data/jobs/:jobId", h.job)
+	}
+}
+
+// componentID parses the :id path parameter, writing a 400 on failure.
+func componentID(c *gin.Context) (domain.ComponentID, bool) {
+	id, err := domain.ParseComponentID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.ComponentID{}, false
+	}
+	return id, true
+}
+
+// properties answers the properties of the component with its dynamic
+// properties evaluated for the variable selection of the request.
+func (h componentHandlers) properties(c *gin.Context) {
+	id, ok := componentID(c)
+	if !ok {
+		return
+	}
+	vars, err := variableSelection(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	props, err := h.queries.ComponentProperties(c.Request.Context(), id, vars)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, props)
+}
+
+// submitJob accepts the same query parameters as data and answers 202
+// with the queued job, to be polled at its Location.
+func (h componentHandlers) submitJob(c *gin.Context) {
+	id, ok := componentID(c)
+	if !ok {
+		return
+	}
+	opts, err := dataOptions(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	vars, err := variableSelection(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	job, err := h.jobs.Submit(c.Request.Context(), id, opts, vars)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("Location", c.Request.URL.Path+"/"+job.ID.String())
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h componentHandlers) job(c *gin.Context) {
+	id, ok := componentID(c)
+	if !ok {
+		return
+	}
+	jobID, err := domain.ParseQueryJobID(c.Param("jobId"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	job, err := h.jobs.Get(c.Request.Context(), id, jobID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+const (
+	headerTotalRows      = "X-Total-Rows"
+	headerTotalEstimated = "X-Total-Estimated"
+	headerNextCursor     = "X-Next-Cursor"
+	headerCache          = "X-Cache"
+	headerTruncated      = "X-Truncated"
+)
+
+// setCacheHeaders reports whether data came from the result cache and, if
+// so, its age in seconds in the standard Age header.
+func setCacheHeaders(c *gin.Context, status service.CacheStatus) {
+	if !status.Hit {
+		c.Header(headerCache, "MISS")
+		return
+	}
+	c.Header(headerCache, "HIT")
+	c.Header("Age", strconv.Itoa(int(status.Age.Seconds())))
+}
+
+// dataOptions parses the paging, sorting and filtering query parameters:
+//
+//	limit=50&offset=100 or limit=50&cursor=<nextCursor>
+//	sort=-created,name          descending when prefixed with "-"
+//	filter=status:eq:open       repeatable, column:op[:value]
+func dataOptions(c *gin.Context) (domain.DataOptions, error) {
+	var opts domain.DataOptions
+	var err error
+	if opts.Limit, err = nonNegative(c, "limit"); err != nil {
+		return opts, err
+	}
+	if opts.Offset, err = nonNegative(c, "offset"); err != nil {
+		return opts, err
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if _, ok := c.GetQuery("offset"); ok {
+			return opts, fmt.Errorf("%w: cursor and offset are exclusive", service.ErrBadRequest)
+		}
+		offset, ok := domain.DecodeCursor(cursor)
+		if !ok {
+			return opts, fmt.Errorf("%w: invalid cursor", service.ErrBadRequest)
+		}
+		opts.Offset = offset
+	}
+	for _, param := range c.QueryArray("sort") {
+		for _, key := range strings.Split(param, ",") {
+			desc := strings.HasPrefix(key, "-")
+			name := strings.TrimPrefix(key, "-")
+			if name == "" {
+				return opts, fmt.Errorf("%w: invalid sort %q", service.ErrBadRequest, param)
+			}
+			opts.Sort = append(opts.Sort, domain.SortKey{Column: domain.Name(name), Descending: desc})
+		}
+	}
+	for _, param := range c.QueryArray("filter") {
+		parts := strings.SplitN(param, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || !domain.FilterOp(parts[1]).Valid() {
+			return opts, fmt.Errorf("%w: invalid filter %q", service.ErrBadRequest, param)
+		}
+		f := domain.Filter{Column: domain.Name(parts[0]), Op: domain.FilterOp(parts[1])}
+		if len(parts) == 3 {
+			f.Value = domain.PropertyValue(parts[2])
+		}
+		opts.Filters = append(opts.Filters, f)
+	}
+	return opts, nil
+}
+
+func nonNegative(c *gin.Context, key string) (int, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", service.ErrBadRequest, key)
+	}
+	return n, nil
+}
+
+// arrowBatchRows bounds the rows buffered per Arrow record batch.
+const arrowBatchRows = 4096
+
+func (h componentHandlers) data(c *gin.Context) {
+	id, ok := componentID(c)
+	if !ok {
+		return
+	}
+	opts, err := dataOptions(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	vars, err := variableSelection(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx, done, ok := startRun(c, h.queries)
+	if !ok {
+		return
+	}
+	defer done()
+	if c.NegotiateFormat(gin.MIMEJSON, tablearrow.ContentType) == tablearrow.ContentType {
+		h.streamArrow(ctx, c, id, opts, vars)
+		return
+	}
+	table, status, err := h.queries.ComponentData(ctx, id, opts, vars)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	setCacheHeaders(c, status)
+	c.JSON(http.StatusOK, table)
+}
+
+// streamArrow writes record batches as the data source produces rows.
+// Errors before the first batch still get a JSON error response; later
+// ones end the body without the end-of-stream marker, which Arrow readers
+// report as a truncated stream.
+// Total and NextCursor have no place in the Arrow stream and travel in
+// headers instead; Truncated is only known at the end and is a trailer.
+// A column widened by a later batch starts another stream in the body.
+func (h componentHandlers) streamArrow(ctx context.Context, c *gin.Context, id domain.ComponentID, opts domain.DataOptions, vars domain.VariableSelection) {
+	var w *tablearrow.Writer
+	truncated := false
+	err := h.queries.StreamComponentData(ctx, id, opts, vars, arrowBatchRows, func(batch domain.TableData, status service.CacheStatus) error {
+		truncated = truncated || batch.Truncated
+		if w == nil {
+			c.Header("Trailer", headerTruncated)
+			setCacheHeaders(c, status)
+			if batch.Total != nil {
+				c.Header(headerTotalRows, strconv.FormatInt(batch.Total.Rows, 10))
+				c.Header(headerTotalEstimated, strconv.FormatBool(batch.Total.Estimated))
+			}
+			if batch.NextCursor != "" {
+				c.Header(headerNextCursor, batch.NextCursor)
+			}
+			c.Header("Content-Type", tablearrow.ContentType)
+			c.Status(http.StatusOK)
+			w = tablearrow.NewWriter(c.Writer)
+		}
+		if err := w.Write(batch); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if w == nil {
+			writeError(c, err)
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+	if w == nil {
+		c.Header("Content-Type", tablearrow.ContentType)
+		c.Status(http.StatusOK)
+		w = tablearrow.NewWriter(c.Writer)
+	}
+	if err := w.Close(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if truncated {
+		c.Writer.Header().Set(headerTruncated, "true")
+	}
+}
@@ -0,0 +1,438 @@
+package server_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/pkg/tablearrow"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestComponentData(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ctx := context.Background()
+
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER, at DATETIME); INSERT INTO t VALUES (7, NULL);`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n, at FROM t"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyColumnMeta: `{"n": {"unit": "short"}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("data status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got := w.Header().Get("X-Cache"); got != "MISS" {
+		t.Fatalf("X-Cache = %q, want MISS", got)
+	}
+	var body struct {
+		Columns []struct {
+			Name   string            `json:"name"`
+			Type   string            `json:"type"`
+			Values []string          `json:"values"`
+			Data   []json.RawMessage `json:"data"`
+			Meta   map[string]any    `json:"meta"`
+		} `json:"columns"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	n, at := body.Columns[0], body.Columns[1]
+	if n.Type != "integer" || n.Values[0] != "7" || string(n.Data[0]) != "7" || n.Meta["unit"] != "short" {
+		t.Fatalf("n column = %+v", n)
+	}
+	if at.Type != "time" || at.Values[0] != "" || string(at.Data[0]) != "null" {
+		t.Fatalf("at column = %+v", at)
+	}
+
+	w = doRequest(router, http.MethodGet, "/api/components/"+domain.NewComponentID(1).String()+"/data", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing component status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestComponentDataArrow(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds := createSQLiteSource(t, deps, `
+		CREATE TABLE t (n INTEGER, label TEXT);
+		INSERT INTO t VALUES (1, 'a'), (2, NULL);
+	`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n, label FROM t ORDER BY n"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	req.Header.Set("Accept", tablearrow.ContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != tablearrow.ContentType {
+		t.Fatalf("content-type = %q, want %q", got, tablearrow.ContentType)
+	}
+
+	r, err := ipc.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	defer r.Release()
+	if !r.Next() {
+		t.Fatalf("no record batch: %v", r.Err())
+	}
+	rec := r.RecordBatch()
+	if rec.NumRows() != 2 || rec.Schema().Field(0).Type.ID() != arrow.INT64 {
+		t.Fatalf("record = %v", rec)
+	}
+	if !rec.Column(1).IsNull(1) {
+		t.Fatalf("label row 1 should be null")
+	}
+}
+
+// A value past the first record batch that does not fit its column
+// widens the column from there on, in a stream of its own.
+func TestComponentDataArrowWidensColumns(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds := createSQLiteSource(t, deps, `
+		CREATE TABLE t (n INTEGER);
+		WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < 5000)
+		INSERT INTO t SELECT i FROM seq;
+		INSERT INTO t VALUES ('n/a');
+	`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	req.Header.Set("Accept", tablearrow.ContentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var types []arrow.Type
+	rows := int64(0)
+	for w.Body.Len() > 0 {
+		r, err := ipc.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("NewReader: %v", err)
+		}
+		types = append(types, r.Schema().Field(0).Type.ID())
+		for r.Next() {
+			rows += r.RecordBatch().NumRows()
+		}
+		if r.Err() != nil {
+			t.Fatalf("read: %v", r.Err())
+		}
+		r.Release()
+	}
+	if !slices.Equal(types, []arrow.Type{arrow.INT64, arrow.STRING}) || rows != 5001 {
+		t.Fatalf("read %d rows in streams of %v; want 5001 in int64 then string", rows, types)
+	}
+}
+
+func TestComponentDataPaging(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds := createSQLiteSource(t, deps, `
+		CREATE TABLE t (n INTEGER, label TEXT);
+		INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, NULL);
+	`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n, label FROM t"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	base := "/api/components/" + comp.ID.String() + "/data"
+
+	w := doRequest(router, http.MethodGet, base+"?limit=2&sort=-n&filter=label:notnull", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var page domain.TableData
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if page.Total == nil || page.Total.Rows != 3 || page.NextCursor == "" {
+		t.Fatalf("total = %+v, cursor = %q", page.Total, page.NextCursor)
+	}
+	if ns := page.Columns[0].Ints; len(ns) != 2 || ns[0] != 3 || ns[1] != 2 {
+		t.Fatalf("first page = %v, want [3 2]", ns)
+	}
+
+	w = doRequest(router, http.MethodGet, base+"?limit=2&sort=-n&filter=label:notnull&cursor="+page.NextCursor, nil)
+	page = domain.TableData{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if ns := page.Columns[0].Ints; len(ns) != 1 || ns[0] != 1 || page.NextCursor != "" {
+		t.Fatalf("second page = %v, cursor = %q", ns, page.NextCursor)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, base+"?limit=1", nil)
+	req.Header.Set("Accept", tablearrow.ContentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Header().Get("X-Total-Rows") != "4" || w.Header().Get("X-Next-Cursor") == "" {
+		t.Fatalf("arrow headers = %v", w.Header())
+	}
+
+	for _, query := range []string{"limit=-1", "cursor=bogus", "filter=n:between:1", "sort=missing", "filter=n:gt:x"} {
+		w := doRequest(router, http.MethodGet, base+"?"+query, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestComponentDataJobs(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (1), (2), (3);`)
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	w := doRequest(router, http.MethodPost, "/api/components/"+comp.ID.String()+"/data/jobs?sort=-n", nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submit status = %d, want %d: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+
+	var job domain.QueryJob
+	deadline := time.Now().Add(5 * time.Second)
+	for !job.Status.Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish: %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+		w = doRequest(router, http.MethodGet, location, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("poll status = %d: %s", w.Code, w.Body.String())
+		}
+		job = domain.QueryJob{}
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	if job.Status != domain.QueryJobSucceeded || job.RowsFetched != 3 || job.Result == nil {
+		t.Fatalf("job = %+v, want succeeded with 3 rows", job)
+	}
+	if ns := job.Result.Columns[0].Ints; len(ns) != 3 || ns[0] != 3 || job.Result.Total.Rows != 3 {
+		t.Fatalf("result = %v", ns)
+	}
+
+	other := domain.NewComponentID(1).String()
+	w = doRequest(router, http.MethodGet, "/api/components/"+other+"/data/jobs/"+job.ID.String(), nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("job of other component status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestComponentDataReadOnly(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds, err := deps.DataSources.Create(context.Background(), domain.CreateDataSourceOptions{
+		Name:    "guarded",
+		ClassID: "sqlite",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"path":                     createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER);`).Properties["path"],
+			sqlsource.PropertyReadOnly: "true",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t; DROP TABLE t"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+	}
+	var body server.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	details, _ := body.Details.(map[string]any)
+	if body.Code != "read_only_violation" || details["statement"] != float64(2) || details["keyword"] != "DROP" {
+		t.Fatalf("error = %+v, want a read_only_violation of statement 2 at DROP", body)
+	}
+}
+
+// createSQLiteSource registers a sqlite data source backed by a fresh file.
+func createSQLiteSource(t *testing.T, deps server.Deps, schema string) domain.DataSource {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "source.db")
+	src, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Exec(schema); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	ds, err := deps.DataSources.Create(context.Background(), domain.CreateDataSourceOptions{
+		Name:       "source",
+		ClassID:    "sqlite",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"path": domain.PropertyValue(path)},
+	})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	return ds
+}
+
+func TestComponentExpressions(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ctx := context.Background()
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (7);`)
+	for _, v := range []domain.CreateVariableOptions{
+		{Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"}},
+		{Name: "hosts", Type: domain.VariableCustom, Multi: true, Options: []domain.PropertyValue{"a", "b", "c"}},
+	} {
+		if _, err := deps.Variables.Create(ctx, v); err != nil {
+			t.Fatalf("Create variable: %v", err)
+		}
+	}
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	opts := domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"title":                                  "static",
+			"unit":                                   "short",
+			service.ComponentPropertyDynamic:         `{"title": "'CPU of ' + join(hosts, ', ')", "critical": "env == 'prod'"}`,
+			service.ComponentPropertyTransformations: `[{"type": "expression", "options": {"as": "x", "expression": "n + 'a'"}}]`,
+		},
+	}
+	comp, err := comps.Create(ctx, opts)
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/properties?var-hosts=a&var-hosts=c", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("properties status = %d: %s", w.Code, w.Body.String())
+	}
+	var props map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &props); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if props["title"] != "CPU of a, c" || props["critical"] != "true" || props["unit"] != "short" {
+		t.Fatalf("properties = %v", props)
+	}
+
+	// an expression that fails on the data names its field
+	w = doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	var body struct {
+		Code    string `json:"code"`
+		Details struct {
+			Field string `json:"field"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || body.Code != "invalid_expression" || body.Details.Field != "properties.transformations[0]" {
+		t.Fatalf("data: status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	// and so does one that does not compile, when the component is saved
+	opts.Properties[service.ComponentPropertyDynamic] = `{"title": "'CPU of ' +"}`
+	_, err = comps.Create(ctx, opts)
+	var e *expression.Error
+	if !errors.Is(err, service.ErrBadRequest) || !errors.As(err, &e) || e.Field != "properties.dynamicProperties.title" {
+		t.Fatalf("Create: err = %v, want an invalid expression at properties.dynamicProperties.title", err)
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type contactPointHandlers struct {
+	svc *service.ContactPointService
+}
+
+func registerContactPointRoutes(api *gin.RouterGroup, svc *service.ContactPointService) {
+	h := contactPointHandlers{svc: svc}
+	api.GET("/contact-point-types", h.types)
+	g := api.Group("/contact-points")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.POST("/test", h.testSettings)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.POST("/:id/test", h.test)
+}
+
+// contactPointID parses the :id path parameter, writing a 400 on failure.
+func contactPointID(c *gin.Context) (domain.ContactPointID, bool) {
+	id, err := domain.ParseContactPointID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.ContactPointID{}, false
+	}
+	return id, true
+}
+
+func (h contactPointHandlers) types(c *gin.Context) {
+	c.JSON(http.StatusOK, h.svc.Types())
+}
+
+func (h contactPointHandlers) list(c *gin.Context) {
+	list, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	for i := range list {
+		list[i] = h.svc.Redact(list[i])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h contactPointHandlers) create(c *gin.Context) {
+	var opts domain.CreateContactPointOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	cp, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, h.svc.Redact(cp))
+}
+
+func (h contactPointHandlers) get(c *gin.Context) {
+	id, ok := contactPointID(c)
+	if !ok {
+		return
+	}
+	cp, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.svc.Redact(cp))
+}
+
+func (h contactPointHandlers) update(c *gin.Context) {
+	id, ok := contactPointID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateContactPointOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	cp, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.svc.Redact(cp))
+}
+
+func (h contactPointHandlers) delete(c *gin.Context) {
+	id, ok := contactPointID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// test sends a test notification to a saved contact point. Delivery
+// failures answer 502 with the reason.
+func (h contactPointHandlers) test(c *gin.Context) {
+	id, ok := contactPointID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Test(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// testSettings sends a test notification to the contact point in the
+// body without saving it.
+func (h contactPointHandlers) testSettings(c *gin.Context) {
+	var opts domain.CreateContactPointOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	if err := h.svc.TestSettings(c.Request.Context(), opts); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,135 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/smilu97/refana/internal/notifier/email/emailtest"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/server"
+)
+
+func TestContactPointCRUDAndTest(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	w := doRequest(router, http.MethodGet, "/api/contact-point-types", nil)
+	var types []domain.ContactPointType
+	if err := json.Unmarshal(w.Body.Bytes(), &types); err != nil || len(types) != 4 {
+		t.Fatalf("types = %+v, %v", types, err)
+	}
+
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	payloads := make(chan string, 4)
+	var auth atomic.Value
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		payloads <- string(body)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer hook.Close()
+
+	w = doRequest(router, http.MethodPost, "/api/contact-points", domain.CreateContactPointOptions{
+		Name: "ops",
+		Type: "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{
+			"url":      domain.PropertyValue(hook.URL),
+			"template": `{"text": {{json .Title}}, "test": {{.Test}}}`,
+			"headers":  `{"Authorization": "Bearer token"}`,
+		},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.ContactPoint
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.Settings["headers"] != domain.SecretPlaceholder {
+		t.Fatalf("created headers = %q, want them redacted", created.Settings["headers"])
+	}
+	for name, opts := range map[string]domain.CreateContactPointOptions{
+		"duplicate":    {Name: "ops", Type: "webhook", Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "http://example.com"}},
+		"unknown type": {Name: "pager", Type: "pager"},
+		"bad template": {Name: "bad", Type: "webhook", Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "http://example.com", "template": "{{"}},
+	} {
+		want := http.StatusBadRequest
+		if name == "duplicate" {
+			want = http.StatusConflict
+		}
+		if w := doRequest(router, http.MethodPost, "/api/contact-points", opts); w.Code != want {
+			t.Errorf("%s: status = %d, want %d: %s", name, w.Code, want, w.Body.String())
+		}
+	}
+
+	// an edit sending back the redacted headers keeps the stored ones
+	path := "/api/contact-points/" + created.ID.String()
+	w = doRequest(router, http.MethodPut, path, domain.UpdateContactPointOptions{Name: "ops", Type: "webhook", Settings: created.Settings})
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "Bearer") {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(router, http.MethodPost, path+"/test", nil); w.Code != http.StatusOK {
+		t.Fatalf("test: status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := <-payloads; got != `{"text": "Test notification", "test": true}` {
+		t.Fatalf("test payload = %s", got)
+	}
+	if got := auth.Load(); got != "Bearer token" {
+		t.Fatalf("Authorization = %v after the update", got)
+	}
+
+	status.Store(http.StatusNotFound)
+	w = doRequest(router, http.MethodPost, path+"/test", nil)
+	<-payloads
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "404") {
+		t.Fatalf("failing test: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w := doRequest(router, http.MethodPost, path+"/test", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("test after delete: status = %d", w.Code)
+	}
+}
+
+func TestContactPointTestSettings(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	smtp, err := emailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtp.Close()
+
+	w := doRequest(router, http.MethodPost, "/api/contact-points/test", domain.CreateContactPointOptions{
+		Name: "mail",
+		Type: "email",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{
+			"host":    domain.PropertyValue(smtp.Host),
+			"port":    domain.PropertyValue(smtp.Port),
+			"from":    "alerts@example.com",
+			"to":      "ops@example.com",
+			"subject": "{{if .Test}}[test] {{end}}{{.Title}}",
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test: status = %d: %s", w.Code, w.Body.String())
+	}
+	msgs := smtp.Messages()
+	if len(msgs) != 1 || !strings.Contains(msgs[0].Data, "Subject: [test] Test notification") {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	w = doRequest(router, http.MethodGet, "/api/contact-points", nil)
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("list = %s, want nothing saved", w.Body.String())
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type dataSourceHandlers struct {
+	svc     *service.DataSourceService
+	queries *service.QueryService
+}
+
+// registerDataSourceRoutes registers the connection test and schema routes
+// only when queries is non-nil.
+func registerDataSourceRoutes(api *gin.RouterGroup, svc *service.DataSourceService, queries *service.QueryService) {
+	h := dataSourceHandlers{svc: svc, queries: queries}
+	g := api.Group("/data-sources")
+	g.GET("/by-alias/:alias", h.getByAlias)
+	g.GET("/:id/usages", h.usages)
+	g.DELETE("/:id", h.delete)
+	if queries != nil {
+		g.POST("/test", h.test)
+		g.POST("/:id/test", h.testSaved)
+		g.GET("/:id/schema", h.schema)
+	}
+}
+
+// queryFlag treats a bare ?name or any strconv true value as set.
+func queryFlag(c *gin.Context, name string) bool {
+	v, ok := c.GetQuery(name)
+	if !ok {
+		return false
+	}
+	if v == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(v)
+	return err == nil && b
+}
+
+// dataSourceID parses the :id path parameter, writing a 400 on failure.
+func dataSourceID(c *gin.Context) (domain.DataSourceID, bool) {
+	id, err := domain.ParseDataSourceID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.DataSourceID{}, false
+	}
+	return id, true
+}
+
+func (h dataSourceHandlers) getByAlias(c *gin.Context) {
+	ds, err := h.svc.GetByAlias(c.Request.Context(), domain.Alias(c.Param("alias")))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.svc.Redact(ds))
+}
+
+func (h dataSourceHandlers) usages(c *gin.Context) {
+	id, ok := dataSourceID(c)
+	if !ok {
+		return
+	}
+	comps, err := h.svc.Usages(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, comps)
+}
+
+// delete refuses to remove a referenced data source unless the caller opts
+// into ?cascade=true (delete dependents) or ?force=true (orphan them).
+func (h dataSourceHandlers) delete(c *gin.Context) {
+	id, ok := dataSourceID(c)
+	if !ok {
+		return
+	}
+	policy := domain.DataSourceDeleteRestrict
+	switch {
+	case queryFlag(c, "cascade"):
+		policy = domain.DataSourceDeleteCascade
+	case queryFlag(c, "force"):
+		policy = domain.DataSourceDeleteForce
+	}
+	if err := h.svc.DeleteWithPolicy(c.Request.Context(), id, policy); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// test checks the connection settings in the body, shaped like a create
+// request, without saving them. A failed check is still a 200; the report
+// tells which step failed.
+func (h dataSourceHandlers) test(c *gin.Context) {
+	var opts domain.CreateDataSourceOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	report, err := h.queries.CheckDataSource(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (h dataSourceHandlers) testSaved(c *gin.Context) {
+	id, ok := dataSourceID(c)
+	if !ok {
+		return
+	}
+	report, err := h.queries.CheckSavedDataSource(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// schema describes the tables of a data source from the schema cache,
+// or afresh with ?refresh=true.
+func (h dataSourceHandlers) schema(c *gin.Context) {
+	id, ok := dataSourceID(c)
+	if !ok {
+		return
+	}
+	schema, status, err := h.queries.DataSourceSchema(c.Request.Context(), id, queryFlag(c, "refresh"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	setCacheHeaders(c, status)
+	c.JSON(http.StatusOK, schema)
+}
@@ -0,0 +1,260 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/email"
+	"github.com/smilu97/refana/internal/notifier/webhook"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+	"github.com/smilu97/refana/internal/storage"
+)
+
+func TestDataSourceByAlias(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	ds, err := deps.DataSources.Create(context.Background(), domain.CreateDataSourceOptions{
+		ClassID: "postgres",
+		Name:    "Warehouse",
+		Alias:   "warehouse",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/data-sources/by-alias/warehouse", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("by-alias status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var got domain.DataSource
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.ID.Int64() != ds.ID.Int64() || got.Alias != "warehouse" {
+		t.Fatalf("by-alias = (%s,%s), want (%s,warehouse)", got.ID, got.Alias, ds.ID)
+	}
+
+	w = doRequest(router, http.MethodGet, "/api/data-sources/by-alias/unknown", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown alias status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDataSourceDeleteReferenced(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ctx := context.Background()
+
+	ds, err := deps.DataSources.Create(ctx, domain.CreateDataSourceOptions{ClassID: "postgres", Name: "pg"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries:         []domain.Query{{Name: "q", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/data-sources/"+ds.ID.String()+"/usages", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("usages status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var usages []domain.Component
+	if err := json.Unmarshal(w.Body.Bytes(), &usages); err != nil {
+		t.Fatalf("decode usages: %v", err)
+	}
+	if len(usages) != 1 || usages[0].ID.Int64() != comp.ID.Int64() {
+		t.Fatalf("usages = %v, want [%s]", usages, comp.ID)
+	}
+
+	w = doRequest(router, http.MethodDelete, "/api/data-sources/"+ds.ID.String(), nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("delete referenced status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = doRequest(router, http.MethodDelete, "/api/data-sources/"+ds.ID.String()+"?cascade=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("cascade delete status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if _, err := comps.Get(ctx, comp.ID); err != service.ErrNotFound {
+		t.Fatalf("component after cascade: expected ErrNotFound, got %v", err)
+	}
+
+	w = doRequest(router, http.MethodDelete, "/api/data-sources/bogus", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bogus id status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDataSourceConnectionTest(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER);`)
+
+	w := doRequest(router, http.MethodPost, "/api/data-sources/"+ds.ID.String()+"/test", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("test saved status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var report domain.HealthCheckReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !report.OK {
+		t.Fatalf("report = %+v, want OK", report)
+	}
+
+	// unsaved settings are tested as given; failures are reported, not errors
+	w = doRequest(router, http.MethodPost, "/api/data-sources/test", domain.CreateDataSourceOptions{
+		ClassID:    "sqlite",
+		Name:       "draft",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"path": domain.PropertyValue(filepath.Join(t.TempDir(), "none.db"))},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("test draft status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.OK {
+		t.Fatalf("missing database file reported OK")
+	}
+
+	w = doRequest(router, http.MethodPost, "/api/data-sources/test", domain.CreateDataSourceOptions{ClassID: "nope", Name: "draft"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown class status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = doRequest(router, http.MethodPost, "/api/data-sources/"+domain.NewDataSourceID(1).String()+"/test", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown data source status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDataSourceSchema(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE orders (id INTEGER, total REAL);`)
+	path := "/api/data-sources/" + ds.ID.String() + "/schema"
+
+	tables := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("schema status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+		var schema domain.DatabaseSchema
+		if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var names []string
+		for _, s := range schema.Schemas {
+			for _, tbl := range s.Tables {
+				names = append(names, s.Name+"."+tbl.Name)
+			}
+		}
+		return names
+	}
+
+	w := doRequest(router, http.MethodGet, path, nil)
+	if got := tables(w); fmt.Sprint(got) != "[main.orders]" || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first schema = %v (%s), want [main.orders] from a miss", got, w.Header().Get("X-Cache"))
+	}
+
+	db, err := sql.Open("sqlite3", string(ds.Properties["path"]))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE customers (id INTEGER)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	w = doRequest(router, http.MethodGet, path, nil)
+	if got := tables(w); fmt.Sprint(got) != "[main.orders]" || w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("cached schema = %v (%s), want [main.orders] from a hit", got, w.Header().Get("X-Cache"))
+	}
+	w = doRequest(router, http.MethodGet, path+"?refresh=true", nil)
+	if got := tables(w); fmt.Sprint(got) != "[main.customers main.orders]" || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("refreshed schema = %v (%s), want both tables from a miss", got, w.Header().Get("X-Cache"))
+	}
+
+	w = doRequest(router, http.MethodGet, "/api/data-sources/"+domain.NewDataSourceID(1).String()+"/schema", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown data source status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// Helpers
+func newTestDeps(t *testing.T) (server.Deps, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dsn := fmt.Sprintf("file:server-%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := storage.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	components := service.NewComponentService(repository.NewComponentRepository(db))
+	dataSources := service.NewDataSourceService(repository.NewDataSourceRepository(db))
+	variables := service.NewVariableService(repository.NewVariableRepository(db))
+	transformers := service.NewTransformerService(repository.NewTransformerRepository(db))
+	queries := service.NewQueryService(components, dataSources, variables, transformers, datasource.NewRegistry(sqlsource.NewSQLite()))
+	alerts := service.NewAlertService(repository.NewAlertRuleRepository(db), queries)
+	contactPoints := service.NewContactPointService(
+		repository.NewContactPointRepository(db),
+		notifier.NewRegistry(webhook.New(), webhook.NewSlack(), webhook.NewTeams(), email.New()),
+		notifier.Backoff{Attempts: 2, Initial: time.Millisecond},
+	)
+	silences := service.NewSilenceService(repository.NewSilenceRepository(db))
+	return server.Deps{
+		DataSources:   dataSources,
+		Variables:     variables,
+		Transformers:  transformers,
+		Queries:       queries,
+		Jobs:          service.NewQueryJobService(repository.NewQueryJobRepository(db), queries, time.Hour),
+		Alerts:        alerts,
+		ContactPoints: contactPoints,
+		Silences:      silences,
+		Notifications: service.NewNotificationService(
+			repository.NewNotificationPolicyRepository(db), alerts, silences, contactPoints),
+		Reports: service.NewReportService(repository.NewReportRepository(db), queries, contactPoints),
+	}, db
+}
+
+func doRequest(router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != nil {
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req = httptest.NewRequest(method, path, nil)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
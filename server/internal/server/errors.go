@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/service"
+)
+
+// ErrorResponse is the body of every non-2xx API response. Code and
+// Details are set for errors that clients can act on programmatically.
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// detailedError is implemented by errors carrying a stable code and
+// details, such as datasource.ReadOnlyViolation.
+type detailedError interface {
+	error
+	ErrorCode() string
+	ErrorDetails() any
+}
+
+// statusClientClosedRequest is the non-standard status nginx logs for
+// requests abandoned by the client; cancelled query runs answer with it.
+const statusClientClosedRequest = 499
+
+// internalErrorMessage answers errors that map to no client-facing
+// sentinel; their detail is logged instead of leaked to the caller.
+const internalErrorMessage = "internal server error"
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(c *gin.Context, err error) {
+	var status int
+	switch {
+	case errors.Is(err, service.ErrBadRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, service.ErrCanceled):
+		status = statusClientClosedRequest
+	case errors.Is(err, service.ErrDelivery):
+		status = http.StatusBadGateway
+	default:
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Error: internalErrorMessage})
+		return
+	}
+	resp := ErrorResponse{Error: err.Error()}
+	var detailed detailedError
+	if errors.As(err, &detailed) {
+		resp.Code, resp.Details = detailed.ErrorCode(), detailed.ErrorDetails()
+	}
+	c.AbortWithStatusJSON(status, resp)
+}
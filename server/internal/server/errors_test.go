@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestWriteErrorHidesInternalDetail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		err    error
+		status int
+		body   string
+	}{
+		{errors.New("dial tcp 10.0.0.7:5432: password authentication failed"), http.StatusInternalServerError, internalErrorMessage},
+		{fmt.Errorf("%w: name is required", service.ErrBadRequest), http.StatusBadRequest, "bad request: name is required"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/x", nil)
+		writeError(c, tc.err)
+
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != tc.status || resp.Error != tc.body {
+			t.Fatalf("writeError(%v) = %d %q, want %d %q", tc.err, w.Code, resp.Error, tc.status, tc.body)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type notificationHandlers struct {
+	svc *service.NotificationService
+}
+
+func registerNotificationRoutes(api *gin.RouterGroup, svc *service.NotificationService) {
+	h := notificationHandlers{svc: svc}
+	api.GET("/notification-policy", h.policy)
+	api.PUT("/notification-policy", h.updatePolicy)
+}
+
+func (h notificationHandlers) policy(c *gin.Context) {
+	p, err := h.svc.Policy(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// updatePolicy replaces the routing tree with the root route in the body.
+func (h notificationHandlers) updatePolicy(c *gin.Context) {
+	var root domain.NotificationRoute
+	if err := c.ShouldBindJSON(&root); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	p, err := h.svc.UpdatePolicy(c.Request.Context(), root)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,61 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/server"
+)
+
+func TestNotificationPolicy(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	router := server.NewRouter(ctx, deps)
+
+	w := doRequest(router, http.MethodGet, "/api/notification-policy", nil)
+	var policy domain.NotificationPolicy
+	if err := json.Unmarshal(w.Body.Bytes(), &policy); err != nil || w.Code != http.StatusOK || policy.Root.ContactPointID != nil {
+		t.Fatalf("empty policy: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	cp, err := deps.ContactPoints.Create(context.Background(), domain.CreateContactPointOptions{
+		Name:     "ops",
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "http://127.0.0.1:1/hook"},
+	})
+	if err != nil {
+		t.Fatalf("contact point: %v", err)
+	}
+	root := map[string]any{
+		"contactPointId": cp.ID,
+		"groupBy":        []string{"alertname"},
+		"groupWait":      "10s",
+		"routes": []map[string]any{{
+			"matchers": []map[string]string{{"label": "team", "op": "=~", "value": "db|storage"}},
+			"groupBy":  []string{"team"},
+		}},
+	}
+	w = doRequest(router, http.MethodPut, "/api/notification-policy", root)
+	if err := json.Unmarshal(w.Body.Bytes(), &policy); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(router, http.MethodGet, "/api/notification-policy", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &policy); err != nil || len(policy.Root.Routes) != 1 ||
+		policy.Root.Routes[0].Matchers[0].Op != domain.MatchRegexp {
+		t.Fatalf("policy = %s", w.Body.String())
+	}
+
+	root["routes"] = []map[string]any{{"matchers": []map[string]string{{"label": "team", "op": "=~", "value": "("}}}}
+	w = doRequest(router, http.MethodPut, "/api/notification-policy", root)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad regexp: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = doRequest(router, http.MethodPut, "/api/notification-policy", map[string]any{"groupWait": "soon"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad duration: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type queryHandlers struct {
+	queries *service.QueryService
+}
+
+func registerQueryRoutes(api *gin.RouterGroup, queries *service.QueryService) {
+	h := queryHandlers{queries: queries}
+	g := api.Group("/queries")
+	g.DELETE("/:runId", h.cancel)
+}
+
+// headerRunID carries the run ID of a data request. Clients that may want
+// to cancel a request pass their own ID in the runId query parameter,
+// since the header only arrives with the response.
+const headerRunID = "X-Query-Run-Id"
+
+// startRun registers the query run of a data request, writing an error
+// response on failure. The run is cancelled when the client disconnects
+// or when DELETE /queries/:runId is called.
+func startRun(c *gin.Context, queries *service.QueryService) (context.Context, func(), bool) {
+	id := domain.NewQueryRunID(time.Now().UnixNano())
+	if raw := c.Query("runId"); raw != "" {
+		var err error
+		if id, err = domain.ParseQueryRunID(raw); err != nil {
+			writeError(c, service.ErrBadRequest)
+			return nil, nil, false
+		}
+	}
+	ctx, done, err := queries.StartRun(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return nil, nil, false
+	}
+	c.Header(headerRunID, id.String())
+	return ctx, done, true
+}
+
+func (h queryHandlers) cancel(c *gin.Context) {
+	id, err := domain.ParseQueryRunID(c.Param("runId"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	if err := h.queries.CancelRun(id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+// endless never finishes on its own; sqlite interrupts it when the
+// context of the query ends.
+const endless = `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c`
+
+func TestQueryCancel(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	comp := createEndlessComponent(t, deps, db, nil)
+
+	runID := domain.NewQueryRunID(time.Now().UnixNano()).String()
+	result := make(chan int, 1)
+	go func() {
+		w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data?runId="+runID, nil)
+		result <- w.Code
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := doRequest(router, http.MethodDelete, "/api/queries/"+runID, nil)
+		if w.Code == http.StatusNoContent {
+			break
+		}
+		if w.Code != http.StatusNotFound || time.Now().After(deadline) {
+			t.Fatalf("cancel status = %d", w.Code)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case code := <-result:
+		if code != 499 {
+			t.Fatalf("cancelled data status = %d, want 499", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("query kept running after cancel")
+	}
+
+	w := doRequest(router, http.MethodDelete, "/api/queries/"+runID, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("finished run cancel status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	comp := createEndlessComponent(t, deps, db, map[domain.PropertyKey]domain.PropertyValue{
+		service.PropertyTimeout: "50ms",
+	})
+
+	w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body.String())
+	}
+}
+
+func createEndlessComponent(
+	t *testing.T,
+	deps server.Deps,
+	db *gorm.DB,
+	props map[domain.PropertyKey]domain.PropertyValue,
+) domain.Component {
+	t.Helper()
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER);`)
+	queryProps := map[domain.PropertyKey]domain.PropertyValue{"sql": endless}
+	for k, v := range props {
+		queryProps[k] = v
+	}
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "endless",
+		Queries:         []domain.Query{{Name: "q", DataSourceID: ds.ID, Properties: queryProps}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	return comp
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type reportHandlers struct {
+	svc *service.ReportService
+}
+
+func registerReportRoutes(api *gin.RouterGroup, svc *service.ReportService) {
+	h := reportHandlers{svc: svc}
+	g := api.Group("/reports")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.GET("/:id/runs", h.runs)
+	g.POST("/:id/run", h.run)
+}
+
+// reportID parses the :id path parameter, writing a 400 on failure.
+func reportID(c *gin.Context) (domain.ReportID, bool) {
+	id, err := domain.ParseReportID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.ReportID{}, false
+	}
+	return id, true
+}
+
+func (h reportHandlers) list(c *gin.Context) {
+	reports, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func (h reportHandlers) create(c *gin.Context) {
+	var opts domain.CreateReportOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	rep, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, rep)
+}
+
+func (h reportHandlers) get(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	rep, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+func (h reportHandlers) update(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateReportOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	rep, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+func (h reportHandlers) delete(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h reportHandlers) runs(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	limit, err := nonNegative(c, "limit")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	runs, err := h.svc.Runs(c.Request.Context(), id, limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// run runs a report immediately and returns the record of the run, which
+// tells whether it was delivered.
+func (h reportHandlers) run(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	run, err := h.svc.RunNow(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
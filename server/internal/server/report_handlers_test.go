@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestReports(t *testing.T) {
+	deps, db := newTestDeps(t)
+	// runs are requested explicitly, not left to the scheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	router := server.NewRouter(ctx, deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE signups (day TEXT, n INTEGER); INSERT INTO signups VALUES ('mon', 3), ('tue', 5);`)
+	comp, err := service.NewComponentService(repository.NewComponentRepository(db)).Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "bar",
+		Name:            "Signups",
+		Queries:         []domain.Query{{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT day, n FROM signups"}}},
+	})
+	if err != nil {
+		t.Fatalf("component: %v", err)
+	}
+	payloads := make(chan domain.Notification, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var n domain.Notification
+		_ = json.Unmarshal(body, &n)
+		payloads <- n
+	}))
+	defer hook.Close()
+	cp, err := deps.ContactPoints.Create(context.Background(), domain.CreateContactPointOptions{
+		Name:     "growth",
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": domain.PropertyValue(hook.URL)},
+	})
+	if err != nil {
+		t.Fatalf("contact point: %v", err)
+	}
+
+	body := map[string]any{
+		"name":          "Weekly signups",
+		"schedule":      "0 9 * * MON",
+		"components":    []domain.ComponentID{comp.ID},
+		"from":          "now-7d",
+		"contactPoints": []domain.ContactPointID{cp.ID},
+	}
+	w := doRequest(router, http.MethodPost, "/api/reports", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var rep domain.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil || rep.NextRunAt == nil || rep.Format != domain.ReportHTML {
+		t.Fatalf("created = %s", w.Body.String())
+	}
+	body["schedule"] = "weekly"
+	if w = doRequest(router, http.MethodPost, "/api/reports", body); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad schedule: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	path := "/api/reports/" + rep.ID.String()
+	w = doRequest(router, http.MethodPost, path+"/run", nil)
+	var run domain.ReportRun
+	if err := json.Unmarshal(w.Body.Bytes(), &run); err != nil || w.Code != http.StatusOK || run.Status != domain.ReportSucceeded {
+		t.Fatalf("run: status = %d: %s", w.Code, w.Body.String())
+	}
+	n := <-payloads
+	if n.Title != "Weekly signups" || len(n.Attachments) != 1 || n.Attachments[0].ContentType != "image/png" {
+		t.Fatalf("delivered = %q with %d attachments", n.Title, len(n.Attachments))
+	}
+
+	w = doRequest(router, http.MethodGet, path+"/runs?limit=5", nil)
+	var runs []domain.ReportRun
+	if err := json.Unmarshal(w.Body.Bytes(), &runs); err != nil || len(runs) != 1 || runs[0].ID != run.ID {
+		t.Fatalf("runs = %s", w.Body.String())
+	}
+	if w = doRequest(router, http.MethodGet, path+"/runs?limit=-1", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad limit: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w = doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w = doRequest(router, http.MethodPost, path+"/run", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("run deleted: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
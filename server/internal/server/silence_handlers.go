@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type silenceHandlers struct {
+	svc *service.SilenceService
+}
+
+func registerSilenceRoutes(api *gin.RouterGroup, svc *service.SilenceService) {
+	h := silenceHandlers{svc: svc}
+	g := api.Group("/silences")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.POST("/:id/expire", h.expire)
+}
+
+// silenceID parses the :id path parameter, writing a 400 on failure.
+func silenceID(c *gin.Context) (domain.SilenceID, bool) {
+	id, err := domain.ParseSilenceID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.SilenceID{}, false
+	}
+	return id, true
+}
+
+func (h silenceHandlers) list(c *gin.Context) {
+	list, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h silenceHandlers) create(c *gin.Context) {
+	var opts domain.CreateSilenceOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	s, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+func (h silenceHandlers) get(c *gin.Context) {
+	id, ok := silenceID(c)
+	if !ok {
+		return
+	}
+	s, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h silenceHandlers) update(c *gin.Context) {
+	id, ok := silenceID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateSilenceOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	s, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h silenceHandlers) delete(c *gin.Context) {
+	id, ok := silenceID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// expire ends a silence now, keeping it listed as expired.
+func (h silenceHandlers) expire(c *gin.Context) {
+	id, ok := silenceID(c)
+	if !ok {
+		return
+	}
+	s, err := h.svc.Expire(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
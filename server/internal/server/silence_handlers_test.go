@@ -0,0 +1,64 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/server"
+)
+
+func TestSilences(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	w := doRequest(router, http.MethodPost, "/api/silences", map[string]any{
+		"matchers":  []map[string]string{{"label": "alertname", "op": "=", "value": "postgres down"}},
+		"duration":  "2h",
+		"comment":   "failover drill",
+		"createdBy": "alice",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var s domain.Silence
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil || s.State != domain.SilenceActive {
+		t.Fatalf("created = %s", w.Body.String())
+	}
+	w = doRequest(router, http.MethodPost, "/api/silences", map[string]any{
+		"matchers": []map[string]string{{"label": "alertname", "op": "=~", "value": ".*"}},
+		"duration": "2h",
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("silence of everything: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	path := "/api/silences/" + s.ID.String()
+	w = doRequest(router, http.MethodPost, path+"/expire", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil || w.Code != http.StatusOK || s.State != domain.SilenceExpired {
+		t.Fatalf("expire: status = %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(router, http.MethodPut, path, map[string]any{
+		"matchers": []map[string]string{{"label": "alertname", "op": "=", "value": "postgres down"}},
+		"duration": "1h",
+	})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("update expired: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	w = doRequest(router, http.MethodGet, "/api/silences", nil)
+	var list []domain.Silence
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("list = %s", w.Body.String())
+	}
+	if w = doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w = doRequest(router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w = doRequest(router, http.MethodGet, "/api/silences/x", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad id: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type transformerHandlers struct {
+	svc *service.TransformerService
+}
+
+func registerTransformerRoutes(api *gin.RouterGroup, svc *service.TransformerService) {
+	h := transformerHandlers{svc: svc}
+	g := api.Group("/transformers")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.POST("/run", h.run)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+}
+
+// transformerID parses the :id path parameter, writing a 400 on failure.
+func transformerID(c *gin.Context) (domain.TransformerID, bool) {
+	id, err := domain.ParseTransformerID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.TransformerID{}, false
+	}
+	return id, true
+}
+
+func (h transformerHandlers) list(c *gin.Context) {
+	list, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h transformerHandlers) create(c *gin.Context) {
+	var opts domain.CreateTransformerOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	t, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+func (h transformerHandlers) get(c *gin.Context) {
+	id, ok := transformerID(c)
+	if !ok {
+		return
+	}
+	t, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h transformerHandlers) update(c *gin.Context) {
+	id, ok := transformerID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateTransformerOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	t, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h transformerHandlers) delete(c *gin.Context) {
+	id, ok := transformerID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// transformerRunRequest is a script to try on a table, as it would run in a script
+// step.
+type transformerRunRequest struct {
+	Script string           `json:"script"`
+	Table  domain.TableData `json:"table"`
+}
+
+// run applies a script to the given table without saving it.
+func (h transformerHandlers) run(c *gin.Context) {
+	var req transformerRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	out, err := h.svc.Run(c.Request.Context(), req.Script, req.Table)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,137 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestTransformerCRUDAndRun(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	w := doRequest(router, http.MethodPost, "/api/transformers", domain.CreateTransformerOptions{
+		Name: "top", Script: "return table.rows.slice(0, 1)",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.Transformer
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for name, opts := range map[string]domain.CreateTransformerOptions{
+		"duplicate":    {Name: "top", Script: "return table"},
+		"syntax error": {Name: "broken", Script: "return ("},
+		"no script":    {Name: "empty"},
+	} {
+		want := http.StatusBadRequest
+		if name == "duplicate" {
+			want = http.StatusConflict
+		}
+		if w := doRequest(router, http.MethodPost, "/api/transformers", opts); w.Code != want {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, want)
+		}
+	}
+
+	path := "/api/transformers/" + created.ID.String()
+	if w := doRequest(router, http.MethodPut, path, domain.UpdateTransformerOptions{
+		Name: "top", Description: "first row", Script: "return table.rows.slice(0, 1)",
+	}); w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+	w = doRequest(router, http.MethodGet, path, nil)
+	var got domain.Transformer
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Description != "first row" {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+
+	n := domain.NewColumnData("n", domain.PropertyTypeInteger)
+	n.Ints = []int64{1, 2, 3}
+	w = doRequest(router, http.MethodPost, "/api/transformers/run", map[string]any{
+		"script": "return table.rows.map(r => ({n: r.n, square: r.n * r.n}))",
+		"table":  domain.TableData{Columns: []domain.ColumnData{n}},
+	})
+	var out domain.TableData
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if w.Code != http.StatusOK || len(out.Columns) != 2 || out.Columns[1].Ints[2] != 9 {
+		t.Fatalf("run: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(router, http.MethodPost, "/api/transformers/run", map[string]any{"script": "for (;;) {}"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("run forever: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	if w := doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w := doRequest(router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestComponentScriptSteps(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ctx := context.Background()
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (1), (2), (3);`)
+	tr, err := deps.Transformers.Create(ctx, domain.CreateTransformerOptions{Name: "double", Script: "return table.rows.map(r => ({n: r.n * 2}))"})
+	if err != nil {
+		t.Fatalf("Create transformer: %v", err)
+	}
+	comp, err := service.NewComponentService(repository.NewComponentRepository(db)).Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t ORDER BY n"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyCacheTTL: "1m",
+			service.ComponentPropertyTransformations: `[
+				{"type": "script", "options": {"transformer": "double"}},
+				{"type": "script", "options": {"script": "return table.rows.filter(r => r.n > 2)"}}
+			]`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	data := func() []int64 {
+		t.Helper()
+		w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("data: status = %d: %s", w.Code, w.Body.String())
+		}
+		var table domain.TableData
+		if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return table.Columns[0].Ints
+	}
+	if n := data(); len(n) != 2 || n[0] != 4 || n[1] != 6 {
+		t.Fatalf("n = %v, want [4 6]", n)
+	}
+	// a changed transformer is not served from the cache
+	if _, err := deps.Transformers.Update(ctx, tr.ID, domain.UpdateTransformerOptions{Name: "double", Script: "return table.rows.map(r => ({n: r.n * 10}))"}); err != nil {
+		t.Fatalf("Update transformer: %v", err)
+	}
+	if n := data(); len(n) != 3 || n[0] != 10 {
+		t.Fatalf("n = %v, want [10 20 30]", n)
+	}
+	if err := deps.Transformers.Delete(ctx, tr.ID); err != nil {
+		t.Fatalf("Delete transformer: %v", err)
+	}
+	if w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("deleted transformer: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/timerange"
+	"github.com/smilu97/refana/internal/service"
+)
+
+type variableHandlers struct {
+	svc     *service.VariableService
+	queries *service.QueryService
+}
+
+// registerVariableRoutes registers the resolve route only when queries is
+// non-nil.
+func registerVariableRoutes(api *gin.RouterGroup, svc *service.VariableService, queries *service.QueryService) {
+	h := variableHandlers{svc: svc, queries: queries}
+	g := api.Group("/variables")
+	g.GET("", h.list)
+	g.POST("", h.create)
+	g.GET("/:id", h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	if queries != nil {
+		g.GET("/resolve", h.resolve)
+	}
+}
+
+// variablePrefix marks the query parameters selecting variable values.
+const variablePrefix = "var-"
+
+// Defaults of the time range when a request gives only one end.
+const (
+	defaultFrom = "now-6h"
+	defaultTo   = "now"
+)
+
+// variableSelection parses the variable values and time range of a data
+// request:
+//
+//	page=overview                which page variables apply
+//	var-region=eu                repeatable for several values
+//	var-region=$__all            every option, or the all value
+//	from=now-6h&to=now/d         relative or absolute, see timerange.ParseTime
+//	timezone=Europe/Berlin       of the dashboard, for dates and roundings; UTC by default
+//	maxDataPoints=500            how finely $__interval splits the range
+func variableSelection(c *gin.Context) (domain.VariableSelection, error) {
+	sel := domain.VariableSelection{Page: domain.Name(c.Query("page"))}
+	var err error
+	if sel.MaxDataPoints, err = nonNegative(c, "maxDataPoints"); err != nil {
+		return sel, err
+	}
+	from, hasFrom := c.GetQuery("from")
+	to, hasTo := c.GetQuery("to")
+	if hasFrom || hasTo {
+		if !hasFrom {
+			from = defaultFrom
+		}
+		if !hasTo {
+			to = defaultTo
+		}
+		loc, err := time.LoadLocation(c.DefaultQuery("timezone", "UTC"))
+		if err != nil {
+			return sel, fmt.Errorf("%w: unknown timezone %q", service.ErrBadRequest, c.Query("timezone"))
+		}
+		points := sel.MaxDataPoints
+		if points == 0 {
+			points = service.DefaultMaxDataPoints
+		}
+		// now moves in steps of the interval, so that repeated requests
+		// share cached results and in-flight runs
+		r, err := timerange.ParseStable(from, to, time.Now(), loc, points)
+		if err != nil {
+			return sel, fmt.Errorf("%w: %v", service.ErrBadRequest, err)
+		}
+		sel.Range = &r
+	}
+	for key, values := range c.Request.URL.Query() {
+		name, ok := strings.CutPrefix(key, variablePrefix)
+		if !ok {
+			continue
+		}
+		if sel.Values == nil {
+			sel.Values = make(map[domain.Name][]domain.PropertyValue)
+		}
+		for _, v := range values {
+			sel.Values[domain.Name(name)] = append(sel.Values[domain.Name(name)], domain.PropertyValue(v))
+		}
+	}
+	return sel, nil
+}
+
+// variableID parses the :id path parameter, writing a 400 on failure.
+func variableID(c *gin.Context) (domain.VariableID, bool) {
+	id, err := domain.ParseVariableID(c.Param("id"))
+	if err != nil {
+		writeError(c, service.ErrBadRequest)
+		return domain.VariableID{}, false
+	}
+	return id, true
+}
+
+// list returns the project variables and, with ?page=, those of the page.
+func (h variableHandlers) list(c *gin.Context) {
+	vars, err := h.svc.List(c.Request.Context(), domain.Name(c.Query("page")))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, vars)
+}
+
+func (h variableHandlers) create(c *gin.Context) {
+	var opts domain.CreateVariableOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	v, err := h.svc.Create(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, v)
+}
+
+func (h variableHandlers) get(c *gin.Context) {
+	id, ok := variableID(c)
+	if !ok {
+		return
+	}
+	v, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+func (h variableHandlers) update(c *gin.Context) {
+	id, ok := variableID(c)
+	if !ok {
+		return
+	}
+	var opts domain.UpdateVariableOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		writeError(c, service.ErrBadRequest)
+		return
+	}
+	v, err := h.svc.Update(c.Request.Context(), id, opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, v)
+}
+
+func (h variableHandlers) delete(c *gin.Context) {
+	id, ok := variableID(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// resolve evaluates the variables of ?page= for the var- parameters, in
+// dependency order, with the options and values of each.
+func (h variableHandlers) resolve(c *gin.Context) {
+	sel, err := variableSelection(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx, done, ok := startRun(c, h.queries)
+	if !ok {
+		return
+	}
+	defer done()
+	vars, err := h.queries.ResolveVariables(ctx, sel)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, vars)
+}
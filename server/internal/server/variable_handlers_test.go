@@ -0,0 +1,239 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/server"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestVariableCRUD(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+
+	w := doRequest(router, http.MethodPost, "/api/variables", domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body.String())
+	}
+	var created domain.Variable
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if w := doRequest(router, http.MethodPost, "/api/variables", domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableTextBox,
+	}); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate: status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := doRequest(router, http.MethodPost, "/api/variables", map[string]any{"name": "x"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing type: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	path := "/api/variables/" + created.ID.String()
+	w = doRequest(router, http.MethodPut, path, domain.UpdateVariableOptions{
+		Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"}, Value: "dev",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(router, http.MethodGet, "/api/variables", nil)
+	var list []domain.Variable
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list) != 1 || list[0].Value != "dev" {
+		t.Fatalf("list = %+v, want env defaulting to dev", list)
+	}
+
+	if w := doRequest(router, http.MethodDelete, path, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d", w.Code)
+	}
+	if w := doRequest(router, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestComponentDataVariables(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE t (env TEXT, n INTEGER);
+		INSERT INTO t VALUES ('prod', 1), ('dev', 2);`)
+	if _, err := deps.Variables.Create(context.Background(), domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"},
+	}); err != nil {
+		t.Fatalf("Create variable: %v", err)
+	}
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t WHERE env = $env"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	for query, want := range map[string]int64{"": 1, "?var-env=dev": 2} {
+		w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var table domain.TableData
+		if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if table.NumRows() != 1 || table.Columns[0].Ints[0] != want {
+			t.Fatalf("%q: table = %+v, want n = %d", query, table, want)
+		}
+	}
+	if w := doRequest(router, http.MethodGet, "/api/components/"+comp.ID.String()+"/data?var-env=qa", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown option: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestResolveVariables(t *testing.T) {
+	deps, _ := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, `CREATE TABLE hosts (env TEXT, host TEXT);
+		INSERT INTO hosts VALUES ('prod', 'p1'), ('prod', 'p2'), ('dev', 'd1');`)
+	ctx := context.Background()
+	if _, err := deps.Variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableCustom, Options: []domain.PropertyValue{"prod", "dev"},
+	}); err != nil {
+		t.Fatalf("Create env: %v", err)
+	}
+	host, err := deps.Variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "host", Type: domain.VariableQuery, Multi: true, IncludeAll: true, AllValue: ".*",
+		Query: &domain.Query{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{
+			"sql": "SELECT host FROM hosts WHERE env = $env ORDER BY host",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create host: %v", err)
+	}
+
+	w := doRequest(router, http.MethodGet, "/api/variables/resolve?var-env=dev&var-host=$__all", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resolved []domain.ResolvedVariable
+	if err := json.Unmarshal(w.Body.Bytes(), &resolved); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resolved) != 2 || resolved[1].Name != "host" {
+		t.Fatalf("resolved = %+v, want env then host", resolved)
+	}
+	if h := resolved[1]; len(h.Options) != 1 || h.Options[0] != "d1" || !h.Value.Raw || h.Value.Values[0] != ".*" {
+		t.Fatalf("host = %+v, want option d1 and the raw all value", h)
+	}
+
+	// cycles are rejected when saved, with their path in the details
+	if _, err := deps.Variables.Update(ctx, host.ID, domain.UpdateVariableOptions{
+		Name: "host", Type: domain.VariableQuery,
+		Query: &domain.Query{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT $host"}},
+	}); err == nil {
+		t.Fatal("Update: expected a self reference to be rejected")
+	}
+	if _, err := deps.Variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableQuery, Page: "loop",
+		Query: &domain.Query{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT $host"}},
+	}); err == nil {
+		t.Fatal("Create: expected a page variable closing a cycle to be rejected")
+	}
+	w = doRequest(router, http.MethodPost, "/api/variables", domain.CreateVariableOptions{
+		Name: "self", Type: domain.VariableQuery,
+		Query: &domain.Query{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT $self"}},
+	})
+	var body server.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || body.Code != "variable_cycle" {
+		t.Fatalf("self reference: status = %d, body = %+v, want a variable_cycle 400", w.Code, body)
+	}
+}
+
+func TestComponentDataTimeRange(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, "")
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT $__timeFrom AS f, $__timeTo AS t, $__interval_ms AS ms"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	path := "/api/components/" + comp.ID.String() + "/data"
+
+	w := doRequest(router, http.MethodGet, path+"?from=2024-03-01&to=2024-03-01+06:00&timezone=Asia/Tokyo&maxDataPoints=360", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var table domain.TableData
+	if err := json.Unmarshal(w.Body.Bytes(), &table); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	row := []domain.PropertyValue{table.Columns[0].Values[0], table.Columns[1].Values[0], table.Columns[2].Values[0]}
+	want := []domain.PropertyValue{"2024-02-29T15:00:00Z", "2024-02-29T21:00:00Z", "60000"}
+	for i := range want {
+		if row[i] != want[i] {
+			t.Fatalf("row = %v, want %v", row, want)
+		}
+	}
+
+	for _, query := range []string{"?from=now-6q", "?from=now&to=now-1h", "?from=now-1h&timezone=Mars/Base"} {
+		if w := doRequest(router, http.MethodGet, path+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Relative ranges requested within one interval resolve alike, so cached
+// results serve them.
+func TestComponentDataRelativeRangeCached(t *testing.T) {
+	deps, db := newTestDeps(t)
+	router := server.NewRouter(context.Background(), deps)
+	ds := createSQLiteSource(t, deps, "")
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	comp, err := comps.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "q",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT $__timeTo AS t"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyCacheTTL: "1h"},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	// a week in 10 points moves in 1d steps
+	path := "/api/components/" + comp.ID.String() + "/data?from=now-7d&to=now&maxDataPoints=10"
+	if w := doRequest(router, http.MethodGet, path, nil); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first: status = %d, cache = %q", w.Code, w.Header().Get("X-Cache"))
+	}
+	time.Sleep(2 * time.Millisecond)
+	if w := doRequest(router, http.MethodGet, path, nil); w.Code != http.StatusOK || w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("second: status = %d, cache = %q", w.Code, w.Header().Get("X-Cache"))
+	}
+}
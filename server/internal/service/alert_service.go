@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/pkg/timerange"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+const (
+	// MinAlertInterval is the shortest interval rules are evaluated at.
+	MinAlertInterval = time.Second
+	// DefaultAlertInterval applies to rules created without an interval.
+	DefaultAlertInterval = time.Minute
+	// DefaultAlertHistory is how many events History returns by default.
+	DefaultAlertHistory = 100
+	// alertTick is how often Run looks for rules that are due.
+	alertTick = time.Second
+)
+
+// AlertService manages alert rules and evaluates them: on demand through
+// Evaluate, and on their interval once Run is started.
+type AlertService struct {
+	repo    *repository.AlertRuleRepository
+	queries *QueryService
+
+	// mu serializes the status updates of evaluations and guards inFlight,
+	// the rules being evaluated by EvaluateDue.
+	mu       sync.Mutex
+	inFlight map[domain.AlertRuleID]bool
+	watchers []AlertWatcher
+}
+
+// AlertWatcher is told about the outcome of every evaluation of a rule, with
+// the rule in its new status, and about deleted rules, e.g. to notify
+// about firing alerts. Calls are serialized and must return quickly.
+type AlertWatcher interface {
+	AlertEvaluated(ctx context.Context, r domain.AlertRule)
+	AlertRuleDeleted(id domain.AlertRuleID)
+}
+
+// Watch registers w for evaluations made after the call. It is not safe to
+// call concurrently with evaluations.
+func (s *AlertService) Watch(w AlertWatcher) {
+	s.watchers = append(s.watchers, w)
+}
+
+func NewAlertService(repo *repository.AlertRuleRepository, queries *QueryService) *AlertService {
+	return &AlertService{repo: repo, queries: queries, inFlight: make(map[domain.AlertRuleID]bool)}
+}
+
+func (s *AlertService) Create(ctx context.Context, opts domain.CreateAlertRuleOptions) (domain.AlertRule, error) {
+	if err := s.validate(ctx, &opts); err != nil {
+		return domain.AlertRule{}, err
+	}
+	now := time.Now()
+	r := alertRuleOf(opts)
+	r.ID, r.UpdatedAt = domain.NewAlertRuleID(now.UnixNano()), now
+	r.Status = domain.AlertStatus{State: domain.AlertNormal, Since: now}
+	if err := s.repo.Create(ctx, r); err != nil {
+		return domain.AlertRule{}, err
+	}
+	return r, nil
+}
+
+func (s *AlertService) Get(ctx context.Context, id domain.AlertRuleID) (domain.AlertRule, error) {
+	r, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.AlertRule{}, ErrNotFound
+		}
+		return domain.AlertRule{}, err
+	}
+	return r, nil
+}
+
+func (s *AlertService) List(ctx context.Context) ([]domain.AlertRule, error) {
+	return s.repo.List(ctx)
+}
+
+// Update replaces the configuration of a rule. Its state carries over and
+// the next evaluation applies the new configuration to it.
+func (s *AlertService) Update(ctx context.Context, id domain.AlertRuleID, opts domain.UpdateAlertRuleOptions) (domain.AlertRule, error) {
+	create := domain.CreateAlertRuleOptions(opts)
+	if err := s.validate(ctx, &create); err != nil {
+		return domain.AlertRule{}, err
+	}
+	r := alertRuleOf(create)
+	r.ID, r.UpdatedAt = id, time.Now()
+	if err := s.repo.Update(ctx, r); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.AlertRule{}, ErrNotFound
+		}
+		return domain.AlertRule{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a rule and its history.
+func (s *AlertService) Delete(ctx context.Context, id domain.AlertRuleID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	for _, w := range s.watchers {
+		w.AlertRuleDeleted(id)
+	}
+	return nil
+}
+
+// History returns the latest limit events of a rule, newest first, or
+// DefaultAlertHistory of them when limit is not positive.
+func (s *AlertService) History(ctx context.Context, id domain.AlertRuleID, limit int) ([]domain.AlertEvent, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultAlertHistory
+	}
+	return s.repo.History(ctx, id, limit)
+}
+
+// Evaluate evaluates a rule now, paused or not, and returns it with its
+// new status.
+func (s *AlertService) Evaluate(ctx context.Context, id domain.AlertRuleID) (domain.AlertRule, error) {
+	r, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+	return s.evaluate(ctx, r, time.Now())
+}
+
+// Run evaluates the rules that are due every second until ctx is done.
+func (s *AlertService) Run(ctx context.Context) {
+	ticker := time.NewTicker(alertTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.EvaluateDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				log.Printf("alert rules: %v", err)
+			}
+		}
+	}
+}
+
+// EvaluateDue evaluates, concurrently, the rules that are not paused and
+// whose interval has passed at now since they were last evaluated, and
+// waits for them. Rules still being evaluated from an earlier call are
+// skipped. Evaluations that fail are recorded in the status of their rule.
+func (s *AlertService) EvaluateDue(ctx context.Context, now time.Time) error {
+	rules, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, r := range rules {
+		if r.Paused || !alertDue(r, now) || !s.claim(r.ID) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.release(r.ID)
+			if _, err := s.evaluate(ctx, r, now); err != nil && ctx.Err() == nil {
+				log.Printf("alert rule %s: %v", r.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func alertDue(r domain.AlertRule, now time.Time) bool {
+	last := r.Status.EvaluatedAt
+	return last == nil || !now.Before(last.Add(time.Duration(r.Interval)))
+}
+
+func (s *AlertService) claim(id domain.AlertRuleID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[id] {
+		return false
+	}
+	s.inFlight[id] = true
+	return true
+}
+
+func (s *AlertService) release(id domain.AlertRuleID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, id)
+}
+
+// evaluate checks the condition of r at now and records the outcome.
+func (s *AlertService) evaluate(ctx context.Context, r domain.AlertRule, now time.Time) (domain.AlertRule, error) {
+	holds, evalErr := s.check(ctx, r, now)
+	if ctx.Err() != nil {
+		// shutting down says nothing about the rule
+		return domain.AlertRule{}, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the rule may have changed while its query ran
+	current, err := s.Get(ctx, r.ID)
+	if err != nil {
+		return domain.AlertRule{}, err
+	}
+	status, events := nextAlertStatus(current, holds, evalErr, now)
+	if err := s.repo.UpdateStatus(ctx, r.ID, status, events); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.AlertRule{}, ErrNotFound
+		}
+		return domain.AlertRule{}, err
+	}
+	current.Status = status
+	for _, w := range s.watchers {
+		w.AlertEvaluated(ctx, current)
+	}
+	return current, nil
+}
+
+// check runs the query of r over its time range at now, within the
+// interval of r, and evaluates its condition over the result.
+func (s *AlertService) check(ctx context.Context, r domain.AlertRule, now time.Time) (bool, error) {
+	program, err := expression.Compile(r.Condition)
+	if err != nil {
+		return false, err
+	}
+	var sel domain.VariableSelection
+	if r.From != "" {
+		rng, err := timerange.Parse(r.From, r.To, now, time.UTC)
+		if err != nil {
+			return false, err
+		}
+		sel.Range = &rng
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(r.Interval))
+	defer cancel()
+	var table domain.TableData
+	if r.ComponentID != nil {
+		table, _, err = s.queries.ComponentData(ctx, *r.ComponentID, domain.DataOptions{}, sel)
+	} else {
+		table, err = s.queries.QueryData(ctx, *r.Query, sel)
+	}
+	if err != nil {
+		return false, err
+	}
+	v, err := program.Run(expression.Table(table))
+	if err != nil {
+		return false, err
+	}
+	holds, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition yields %T, not a boolean", v)
+	}
+	return holds, nil
+}
+
+// nextAlertStatus moves the status of r by one evaluation at now. A rule
+// whose condition holds goes pending, and firing once it has held for the
+// For of the rule; one whose condition stops holding goes back to normal,
+// through resolved if it was firing. Failed evaluations leave the state
+// and record their error, with an event when it is a new one.
+func nextAlertStatus(r domain.AlertRule, holds bool, evalErr error, now time.Time) (domain.AlertStatus, []domain.AlertEvent) {
+	status := r.Status
+	status.EvaluatedAt = &now
+	if evalErr != nil {
+		msg := evalErr.Error()
+		var events []domain.AlertEvent
+		if msg != status.Error {
+			events = append(events, domain.AlertEvent{RuleID: r.ID, From: status.State, To: status.State, Error: msg, At: now})
+		}
+		status.Error = msg
+		return status, events
+	}
+	status.Error = ""
+
+	next := status.State
+	switch {
+	case holds && status.State == domain.AlertPending:
+		if now.Sub(status.Since) >= time.Duration(r.For) {
+			next = domain.AlertFiring
+		}
+	case holds && status.State != domain.AlertFiring:
+		next = domain.AlertPending
+		if r.For <= 0 {
+			next = domain.AlertFiring
+		}
+	case !holds && status.State == domain.AlertFiring:
+		next = domain.AlertResolved
+	case !holds:
+		next = domain.AlertNormal
+	}
+	if next == status.State {
+		return status, nil
+	}
+	event := domain.AlertEvent{RuleID: r.ID, From: status.State, To: next, At: now}
+	status.State, status.Since = next, now
+	return status, []domain.AlertEvent{event}
+}
+
+func alertRuleOf(opts domain.CreateAlertRuleOptions) domain.AlertRule {
+	return domain.AlertRule{
+		Name:        opts.Name,
+		ComponentID: opts.ComponentID,
+		Query:       opts.Query,
+		From:        opts.From,
+		To:          opts.To,
+		Condition:   opts.Condition,
+		Interval:    opts.Interval,
+		For:         opts.For,
+		Labels:      opts.Labels,
+		Annotations: opts.Annotations,
+		Paused:      opts.Paused,
+	}
+}
+
+// validate checks a rule and fills in the defaults of its interval and
+// time range. Rules evaluate either a component or a standalone query.
+func (s *AlertService) validate(ctx context.Context, opts *domain.CreateAlertRuleOptions) error {
+	if err := domain.Validate(opts); err != nil {
+		return ErrBadRequest
+	}
+	switch {
+	case (opts.ComponentID == nil) == (opts.Query == nil):
+		return fmt.Errorf("%w: an alert rule needs exactly one of componentId and query", ErrBadRequest)
+	case opts.ComponentID != nil:
+		if _, err := s.queries.components.Get(ctx, *opts.ComponentID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("%w: unknown component %s", ErrBadRequest, opts.ComponentID)
+			}
+			return err
+		}
+	default:
+		q := opts.Query
+		if q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+			return fmt.Errorf("%w: the query of an alert rule needs a data source", ErrBadRequest)
+		}
+		if err := validateQueries([]domain.Query{*q}); err != nil {
+			return err
+		}
+	}
+	if _, err := expression.Compile(opts.Condition); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadRequest, expression.At(err, "condition"))
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = domain.Duration(DefaultAlertInterval)
+	}
+	if time.Duration(opts.Interval) < MinAlertInterval {
+		return fmt.Errorf("%w: interval must be at least %s", ErrBadRequest, MinAlertInterval)
+	}
+	if opts.For < 0 {
+		return fmt.Errorf("%w: for must not be negative", ErrBadRequest)
+	}
+	if opts.From == "" && opts.To != "" {
+		return fmt.Errorf("%w: a time range needs from", ErrBadRequest)
+	}
+	if opts.From != "" {
+		if opts.To == "" {
+			opts.To = "now"
+		}
+		if _, err := timerange.Parse(opts.From, opts.To, time.Now(), time.UTC); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadRequest, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestAlertService_StateMachine(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE cpu (host TEXT, load REAL); INSERT INTO cpu VALUES ('a', 40), ('b', 50);`)
+	alerts := service.NewAlertService(repository.NewAlertRuleRepository(env.db), env.queries)
+	ctx := context.Background()
+
+	rule, err := alerts.Create(ctx, domain.CreateAlertRuleOptions{
+		Name: "high load",
+		Query: &domain.Query{
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT host, load FROM cpu"},
+		},
+		Condition: "max(load) > 90",
+		Interval:  domain.Duration(time.Minute),
+		For:       domain.Duration(2 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if rule.Status.State != domain.AlertNormal {
+		t.Fatalf("initial state = %s", rule.Status.State)
+	}
+
+	t0 := time.Now()
+	step := func(at time.Duration, want domain.AlertState) {
+		t.Helper()
+		if err := alerts.EvaluateDue(ctx, t0.Add(at)); err != nil {
+			t.Fatalf("EvaluateDue: %v", err)
+		}
+		got, err := alerts.Get(ctx, rule.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got.Status.State != want || got.Status.Error != "" {
+			t.Fatalf("at %s: status = %+v, want %s", at, got.Status, want)
+		}
+	}
+	step(0, domain.AlertNormal)
+	env.exec(t, `UPDATE cpu SET load = 95 WHERE host = 'b'`)
+	step(30*time.Second, domain.AlertNormal) // not due yet
+	step(time.Minute, domain.AlertPending)
+	step(2*time.Minute, domain.AlertPending)
+	step(3*time.Minute, domain.AlertFiring)
+	step(4*time.Minute, domain.AlertFiring)
+	env.exec(t, `UPDATE cpu SET load = 10`)
+	step(5*time.Minute, domain.AlertResolved)
+	step(6*time.Minute, domain.AlertNormal)
+
+	// failures keep the state and are recorded once
+	env.exec(t, `DROP TABLE cpu`)
+	for _, at := range []time.Duration{7 * time.Minute, 8 * time.Minute} {
+		if err := alerts.EvaluateDue(ctx, t0.Add(at)); err != nil {
+			t.Fatalf("EvaluateDue: %v", err)
+		}
+	}
+	got, _ := alerts.Get(ctx, rule.ID)
+	if got.Status.State != domain.AlertNormal || got.Status.Error == "" {
+		t.Fatalf("failed evaluation: status = %+v", got.Status)
+	}
+
+	history, err := alerts.History(ctx, rule.ID, 0)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	want := []domain.AlertState{domain.AlertNormal, domain.AlertNormal, domain.AlertResolved, domain.AlertFiring, domain.AlertPending}
+	if len(history) != len(want) || history[0].Error == "" {
+		t.Fatalf("history = %+v", history)
+	}
+	for i, w := range want {
+		if history[i].To != w {
+			t.Fatalf("history[%d] = %+v, want a change to %s", i, history[i], w)
+		}
+	}
+}
+
+func TestAlertService_Components(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE jobs (status TEXT); INSERT INTO jobs VALUES ('ok'), ('failed');`)
+	alerts := service.NewAlertService(repository.NewAlertRuleRepository(env.db), env.queries)
+	ctx := context.Background()
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "jobs",
+		Queries: []domain.Query{{
+			Name:         "jobs",
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT status FROM jobs"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	rule, err := alerts.Create(ctx, domain.CreateAlertRuleOptions{
+		Name:        "failed jobs",
+		ComponentID: &comp.ID,
+		Condition:   `any(rows, .status == "failed")`,
+		From:        "now-1h",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if rule.Interval != domain.Duration(service.DefaultAlertInterval) || rule.To != "now" {
+		t.Fatalf("defaults: interval = %s, to = %q", time.Duration(rule.Interval), rule.To)
+	}
+	rule, err = alerts.Evaluate(ctx, rule.ID)
+	if err != nil || rule.Status.State != domain.AlertFiring {
+		t.Fatalf("Evaluate = %+v, %v, want firing", rule.Status, err)
+	}
+
+	missing := domain.NewComponentID(1)
+	for name, opts := range map[string]domain.CreateAlertRuleOptions{
+		"no target":         {Name: "x", Condition: "true"},
+		"both targets":      {Name: "x", Condition: "true", ComponentID: &comp.ID, Query: &domain.Query{DataSourceID: env.ds.ID}},
+		"unknown component": {Name: "x", Condition: "true", ComponentID: &missing},
+		"no data source":    {Name: "x", Condition: "true", Query: &domain.Query{}},
+		"short interval":    {Name: "x", Condition: "true", ComponentID: &comp.ID, Interval: domain.Duration(time.Millisecond)},
+		"bad range":         {Name: "x", Condition: "true", ComponentID: &comp.ID, From: "yesterday"},
+		"to without from":   {Name: "x", Condition: "true", ComponentID: &comp.ID, To: "now"},
+	} {
+		if _, err := alerts.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+	_, err = alerts.Create(ctx, domain.CreateAlertRuleOptions{Name: "x", Condition: "len(rows) >", ComponentID: &comp.ID})
+	var e *expression.Error
+	if !errors.Is(err, service.ErrBadRequest) || !errors.As(err, &e) || e.Field != "condition" {
+		t.Fatalf("bad condition: err = %v", err)
+	}
+
+	// conditions must yield booleans
+	if _, err := alerts.Update(ctx, rule.ID, domain.UpdateAlertRuleOptions{Name: "failed jobs", ComponentID: &comp.ID, Condition: "len(rows)"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	rule, err = alerts.Evaluate(ctx, rule.ID)
+	if err != nil || rule.Status.State != domain.AlertFiring || rule.Status.Error == "" {
+		t.Fatalf("Evaluate = %+v, %v, want firing with an error", rule.Status, err)
+	}
+}
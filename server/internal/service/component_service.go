@@ -12,7 +12,21 @@ import (
 )
 
 type ComponentService struct {
-	repo *repository.ComponentRepository
+	repo     *repository.ComponentRepository
+	watchers []ComponentWatcher
+}
+
+// ComponentWatcher is told about stored component changes, e.g. to drop
+// results cached for their previous version.
+type ComponentWatcher interface {
+	ComponentUpdated(c domain.Component)
+	ComponentDeleted(id domain.ComponentID)
+}
+
+// Watch registers w for changes made after the call. It is not safe to
+// call concurrently with updates.
+func (s *ComponentService) Watch(w ComponentWatcher) {
+	s.watchers = append(s.watchers, w)
 }
 
 func NewComponentService(repo *repository.ComponentRepository) *ComponentService {
@@ -20,9 +34,15 @@ func NewComponentService(repo *repository.ComponentRepository) *ComponentService
 }
 
 func (s *ComponentService) Create(ctx context.Context, opts domain.CreateComponentOptions) (domain.Component, error) {
-	if opts.Name == "" || opts.VisualisationID == "" {
+	if err := domain.Validate(opts); err != nil {
 		return domain.Component{}, ErrBadRequest
 	}
+	if err := validateComponentProperties(opts.Properties, opts.Queries); err != nil {
+		return domain.Component{}, err
+	}
+	if err := validateQueries(opts.Queries); err != nil {
+		return domain.Component{}, err
+	}
 	var query domain.Query
 	if len(opts.Queries) > 0 {
 		query = opts.Queries[0]
@@ -32,6 +52,7 @@ func (s *ComponentService) Create(ctx context.Context, opts domain.CreateCompone
 		ID:              domain.NewComponentID(time.Now().UnixNano()),
 		VisualisationID: opts.VisualisationID,
 		Query:           query,
+		Queries:         opts.Queries,
 		Name:            opts.Name,
 		Coordination:    opts.Coordination,
 		Properties:      opts.Properties,
@@ -65,9 +86,15 @@ func (s *ComponentService) Update(
 	opts domain.UpdateComponentOptions,
 	updatedAt time.Time,
 ) error {
-	if opts.Name == "" || opts.VisualisationID == "" {
+	if err := domain.Validate(opts); err != nil {
 		return ErrBadRequest
 	}
+	if err := validateComponentProperties(opts.Properties, opts.Queries); err != nil {
+		return err
+	}
+	if err := validateQueries(opts.Queries); err != nil {
+		return err
+	}
 
 	var query domain.Query
 	if len(opts.Queries) > 0 {
@@ -78,6 +105,7 @@ func (s *ComponentService) Update(
 		ID:              id,
 		VisualisationID: opts.VisualisationID,
 		Query:           query,
+		Queries:         opts.Queries,
 		Name:            opts.Name,
 		Coordination:    opts.Coordination,
 		Properties:      opts.Properties,
@@ -89,6 +117,9 @@ func (s *ComponentService) Update(
 		}
 		return err
 	}
+	for _, w := range s.watchers {
+		w.ComponentUpdated(comp)
+	}
 	return nil
 }
 
@@ -99,5 +130,14 @@ func (s *ComponentService) Delete(ctx context.Context, id domain.ComponentID) er
 		}
 		return err
 	}
+	s.deleted(id)
 	return nil
 }
+
+// deleted tells the watchers about a component removed, also by cascading
+// data source deletes.
+func (s *ComponentService) deleted(id domain.ComponentID) {
+	for _, w := range s.watchers {
+		w.ComponentDeleted(id)
+	}
+}
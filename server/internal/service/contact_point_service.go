@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+// ContactPointService manages contact points and delivers notifications to
+// them through the notifiers of their types.
+type ContactPointService struct {
+	repo      *repository.ContactPointRepository
+	notifiers *notifier.Registry
+	backoff   notifier.Backoff
+}
+
+// NewContactPointService retries deliveries with backoff, or with
+// notifier.DefaultBackoff when it is the zero Backoff.
+func NewContactPointService(
+	repo *repository.ContactPointRepository,
+	notifiers *notifier.Registry,
+	backoff notifier.Backoff,
+) *ContactPointService {
+	if backoff == (notifier.Backoff{}) {
+		backoff = notifier.DefaultBackoff
+	}
+	return &ContactPointService{repo: repo, notifiers: notifiers, backoff: backoff}
+}
+
+// Types lists the kinds of contact points the server can deliver to.
+func (s *ContactPointService) Types() []domain.ContactPointType {
+	return s.notifiers.Descriptors()
+}
+
+func (s *ContactPointService) Create(ctx context.Context, opts domain.CreateContactPointOptions) (domain.ContactPoint, error) {
+	if err := s.validate(opts); err != nil {
+		return domain.ContactPoint{}, err
+	}
+	now := time.Now()
+	cp := domain.ContactPoint{
+		ID:        domain.NewContactPointID(now.UnixNano()),
+		Name:      opts.Name,
+		Type:      opts.Type,
+		Settings:  opts.Settings,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, cp); err != nil {
+		if errors.Is(err, repository.ErrDuplicateContactPoint) {
+			return domain.ContactPoint{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.ContactPoint{}, err
+	}
+	return cp, nil
+}
+
+func (s *ContactPointService) Get(ctx context.Context, id domain.ContactPointID) (domain.ContactPoint, error) {
+	cp, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ContactPoint{}, ErrNotFound
+		}
+		return domain.ContactPoint{}, err
+	}
+	return cp, nil
+}
+
+func (s *ContactPointService) List(ctx context.Context) ([]domain.ContactPoint, error) {
+	return s.repo.List(ctx)
+}
+
+// Update keeps the stored value of every secret setting that opts sends
+// back as the placeholder it was shown.
+func (s *ContactPointService) Update(ctx context.Context, id domain.ContactPointID, opts domain.UpdateContactPointOptions) (domain.ContactPoint, error) {
+	if descs := s.descriptors(opts.Type); hasPlaceholder(opts.Settings) && len(descs) > 0 {
+		stored, err := s.Get(ctx, id)
+		if err != nil {
+			return domain.ContactPoint{}, err
+		}
+		opts.Settings = domain.KeepSecrets(opts.Settings, stored.Settings, descs)
+	}
+	if err := s.validate(domain.CreateContactPointOptions(opts)); err != nil {
+		return domain.ContactPoint{}, err
+	}
+	cp := domain.ContactPoint{
+		ID:        id,
+		Name:      opts.Name,
+		Type:      opts.Type,
+		Settings:  opts.Settings,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.repo.Update(ctx, cp); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ContactPoint{}, ErrNotFound
+		}
+		if errors.Is(err, repository.ErrDuplicateContactPoint) {
+			return domain.ContactPoint{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.ContactPoint{}, err
+	}
+	return cp, nil
+}
+
+func (s *ContactPointService) Delete(ctx context.Context, id domain.ContactPointID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Redact hides the values of the secret settings of cp, for responses.
+func (s *ContactPointService) Redact(cp domain.ContactPoint) domain.ContactPoint {
+	cp.Settings = domain.RedactSecrets(cp.Settings, s.descriptors(cp.Type))
+	return cp
+}
+
+func (s *ContactPointService) descriptors(id domain.ContactPointTypeID) []domain.PropertyDescriptor {
+	n, err := s.notifiers.Get(id)
+	if err != nil {
+		return nil
+	}
+	return n.Descriptor().PropertyDescriptors
+}
+
+// Notify delivers n to the contact point, retrying failed attempts with
+// backoff until they succeed, fail permanently or ctx is done.
+func (s *ContactPointService) Notify(ctx context.Context, id domain.ContactPointID, n domain.Notification) error {
+	cp, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, cp, n, s.backoff)
+}
+
+// Test sends a test notification to the contact point once, so that
+// failures are reported right away.
+func (s *ContactPointService) Test(ctx context.Context, id domain.ContactPointID) error {
+	cp, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, cp, testNotification(cp, time.Now()), notifier.Backoff{Attempts: 1})
+}
+
+// TestSettings sends a test notification to a contact point that is not
+// saved yet.
+func (s *ContactPointService) TestSettings(ctx context.Context, opts domain.CreateContactPointOptions) error {
+	if err := s.validate(opts); err != nil {
+		return err
+	}
+	cp := domain.ContactPoint{Name: opts.Name, Type: opts.Type, Settings: opts.Settings}
+	return s.deliver(ctx, cp, testNotification(cp, time.Now()), notifier.Backoff{Attempts: 1})
+}
+
+func (s *ContactPointService) deliver(ctx context.Context, cp domain.ContactPoint, n domain.Notification, b notifier.Backoff) error {
+	nt, err := s.notifiers.Get(cp.Type)
+	if err != nil {
+		return fmt.Errorf("%w: contact point %s: %v", ErrDelivery, cp.Name, err)
+	}
+	err = b.Do(ctx, func(ctx context.Context) error {
+		return nt.Notify(ctx, cp, n)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: contact point %s: %v", ErrDelivery, cp.Name, err)
+	}
+	return nil
+}
+
+// testNotification is a firing notification about a made-up alert, so
+// that payload templates ranging over alerts can be tried too.
+func testNotification(cp domain.ContactPoint, now time.Time) domain.Notification {
+	return domain.Notification{
+		Title:   "Test notification",
+		Message: fmt.Sprintf("This is a test notification sent to contact point %s.", cp.Name),
+		Status:  domain.AlertFiring,
+		Alerts: []domain.NotifiedAlert{{
+			Name:        "Test alert",
+			State:       domain.AlertFiring,
+			Labels:      map[string]string{"alertname": "Test alert"},
+			Annotations: map[string]string{"summary": "Notifications reach this contact point."},
+			Since:       now,
+		}},
+		Test: true,
+	}
+}
+
+func (s *ContactPointService) validate(opts domain.CreateContactPointOptions) error {
+	if err := domain.Validate(opts); err != nil {
+		return ErrBadRequest
+	}
+	if err := s.notifiers.Validate(opts.Type, opts.Settings); err != nil {
+		if errors.Is(err, notifier.ErrUnknownType) {
+			return fmt.Errorf("%w: %v %s", ErrBadRequest, err, opts.Type)
+		}
+		return fmt.Errorf("%w: settings: %v", ErrBadRequest, err)
+	}
+	return nil
+}
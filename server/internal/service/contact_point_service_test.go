@@ -0,0 +1,153 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/email"
+	"github.com/smilu97/refana/internal/notifier/email/emailtest"
+	"github.com/smilu97/refana/internal/notifier/webhook"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func newContactPointService(t *testing.T) *service.ContactPointService {
+	t.Helper()
+	return service.NewContactPointService(
+		repository.NewContactPointRepository(openServiceDB(t)),
+		notifier.NewRegistry(webhook.New(), webhook.NewSlack(), webhook.NewTeams(), email.New()),
+		notifier.Backoff{Attempts: 3, Initial: time.Millisecond},
+	)
+}
+
+func TestContactPointServiceValidation(t *testing.T) {
+	svc := newContactPointService(t)
+	ctx := context.Background()
+
+	if types := svc.Types(); len(types) != 4 || types[0].ID != "webhook" {
+		t.Fatalf("Types = %+v", types)
+	}
+	for name, opts := range map[string]domain.CreateContactPointOptions{
+		"no name":          {Type: "webhook", Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "http://example.com"}},
+		"unknown type":     {Name: "svc-pager", Type: "pager"},
+		"missing setting":  {Name: "svc-hook", Type: "webhook"},
+		"invalid settings": {Name: "svc-hook", Type: "webhook", Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "example.com"}},
+		"invalid email":    {Name: "svc-mail", Type: "email", Settings: map[domain.PropertyKey]domain.PropertyValue{"host": "smtp", "from": "x", "to": "y@example.com"}},
+	} {
+		if _, err := svc.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+
+	opts := domain.CreateContactPointOptions{
+		Name:     "svc-slack",
+		Type:     "slack",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "https://hooks.example.com/x"},
+	}
+	cp, err := svc.Create(ctx, opts)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Create(ctx, opts); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("Create duplicate = %v, want ErrConflict", err)
+	}
+	if err := svc.Delete(ctx, cp.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.Get(ctx, cp.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestContactPointServiceDelivery(t *testing.T) {
+	svc := newContactPointService(t)
+	ctx := context.Background()
+
+	// The receiver fails every other request, so Notify needs a retry and
+	// Test, which makes a single attempt, alternately fails.
+	var calls atomic.Int32
+	bodies := make(chan []byte, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	cp, err := svc.Create(ctx, domain.CreateContactPointOptions{
+		Name:     "svc-ops",
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": domain.PropertyValue(srv.URL)},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	n := domain.Notification{Title: "High CPU", Status: domain.AlertFiring}
+	if err := svc.Notify(ctx, cp.ID, n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var got domain.Notification
+	if err := json.Unmarshal(<-bodies, &got); err != nil || got.Title != "High CPU" {
+		t.Fatalf("delivered %+v, %v", got, err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+
+	if err := svc.Test(ctx, cp.ID); !errors.Is(err, service.ErrDelivery) {
+		t.Fatalf("Test = %v, want ErrDelivery after a single failed attempt", err)
+	}
+	if err := svc.Test(ctx, cp.ID); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	if err := json.Unmarshal(<-bodies, &got); err != nil || !got.Test || len(got.Alerts) != 1 {
+		t.Fatalf("test notification = %+v, %v", got, err)
+	}
+	if err := svc.Test(ctx, domain.NewContactPointID(1)); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("Test of a missing contact point = %v, want ErrNotFound", err)
+	}
+}
+
+func TestContactPointServiceTestSettings(t *testing.T) {
+	svc := newContactPointService(t)
+	smtp, err := emailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer smtp.Close()
+
+	opts := domain.CreateContactPointOptions{
+		Name: "svc-mail",
+		Type: "email",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{
+			"host": domain.PropertyValue(smtp.Host),
+			"port": domain.PropertyValue(smtp.Port),
+			"from": "alerts@example.com",
+			"to":   "ops@example.com",
+		},
+	}
+	if err := svc.TestSettings(context.Background(), opts); err != nil {
+		t.Fatalf("TestSettings: %v", err)
+	}
+	if msgs := smtp.Messages(); len(msgs) != 1 || msgs[0].To[0] != "ops@example.com" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	list, _ := svc.List(context.Background())
+	for _, cp := range list {
+		if cp.Name == opts.Name {
+			t.Fatalf("TestSettings saved %+v", cp)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,7 +13,43 @@ import (
 )
 
 type DataSourceService struct {
-	repo *repository.DataSourceRepository
+	repo      *repository.DataSourceRepository
+	watchers  []DataSourceWatcher
+	describer PropertyDescriber
+	// components hears of the components cascading deletes remove.
+	components *ComponentService
+}
+
+// PropertyDescriber tells the property descriptors of data source classes,
+// so that secret properties can be told apart.
+type PropertyDescriber interface {
+	PropertyDescriptors(id domain.DataSourceClassID) []domain.PropertyDescriptor
+}
+
+// Describe has the service take secret properties from d. Until then no
+// property is secret. It is not safe to call concurrently with updates.
+func (s *DataSourceService) Describe(d PropertyDescriber) {
+	s.describer = d
+}
+
+// DataSourceWatcher is told about stored data source changes, e.g. to drop
+// connections that no longer match their data source.
+type DataSourceWatcher interface {
+	DataSourceUpdated(ds domain.DataSource)
+	DataSourceDeleted(id domain.DataSourceID)
+}
+
+// Watch registers w for changes made after the call. It is not safe to
+// call concurrently with updates.
+func (s *DataSourceService) Watch(w DataSourceWatcher) {
+	s.watchers = append(s.watchers, w)
+}
+
+// CascadeTo has the watchers of components told about the components that
+// cascading deletes remove. It is not safe to call concurrently with
+// deletes.
+func (s *DataSourceService) CascadeTo(components *ComponentService) {
+	s.components = components
 }
 
 func NewDataSourceService(repo *repository.DataSourceRepository) *DataSourceService {
@@ -20,9 +57,12 @@ func NewDataSourceService(repo *repository.DataSourceRepository) *DataSourceServ
 }
 
 func (s *DataSourceService) Create(ctx context.Context, opts domain.CreateDataSourceOptions) (domain.DataSource, error) {
-	if opts.Name == "" || opts.ClassID == "" {
+	if err := domain.Validate(opts); err != nil {
 		return domain.DataSource{}, ErrBadRequest
 	}
+	if err := validateDataSourceProperties(opts.Properties); err != nil {
+		return domain.DataSource{}, err
+	}
 	ds := domain.DataSource{
 		ID:         domain.NewDataSourceID(time.Now().UnixNano()),
 		ClassID:    opts.ClassID,
@@ -32,6 +72,9 @@ func (s *DataSourceService) Create(ctx context.Context, opts domain.CreateDataSo
 		UpdatedAt:  time.Now(),
 	}
 	if err := s.repo.Create(ctx, ds); err != nil {
+		if errors.Is(err, repository.ErrDuplicateAlias) {
+			return domain.DataSource{}, ErrConflict
+		}
 		return domain.DataSource{}, err
 	}
 	return ds, nil
@@ -48,6 +91,29 @@ func (s *DataSourceService) Get(ctx context.Context, id domain.DataSourceID) (do
 	return ds, nil
 }
 
+func (s *DataSourceService) GetByAlias(ctx context.Context, alias domain.Alias) (domain.DataSource, error) {
+	if alias == "" {
+		return domain.DataSource{}, ErrNotFound
+	}
+	ds, err := s.repo.GetByAlias(ctx, alias)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.DataSource{}, ErrNotFound
+		}
+		return domain.DataSource{}, err
+	}
+	return ds, nil
+}
+
+// Resolve finds the data source a query points at, preferring its alias
+// over its generated ID.
+func (s *DataSourceService) Resolve(ctx context.Context, q domain.Query) (domain.DataSource, error) {
+	if q.DataSourceAlias != "" {
+		return s.GetByAlias(ctx, q.DataSourceAlias)
+	}
+	return s.Get(ctx, q.DataSourceID)
+}
+
 func (s *DataSourceService) List(ctx context.Context) ([]domain.DataSource, error) {
 	return s.repo.List(ctx)
 }
@@ -58,9 +124,19 @@ func (s *DataSourceService) Update(
 	opts domain.UpdateDataSourceOptions,
 	updatedAt time.Time,
 ) error {
-	if opts.Name == "" || opts.ClassID == "" {
+	if err := domain.Validate(opts); err != nil {
 		return ErrBadRequest
 	}
+	if err := validateDataSourceProperties(opts.Properties); err != nil {
+		return err
+	}
+	if descs := s.descriptors(opts.ClassID); hasPlaceholder(opts.Properties) && len(descs) > 0 {
+		stored, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		opts.Properties = domain.KeepSecrets(opts.Properties, stored.Properties, descs)
+	}
 	ds := domain.DataSource{
 		ID:         id,
 		ClassID:    opts.ClassID,
@@ -73,17 +149,77 @@ func (s *DataSourceService) Update(
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrNotFound
 		}
+		if errors.Is(err, repository.ErrDuplicateAlias) {
+			return ErrConflict
+		}
 		return err
 	}
+	for _, w := range s.watchers {
+		w.DataSourceUpdated(ds)
+	}
 	return nil
 }
 
+// Redact hides the values of the secret properties of ds, for responses.
+func (s *DataSourceService) Redact(ds domain.DataSource) domain.DataSource {
+	ds.Properties = domain.RedactSecrets(ds.Properties, s.descriptors(ds.ClassID))
+	return ds
+}
+
+func (s *DataSourceService) descriptors(id domain.DataSourceClassID) []domain.PropertyDescriptor {
+	if s.describer == nil {
+		return nil
+	}
+	return s.describer.PropertyDescriptors(id)
+}
+
+func hasPlaceholder(props map[domain.PropertyKey]domain.PropertyValue) bool {
+	for _, v := range props {
+		if v == domain.SecretPlaceholder {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *DataSourceService) Delete(ctx context.Context, id domain.DataSourceID) error {
-	if err := s.repo.Delete(ctx, id); err != nil {
+	return s.DeleteWithPolicy(ctx, id, domain.DataSourceDeleteRestrict)
+}
+
+func (s *DataSourceService) DeleteWithPolicy(
+	ctx context.Context,
+	id domain.DataSourceID,
+	policy domain.DataSourceDeletePolicy,
+) error {
+	deleted, err := s.repo.DeleteWithPolicy(ctx, id, policy)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrNotFound
 		}
+		if errors.Is(err, repository.ErrDataSourceInUse) {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
 		return err
 	}
+	for _, w := range s.watchers {
+		w.DataSourceDeleted(id)
+	}
+	if s.components != nil {
+		for _, cid := range deleted {
+			s.components.deleted(cid)
+		}
+	}
 	return nil
 }
+
+// Usages lists the components that depend on the data source.
+func (s *DataSourceService) Usages(ctx context.Context, id domain.DataSourceID) ([]domain.Component, error) {
+	comps, err := s.repo.Usages(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return comps, nil
+}
@@ -2,6 +2,7 @@ package service_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +20,7 @@ func TestDataSourceService_CreateValidate(t *testing.T) {
 	ctx := context.Background()
 
 	_, err := svc.Create(ctx, domain.CreateDataSourceOptions{
-		Name:   "",
+		Name:    "",
 		ClassID: "",
 	})
 	if err != service.ErrBadRequest {
@@ -88,6 +89,124 @@ func TestDataSourceService_CRUD_LastWriteWins(t *testing.T) {
 	}
 }
 
+func TestDataSourceService_ValidateLengths(t *testing.T) {
+	svc := newDataSourceService(t)
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, domain.CreateDataSourceOptions{
+		Name:    domain.Name(strings.Repeat("n", 257)),
+		ClassID: "postgres",
+	})
+	if err != service.ErrBadRequest {
+		t.Fatalf("long name: expected ErrBadRequest, got %v", err)
+	}
+	_, err = svc.Create(ctx, domain.CreateDataSourceOptions{
+		Name:    "ds",
+		ClassID: "postgres",
+		Alias:   domain.Alias(strings.Repeat("a", 65)),
+	})
+	if err != service.ErrBadRequest {
+		t.Fatalf("long alias: expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestDataSourceService_AliasConflictAndResolve(t *testing.T) {
+	svc := newDataSourceService(t)
+	ctx := context.Background()
+
+	ds, err := svc.Create(ctx, domain.CreateDataSourceOptions{Name: "ds", ClassID: "postgres", Alias: "warehouse"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Create(ctx, domain.CreateDataSourceOptions{Name: "dup", ClassID: "postgres", Alias: "warehouse"}); err != service.ErrConflict {
+		t.Fatalf("duplicate alias: expected ErrConflict, got %v", err)
+	}
+
+	got, err := svc.Resolve(ctx, domain.Query{DataSourceID: domain.NewDataSourceID(1), DataSourceAlias: "warehouse"})
+	if err != nil {
+		t.Fatalf("Resolve by alias: %v", err)
+	}
+	if got.ID.Int64() != ds.ID.Int64() {
+		t.Fatalf("Resolve id = %s, want %s", got.ID, ds.ID)
+	}
+	if _, err := svc.GetByAlias(ctx, "nope"); err != service.ErrNotFound {
+		t.Fatalf("GetByAlias missing: expected ErrNotFound, got %v", err)
+	}
+}
+
+// secretDescriber marks "password" secret for every class.
+type secretDescriber struct{}
+
+func (secretDescriber) PropertyDescriptors(domain.DataSourceClassID) []domain.PropertyDescriptor {
+	return []domain.PropertyDescriptor{{Key: "host"}, {Key: "password", IsSecret: true}}
+}
+
+func TestDataSourceService_RedactsAndKeepsSecrets(t *testing.T) {
+	svc := newDataSourceService(t)
+	svc.Describe(secretDescriber{})
+	ctx := context.Background()
+
+	ds, err := svc.Create(ctx, domain.CreateDataSourceOptions{
+		Name: "secret", ClassID: "postgres",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"host": "db", "password": "hunter2"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	redacted := svc.Redact(ds)
+	if redacted.Properties["password"] != domain.SecretPlaceholder || redacted.Properties["host"] != "db" {
+		t.Fatalf("Redact = %v", redacted.Properties)
+	}
+
+	// an edit sending back what it was shown keeps the password
+	opts := domain.UpdateDataSourceOptions{Name: "renamed", ClassID: "postgres", Properties: redacted.Properties}
+	if err := svc.Update(ctx, ds.ID, opts, ds.UpdatedAt.Add(time.Minute)); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err := svc.Get(ctx, ds.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Name != "renamed" || got.Properties["password"] != "hunter2" {
+		t.Fatalf("after update = %+v", got)
+	}
+}
+
+// deletedComponents records the components its ComponentService deletes.
+type deletedComponents struct{ ids []domain.ComponentID }
+
+func (d *deletedComponents) ComponentUpdated(domain.Component)      {}
+func (d *deletedComponents) ComponentDeleted(id domain.ComponentID) { d.ids = append(d.ids, id) }
+
+func TestDataSourceService_CascadeTellsComponentWatchers(t *testing.T) {
+	db := openDSServiceDB(t)
+	ctx := context.Background()
+	svc := service.NewDataSourceService(repository.NewDataSourceRepository(db))
+	comps := service.NewComponentService(repository.NewComponentRepository(db))
+	watcher := &deletedComponents{}
+	comps.Watch(watcher)
+	svc.CascadeTo(comps)
+
+	ds, err := svc.Create(ctx, domain.CreateDataSourceOptions{Name: "cascaded", ClassID: "postgres"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	comp, err := comps.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "dependent",
+		Queries:         []domain.Query{{Name: "q", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	if err := svc.DeleteWithPolicy(ctx, ds.ID, domain.DataSourceDeleteCascade); err != nil {
+		t.Fatalf("cascade delete: %v", err)
+	}
+	if len(watcher.ids) != 1 || watcher.ids[0].Int64() != comp.ID.Int64() {
+		t.Fatalf("watchers heard of %v, want %s", watcher.ids, comp.ID)
+	}
+}
+
 // helpers
 func newDataSourceService(t *testing.T) *service.DataSourceService {
 	t.Helper()
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+)
+
+// dynamicProperties compiles the expressions of the dynamicProperties
+// property, reporting the field of the first that does not compile.
+func dynamicProperties(props map[domain.PropertyKey]domain.PropertyValue) (map[domain.PropertyKey]expression.Program, error) {
+	raw, ok := props[ComponentPropertyDynamic]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	var srcs map[domain.PropertyKey]string
+	if err := json.Unmarshal([]byte(raw), &srcs); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadRequest, ComponentPropertyDynamic, err)
+	}
+	programs := make(map[domain.PropertyKey]expression.Program, len(srcs))
+	for _, key := range slices.Sorted(maps.Keys(srcs)) {
+		p, err := expression.Compile(srcs[key])
+		if err != nil {
+			err = expression.At(err, fmt.Sprintf("properties.%s.%s", ComponentPropertyDynamic, key))
+			return nil, fmt.Errorf("%w: %w", ErrBadRequest, err)
+		}
+		programs[key] = p
+	}
+	return programs, nil
+}
+
+// ComponentProperties returns the properties of a component with those
+// of its dynamicProperties evaluated for sel. Expressions see the
+// variables they name, as strings or, for multi-value variables, lists of
+// strings, and __timeFrom and __timeTo as times when sel has a range.
+// Values other than strings are written as JSON.
+func (s *QueryService) ComponentProperties(
+	ctx context.Context,
+	id domain.ComponentID,
+	sel domain.VariableSelection,
+) (map[domain.PropertyKey]domain.PropertyValue, error) {
+	comp, err := s.components.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	programs, err := dynamicProperties(comp.Properties)
+	if err != nil {
+		return nil, err
+	}
+	out := maps.Clone(comp.Properties)
+	if len(programs) == 0 {
+		return out, nil
+	}
+	env, err := s.expressionEnv(ctx, programs, sel)
+	if err != nil {
+		return nil, err
+	}
+	for key, p := range programs {
+		v, err := p.Run(env)
+		if err != nil {
+			err = expression.At(err, fmt.Sprintf("properties.%s.%s", ComponentPropertyDynamic, key))
+			return nil, fmt.Errorf("%w: %w", ErrBadRequest, err)
+		}
+		if str, ok := v.(string); ok {
+			out[key] = domain.PropertyValue(str)
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrBadRequest, ComponentPropertyDynamic, key, err)
+		}
+		out[key] = domain.PropertyValue(raw)
+	}
+	return out, nil
+}
+
+// expressionEnv resolves the variables the programs name.
+func (s *QueryService) expressionEnv(
+	ctx context.Context,
+	programs map[domain.PropertyKey]expression.Program,
+	sel domain.VariableSelection,
+) (map[string]any, error) {
+	env := make(map[string]any)
+	if sel.Range != nil {
+		env[string(domain.VariableTimeFrom)] = sel.Range.From
+		env[string(domain.VariableTimeTo)] = sel.Range.To
+	}
+	var names []domain.Name
+	for _, p := range programs {
+		for _, name := range p.Names() {
+			if n := domain.Name(name); !domain.BuiltinVariable(n) && !slices.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	if s.variables == nil || len(names) == 0 {
+		return env, nil
+	}
+	resolved, err := s.resolveVariables(ctx, names, sel)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resolved {
+		values := make([]any, len(r.Value.Values))
+		for i, v := range r.Value.Values {
+			values[i] = string(v)
+		}
+		switch {
+		case r.Multi:
+			env[string(r.Name)] = values
+		case len(values) > 0:
+			env[string(r.Name)] = values[0]
+		default:
+			env[string(r.Name)] = ""
+		}
+	}
+	return env, nil
+}
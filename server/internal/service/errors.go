@@ -5,4 +5,10 @@ import "errors"
 var (
 	ErrBadRequest = errors.New("bad request")
 	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrTimeout    = errors.New("query timed out")
+	// ErrCanceled is returned for query runs cancelled through CancelRun.
+	ErrCanceled = errors.New("query canceled")
+	// ErrDelivery is returned when a contact point could not be notified.
+	ErrDelivery = errors.New("notification delivery failed")
 )
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// defaultHealthCheckTimeout bounds a connection test whose data source has
+// no timeout property.
+const defaultHealthCheckTimeout = 10 * time.Second
+
+// CheckDataSource tests the connection to an unsaved data source, so that
+// settings can be verified before they are stored.
+func (s *QueryService) CheckDataSource(ctx context.Context, opts domain.CreateDataSourceOptions) (domain.HealthCheckReport, error) {
+	if err := domain.Validate(opts); err != nil {
+		return domain.HealthCheckReport{}, ErrBadRequest
+	}
+	return s.healthCheck(ctx, domain.DataSource{ClassID: opts.ClassID, Properties: opts.Properties})
+}
+
+// CheckSavedDataSource tests the connection to a stored data source. The
+// check opens connections of its own and leaves the data source's pool
+// alone.
+func (s *QueryService) CheckSavedDataSource(ctx context.Context, id domain.DataSourceID) (domain.HealthCheckReport, error) {
+	ds, err := s.dataSources.Get(ctx, id)
+	if err != nil {
+		return domain.HealthCheckReport{}, err
+	}
+	return s.healthCheck(ctx, ds)
+}
+
+func (s *QueryService) healthCheck(ctx context.Context, ds domain.DataSource) (domain.HealthCheckReport, error) {
+	limits, err := queryLimitsOf(ds.Properties)
+	if err != nil {
+		return domain.HealthCheckReport{}, err
+	}
+	class, err := s.classes.Get(ds.ClassID)
+	if err != nil {
+		if errors.Is(err, datasource.ErrUnknownClass) {
+			return domain.HealthCheckReport{}, fmt.Errorf("%w: %v: %s", ErrBadRequest, err, ds.ClassID)
+		}
+		return domain.HealthCheckReport{}, err
+	}
+	checker, ok := class.(datasource.HealthChecker)
+	if !ok {
+		return domain.HealthCheckReport{}, fmt.Errorf("%w: class %s cannot test connections", ErrBadRequest, ds.ClassID)
+	}
+
+	timeout := limits.timeout
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return checker.HealthCheck(ctx, ds), nil
+}
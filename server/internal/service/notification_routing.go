@@ -0,0 +1,215 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// Defaults of the timing of notification groups, as in Alertmanager.
+const (
+	DefaultGroupWait      = 30 * time.Second
+	DefaultGroupInterval  = 5 * time.Minute
+	DefaultRepeatInterval = 4 * time.Hour
+)
+
+// groupByAll in GroupBy groups alerts by every label.
+const groupByAll domain.Name = "..."
+
+// alertNameLabel is the label carrying the name of the rule of an alert.
+const alertNameLabel = "alertname"
+
+// labelMatcher is a LabelMatcher with its regular expression compiled.
+type labelMatcher struct {
+	domain.LabelMatcher
+	re *regexp.Regexp
+}
+
+func compileMatchers(ms []domain.LabelMatcher) ([]labelMatcher, error) {
+	out := make([]labelMatcher, len(ms))
+	for i, m := range ms {
+		out[i].LabelMatcher = m
+		switch m.Op {
+		case domain.MatchEqual, domain.MatchNotEqual:
+		case domain.MatchRegexp, domain.MatchNotRegexp:
+			re, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("matcher %s: %v", m.Label, err)
+			}
+			out[i].re = re
+		default:
+			return nil, fmt.Errorf("matcher %s: unknown operator %q", m.Label, m.Op)
+		}
+	}
+	return out, nil
+}
+
+func (m labelMatcher) matches(labels map[string]string) bool {
+	v := labels[string(m.Label)]
+	switch m.Op {
+	case domain.MatchEqual:
+		return v == m.Value
+	case domain.MatchNotEqual:
+		return v != m.Value
+	case domain.MatchRegexp:
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+func matchAll(ms []labelMatcher, labels map[string]string) bool {
+	for _, m := range ms {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// route is a NotificationRoute with the fields it inherits filled in.
+type route struct {
+	// path locates the route in the tree, e.g. "0.2.1" for the second
+	// child of the third child of the root.
+	path           string
+	matchers       []labelMatcher
+	contactPoint   *domain.ContactPointID
+	groupBy        []domain.Name
+	groupWait      time.Duration
+	groupInterval  time.Duration
+	repeatInterval time.Duration
+	cont           bool
+	children       []*route
+}
+
+// compileRoutes compiles the policy tree rooted at root, with the default
+// timing where the root leaves it unset.
+func compileRoutes(root domain.NotificationRoute) (*route, error) {
+	return compileRoute(root, &route{
+		groupWait:      DefaultGroupWait,
+		groupInterval:  DefaultGroupInterval,
+		repeatInterval: DefaultRepeatInterval,
+	}, "0")
+}
+
+func compileRoute(r domain.NotificationRoute, parent *route, path string) (*route, error) {
+	matchers, err := compileMatchers(r.Matchers)
+	if err != nil {
+		return nil, fmt.Errorf("route %s: %v", path, err)
+	}
+	if r.GroupWait < 0 || r.GroupInterval < 0 || r.RepeatInterval < 0 {
+		return nil, fmt.Errorf("route %s: intervals must not be negative", path)
+	}
+	out := &route{
+		path:           path,
+		matchers:       matchers,
+		contactPoint:   parent.contactPoint,
+		groupBy:        parent.groupBy,
+		groupWait:      parent.groupWait,
+		groupInterval:  parent.groupInterval,
+		repeatInterval: parent.repeatInterval,
+		cont:           r.Continue,
+	}
+	if r.ContactPointID != nil {
+		out.contactPoint = r.ContactPointID
+	}
+	if r.GroupBy != nil {
+		out.groupBy = r.GroupBy
+	}
+	if r.GroupWait > 0 {
+		out.groupWait = time.Duration(r.GroupWait)
+	}
+	if r.GroupInterval > 0 {
+		out.groupInterval = time.Duration(r.GroupInterval)
+	}
+	if r.RepeatInterval > 0 {
+		out.repeatInterval = time.Duration(r.RepeatInterval)
+	}
+	for i, child := range r.Routes {
+		c, err := compileRoute(child, out, path+"."+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		out.children = append(out.children, c)
+	}
+	return out, nil
+}
+
+// match returns the routes that deliver an alert with labels, assuming r
+// itself matches: the deepest matching descendants, or r.
+func (r *route) match(labels map[string]string) []*route {
+	var out []*route
+	for _, c := range r.children {
+		if !matchAll(c.matchers, labels) {
+			continue
+		}
+		out = append(out, c.match(labels)...)
+		if !c.cont {
+			break
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, r)
+	}
+	return out
+}
+
+// groupLabels returns the labels of an alert with labels that r groups it
+// by.
+func (r *route) groupLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, name := range r.groupBy {
+		if name == groupByAll {
+			for k, v := range labels {
+				out[k] = v
+			}
+			break
+		}
+		if v, ok := labels[string(name)]; ok {
+			out[string(name)] = v
+		}
+	}
+	return out
+}
+
+// groupKey identifies the group of the alerts that r delivers with the
+// given group labels.
+func groupKey(r *route, groupLabels map[string]string) string {
+	var b strings.Builder
+	b.WriteString(r.path)
+	b.WriteByte('{')
+	for i, k := range sortedKeys(groupLabels) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(k))
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(groupLabels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// alertLabels are the labels of the alert of r, including alertname.
+func alertLabels(r domain.AlertRule) map[string]string {
+	labels := make(map[string]string, len(r.Labels)+1)
+	for k, v := range r.Labels {
+		labels[string(k)] = string(v)
+	}
+	labels[alertNameLabel] = string(r.Name)
+	return labels
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+// notificationTick is how often Run looks for groups that are due.
+const notificationTick = time.Second
+
+// NotificationService routes the alerts of firing rules through the
+// notification policy to contact points. Alerts are grouped per route as
+// the policy says and each group is notified on its own schedule, leaving
+// out the alerts that silences match; see domain.NotificationRoute.
+//
+// Groups live in memory. After a restart, the alerts that still fire are
+// grouped and notified anew as their rules are evaluated.
+type NotificationService struct {
+	repo          *repository.NotificationPolicyRepository
+	silences      *SilenceService
+	contactPoints *ContactPointService
+
+	// mu guards root, the compiled policy once loaded, and groups, keyed by
+	// groupKey.
+	mu     sync.Mutex
+	root   *route
+	groups map[string]*alertGroup
+}
+
+// NewNotificationService starts watching the evaluations of alerts.
+func NewNotificationService(
+	repo *repository.NotificationPolicyRepository,
+	alerts *AlertService,
+	silences *SilenceService,
+	contactPoints *ContactPointService,
+) *NotificationService {
+	s := &NotificationService{
+		repo:          repo,
+		silences:      silences,
+		contactPoints: contactPoints,
+		groups:        make(map[string]*alertGroup),
+	}
+	alerts.Watch(s)
+	return s
+}
+
+// alertGroup is the alerts a route delivers together.
+type alertGroup struct {
+	route  *route
+	labels map[string]string
+	alerts map[domain.AlertRuleID]*groupedAlert
+	// created is when the first alert joined, flushed when the group was
+	// last notified, and changed whether alerts fired or resolved since.
+	// sending is set while a notification of the group is delivered.
+	created time.Time
+	flushed time.Time
+	changed bool
+	sending bool
+}
+
+type groupedAlert struct {
+	alert domain.NotifiedAlert
+	// notified is whether the group was notified of the alert firing, so
+	// that it is notified of its resolution too.
+	notified bool
+}
+
+// Policy returns the notification policy. Until one is saved, it is an
+// empty root, which delivers nothing.
+func (s *NotificationService) Policy(ctx context.Context) (domain.NotificationPolicy, error) {
+	p, err := s.repo.Get(ctx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.NotificationPolicy{}, nil
+	}
+	return p, err
+}
+
+// UpdatePolicy replaces the notification policy. The root matches every
+// alert and needs a contact point, which its descendants inherit. Alerts
+// already grouped are grouped anew under the new policy.
+func (s *NotificationService) UpdatePolicy(ctx context.Context, root domain.NotificationRoute) (domain.NotificationPolicy, error) {
+	if err := domain.Validate(root); err != nil {
+		return domain.NotificationPolicy{}, ErrBadRequest
+	}
+	if len(root.Matchers) > 0 {
+		return domain.NotificationPolicy{}, fmt.Errorf("%w: the root route matches every alert and takes no matchers", ErrBadRequest)
+	}
+	if root.ContactPointID == nil {
+		return domain.NotificationPolicy{}, fmt.Errorf("%w: the root route needs a contact point", ErrBadRequest)
+	}
+	compiled, err := compileRoutes(root)
+	if err != nil {
+		return domain.NotificationPolicy{}, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	if err := s.checkContactPoints(ctx, root); err != nil {
+		return domain.NotificationPolicy{}, err
+	}
+	p := domain.NotificationPolicy{Root: root, UpdatedAt: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.repo.Save(ctx, p); err != nil {
+		return domain.NotificationPolicy{}, err
+	}
+	s.root = compiled
+	s.regroup(p.UpdatedAt)
+	return p, nil
+}
+
+func (s *NotificationService) checkContactPoints(ctx context.Context, r domain.NotificationRoute) error {
+	if r.ContactPointID != nil {
+		if _, err := s.contactPoints.Get(ctx, *r.ContactPointID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("%w: unknown contact point %s", ErrBadRequest, r.ContactPointID)
+			}
+			return err
+		}
+	}
+	for _, c := range r.Routes {
+		if err := s.checkContactPoints(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// regroup moves the alerts of every group into the groups of the current
+// policy, which start waiting anew. Alerts keep whether they were
+// notified.
+func (s *NotificationService) regroup(now time.Time) {
+	old := s.groups
+	s.groups = make(map[string]*alertGroup)
+	for _, g := range old {
+		for id, a := range g.alerts {
+			if a.alert.State != domain.AlertFiring {
+				continue
+			}
+			for _, r := range s.root.match(a.alert.Labels) {
+				ng := s.group(r, a.alert.Labels, now)
+				if _, ok := ng.alerts[id]; !ok {
+					ng.alerts[id] = &groupedAlert{alert: a.alert, notified: a.notified}
+				}
+			}
+		}
+	}
+}
+
+// routes returns the compiled policy, loading it on first use.
+func (s *NotificationService) routes(ctx context.Context) (*route, error) {
+	if s.root != nil {
+		return s.root, nil
+	}
+	p, err := s.Policy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	root, err := compileRoutes(p.Root)
+	if err != nil {
+		return nil, err
+	}
+	s.root = root
+	return root, nil
+}
+
+// group returns the group of r for an alert with labels, creating it at
+// now if needed.
+func (s *NotificationService) group(r *route, labels map[string]string, now time.Time) *alertGroup {
+	gl := r.groupLabels(labels)
+	key := groupKey(r, gl)
+	g, ok := s.groups[key]
+	if !ok {
+		g = &alertGroup{route: r, labels: gl, alerts: make(map[domain.AlertRuleID]*groupedAlert), created: now}
+		s.groups[key] = g
+	}
+	return g
+}
+
+// AlertEvaluated puts the alert of a firing rule in the groups of the
+// routes it matches, and marks it resolved in those of a rule that stopped
+// firing.
+func (s *NotificationService) AlertEvaluated(ctx context.Context, r domain.AlertRule) {
+	now := time.Now()
+	if r.Status.EvaluatedAt != nil {
+		now = *r.Status.EvaluatedAt
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := s.routes(ctx)
+	if err != nil {
+		log.Printf("notification policy: %v", err)
+		return
+	}
+
+	targets := make(map[*alertGroup]bool)
+	if r.Status.State == domain.AlertFiring {
+		labels := alertLabels(r)
+		alert := domain.NotifiedAlert{
+			RuleID:      r.ID,
+			Name:        r.Name,
+			State:       domain.AlertFiring,
+			Labels:      labels,
+			Annotations: annotationsOf(r),
+			Since:       r.Status.Since,
+		}
+		for _, rt := range root.match(labels) {
+			g := s.group(rt, labels, now)
+			targets[g] = true
+			a, ok := g.alerts[r.ID]
+			if !ok {
+				a = &groupedAlert{}
+				g.alerts[r.ID] = a
+			}
+			// An alert firing again before the group was flushed keeps
+			// whether it was notified, so its resolution is not lost.
+			if a.alert.State != domain.AlertFiring {
+				g.changed = true
+			}
+			a.alert = alert
+		}
+	}
+	// The alert resolved, or its labels moved it out of these groups.
+	for _, g := range s.groups {
+		if !targets[g] {
+			g.resolve(r.ID, now)
+		}
+	}
+}
+
+// AlertRuleDeleted resolves the alert of a deleted rule.
+func (s *NotificationService) AlertRuleDeleted(id domain.AlertRuleID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, g := range s.groups {
+		g.resolve(id, now)
+	}
+}
+
+func (g *alertGroup) resolve(id domain.AlertRuleID, now time.Time) {
+	a, ok := g.alerts[id]
+	if !ok || a.alert.State != domain.AlertFiring {
+		return
+	}
+	a.alert.State, a.alert.Since = domain.AlertResolved, now
+	g.changed = true
+}
+
+// due reports whether g is to be notified at now: GroupWait after it was
+// created, GroupInterval after its last notification if its alerts
+// changed since, and RepeatInterval after it while any fires.
+func (g *alertGroup) due(now time.Time) bool {
+	switch {
+	case g.sending:
+		return false
+	case g.flushed.IsZero():
+		return !now.Before(g.created.Add(g.route.groupWait))
+	case g.changed:
+		return !now.Before(g.flushed.Add(g.route.groupInterval))
+	}
+	for _, a := range g.alerts {
+		if a.alert.State == domain.AlertFiring {
+			return !now.Before(g.flushed.Add(g.route.repeatInterval))
+		}
+	}
+	return false
+}
+
+// Run notifies the groups that are due every second until ctx is done.
+func (s *NotificationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(notificationTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				log.Printf("notifications: %v", err)
+			}
+		}
+	}
+}
+
+// delivery is a notification of a group to its contact point.
+type delivery struct {
+	key          string
+	group        *alertGroup
+	contactPoint domain.ContactPointID
+	notification domain.Notification
+}
+
+// Flush notifies, concurrently, the groups that are due at now of their
+// firing alerts that no silence matches, and of the resolution of those
+// they were notified of, and waits for the deliveries. A group moves past
+// a notification only once it is delivered; groups whose delivery fails
+// keep their alerts and are retried at their next group interval.
+func (s *NotificationService) Flush(ctx context.Context, now time.Time) error {
+	active, err := s.silences.Active(ctx, now)
+	if err != nil {
+		return err
+	}
+	silences := make([][]labelMatcher, 0, len(active))
+	for _, sl := range active {
+		ms, err := compileMatchers(sl.Matchers)
+		if err != nil {
+			log.Printf("silence %s: %v", sl.ID, err)
+			continue
+		}
+		silences = append(silences, ms)
+	}
+	silenced := func(labels map[string]string) bool {
+		for _, ms := range silences {
+			if matchAll(ms, labels) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var deliveries []delivery
+	s.mu.Lock()
+	for key, g := range s.groups {
+		if !g.due(now) {
+			continue
+		}
+		n, ok := g.notification(silenced)
+		g.changed = false
+		if !ok || g.route.contactPoint == nil {
+			s.commit(key, g, n, now)
+			continue
+		}
+		g.sending = true
+		deliveries = append(deliveries, delivery{key: key, group: g, contactPoint: *g.route.contactPoint, notification: n})
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.contactPoints.Notify(ctx, d.contactPoint, d.notification)
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			d.group.sending = false
+			if err != nil {
+				log.Printf("notification group %s: %v", d.key, err)
+				d.group.flushed, d.group.changed = now, true
+				return
+			}
+			s.commit(d.key, d.group, d.notification, now)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// notification builds the notification of g, leaving g as it is. It
+// reports false when there is nothing to notify of.
+func (g *alertGroup) notification(silenced func(map[string]string) bool) (domain.Notification, bool) {
+	var firing, resolved []domain.NotifiedAlert
+	for _, a := range g.alerts {
+		switch {
+		case a.alert.State != domain.AlertFiring:
+			if a.notified {
+				resolved = append(resolved, a.alert)
+			}
+		case !silenced(a.alert.Labels):
+			firing = append(firing, a.alert)
+		}
+	}
+	if len(firing) == 0 && len(resolved) == 0 {
+		return domain.Notification{}, false
+	}
+	return groupNotification(g.labels, firing, resolved), true
+}
+
+// commit moves g, stored under key, past n delivered at now: the firing
+// alerts of n count as notified, and alerts still resolved leave the
+// group, which goes once empty. Alerts that changed while n was delivered
+// are left for the next notification.
+func (s *NotificationService) commit(key string, g *alertGroup, n domain.Notification, now time.Time) {
+	for _, na := range n.Alerts {
+		if a, ok := g.alerts[na.RuleID]; ok && na.State == domain.AlertFiring {
+			a.notified = true
+		}
+	}
+	for id, a := range g.alerts {
+		if a.alert.State == domain.AlertFiring {
+			continue
+		}
+		if !a.notified || slices.ContainsFunc(n.Alerts, func(na domain.NotifiedAlert) bool {
+			return na.RuleID == id && na.State != domain.AlertFiring
+		}) {
+			delete(g.alerts, id)
+		}
+	}
+	g.flushed = now
+	if len(g.alerts) == 0 && s.groups[key] == g {
+		delete(s.groups, key)
+	}
+}
+
+// groupNotification sums up the firing and resolved alerts of a group
+// with labels, e.g. "[FIRING:2] team=db" with a line per alert.
+func groupNotification(labels map[string]string, firing, resolved []domain.NotifiedAlert) domain.Notification {
+	byName := func(a, b domain.NotifiedAlert) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.RuleID.Int64(), b.RuleID.Int64()))
+	}
+	slices.SortFunc(firing, byName)
+	slices.SortFunc(resolved, byName)
+
+	status, count := domain.AlertFiring, len(firing)
+	if count == 0 {
+		status, count = domain.AlertResolved, len(resolved)
+	}
+	var subject []string
+	for _, k := range sortedKeys(labels) {
+		subject = append(subject, k+"="+labels[k])
+	}
+	if len(subject) == 0 {
+		for _, a := range append(slices.Clone(firing), resolved...) {
+			if !slices.Contains(subject, string(a.Name)) {
+				subject = append(subject, string(a.Name))
+			}
+		}
+	}
+
+	var msg strings.Builder
+	section := func(title string, alerts []domain.NotifiedAlert) {
+		if len(alerts) == 0 {
+			return
+		}
+		if msg.Len() > 0 {
+			msg.WriteByte('\n')
+		}
+		msg.WriteString(title + ":\n")
+		for _, a := range alerts {
+			var ls []string
+			for _, k := range sortedKeys(a.Labels) {
+				if k != alertNameLabel {
+					ls = append(ls, k+"="+a.Labels[k])
+				}
+			}
+			fmt.Fprintf(&msg, "- %s", a.Name)
+			if len(ls) > 0 {
+				fmt.Fprintf(&msg, " {%s}", strings.Join(ls, ", "))
+			}
+			fmt.Fprintf(&msg, " since %s\n", a.Since.UTC().Format(time.RFC3339))
+			for _, k := range sortedKeys(a.Annotations) {
+				fmt.Fprintf(&msg, "  %s: %s\n", k, a.Annotations[k])
+			}
+		}
+	}
+	section("Firing", firing)
+	section("Resolved", resolved)
+
+	return domain.Notification{
+		Title:   fmt.Sprintf("[%s:%d] %s", strings.ToUpper(string(status)), count, strings.Join(subject, ", ")),
+		Message: strings.TrimSuffix(msg.String(), "\n"),
+		Status:  status,
+		Alerts:  append(firing, resolved...),
+	}
+}
+
+func annotationsOf(r domain.AlertRule) map[string]string {
+	if len(r.Annotations) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(r.Annotations))
+	for k, v := range r.Annotations {
+		out[string(k)] = string(v)
+	}
+	return out
+}
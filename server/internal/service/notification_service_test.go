@@ -0,0 +1,357 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/webhook"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+// notificationEnv wires alert rules over a checks table to notifications
+// delivered to webhook receivers.
+type notificationEnv struct {
+	queryEnv
+	alerts        *service.AlertService
+	silences      *service.SilenceService
+	contactPoints *service.ContactPointService
+	notifications *service.NotificationService
+}
+
+func newNotificationEnv(t *testing.T) notificationEnv {
+	t.Helper()
+	env := notificationEnv{queryEnv: newQueryEnv(t, `CREATE TABLE checks (name TEXT, down INTEGER);
+		INSERT INTO checks VALUES ('postgres', 0), ('redis', 0), ('web', 0);`)}
+	env.alerts = service.NewAlertService(repository.NewAlertRuleRepository(env.db), env.queries)
+	env.silences = service.NewSilenceService(repository.NewSilenceRepository(env.db))
+	env.contactPoints = service.NewContactPointService(
+		repository.NewContactPointRepository(env.db),
+		notifier.NewRegistry(webhook.New()),
+		notifier.Backoff{Attempts: 1},
+	)
+	env.notifications = service.NewNotificationService(
+		repository.NewNotificationPolicyRepository(env.db), env.alerts, env.silences, env.contactPoints)
+	return env
+}
+
+// webhookReceiver creates a webhook contact point whose notifications
+// arrive on the returned channel.
+func webhookReceiver(t *testing.T, contactPoints *service.ContactPointService, name domain.Name) (domain.ContactPointID, <-chan domain.Notification) {
+	t.Helper()
+	ch := make(chan domain.Notification, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var n domain.Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Errorf("receiver %s: %v", name, err)
+		}
+		ch <- n
+	}))
+	t.Cleanup(srv.Close)
+	cp, err := contactPoints.Create(context.Background(), domain.CreateContactPointOptions{
+		Name:     name,
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": domain.PropertyValue(srv.URL)},
+	})
+	if err != nil {
+		t.Fatalf("contact point %s: %v", name, err)
+	}
+	return cp.ID, ch
+}
+
+// rule creates a rule firing while the check of the same name is down.
+func (e notificationEnv) rule(t *testing.T, check string, labels map[domain.Name]domain.PropertyValue) domain.AlertRule {
+	t.Helper()
+	r, err := e.alerts.Create(context.Background(), domain.CreateAlertRuleOptions{
+		Name: domain.Name(check + " down"),
+		Query: &domain.Query{
+			DataSourceID: e.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": domain.PropertyValue("SELECT down FROM checks WHERE name = '" + check + "'")},
+		},
+		Condition:   "max(down) > 0",
+		Interval:    domain.Duration(time.Minute),
+		Labels:      labels,
+		Annotations: map[domain.Name]domain.PropertyValue{"summary": domain.PropertyValue(check + " is unreachable")},
+	})
+	if err != nil {
+		t.Fatalf("rule %s: %v", check, err)
+	}
+	return r
+}
+
+func expectNone(t *testing.T, ch <-chan domain.Notification) {
+	t.Helper()
+	select {
+	case n := <-ch:
+		t.Fatalf("unexpected notification %q", n.Title)
+	default:
+	}
+}
+
+func expectNotification(t *testing.T, ch <-chan domain.Notification, title string, alerts int) domain.Notification {
+	t.Helper()
+	select {
+	case n := <-ch:
+		if n.Title != title || len(n.Alerts) != alerts {
+			t.Fatalf("notification %q with %d alerts, want %q with %d:\n%s", n.Title, len(n.Alerts), title, alerts, n.Message)
+		}
+		return n
+	default:
+		t.Fatalf("no notification, want %q", title)
+	}
+	return domain.Notification{}
+}
+
+func TestNotificationService_GroupsAndSilences(t *testing.T) {
+	env := newNotificationEnv(t)
+	ctx := context.Background()
+	general, generalCh := webhookReceiver(t, env.contactPoints, "general")
+	dba, dbaCh := webhookReceiver(t, env.contactPoints, "dba")
+
+	_, err := env.notifications.UpdatePolicy(ctx, domain.NotificationRoute{
+		ContactPointID: &general,
+		GroupBy:        []domain.Name{"alertname"},
+		GroupWait:      domain.Duration(30 * time.Second),
+		GroupInterval:  domain.Duration(5 * time.Minute),
+		RepeatInterval: domain.Duration(time.Hour),
+		Routes: []domain.NotificationRoute{{
+			Matchers:       []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}},
+			ContactPointID: &dba,
+			GroupBy:        []domain.Name{"team"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+	env.rule(t, "postgres", map[domain.Name]domain.PropertyValue{"team": "db"})
+	env.rule(t, "redis", map[domain.Name]domain.PropertyValue{"team": "db"})
+	env.rule(t, "web", map[domain.Name]domain.PropertyValue{"team": "web"})
+
+	t0 := time.Now()
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	evaluate := func(d time.Duration) {
+		t.Helper()
+		if err := env.alerts.EvaluateDue(ctx, at(d)); err != nil {
+			t.Fatalf("EvaluateDue: %v", err)
+		}
+	}
+	flush := func(d time.Duration) {
+		t.Helper()
+		if err := env.notifications.Flush(ctx, at(d)); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	env.exec(t, `UPDATE checks SET down = 1`)
+	evaluate(0)
+	flush(10 * time.Second)
+	expectNone(t, dbaCh)
+	expectNone(t, generalCh)
+
+	// Both database alerts arrive together once the group has waited.
+	flush(30 * time.Second)
+	n := expectNotification(t, dbaCh, "[FIRING:2] team=db", 2)
+	if n.Status != domain.AlertFiring || n.Alerts[0].Name != "postgres down" ||
+		n.Alerts[0].Labels["alertname"] != "postgres down" || n.Alerts[0].Annotations["summary"] != "postgres is unreachable" {
+		t.Fatalf("notification = %+v", n)
+	}
+	expectNotification(t, generalCh, "[FIRING:1] alertname=web down", 1)
+
+	// Postgres flaps every minute; the team hears of it once per group
+	// interval, not at every evaluation.
+	for i, down := range []int{0, 1, 0} {
+		env.exec(t, `UPDATE checks SET down = `+string(rune('0'+down))+` WHERE name = 'postgres'`)
+		evaluate(time.Duration(i+1) * time.Minute)
+		flush(time.Duration(i+1)*time.Minute + time.Second)
+	}
+	expectNone(t, dbaCh)
+	flush(5*time.Minute + 30*time.Second)
+	n = expectNotification(t, dbaCh, "[FIRING:1] team=db", 2)
+	if n.Alerts[0].Name != "redis down" || n.Alerts[1].Name != "postgres down" || n.Alerts[1].State != domain.AlertResolved {
+		t.Fatalf("notification = %+v", n)
+	}
+
+	// Silenced alerts are left out of repeated notifications.
+	if _, err := env.silences.Create(ctx, domain.CreateSilenceOptions{
+		Matchers: []domain.LabelMatcher{{Label: "alertname", Op: domain.MatchRegexp, Value: "web.*"}},
+		Duration: domain.Duration(24 * time.Hour),
+		Comment:  "deploying",
+	}); err != nil {
+		t.Fatalf("silence: %v", err)
+	}
+	flush(time.Hour + 30*time.Second)
+	expectNone(t, generalCh)
+	flush(time.Hour + 5*time.Minute + 30*time.Second)
+	expectNotification(t, dbaCh, "[FIRING:1] team=db", 1)
+	expectNone(t, generalCh)
+
+	// Resolutions of alerts notified before the silence still go out.
+	env.exec(t, `UPDATE checks SET down = 0`)
+	evaluate(2 * time.Hour)
+	flush(2*time.Hour + time.Minute)
+	n = expectNotification(t, generalCh, "[RESOLVED:1] alertname=web down", 1)
+	if n.Status != domain.AlertResolved {
+		t.Fatalf("status = %s", n.Status)
+	}
+	expectNotification(t, dbaCh, "[RESOLVED:1] team=db", 1)
+	flush(5 * time.Hour)
+	expectNone(t, generalCh)
+	expectNone(t, dbaCh)
+}
+
+func TestNotificationService_Routing(t *testing.T) {
+	env := newNotificationEnv(t)
+	ctx := context.Background()
+	general, generalCh := webhookReceiver(t, env.contactPoints, "general")
+	pager, pagerCh := webhookReceiver(t, env.contactPoints, "pager")
+	dba, dbaCh := webhookReceiver(t, env.contactPoints, "dba")
+
+	for name, root := range map[string]domain.NotificationRoute{
+		"no contact point": {},
+		"root matchers": {
+			ContactPointID: &general,
+			Matchers:       []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}},
+		},
+		"bad regexp": {
+			ContactPointID: &general,
+			Routes:         []domain.NotificationRoute{{Matchers: []domain.LabelMatcher{{Label: "team", Op: domain.MatchRegexp, Value: "("}}}},
+		},
+		"bad operator": {
+			ContactPointID: &general,
+			Routes:         []domain.NotificationRoute{{Matchers: []domain.LabelMatcher{{Label: "team", Op: "~", Value: "db"}}}},
+		},
+		"unknown contact point": {
+			ContactPointID: &general,
+			Routes:         []domain.NotificationRoute{{ContactPointID: &domain.ContactPointID{}}},
+		},
+	} {
+		if _, err := env.notifications.UpdatePolicy(ctx, root); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+
+	// Pages go to the pager and, as the route continues, to the team too.
+	// Routes inherit the timing of the root.
+	_, err := env.notifications.UpdatePolicy(ctx, domain.NotificationRoute{
+		ContactPointID: &general,
+		GroupWait:      domain.Duration(time.Second),
+		Routes: []domain.NotificationRoute{
+			{
+				Matchers:       []domain.LabelMatcher{{Label: "severity", Op: domain.MatchRegexp, Value: "page|critical"}},
+				ContactPointID: &pager,
+				Continue:       true,
+			},
+			{
+				Matchers:       []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}},
+				ContactPointID: &dba,
+			},
+			{
+				Matchers:       []domain.LabelMatcher{{Label: "team", Op: domain.MatchNotEqual, Value: "web"}},
+				ContactPointID: &pager,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+	policy, err := env.notifications.Policy(ctx)
+	if err != nil || len(policy.Root.Routes) != 3 {
+		t.Fatalf("Policy = %+v, %v", policy, err)
+	}
+
+	env.rule(t, "postgres", map[domain.Name]domain.PropertyValue{"team": "db", "severity": "page"})
+	env.rule(t, "web", map[domain.Name]domain.PropertyValue{"team": "web"})
+	env.exec(t, `UPDATE checks SET down = 1`)
+	t0 := time.Now()
+	if err := env.alerts.EvaluateDue(ctx, t0); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.notifications.Flush(ctx, t0.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	expectNotification(t, pagerCh, "[FIRING:1] postgres down", 1)
+	expectNotification(t, dbaCh, "[FIRING:1] postgres down", 1)
+	expectNotification(t, generalCh, "[FIRING:1] web down", 1)
+	expectNone(t, pagerCh)
+}
+
+func TestNotificationService_DeliveryFailure(t *testing.T) {
+	env := newNotificationEnv(t)
+	ctx := context.Background()
+	var down atomic.Bool
+	ch := make(chan domain.Notification, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var n domain.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("receiver: %v", err)
+		}
+		ch <- n
+	}))
+	t.Cleanup(srv.Close)
+	cp, err := env.contactPoints.Create(ctx, domain.CreateContactPointOptions{
+		Name:     "flaky",
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": domain.PropertyValue(srv.URL)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.notifications.UpdatePolicy(ctx, domain.NotificationRoute{
+		ContactPointID: &cp.ID,
+		GroupWait:      domain.Duration(time.Second),
+		GroupInterval:  domain.Duration(time.Minute),
+	}); err != nil {
+		t.Fatalf("UpdatePolicy: %v", err)
+	}
+	env.rule(t, "postgres", nil)
+
+	t0 := time.Now()
+	at := func(d time.Duration) time.Time { return t0.Add(d) }
+	flush := func(d time.Duration) {
+		t.Helper()
+		if err := env.notifications.Flush(ctx, at(d)); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+
+	// A failed notification of a firing alert is retried a group
+	// interval later.
+	down.Store(true)
+	env.exec(t, `UPDATE checks SET down = 1 WHERE name = 'postgres'`)
+	if err := env.alerts.EvaluateDue(ctx, t0); err != nil {
+		t.Fatal(err)
+	}
+	flush(time.Second)
+	down.Store(false)
+	flush(30 * time.Second)
+	expectNone(t, ch)
+	flush(time.Minute + time.Second)
+	expectNotification(t, ch, "[FIRING:1] postgres down", 1)
+
+	// A resolution that fails to go out stays in the group until it does.
+	down.Store(true)
+	env.exec(t, `UPDATE checks SET down = 0`)
+	if err := env.alerts.EvaluateDue(ctx, at(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	flush(3 * time.Minute)
+	down.Store(false)
+	flush(4*time.Minute + time.Second)
+	expectNotification(t, ch, "[RESOLVED:1] postgres down", 1)
+	flush(time.Hour)
+	expectNone(t, ch)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/pkg/transform"
+)
+
+// Component properties understood by the server. Everything else is left
+// to the visualisation in the frontend.
+const (
+	// ComponentPropertyColumnMeta holds a JSON object of ColumnMeta keyed by
+	// column name, e.g. {"total": {"unit": "currencyUSD", "decimals": 2}}.
+	ComponentPropertyColumnMeta domain.PropertyKey = "columnMeta"
+	// ComponentPropertyCacheTTL is a Go duration, e.g. "30s", for which
+	// query results are served from the result cache. Unset disables it.
+	ComponentPropertyCacheTTL domain.PropertyKey = "cacheTTL"
+	// ComponentPropertyTransformations holds a JSON array of transformations
+	// applied in order to the query result before it is windowed, e.g.
+	// [{"type": "groupBy", "options": {"by": ["region"], "aggregations":
+	// [{"column": "total", "func": "sum"}]}}]. See package transform.
+	ComponentPropertyTransformations domain.PropertyKey = "transformations"
+	// ComponentPropertyMerge holds how the results of the queries of a
+	// component are combined before transformations, e.g. {"type": "join",
+	// "on": "id", "how": "left"}; see transform.Merge. Components with
+	// several queries need it, and their queries need distinct names.
+	ComponentPropertyMerge domain.PropertyKey = "merge"
+	// ComponentPropertyDynamic holds a JSON object of expressions keyed by
+	// property, e.g. {"title": "'CPU of ' + host"}. Each is evaluated with
+	// the selected variables and replaces the property of the same key;
+	// see QueryService.ComponentProperties.
+	ComponentPropertyDynamic domain.PropertyKey = "dynamicProperties"
+)
+
+// validateComponentProperties rejects server-interpreted properties that
+// would otherwise only fail once the component is rendered.
+func validateComponentProperties(props map[domain.PropertyKey]domain.PropertyValue, queries []domain.Query) error {
+	if _, err := columnMetaOverrides(props); err != nil {
+		return err
+	}
+	if _, err := cacheTTL(props); err != nil {
+		return err
+	}
+	if _, err := transformations(props); err != nil {
+		return err
+	}
+	if _, err := mergeOf(props, queries); err != nil {
+		return err
+	}
+	if _, err := dynamicProperties(props); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateQueries rejects malformed limit, variable and interval
+// properties of queries.
+func validateQueries(queries []domain.Query) error {
+	for _, q := range queries {
+		if _, err := queryLimitsOf(q.Properties); err != nil {
+			return err
+		}
+		if err := validateVariableRefs(q.Properties); err != nil {
+			return err
+		}
+		if _, err := minInterval(q.Properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DataSourcePropertySchemaTTL is a Go duration for which the introspected
+// schema of a data source is cached; DefaultSchemaTTL applies when unset.
+const DataSourcePropertySchemaTTL domain.PropertyKey = "schemaCacheTTL"
+
+// validateDataSourceProperties rejects malformed limit and cache properties.
+func validateDataSourceProperties(props map[domain.PropertyKey]domain.PropertyValue) error {
+	if _, err := queryLimitsOf(props); err != nil {
+		return err
+	}
+	_, err := schemaTTL(props)
+	return err
+}
+
+func cacheTTL(props map[domain.PropertyKey]domain.PropertyValue) (time.Duration, error) {
+	raw, ok := props[ComponentPropertyCacheTTL]
+	if !ok || raw == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(string(raw))
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("%w: %s: must be a non-negative duration such as 30s", ErrBadRequest, ComponentPropertyCacheTTL)
+	}
+	return ttl, nil
+}
+
+func columnMetaOverrides(props map[domain.PropertyKey]domain.PropertyValue) (map[domain.Name]domain.ColumnMeta, error) {
+	raw, ok := props[ComponentPropertyColumnMeta]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	var overrides map[domain.Name]domain.ColumnMeta
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadRequest, ComponentPropertyColumnMeta, err)
+	}
+	for name, meta := range overrides {
+		if err := domain.Validate(meta); err != nil {
+			return nil, fmt.Errorf("%w: %s.%s: %v", ErrBadRequest, ComponentPropertyColumnMeta, name, err)
+		}
+	}
+	return overrides, nil
+}
+
+func transformations(props map[domain.PropertyKey]domain.PropertyValue) (transform.Pipeline, error) {
+	raw, ok := props[ComponentPropertyTransformations]
+	if !ok || raw == "" {
+		return transform.Pipeline{}, nil
+	}
+	p, err := transform.Parse(string(raw))
+	if err != nil {
+		err = expression.At(err, "properties."+string(ComponentPropertyTransformations))
+		return transform.Pipeline{}, fmt.Errorf("%w: %s: %w", ErrBadRequest, ComponentPropertyTransformations, err)
+	}
+	return p, nil
+}
+
+// mergeOf returns how the results of queries are merged. It is the zero
+// Merge for components with a single query.
+func mergeOf(props map[domain.PropertyKey]domain.PropertyValue, queries []domain.Query) (transform.Merge, error) {
+	if len(queries) < 2 {
+		return transform.Merge{}, nil
+	}
+	names := make(map[domain.Name]bool, len(queries))
+	for _, q := range queries {
+		if q.Name == "" || names[q.Name] {
+			return transform.Merge{}, fmt.Errorf("%w: the queries of a component with several need distinct names", ErrBadRequest)
+		}
+		names[q.Name] = true
+	}
+	raw := props[ComponentPropertyMerge]
+	if raw == "" {
+		return transform.Merge{}, fmt.Errorf("%w: %s: required for components with several queries", ErrBadRequest, ComponentPropertyMerge)
+	}
+	m, err := transform.ParseMerge(string(raw))
+	if err != nil {
+		return transform.Merge{}, fmt.Errorf("%w: %s: %v", ErrBadRequest, ComponentPropertyMerge, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+// DefaultJobRetention is how long finished jobs are kept when the service
+// is created without a retention.
+const DefaultJobRetention = 24 * time.Hour
+
+// jobBatchRows is how many rows a job reads per batch.
+const jobBatchRows = 4096
+
+// jobConcurrency is how many jobs run at once; later ones stay queued
+// until one finishes.
+const jobConcurrency = 4
+
+// jobProgressInterval is the least time between two progress updates of a
+// running job; the final count is stored with its result.
+const jobProgressInterval = time.Second
+
+// QueryJobService runs component queries in the background for clients
+// that cannot hold a request open for as long as the query takes. Finished
+// jobs, with their results, are kept for the retention period.
+type QueryJobService struct {
+	repo      *repository.QueryJobRepository
+	queries   *QueryService
+	retention time.Duration
+	// slots holds a token for every running job.
+	slots chan struct{}
+}
+
+func NewQueryJobService(
+	repo *repository.QueryJobRepository,
+	queries *QueryService,
+	retention time.Duration,
+) *QueryJobService {
+	if retention <= 0 {
+		retention = DefaultJobRetention
+	}
+	return &QueryJobService{repo: repo, queries: queries, retention: retention, slots: make(chan struct{}, jobConcurrency)}
+}
+
+// Recover fails the jobs a previous process left unfinished. Call it once
+// at startup, before any job is submitted; server.Start does.
+func (s *QueryJobService) Recover(ctx context.Context) error {
+	now := time.Now()
+	_, err := s.repo.FailUnfinished(ctx, "server restarted before the job finished", now, now.Add(s.retention))
+	return err
+}
+
+// Submit queues a job for the component's data narrowed by opts, with the
+// variables in vars, and returns it without waiting. The job can be cancelled through CancelRun
+// with the run ID equal to the job ID.
+func (s *QueryJobService) Submit(
+	ctx context.Context,
+	id domain.ComponentID,
+	opts domain.DataOptions,
+	vars domain.VariableSelection,
+) (domain.QueryJob, error) {
+	if _, err := s.queries.prepare(ctx, id, vars); err != nil {
+		return domain.QueryJob{}, err
+	}
+	now := time.Now()
+	if err := s.repo.DeleteExpired(ctx, now); err != nil {
+		return domain.QueryJob{}, err
+	}
+	job := domain.QueryJob{
+		ID:          domain.NewQueryJobID(now.UnixNano()),
+		ComponentID: id,
+		Status:      domain.QueryJobQueued,
+		CreatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, job); err != nil {
+		return domain.QueryJob{}, err
+	}
+	go s.run(job, opts, vars)
+	return job, nil
+}
+
+// Get returns a job of the component. Expired jobs are not found.
+func (s *QueryJobService) Get(ctx context.Context, componentID domain.ComponentID, id domain.QueryJobID) (domain.QueryJob, error) {
+	job, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.QueryJob{}, ErrNotFound
+		}
+		return domain.QueryJob{}, err
+	}
+	if job.ComponentID != componentID || job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+		return domain.QueryJob{}, ErrNotFound
+	}
+	return job, nil
+}
+
+// run executes a job detached from the request that submitted it, once a
+// slot is free; a job cancelled while it waits is not run. Store writes use
+// their own context so that a cancelled job is still recorded.
+func (s *QueryJobService) run(job domain.QueryJob, opts domain.DataOptions, vars domain.VariableSelection) {
+	store := context.Background()
+	ctx, done, err := s.queries.StartRun(context.Background(), domain.QueryRunID(job.ID))
+	if err == nil {
+		defer done()
+		err = s.acquire(ctx)
+	}
+	if err == nil {
+		defer func() { <-s.slots }()
+		job.Status = domain.QueryJobRunning
+		if err = s.repo.Update(store, job); err == nil {
+			var result domain.TableData
+			result, err = s.collect(ctx, &job, opts, vars)
+			job.Result = &result
+		}
+	}
+
+	switch {
+	case err == nil:
+		job.Status = domain.QueryJobSucceeded
+	case errors.Is(err, ErrCanceled):
+		job.Status, job.Error, job.Result = domain.QueryJobCanceled, err.Error(), nil
+	default:
+		job.Status, job.Error, job.Result = domain.QueryJobFailed, err.Error(), nil
+	}
+	finished := time.Now()
+	expires := finished.Add(s.retention)
+	job.FinishedAt, job.ExpiresAt = &finished, &expires
+	if err := s.repo.Update(store, job); err != nil {
+		log.Printf("query job %s: store result: %v", job.ID, err)
+	}
+}
+
+// acquire waits for a free slot until ctx is done.
+func (s *QueryJobService) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx)
+	}
+}
+
+func (s *QueryJobService) collect(
+	ctx context.Context,
+	job *domain.QueryJob,
+	opts domain.DataOptions,
+	vars domain.VariableSelection,
+) (domain.TableData, error) {
+	var batches []domain.TableData
+	reported := time.Now()
+	err := s.queries.StreamComponentData(ctx, job.ComponentID, opts, vars, jobBatchRows, func(batch domain.TableData, _ CacheStatus) error {
+		batches = append(batches, batch)
+		job.RowsFetched += int64(batch.NumRows())
+		if time.Since(reported) < jobProgressInterval {
+			return nil
+		}
+		reported = time.Now()
+		return s.repo.UpdateProgress(context.WithoutCancel(ctx), job.ID, job.RowsFetched)
+	})
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	return tableops.Concat(batches)
+}
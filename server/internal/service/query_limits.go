@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+// Properties bounding query execution. Each may be set on a DataSource, to
+// protect the backend, and on a Query; the tighter of the two applies.
+const (
+	// PropertyTimeout is a Go duration, e.g. "30s".
+	PropertyTimeout domain.PropertyKey = "timeout"
+	// PropertyMaxRows and PropertyMaxBytes truncate results that exceed
+	// them. Bytes are approximated with TableData.RowSize.
+	PropertyMaxRows  domain.PropertyKey = "maxRows"
+	PropertyMaxBytes domain.PropertyKey = "maxBytes"
+)
+
+type queryLimits struct {
+	timeout  time.Duration
+	maxRows  int64
+	maxBytes int64
+}
+
+// queryLimitsOf combines the limit properties of every props, keeping the
+// tightest value of each.
+func queryLimitsOf(props ...map[domain.PropertyKey]domain.PropertyValue) (queryLimits, error) {
+	var l queryLimits
+	for _, p := range props {
+		if raw := p[PropertyTimeout]; raw != "" {
+			d, err := time.ParseDuration(string(raw))
+			if err != nil || d <= 0 {
+				return queryLimits{}, fmt.Errorf("%w: %s: must be a positive duration such as 30s", ErrBadRequest, PropertyTimeout)
+			}
+			if l.timeout == 0 || d < l.timeout {
+				l.timeout = d
+			}
+		}
+		for key, dst := range map[domain.PropertyKey]*int64{PropertyMaxRows: &l.maxRows, PropertyMaxBytes: &l.maxBytes} {
+			raw := p[key]
+			if raw == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(string(raw), 10, 64)
+			if err != nil || n <= 0 {
+				return queryLimits{}, fmt.Errorf("%w: %s: must be a positive integer", ErrBadRequest, key)
+			}
+			if *dst == 0 || n < *dst {
+				*dst = n
+			}
+		}
+	}
+	return l, nil
+}
+
+// errBudgetExhausted stops a class from producing rows nobody will read.
+var errBudgetExhausted = errors.New("row budget exhausted")
+
+// budget passes batches through until maxRows or maxBytes is exceeded,
+// then emits the rows that still fit, marked truncated, and stops.
+type budget struct {
+	limits queryLimits
+	rows   int64
+	bytes  int64
+}
+
+func (b *budget) enabled() bool {
+	return b.limits.maxRows > 0 || b.limits.maxBytes > 0
+}
+
+func (b *budget) wrap(emit func(domain.TableData) error) func(domain.TableData) error {
+	if !b.enabled() {
+		return emit
+	}
+	return func(batch domain.TableData) error {
+		n := batch.NumRows()
+		fit := 0
+		for ; fit < n; fit++ {
+			size := int64(batch.RowSize(fit))
+			if b.limits.maxRows > 0 && b.rows+1 > b.limits.maxRows ||
+				b.limits.maxBytes > 0 && b.bytes+size > b.limits.maxBytes {
+				break
+			}
+			b.rows++
+			b.bytes += size
+		}
+		if fit == n {
+			return emit(batch)
+		}
+		rows := make([]int, fit)
+		for i := range rows {
+			rows[i] = i
+		}
+		cut := tableops.Take(batch, rows)
+		cut.Total, cut.NextCursor = batch.Total, batch.NextCursor
+		cut.Truncated = true
+		if err := emit(cut); err != nil {
+			return err
+		}
+		return errBudgetExhausted
+	}
+}
+
+// runRegistry tracks the cancel functions of running queries.
+type runRegistry struct {
+	mu   sync.Mutex
+	runs map[domain.QueryRunID]context.CancelCauseFunc
+}
+
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: make(map[domain.QueryRunID]context.CancelCauseFunc)}
+}
+
+// StartRun registers a run under id and returns the context its queries
+// must use. done must be called once the run finishes. Starting a run with
+// an id that is still running is a conflict.
+func (s *QueryService) StartRun(ctx context.Context, id domain.QueryRunID) (context.Context, func(), error) {
+	r := s.runs
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.runs[id]; ok {
+		return nil, nil, fmt.Errorf("%w: run %s is already running", ErrConflict, id)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	r.runs[id] = cancel
+	done := func() {
+		r.mu.Lock()
+		delete(r.runs, id)
+		r.mu.Unlock()
+		cancel(nil)
+	}
+	return ctx, done, nil
+}
+
+// CancelRun cancels a running query. The driver query stops once no other
+// request is waiting for the same result.
+func (s *QueryService) CancelRun(id domain.QueryRunID) error {
+	r := s.runs
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cancel, ok := r.runs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	cancel(ErrCanceled)
+	return nil
+}
+
+// contextError reports why ctx ended in terms of service errors.
+func contextError(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), ErrCanceled) {
+		return ErrCanceled
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
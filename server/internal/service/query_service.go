@@ -0,0 +1,406 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/expression"
+	"github.com/smilu97/refana/internal/pkg/script"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+	"github.com/smilu97/refana/internal/pkg/transform"
+)
+
+// QueryService executes component queries through their DataSourceClass.
+type QueryService struct {
+	components   *ComponentService
+	dataSources  *DataSourceService
+	variables    *VariableService
+	transformers *TransformerService
+	classes      *datasource.Registry
+	cache        *resultCache
+	runs         *runRegistry
+	schemas      *schemaCache
+}
+
+// NewQueryService returns a service resolving query variables through
+// variables, which may be nil to leave references uninterpolated, and the
+// saved transformers of script steps through transformers, which may be
+// nil to reject steps naming one.
+func NewQueryService(
+	components *ComponentService,
+	dataSources *DataSourceService,
+	variables *VariableService,
+	transformers *TransformerService,
+	classes *datasource.Registry,
+) *QueryService {
+	s := &QueryService{
+		components:   components,
+		dataSources:  dataSources,
+		variables:    variables,
+		transformers: transformers,
+		classes:      classes,
+		cache:        newResultCache(),
+		runs:         newRunRegistry(),
+		schemas:      newSchemaCache(),
+	}
+	dataSources.Describe(classes)
+	dataSources.CascadeTo(components)
+	dataSources.Watch(classes)
+	dataSources.Watch(s.schemas)
+	dataSources.Watch(s.cache)
+	components.Watch(s.cache)
+	return s
+}
+
+// PoolStats reports the connection pools the data source classes keep open.
+func (s *QueryService) PoolStats() []domain.PoolStats {
+	return s.classes.PoolStats()
+}
+
+// componentQuery is everything needed to run the queries of one
+// component. ds, class and limits are those of its first query; merged
+// holds one componentQuery for each further query, whose results merge
+// combines. class is nil when the component has no data source.
+// transformers holds the versions of the saved transformers pipeline uses.
+type componentQuery struct {
+	comp         domain.Component
+	ds           domain.DataSource
+	class        datasource.Class
+	overrides    map[domain.Name]domain.ColumnMeta
+	pipeline     transform.Pipeline
+	transformers map[domain.Name]time.Time
+	merge        transform.Merge
+	merged       []componentQuery
+	ttl          time.Duration
+	limits       queryLimits
+}
+
+func (s *QueryService) prepare(ctx context.Context, id domain.ComponentID, vars domain.VariableSelection) (componentQuery, error) {
+	comp, err := s.components.Get(ctx, id)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	overrides, err := columnMetaOverrides(comp.Properties)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	ttl, err := cacheTTL(comp.Properties)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	pipeline, err := transformations(comp.Properties)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	transformers, err := s.bindTransformers(ctx, pipeline)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	merge, err := mergeOf(comp.Properties, comp.Queries)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	cq := componentQuery{comp: comp, overrides: overrides, pipeline: pipeline, transformers: transformers, merge: merge, ttl: ttl}
+	for i, q := range comp.Queries {
+		if q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+			if len(comp.Queries) == 1 {
+				return cq, nil
+			}
+			return componentQuery{}, fmt.Errorf("%w: query %s has no data source", ErrBadRequest, q.Name)
+		}
+		part := componentQuery{comp: comp}
+		part.ds, part.class, part.limits, err = s.target(ctx, q)
+		if err != nil {
+			return componentQuery{}, err
+		}
+		part.comp.Query, err = s.interpolate(ctx, part.class, q, vars)
+		if err != nil {
+			return componentQuery{}, err
+		}
+		if i > 0 {
+			cq.merged = append(cq.merged, part)
+			continue
+		}
+		cq.ds, cq.class, cq.limits, cq.comp.Query = part.ds, part.class, part.limits, part.comp.Query
+	}
+	return cq, nil
+}
+
+// bindTransformers gives the script steps of pipeline the saved
+// transformers they name, and returns the versions of those.
+func (s *QueryService) bindTransformers(ctx context.Context, pipeline transform.Pipeline) (map[domain.Name]time.Time, error) {
+	names := pipeline.Transformers()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if s.transformers == nil {
+		return nil, fmt.Errorf("%w: saved transformers are not available", ErrBadRequest)
+	}
+	versions := make(map[domain.Name]time.Time, len(names))
+	err := pipeline.Bind(func(name domain.Name) (script.Program, error) {
+		t, p, err := s.transformers.byName(ctx, name)
+		versions[name] = t.UpdatedAt
+		return p, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ComponentPropertyTransformations, err)
+	}
+	return versions, nil
+}
+
+// target resolves the data source and class a query runs against, and the
+// limits that apply to it.
+func (s *QueryService) target(ctx context.Context, q domain.Query) (domain.DataSource, datasource.Class, queryLimits, error) {
+	ds, err := s.dataSources.Resolve(ctx, q)
+	if err != nil {
+		return domain.DataSource{}, nil, queryLimits{}, err
+	}
+	limits, err := queryLimitsOf(ds.Properties, q.Properties)
+	if err != nil {
+		return domain.DataSource{}, nil, queryLimits{}, err
+	}
+	class, err := s.classes.Get(ds.ClassID)
+	if err != nil {
+		if errors.Is(err, datasource.ErrUnknownClass) {
+			return domain.DataSource{}, nil, queryLimits{}, fmt.Errorf("%w: %v: %s", ErrBadRequest, err, ds.ClassID)
+		}
+		return domain.DataSource{}, nil, queryLimits{}, err
+	}
+	return ds, class, limits, nil
+}
+
+// ComponentData runs the query of a component and decorates the result
+// with the component's column metadata overrides. Non-zero opts narrow the
+// rows, in the data source when its class supports it and in memory
+// otherwise; the result then carries Total and, if rows remain, NextCursor.
+// vars selects the values of the variables the query references.
+//
+// Identical concurrent requests share one execution, and results are
+// cached for the component's cacheTTL property.
+func (s *QueryService) ComponentData(
+	ctx context.Context,
+	id domain.ComponentID,
+	opts domain.DataOptions,
+	vars domain.VariableSelection,
+) (domain.TableData, CacheStatus, error) {
+	cq, err := s.prepare(ctx, id, vars)
+	if err != nil {
+		return domain.TableData{}, CacheStatus{}, err
+	}
+	if cq.class == nil {
+		return domain.TableData{Columns: []domain.ColumnData{}}, CacheStatus{}, nil
+	}
+	table, status, err := s.cached(ctx, cq, opts)
+	if err != nil {
+		return domain.TableData{}, CacheStatus{}, err
+	}
+	return cq.decorate(table, opts), status, nil
+}
+
+// StreamComponentData is ComponentData delivered in batches of at most
+// batchSize rows, or in one batch when batchSize is not positive. Classes
+// that cannot stream and cached results produce a single batch. Total
+// and NextCursor are set on the first batch only. Identical concurrent
+// calls, and ComponentData calls, share one run and receive its batches.
+func (s *QueryService) StreamComponentData(
+	ctx context.Context,
+	id domain.ComponentID,
+	opts domain.DataOptions,
+	vars domain.VariableSelection,
+	batchSize int,
+	emit func(domain.TableData, CacheStatus) error,
+) error {
+	cq, err := s.prepare(ctx, id, vars)
+	if err != nil {
+		return err
+	}
+	if cq.class == nil {
+		return emit(domain.TableData{Columns: []domain.ColumnData{}}, CacheStatus{})
+	}
+	load := func(ctx context.Context, emit func(domain.TableData) error) error {
+		return s.stream(ctx, cq, opts, batchSize, emit)
+	}
+	return s.cache.stream(ctx, cacheKey(cq, opts), refsOf(cq), cq.ttl, load, func(batch domain.TableData, status CacheStatus) error {
+		return emit(cq.decorate(batch, opts), status)
+	})
+}
+
+func (s *QueryService) cached(ctx context.Context, cq componentQuery, opts domain.DataOptions) (domain.TableData, CacheStatus, error) {
+	load := func(ctx context.Context, emit func(domain.TableData) error) error {
+		return s.stream(ctx, cq, opts, 0, emit)
+	}
+	return s.cache.get(ctx, cacheKey(cq, opts), refsOf(cq), cq.ttl, load)
+}
+
+// stream runs the query of cq, which must have a class, and emits its
+// undecorated batches within the limits of cq. Components with several
+// queries or with transformations emit a single batch.
+func (s *QueryService) stream(
+	ctx context.Context,
+	cq componentQuery,
+	opts domain.DataOptions,
+	batchSize int,
+	emit func(domain.TableData) error,
+) error {
+	if len(cq.merged) > 0 || !cq.pipeline.Empty() {
+		return s.transform(ctx, cq, opts, emit)
+	}
+	if cq.limits.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cq.limits.timeout)
+		defer cancel()
+	}
+	b := budget{limits: cq.limits}
+	err := s.run(ctx, cq, opts, batchSize, b.wrap(emit))
+	switch {
+	case errors.Is(err, errBudgetExhausted):
+		return nil
+	case errors.As(err, new(*datasource.ReadOnlyViolation)), errors.Is(err, datasource.ErrInvalidQuery):
+		return fmt.Errorf("%w: %w", ErrBadRequest, err)
+	case err != nil && ctx.Err() != nil:
+		// drivers report cancellation in their own words
+		return contextError(ctx)
+	}
+	return err
+}
+
+func (s *QueryService) run(
+	ctx context.Context,
+	cq componentQuery,
+	opts domain.DataOptions,
+	batchSize int,
+	emit func(domain.TableData) error,
+) error {
+	q := cq.comp.Query
+	if !opts.IsZero() {
+		if class, ok := cq.class.(datasource.WindowingClass); ok {
+			return windowError(class.StreamWindow(ctx, cq.ds, q, opts, batchSize, emit))
+		}
+	} else if class, ok := cq.class.(datasource.StreamingClass); ok {
+		return class.StreamQuery(ctx, cq.ds, q, batchSize, emit)
+	}
+
+	table, err := cq.class.Query(ctx, cq.ds, q)
+	if err != nil {
+		return err
+	}
+	if !opts.IsZero() {
+		if table, err = tableops.Apply(table, opts); err != nil {
+			return windowError(err)
+		}
+	}
+	return emit(table)
+}
+
+// transform runs the queries of cq unnarrowed and concurrently, each
+// within its own limits, and emits the merge of their results, transformed
+// and then narrowed by opts.
+func (s *QueryService) transform(
+	ctx context.Context,
+	cq componentQuery,
+	opts domain.DataOptions,
+	emit func(domain.TableData) error,
+) error {
+	parts := append([]componentQuery{cq}, cq.merged...)
+	frames := make([]transform.Frame, len(parts))
+	errs := make([]error, len(parts))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		part.pipeline, part.merged = transform.Pipeline{}, nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var batches []domain.TableData
+			err := s.stream(ctx, part, domain.DataOptions{}, 0, func(batch domain.TableData) error {
+				batches = append(batches, batch)
+				return nil
+			})
+			if err == nil {
+				frames[i].Table, err = tableops.Concat(batches)
+			}
+			if err != nil {
+				errs[i] = err
+				// the other queries are of no use without this one
+				cancel()
+			}
+			frames[i].Name = part.comp.Query.Name
+		}()
+	}
+	wg.Wait()
+	if err := firstError(errs); err != nil {
+		return err
+	}
+
+	table := frames[0].Table
+	var err error
+	if len(frames) > 1 {
+		if table, err = cq.merge.Apply(frames); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadRequest, err)
+		}
+	}
+	if table, err = cq.pipeline.Apply(table); err != nil {
+		err = expression.At(err, "properties."+string(ComponentPropertyTransformations))
+		return fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+	if !opts.IsZero() {
+		if table, err = tableops.Apply(table, opts); err != nil {
+			return windowError(err)
+		}
+	}
+	return emit(table)
+}
+
+// firstError returns the error that caused the others: the first one that
+// is not a cancellation, if any.
+func firstError(errs []error) error {
+	var canceled error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrCanceled), errors.Is(err, context.Canceled):
+			if canceled == nil {
+				canceled = err
+			}
+		default:
+			return err
+		}
+	}
+	return canceled
+}
+
+// decorate applies the component's column metadata overrides to a copy of
+// batch, leaving cached tables untouched.
+func (cq componentQuery) decorate(batch domain.TableData, opts domain.DataOptions) domain.TableData {
+	batch.Columns = slices.Clone(batch.Columns)
+	batch.ApplyColumnMeta(cq.overrides)
+	if batch.Total != nil {
+		batch.NextCursor = nextCursor(opts, batch.Total.Rows)
+	}
+	return batch
+}
+
+// nextCursor points after the current page when more rows match.
+func nextCursor(opts domain.DataOptions, total int64) string {
+	next := opts.Offset + opts.Limit
+	if opts.Limit <= 0 || int64(next) >= total {
+		return ""
+	}
+	return domain.EncodeCursor(next)
+}
+
+// windowError reports options naming unknown columns or carrying values
+// that do not fit their column as bad requests.
+func windowError(err error) error {
+	if errors.Is(err, tableops.ErrUnknownColumn) || errors.Is(err, tableops.ErrInvalidFilter) {
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	return err
+}
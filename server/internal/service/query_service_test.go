@@ -0,0 +1,579 @@
+package service_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/datasource/sqlsource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+	"github.com/smilu97/refana/internal/storage"
+)
+
+func TestQueryService_ComponentDataAppliesColumnMeta(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE orders (total REAL); INSERT INTO orders VALUES (12.5);`)
+	ctx := context.Background()
+
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "orders",
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT total FROM orders"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyColumnMeta: `{"total": {"unit": "currencyUSD", "decimals": 2, "displayName": "Total"}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+	if err != nil {
+		t.Fatalf("ComponentData: %v", err)
+	}
+	if table.NumRows() != 1 || table.Columns[0].Floats[0] != 12.5 {
+		t.Fatalf("table = %+v, want one row of 12.5", table)
+	}
+	meta := table.Columns[0].Meta
+	if meta == nil || meta.Unit != "currencyUSD" || meta.DisplayName != "Total" || *meta.Decimals != 2 {
+		t.Fatalf("meta = %+v, want override applied", meta)
+	}
+}
+
+func TestQueryService_RedactsClassSecrets(t *testing.T) {
+	env := newQueryEnv(t, "")
+	service.NewQueryService(env.components, env.dataSources, env.variables, nil, datasource.NewRegistry(sqlsource.NewPostgres()))
+	ds := env.dataSources.Redact(domain.DataSource{
+		ClassID:    "postgres",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"host": "db", "password": "hunter2"},
+	})
+	if ds.Properties["password"] != domain.SecretPlaceholder || ds.Properties["host"] != "db" {
+		t.Fatalf("Redact = %v", ds.Properties)
+	}
+}
+
+func TestQueryService_RejectsInvalidColumnMeta(t *testing.T) {
+	env := newQueryEnv(t, "")
+	_, err := env.components.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "broken",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyColumnMeta: `{"total": "not an object"}`,
+		},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_UnknownClass(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{Name: "x", ClassID: "nope"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries:         []domain.Query{{Name: "main", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	if _, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{}); !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_CachesResults(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (1);`)
+	ctx := context.Background()
+
+	opts := domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "cached",
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT n FROM t"},
+		}},
+		Properties: map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyCacheTTL: "1h"},
+	}
+	comp, err := env.components.Create(ctx, opts)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	fetch := func() (domain.TableData, service.CacheStatus) {
+		t.Helper()
+		table, status, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+		if err != nil {
+			t.Fatalf("ComponentData: %v", err)
+		}
+		return table, status
+	}
+	if _, status := fetch(); status.Hit {
+		t.Fatalf("first fetch should miss")
+	}
+	env.exec(t, `INSERT INTO t VALUES (2)`)
+	if table, status := fetch(); !status.Hit || table.NumRows() != 1 {
+		t.Fatalf("second fetch: hit = %v, rows = %d, want cached single row", status.Hit, table.NumRows())
+	}
+
+	err = env.components.Update(ctx, comp.ID, domain.UpdateComponentOptions{
+		VisualisationID: opts.VisualisationID,
+		Name:            "renamed",
+		Queries:         opts.Queries,
+		Properties:      opts.Properties,
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if table, status := fetch(); status.Hit || table.NumRows() != 2 {
+		t.Fatalf("after update: hit = %v, rows = %d, want fresh two rows", status.Hit, table.NumRows())
+	}
+}
+
+func TestQueryService_DeduplicatesConcurrentQueries(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	class := &blockingClass{release: make(chan struct{})}
+	queries := service.NewQueryService(env.components, env.dataSources, nil, nil, datasource.NewRegistry(class))
+
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{Name: "slow", ClassID: "blocking"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries:         []domain.Query{{Name: "main", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	// streaming callers, as for Arrow, share the run of the others
+	const callers = 6
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				_, _, err := queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+				errs <- err
+				return
+			}
+			errs <- queries.StreamComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{}, 2,
+				func(domain.TableData, service.CacheStatus) error { return nil })
+		}()
+	}
+	for class.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// give the other callers time to join the running query
+	time.Sleep(50 * time.Millisecond)
+	close(class.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ComponentData: %v", err)
+		}
+	}
+	if n := class.calls.Load(); n != 1 {
+		t.Fatalf("class queried %d times, want 1", n)
+	}
+}
+
+func TestQueryJobService_BoundsRunningJobs(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	class := &blockingClass{release: make(chan struct{})}
+	queries := service.NewQueryService(env.components, env.dataSources, nil, nil, datasource.NewRegistry(class))
+	jobs := service.NewQueryJobService(repository.NewQueryJobRepository(env.db), queries, time.Hour)
+
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{Name: "slow", ClassID: "blocking"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	var submitted []domain.QueryJob
+	for i := 0; i < 6; i++ {
+		comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+			VisualisationID: "table",
+			Name:            domain.Name(fmt.Sprintf("c%d", i)),
+			Queries:         []domain.Query{{Name: "main", DataSourceID: ds.ID}},
+		})
+		if err != nil {
+			t.Fatalf("Create component: %v", err)
+		}
+		job, err := jobs.Submit(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		submitted = append(submitted, job)
+	}
+
+	// four jobs run at once; the others wait for them
+	for class.calls.Load() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := class.calls.Load(); n != 4 {
+		t.Fatalf("%d jobs running, want 4", n)
+	}
+	close(class.release)
+	for _, job := range submitted {
+		for {
+			got, err := jobs.Get(ctx, job.ComponentID, job.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got.Status == domain.QueryJobSucceeded {
+				break
+			}
+			if got.Status == domain.QueryJobFailed {
+				t.Fatalf("job failed: %s", got.Error)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestQueryService_RejectsInvalidCacheTTL(t *testing.T) {
+	env := newQueryEnv(t, "")
+	_, err := env.components.Create(context.Background(), domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Properties:      map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyCacheTTL: "soon"},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_TruncatesToBudget(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (1), (2), (3);`)
+	ctx := context.Background()
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: env.ds.ID,
+			Properties: map[domain.PropertyKey]domain.PropertyValue{
+				"sql":                   "SELECT n FROM t ORDER BY n",
+				service.PropertyMaxRows: "2",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+	if err != nil {
+		t.Fatalf("ComponentData: %v", err)
+	}
+	if !table.Truncated || table.NumRows() != 2 {
+		t.Fatalf("truncated = %v, rows = %d, want 2 truncated rows", table.Truncated, table.NumRows())
+	}
+}
+
+func TestQueryService_AppliesTransformations(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE sales (region TEXT, total INTEGER);
+		INSERT INTO sales VALUES ('eu', 10), ('us', 5), ('eu', 30), ('apac', 1);`)
+	ctx := context.Background()
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyTransformations: `[
+				{"type": "groupBy", "options": {"by": ["region"], "aggregations": [{"column": "total", "func": "sum", "as": "sum"}]}},
+				{"type": "sort", "options": {"keys": [{"column": "sum", "desc": true}]}}
+			]`,
+		},
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT region, total FROM sales"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// options narrow the transformed rows
+	table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{Limit: 2}, domain.VariableSelection{})
+	if err != nil {
+		t.Fatalf("ComponentData: %v", err)
+	}
+	if r := table.Columns[0].Values; len(r) != 2 || r[0] != "eu" || r[1] != "us" || table.Columns[1].Ints[0] != 40 {
+		t.Fatalf("table = %+v, want eu 40 then us", table.Columns)
+	}
+	if table.Total == nil || table.Total.Rows != 3 || table.NextCursor == "" {
+		t.Fatalf("total = %+v, cursor = %q, want 3 groups and a cursor", table.Total, table.NextCursor)
+	}
+
+	_, _, err = env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{Sort: []domain.SortKey{{Column: "total"}}}, domain.VariableSelection{})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("sort by a grouped away column: err = %v, want ErrBadRequest", err)
+	}
+
+	_, err = env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "bad",
+		Properties:      map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyTransformations: `[{"type": "explode"}]`},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("unknown transformation: err = %v, want ErrBadRequest", err)
+	}
+}
+
+func TestQueryService_MergesQueries(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE users (id INTEGER, name TEXT);
+		CREATE TABLE orders (user_id TEXT, total INTEGER);
+		INSERT INTO users VALUES (1, 'ann'), (2, 'bob');
+		INSERT INTO orders VALUES ('2', 7), ('3', 9);`)
+	ctx := context.Background()
+	queries := []domain.Query{
+		{Name: "users", DataSourceID: env.ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT id, name FROM users"}},
+		{Name: "orders", DataSourceID: env.ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT user_id AS id, total FROM orders"}},
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries:         queries,
+		Properties: map[domain.PropertyKey]domain.PropertyValue{
+			service.ComponentPropertyMerge:           `{"type": "join", "on": "id", "how": "left"}`,
+			service.ComponentPropertyTransformations: `[{"type": "sort", "options": {"keys": [{"column": "id"}]}}]`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{})
+	if err != nil {
+		t.Fatalf("ComponentData: %v", err)
+	}
+	if len(table.Columns) != 3 || table.NumRows() != 2 {
+		t.Fatalf("table = %+v, want id, name, total for both users", table.Columns)
+	}
+	if total := table.Columns[2]; !total.IsNull(0) || total.Ints[1] != 7 {
+		t.Fatalf("total = %+v, want null for ann and 7 for bob", total)
+	}
+
+	for name, opts := range map[string]domain.CreateComponentOptions{
+		"no merge": {VisualisationID: "table", Name: "c", Queries: queries},
+		"same names": {
+			VisualisationID: "table", Name: "c",
+			Queries:    []domain.Query{queries[0], queries[0]},
+			Properties: map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyMerge: `{"type": "union"}`},
+		},
+		"bad merge": {
+			VisualisationID: "table", Name: "c", Queries: queries,
+			Properties: map[domain.PropertyKey]domain.PropertyValue{service.ComponentPropertyMerge: `{"type": "join"}`},
+		},
+	} {
+		if _, err := env.components.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+}
+
+func TestQueryService_TimeoutAndCancel(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	class := &blockingClass{release: make(chan struct{})}
+	defer close(class.release)
+	queries := service.NewQueryService(env.components, env.dataSources, nil, nil, datasource.NewRegistry(class))
+
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{
+		Name:       "slow",
+		ClassID:    "blocking",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{service.PropertyTimeout: "20ms"},
+	})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	timed, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "timed",
+		Queries:         []domain.Query{{Name: "main", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	if _, _, err := queries.ComponentData(ctx, timed.ID, domain.DataOptions{}, domain.VariableSelection{}); !errors.Is(err, service.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	ds, err = env.dataSources.Create(ctx, domain.CreateDataSourceOptions{Name: "slower", ClassID: "blocking"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "cancelled",
+		Queries:         []domain.Query{{Name: "main", DataSourceID: ds.ID}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	runID := domain.NewQueryRunID(42)
+	runCtx, done, err := queries.StartRun(ctx, runID)
+	if err != nil {
+		t.Fatalf("StartRun: %v", err)
+	}
+	defer done()
+	if _, _, err := queries.StartRun(ctx, runID); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("duplicate run: expected ErrConflict, got %v", err)
+	}
+	go func() {
+		for class.calls.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		_ = queries.CancelRun(runID)
+	}()
+	if _, _, err := queries.ComponentData(runCtx, comp.ID, domain.DataOptions{}, domain.VariableSelection{}); !errors.Is(err, service.ErrCanceled) {
+		t.Fatalf("expected ErrCanceled, got %v", err)
+	}
+	if err := queries.CancelRun(domain.NewQueryRunID(7)); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("unknown run: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestQueryService_RejectsInvalidLimits(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	_, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{
+		Name:       "x",
+		ClassID:    "sqlite",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{service.PropertyTimeout: "-1s"},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("data source: expected ErrBadRequest, got %v", err)
+	}
+	_, err = env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "c",
+		Queries: []domain.Query{{
+			Name:       "main",
+			Properties: map[domain.PropertyKey]domain.PropertyValue{service.PropertyMaxBytes: "lots"},
+		}},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("component: expected ErrBadRequest, got %v", err)
+	}
+}
+
+// helpers
+type queryEnv struct {
+	components  *service.ComponentService
+	dataSources *service.DataSourceService
+	variables   *service.VariableService
+	queries     *service.QueryService
+	ds          domain.DataSource
+	path        string
+	db          *gorm.DB
+}
+
+// exec runs stmt against the data source database.
+func (e queryEnv) exec(t *testing.T, stmt string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", e.path)
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(stmt); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+}
+
+// blockingClass answers every query with one empty column once release
+// is closed, counting how often it was asked.
+type blockingClass struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (c *blockingClass) Descriptor() domain.DataSourceClass {
+	return domain.DataSourceClass{ID: "blocking", Name: "Blocking"}
+}
+
+// InterpolatedProperties lets variables reach the q property.
+func (c *blockingClass) InterpolatedProperties() []domain.PropertyKey {
+	return []domain.PropertyKey{"q"}
+}
+
+func (c *blockingClass) Query(ctx context.Context, _ domain.DataSource, _ domain.Query) (domain.TableData, error) {
+	c.calls.Add(1)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return domain.TableData{}, ctx.Err()
+	}
+	return domain.TableData{Columns: []domain.ColumnData{domain.NewColumnData("n", domain.PropertyTypeInteger)}}, nil
+}
+
+// newQueryEnv wires services over a fresh store and a sqlite data source
+// whose database is initialised with schema.
+func newQueryEnv(t *testing.T, schema string) queryEnv {
+	t.Helper()
+	dsn := fmt.Sprintf("file:svc-query-%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := storage.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "source.db")
+	if schema != "" {
+		src, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatalf("open source: %v", err)
+		}
+		defer src.Close()
+		if _, err := src.Exec(schema); err != nil {
+			t.Fatalf("schema: %v", err)
+		}
+	}
+
+	env := queryEnv{
+		components:  service.NewComponentService(repository.NewComponentRepository(db)),
+		dataSources: service.NewDataSourceService(repository.NewDataSourceRepository(db)),
+		variables:   service.NewVariableService(repository.NewVariableRepository(db)),
+		path:        path,
+		db:          db,
+	}
+	env.queries = service.NewQueryService(env.components, env.dataSources, env.variables, nil, datasource.NewRegistry(sqlsource.NewSQLite()))
+	env.ds, err = env.dataSources.Create(context.Background(), domain.CreateDataSourceOptions{
+		Name:       "source",
+		ClassID:    "sqlite",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"path": domain.PropertyValue(path)},
+	})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	return env
+}
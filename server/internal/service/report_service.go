@@ -0,0 +1,487 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/cron"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/report"
+	"github.com/smilu97/refana/internal/pkg/timerange"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+const (
+	// DefaultReportRuns is how many runs Runs returns by default.
+	DefaultReportRuns = 20
+	// reportTimeout bounds a run, its queries and deliveries included.
+	reportTimeout = 5 * time.Minute
+	// reportTick is how often Run looks for reports that are due.
+	reportTick = 10 * time.Second
+	// tableVisualisation is shown as a table in reports; the results of
+	// other visualisations are charted when they can be.
+	tableVisualisation domain.VisualisationID = "table"
+)
+
+// ReportService manages scheduled reports and runs them: on demand
+// through RunNow, and on their schedule once Run is started. Every run is
+// recorded with its outcome.
+type ReportService struct {
+	repo          *repository.ReportRepository
+	queries       *QueryService
+	contactPoints *ContactPointService
+
+	// mu guards inFlight, the reports being run.
+	mu       sync.Mutex
+	inFlight map[domain.ReportID]bool
+}
+
+func NewReportService(repo *repository.ReportRepository, queries *QueryService, contactPoints *ContactPointService) *ReportService {
+	return &ReportService{
+		repo:          repo,
+		queries:       queries,
+		contactPoints: contactPoints,
+		inFlight:      make(map[domain.ReportID]bool),
+	}
+}
+
+func (s *ReportService) Create(ctx context.Context, opts domain.CreateReportOptions) (domain.Report, error) {
+	schedule, loc, err := s.validate(ctx, &opts)
+	if err != nil {
+		return domain.Report{}, err
+	}
+	now := time.Now()
+	rep := reportOf(opts, schedule, loc, now)
+	rep.ID, rep.UpdatedAt = domain.NewReportID(now.UnixNano()), now
+	if err := s.repo.Create(ctx, rep); err != nil {
+		return domain.Report{}, err
+	}
+	return rep, nil
+}
+
+func (s *ReportService) Get(ctx context.Context, id domain.ReportID) (domain.Report, error) {
+	rep, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Report{}, ErrNotFound
+		}
+		return domain.Report{}, err
+	}
+	return rep, nil
+}
+
+func (s *ReportService) List(ctx context.Context) ([]domain.Report, error) {
+	return s.repo.List(ctx)
+}
+
+// Update replaces a report. Its next run follows the new schedule from
+// now, so that resuming a paused report does not run the ones it missed.
+func (s *ReportService) Update(ctx context.Context, id domain.ReportID, opts domain.UpdateReportOptions) (domain.Report, error) {
+	create := domain.CreateReportOptions(opts)
+	schedule, loc, err := s.validate(ctx, &create)
+	if err != nil {
+		return domain.Report{}, err
+	}
+	now := time.Now()
+	rep := reportOf(create, schedule, loc, now)
+	rep.ID, rep.UpdatedAt = id, now
+	if err := s.repo.Update(ctx, rep); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Report{}, ErrNotFound
+		}
+		return domain.Report{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a report and its runs.
+func (s *ReportService) Delete(ctx context.Context, id domain.ReportID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Runs returns the latest limit runs of a report, newest first, or
+// DefaultReportRuns of them when limit is not positive.
+func (s *ReportService) Runs(ctx context.Context, id domain.ReportID, limit int) ([]domain.ReportRun, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultReportRuns
+	}
+	return s.repo.Runs(ctx, id, limit)
+}
+
+// RunNow runs a report, paused or not, and returns the record of the run.
+// A report already running answers ErrConflict.
+func (s *ReportService) RunNow(ctx context.Context, id domain.ReportID) (domain.ReportRun, error) {
+	rep, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.ReportRun{}, err
+	}
+	if !s.claim(id) {
+		return domain.ReportRun{}, fmt.Errorf("%w: the report is running", ErrConflict)
+	}
+	defer s.release(id)
+	return s.run(ctx, rep, domain.ReportManual, time.Now())
+}
+
+// Run runs the reports that are due every reportTick until ctx is done.
+func (s *ReportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(reportTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				log.Printf("reports: %v", err)
+			}
+		}
+	}
+}
+
+// RunDue runs, concurrently, the reports that are not paused and whose
+// next run is at or before now, and waits for them. Each is first moved
+// to its next run after now, skipping the runs it missed while the server
+// was down. Reports still running from an earlier call are skipped.
+func (s *ReportService) RunDue(ctx context.Context, now time.Time) error {
+	reports, err := s.repo.Due(ctx, now)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, rep := range reports {
+		if !s.claim(rep.ID) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.release(rep.ID)
+			if err := s.advance(ctx, rep, now); err != nil {
+				log.Printf("report %s: %v", rep.ID, err)
+				return
+			}
+			if _, err := s.run(ctx, rep, domain.ReportScheduled, now); err != nil && ctx.Err() == nil {
+				log.Printf("report %s: %v", rep.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// advance moves the next run of rep past now.
+func (s *ReportService) advance(ctx context.Context, rep domain.Report, now time.Time) error {
+	schedule, err := cron.Parse(rep.Schedule)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(rep.TimeZone)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetNextRun(ctx, rep.ID, nextRun(schedule, loc, now))
+}
+
+func (s *ReportService) claim(id domain.ReportID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[id] {
+		return false
+	}
+	s.inFlight[id] = true
+	return true
+}
+
+func (s *ReportService) release(id domain.ReportID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.inFlight, id)
+}
+
+// run renders rep as of now, delivers it to each of its contact points
+// and records the outcome. The run fails when nothing could be rendered or
+// nobody could be reached, and is partial when some components or contact
+// points failed.
+func (s *ReportService) run(ctx context.Context, rep domain.Report, trigger domain.ReportTrigger, now time.Time) (domain.ReportRun, error) {
+	started := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	var problems []string
+	n, failed, err := s.render(runCtx, rep, now)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, failed...)
+
+	deliveries := []domain.ReportDelivery{}
+	if err == nil {
+		deliveries = make([]domain.ReportDelivery, len(rep.ContactPoints))
+		var wg sync.WaitGroup
+		for i, id := range rep.ContactPoints {
+			deliveries[i].ContactPointID = id
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.contactPoints.Notify(runCtx, id, n); err != nil {
+					deliveries[i].Error = err.Error()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	if ctx.Err() != nil {
+		// shutting down says nothing about the report
+		return domain.ReportRun{}, ctx.Err()
+	}
+
+	delivered := 0
+	for _, d := range deliveries {
+		if d.Error == "" {
+			delivered++
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("contact point %s: %s", d.ContactPointID, d.Error))
+	}
+	status := domain.ReportSucceeded
+	switch {
+	case err != nil || delivered == 0:
+		status = domain.ReportFailed
+	case len(problems) > 0:
+		status = domain.ReportPartial
+	}
+
+	run := domain.ReportRun{
+		ID:         domain.NewReportRunID(time.Now().UnixNano()),
+		ReportID:   rep.ID,
+		Trigger:    trigger,
+		Status:     status,
+		Error:      strings.Join(problems, "\n"),
+		Deliveries: deliveries,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+	}
+	if err := s.repo.CreateRun(ctx, run); err != nil {
+		return domain.ReportRun{}, err
+	}
+	if status != domain.ReportSucceeded {
+		log.Printf("report %s %s: %s", rep.ID, status, run.Error)
+	}
+	return run, nil
+}
+
+// render runs the components of rep over its time range at now and builds
+// the notification showing their results. Components that failed are
+// reported in the notification and returned as problems; render fails
+// when all of them did.
+func (s *ReportService) render(ctx context.Context, rep domain.Report, now time.Time) (domain.Notification, []string, error) {
+	loc, err := time.LoadLocation(rep.TimeZone)
+	if err != nil {
+		return domain.Notification{}, nil, err
+	}
+	sel := domain.VariableSelection{Page: rep.Page, Values: rep.Variables}
+	doc := report.Document{Title: string(rep.Name)}
+	if rep.From != "" {
+		rng, err := timerange.Parse(rep.From, rep.To, now, loc)
+		if err != nil {
+			return domain.Notification{}, nil, err
+		}
+		sel.Range = &rng
+		doc.Subtitle = rng.From.In(loc).Format("2006-01-02 15:04") + " – " + rng.To.In(loc).Format("2006-01-02 15:04 MST")
+	}
+
+	var (
+		attachments []domain.Attachment
+		problems    []string
+	)
+	for i, id := range rep.Components {
+		section, files, err := s.renderComponent(ctx, rep.Format, id, sel, i+1)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("component %s: %v", id, err))
+			section.Error = err.Error()
+		}
+		doc.Sections = append(doc.Sections, section)
+		attachments = append(attachments, files...)
+	}
+	if len(problems) == len(rep.Components) {
+		return domain.Notification{}, nil, errors.New(strings.Join(problems, "\n"))
+	}
+	html, err := report.HTML(doc)
+	if err != nil {
+		return domain.Notification{}, nil, err
+	}
+	return domain.Notification{
+		Title:       string(rep.Name),
+		Message:     report.Text(doc),
+		HTML:        string(html),
+		Attachments: attachments,
+	}, problems, nil
+}
+
+// renderComponent runs the component id and shows its result as a chart
+// or as a table in format, returning the files the section refers to.
+// Files are numbered by the position of the component, n, to keep their
+// names apart.
+func (s *ReportService) renderComponent(
+	ctx context.Context,
+	format domain.ReportFormat,
+	id domain.ComponentID,
+	sel domain.VariableSelection,
+	n int,
+) (report.Section, []domain.Attachment, error) {
+	section := report.Section{Name: id.String()}
+	comp, err := s.queries.components.Get(ctx, id)
+	if err != nil {
+		return section, nil, err
+	}
+	section.Name = string(comp.Name)
+	table, _, err := s.queries.ComponentData(ctx, id, domain.DataOptions{}, sel)
+	if err != nil {
+		return section, nil, err
+	}
+	section.Table = &table
+	base := fmt.Sprintf("%d-%s", n, fileName(comp.Name))
+
+	if comp.VisualisationID != tableVisualisation {
+		chart, err := report.RenderChart(table, report.DefaultChartWidth, report.DefaultChartHeight)
+		switch {
+		case err == nil:
+			name := base + ".png"
+			section.Chart, section.ChartSrc = &chart, "cid:"+name
+			return section, []domain.Attachment{{Name: name, ContentType: "image/png", Data: chart.PNG, Inline: true}}, nil
+		case !errors.Is(err, report.ErrNotChartable):
+			return section, nil, err
+		}
+	}
+	if format != domain.ReportCSV {
+		return section, nil, nil
+	}
+	data, err := report.CSV(table)
+	if err != nil {
+		return section, nil, err
+	}
+	section.Attachment = base + ".csv"
+	return section, []domain.Attachment{{Name: section.Attachment, ContentType: "text/csv", Data: data}}, nil
+}
+
+// fileName makes name fit for a file name: lower case letters and digits
+// separated by dashes.
+func fileName(name domain.Name) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return "component"
+	}
+	return b.String()
+}
+
+func reportOf(opts domain.CreateReportOptions, schedule cron.Schedule, loc *time.Location, now time.Time) domain.Report {
+	return domain.Report{
+		Name:          opts.Name,
+		Schedule:      opts.Schedule,
+		TimeZone:      opts.TimeZone,
+		Components:    opts.Components,
+		Page:          opts.Page,
+		Variables:     opts.Variables,
+		From:          opts.From,
+		To:            opts.To,
+		Format:        opts.Format,
+		ContactPoints: opts.ContactPoints,
+		Paused:        opts.Paused,
+		NextRunAt:     nextRun(schedule, loc, now),
+	}
+}
+
+// nextRun is the first time after now that schedule fires in loc.
+func nextRun(schedule cron.Schedule, loc *time.Location, now time.Time) *time.Time {
+	next := schedule.Next(now.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// validate checks a report and fills in the defaults of its time zone,
+// format and time range, returning its parsed schedule and time zone.
+func (s *ReportService) validate(ctx context.Context, opts *domain.CreateReportOptions) (cron.Schedule, *time.Location, error) {
+	if err := domain.Validate(opts); err != nil {
+		return cron.Schedule{}, nil, ErrBadRequest
+	}
+	schedule, err := cron.Parse(opts.Schedule)
+	if err != nil {
+		return cron.Schedule{}, nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	if opts.TimeZone == "" {
+		opts.TimeZone = "UTC"
+	}
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return cron.Schedule{}, nil, fmt.Errorf("%w: unknown time zone %q", ErrBadRequest, opts.TimeZone)
+	}
+	if schedule.Next(time.Now().In(loc)).IsZero() {
+		return cron.Schedule{}, nil, fmt.Errorf("%w: schedule %q never fires", ErrBadRequest, opts.Schedule)
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = domain.ReportHTML
+	case domain.ReportHTML, domain.ReportCSV:
+	default:
+		return cron.Schedule{}, nil, fmt.Errorf("%w: format must be html or csv", ErrBadRequest)
+	}
+	if opts.From == "" && opts.To != "" {
+		return cron.Schedule{}, nil, fmt.Errorf("%w: a time range needs from", ErrBadRequest)
+	}
+	if opts.From != "" {
+		if opts.To == "" {
+			opts.To = "now"
+		}
+		if _, err := timerange.Parse(opts.From, opts.To, time.Now(), loc); err != nil {
+			return cron.Schedule{}, nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+		}
+	}
+	for _, id := range opts.Components {
+		if _, err := s.queries.components.Get(ctx, id); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return cron.Schedule{}, nil, fmt.Errorf("%w: unknown component %s", ErrBadRequest, id)
+			}
+			return cron.Schedule{}, nil, err
+		}
+	}
+	for _, id := range opts.ContactPoints {
+		if _, err := s.contactPoints.Get(ctx, id); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return cron.Schedule{}, nil, fmt.Errorf("%w: unknown contact point %s", ErrBadRequest, id)
+			}
+			return cron.Schedule{}, nil, err
+		}
+	}
+	return schedule, loc, nil
+}
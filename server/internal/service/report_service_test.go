@@ -0,0 +1,294 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/notifier"
+	"github.com/smilu97/refana/internal/notifier/webhook"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+// reportEnv has a sales database with components over it and a webhook
+// receiving reports.
+type reportEnv struct {
+	queryEnv
+	contactPoints *service.ContactPointService
+	reports       *service.ReportService
+	byRegion      domain.ComponentID
+	daily         domain.ComponentID
+	broken        domain.ComponentID
+	inbox         domain.ContactPointID
+	received      <-chan domain.Notification
+}
+
+func newReportEnv(t *testing.T) reportEnv {
+	t.Helper()
+	env := reportEnv{queryEnv: newQueryEnv(t, `CREATE TABLE orders (day TEXT, region TEXT, total REAL);
+		INSERT INTO orders VALUES ('2024-03-11', 'eu', 10), ('2024-03-11', 'us', 20), ('2024-03-12', 'eu', 5),
+			('2024-03-12', 'us', 7.5), ('2024-03-13', 'us', 30);`)}
+	env.contactPoints = service.NewContactPointService(
+		repository.NewContactPointRepository(env.db),
+		notifier.NewRegistry(webhook.New()),
+		notifier.Backoff{Attempts: 1},
+	)
+	env.reports = service.NewReportService(repository.NewReportRepository(env.db), env.queries, env.contactPoints)
+	env.inbox, env.received = webhookReceiver(t, env.contactPoints, "inbox")
+
+	ctx := context.Background()
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name:    "region",
+		Type:    domain.VariableCustom,
+		Page:    "sales",
+		Options: []domain.PropertyValue{"eu", "us"},
+		Value:   "eu",
+	}); err != nil {
+		t.Fatalf("variable: %v", err)
+	}
+	for dst, c := range map[*domain.ComponentID]struct {
+		visualisation domain.VisualisationID
+		name          domain.Name
+		sql           domain.PropertyValue
+	}{
+		&env.byRegion: {"table", "Orders by region", "SELECT region, COUNT(*) AS orders, SUM(total) AS revenue FROM orders WHERE region = $region GROUP BY region"},
+		&env.daily:    {"timeseries", "Daily revenue", "SELECT day, SUM(total) AS revenue FROM orders GROUP BY day ORDER BY day"},
+		&env.broken:   {"table", "Refunds", "SELECT * FROM refunds"},
+	} {
+		comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+			VisualisationID: c.visualisation,
+			Name:            c.name,
+			Queries: []domain.Query{{
+				DataSourceID: env.ds.ID,
+				Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": c.sql},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("component %s: %v", c.name, err)
+		}
+		*dst = comp.ID
+	}
+	return env
+}
+
+func (e reportEnv) expectReport(t *testing.T) domain.Notification {
+	t.Helper()
+	select {
+	case n := <-e.received:
+		return n
+	default:
+		t.Fatal("no report was delivered")
+	}
+	return domain.Notification{}
+}
+
+func TestReportService_RunNow(t *testing.T) {
+	env := newReportEnv(t)
+	ctx := context.Background()
+	down, err := env.contactPoints.Create(ctx, domain.CreateContactPointOptions{
+		Name:     "down",
+		Type:     "webhook",
+		Settings: map[domain.PropertyKey]domain.PropertyValue{"url": "http://127.0.0.1:1/hook"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := env.reports.Create(ctx, domain.CreateReportOptions{
+		Name:          "Weekly sales",
+		Schedule:      "0 8 * * MON",
+		Components:    []domain.ComponentID{env.byRegion, env.daily, env.broken},
+		Page:          "sales",
+		From:          "now-7d",
+		ContactPoints: []domain.ContactPointID{env.inbox, down.ID},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if rep.TimeZone != "UTC" || rep.Format != domain.ReportHTML || rep.To != "now" || rep.NextRunAt == nil ||
+		rep.NextRunAt.Weekday() != time.Monday || rep.NextRunAt.Hour() != 8 {
+		t.Fatalf("created = %+v", rep)
+	}
+
+	// A failed component and an unreachable contact point leave the run
+	// partial.
+	run, err := env.reports.RunNow(ctx, rep.ID)
+	if err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if run.Status != domain.ReportPartial || run.Trigger != domain.ReportManual || len(run.Deliveries) != 2 ||
+		run.Deliveries[0].Error != "" || run.Deliveries[1].Error == "" {
+		t.Fatalf("run = %+v", run)
+	}
+	if !strings.Contains(run.Error, "component "+env.broken.String()) || !strings.Contains(run.Error, "contact point "+down.ID.String()) {
+		t.Fatalf("run error = %q", run.Error)
+	}
+	n := env.expectReport(t)
+	if n.Title != "Weekly sales" || !strings.HasPrefix(n.Message, "Weekly sales\n") ||
+		!strings.Contains(n.Message, "- Orders by region: 1 rows\n- Daily revenue: chart\n- Refunds: failed: ") {
+		t.Fatalf("notification = %q\n%s", n.Title, n.Message)
+	}
+	// The page variable picks eu.
+	for _, want := range []string{">Orders by region</h2>", ">eu</td>", `src="cid:2-daily-revenue.png"`, "Failed: "} {
+		if !strings.Contains(n.HTML, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+	if len(n.Attachments) != 1 || n.Attachments[0].Name != "2-daily-revenue.png" || !n.Attachments[0].Inline {
+		t.Fatalf("attachments = %+v", n.Attachments)
+	}
+	if _, err := png.Decode(bytes.NewReader(n.Attachments[0].Data)); err != nil {
+		t.Fatalf("chart: %v", err)
+	}
+
+	// As CSV, with a variable of its own, to the inbox alone.
+	rep, err = env.reports.Update(ctx, rep.ID, domain.UpdateReportOptions{
+		Name:          "Weekly sales",
+		Schedule:      "0 8 * * MON",
+		Components:    []domain.ComponentID{env.byRegion, env.daily},
+		Page:          "sales",
+		Variables:     map[domain.Name][]domain.PropertyValue{"region": {"us"}},
+		Format:        domain.ReportCSV,
+		ContactPoints: []domain.ContactPointID{env.inbox},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	run, err = env.reports.RunNow(ctx, rep.ID)
+	if err != nil || run.Status != domain.ReportSucceeded || run.Error != "" {
+		t.Fatalf("RunNow = %+v, %v", run, err)
+	}
+	n = env.expectReport(t)
+	if len(n.Attachments) != 2 || n.Attachments[0].Name != "1-orders-by-region.csv" || n.Attachments[0].Inline {
+		t.Fatalf("attachments = %+v", n.Attachments)
+	}
+	if got := string(n.Attachments[0].Data); got != "region,orders,revenue\nus,3,57.5\n" {
+		t.Fatalf("csv = %q", got)
+	}
+	if !strings.Contains(n.HTML, "1 rows, attached as 1-orders-by-region.csv.") {
+		t.Fatalf("HTML = %s", n.HTML)
+	}
+
+	// Nothing is delivered when every component fails.
+	rep, err = env.reports.Update(ctx, rep.ID, domain.UpdateReportOptions{
+		Name:          "Refunds",
+		Schedule:      "@daily",
+		Components:    []domain.ComponentID{env.broken},
+		ContactPoints: []domain.ContactPointID{env.inbox},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	run, err = env.reports.RunNow(ctx, rep.ID)
+	if err != nil || run.Status != domain.ReportFailed || len(run.Deliveries) != 0 || run.Error == "" {
+		t.Fatalf("RunNow = %+v, %v", run, err)
+	}
+	if len(env.received) != 0 {
+		t.Fatal("a failed report was delivered")
+	}
+
+	runs, err := env.reports.Runs(ctx, rep.ID, 0)
+	if err != nil || len(runs) != 3 || runs[0].Status != domain.ReportFailed || runs[2].Status != domain.ReportPartial {
+		t.Fatalf("Runs = %+v, %v", runs, err)
+	}
+	if err := env.reports.Delete(ctx, rep.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := env.reports.Runs(ctx, rep.ID, 0); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("Runs after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReportService_Schedule(t *testing.T) {
+	env := newReportEnv(t)
+	ctx := context.Background()
+	opts := domain.CreateReportOptions{
+		Name:          "Daily sales",
+		Schedule:      "30 8 * * *",
+		TimeZone:      "Asia/Seoul",
+		Components:    []domain.ComponentID{env.daily},
+		ContactPoints: []domain.ContactPointID{env.inbox},
+	}
+	rep, err := env.reports.Create(ctx, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "time zone") {
+			t.Skipf("no tzdata: %v", err)
+		}
+		t.Fatalf("Create: %v", err)
+	}
+	opts.Name, opts.Paused = "Paused sales", true
+	if _, err := env.reports.Create(ctx, opts); err != nil {
+		t.Fatalf("Create paused: %v", err)
+	}
+	next := *rep.NextRunAt
+	seoul, _ := time.LoadLocation("Asia/Seoul")
+	if local := next.In(seoul); local.Hour() != 8 || local.Minute() != 30 {
+		t.Fatalf("next run = %s", local)
+	}
+
+	if err := env.reports.RunDue(ctx, next.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.received) != 0 {
+		t.Fatal("a report ran before it was due")
+	}
+	// A run late by a few days runs once and moves on to the next day.
+	late := next.Add(72*time.Hour + time.Hour)
+	if err := env.reports.RunDue(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+	env.expectReport(t)
+	if err := env.reports.RunDue(ctx, late); err != nil {
+		t.Fatal(err)
+	}
+	if len(env.received) != 0 {
+		t.Fatal("the report ran twice")
+	}
+	rep, _ = env.reports.Get(ctx, rep.ID)
+	if want := next.Add(96 * time.Hour); rep.NextRunAt == nil || !rep.NextRunAt.Equal(want) {
+		t.Fatalf("next run = %v, want %s", rep.NextRunAt, want)
+	}
+	runs, err := env.reports.Runs(ctx, rep.ID, 0)
+	if err != nil || len(runs) != 1 || runs[0].Trigger != domain.ReportScheduled || runs[0].Status != domain.ReportSucceeded {
+		t.Fatalf("Runs = %+v, %v", runs, err)
+	}
+}
+
+func TestReportService_Validation(t *testing.T) {
+	env := newReportEnv(t)
+	valid := func(edit func(*domain.CreateReportOptions)) domain.CreateReportOptions {
+		opts := domain.CreateReportOptions{
+			Name:          "r",
+			Schedule:      "@weekly",
+			Components:    []domain.ComponentID{env.byRegion},
+			ContactPoints: []domain.ContactPointID{env.inbox},
+		}
+		edit(&opts)
+		return opts
+	}
+	for name, opts := range map[string]domain.CreateReportOptions{
+		"no name":           valid(func(o *domain.CreateReportOptions) { o.Name = "" }),
+		"bad schedule":      valid(func(o *domain.CreateReportOptions) { o.Schedule = "every monday" }),
+		"never fires":       valid(func(o *domain.CreateReportOptions) { o.Schedule = "0 0 31 2 *" }),
+		"bad time zone":     valid(func(o *domain.CreateReportOptions) { o.TimeZone = "Mars/Olympus" }),
+		"bad format":        valid(func(o *domain.CreateReportOptions) { o.Format = "pdf" }),
+		"bad range":         valid(func(o *domain.CreateReportOptions) { o.From = "yesterday" }),
+		"to without from":   valid(func(o *domain.CreateReportOptions) { o.To = "now" }),
+		"no components":     valid(func(o *domain.CreateReportOptions) { o.Components = nil }),
+		"unknown component": valid(func(o *domain.CreateReportOptions) { o.Components = []domain.ComponentID{domain.NewComponentID(1)} }),
+		"no contact points": valid(func(o *domain.CreateReportOptions) { o.ContactPoints = nil }),
+		"unknown contact point": valid(func(o *domain.CreateReportOptions) {
+			o.ContactPoints = []domain.ContactPointID{domain.NewContactPointID(1)}
+		}),
+	} {
+		if _, err := env.reports.Create(context.Background(), opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+// CacheStatus tells how component data was served.
+type CacheStatus struct {
+	Hit bool
+	// Age is how long ago the served result was fetched.
+	Age time.Duration
+}
+
+// resultCache keeps query results for a component-defined TTL and collapses
+// concurrent identical loads into one. Keys include the UpdatedAt of the
+// component and its data source, so updating either stops its old entries
+// from being served; the cache also watches both and evicts them then.
+type resultCache struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	flights   map[string]*flight
+	lastSweep time.Time
+}
+
+// cacheRefs are the component and data sources a result was loaded from,
+// whose updates evict it.
+type cacheRefs struct {
+	component   domain.ComponentID
+	dataSources []domain.DataSourceID
+}
+
+func (r cacheRefs) has(id domain.DataSourceID) bool {
+	return slices.Contains(r.dataSources, id)
+}
+
+// flightWindow bounds the batches a flight whose result is not cached
+// holds for its slowest caller; the load waits while it is full.
+const flightWindow = 16
+
+// flight is a load shared by every caller asking for the same key while it
+// runs. A load whose result is cached keeps its batches until it ends, so
+// that callers joining late receive them all. Otherwise batches are
+// dropped once every caller has had them, and callers may join only until
+// the first one is dropped; later ones start a load of their own. It is
+// cancelled once all of its callers have given up.
+type flight struct {
+	refs cacheRefs
+	keep bool
+	// batches[i] is the batch numbered base+i.
+	batches []domain.TableData
+	base    int
+	// more is closed, and replaced, when a batch arrives or the load ends;
+	// space when batches are dropped.
+	more  chan struct{}
+	space chan struct{}
+	done  bool
+	err   error
+	// cursors point at the number of the next batch of each caller.
+	cursors map[*int]struct{}
+	cancel  context.CancelFunc
+	// stale is set when the component or a data source of the load was
+	// updated while it ran; its result is not stored.
+	stale bool
+}
+
+type cacheEntry struct {
+	refs      cacheRefs
+	table     domain.TableData
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+		flights: make(map[string]*flight),
+	}
+}
+
+// cacheKey identifies a result by everything that can change it.
+func cacheKey(cq componentQuery, opts domain.DataOptions) string {
+	type queryKey struct {
+		DataSource        domain.DataSourceID
+		DataSourceVersion time.Time
+		Properties        map[domain.PropertyKey]domain.PropertyValue
+		Variables         map[domain.Name]domain.VariableValue
+		Range             *domain.TimeRange
+	}
+	queries := make([]queryKey, 0, 1+len(cq.merged))
+	for _, part := range append([]componentQuery{cq}, cq.merged...) {
+		q := part.comp.Query
+		queries = append(queries, queryKey{part.ds.ID, part.ds.UpdatedAt, q.Properties, q.Variables, q.Range})
+	}
+	raw, _ := json.Marshal(struct {
+		Component        domain.ComponentID
+		ComponentVersion time.Time
+		Queries          []queryKey
+		Transformers     map[domain.Name]time.Time
+		Options          domain.DataOptions
+	}{cq.comp.ID, cq.comp.UpdatedAt, queries, cq.transformers, opts})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// variableCacheKey identifies the result of the query of a query variable,
+// prepared as cq, by the version of the variable and of its data source,
+// the values of the variables it depends on and its time range.
+func variableCacheKey(v domain.Variable, cq componentQuery, deps map[domain.Name]domain.VariableValue) string {
+	raw, _ := json.Marshal(struct {
+		Variable          domain.VariableID
+		VariableVersion   time.Time
+		DataSource        domain.DataSourceID
+		DataSourceVersion time.Time
+		Dependencies      map[domain.Name]domain.VariableValue
+		Range             *domain.TimeRange
+	}{v.ID, v.UpdatedAt, cq.ds.ID, cq.ds.UpdatedAt, deps, cq.comp.Query.Range})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// refsOf returns the component and data sources the result of cq depends on.
+func refsOf(cq componentQuery) cacheRefs {
+	refs := cacheRefs{component: cq.comp.ID}
+	for _, part := range append([]componentQuery{cq}, cq.merged...) {
+		if !refs.has(part.ds.ID) {
+			refs.dataSources = append(refs.dataSources, part.ds.ID)
+		}
+	}
+	return refs
+}
+
+// get is stream for callers that want the whole table at once.
+func (c *resultCache) get(
+	ctx context.Context,
+	key string,
+	refs cacheRefs,
+	ttl time.Duration,
+	load func(context.Context, func(domain.TableData) error) error,
+) (domain.TableData, CacheStatus, error) {
+	var batches []domain.TableData
+	var status CacheStatus
+	err := c.stream(ctx, key, refs, ttl, load, func(batch domain.TableData, s CacheStatus) error {
+		batches, status = append(batches, batch), s
+		return nil
+	})
+	if err != nil {
+		return domain.TableData{}, CacheStatus{}, err
+	}
+	table, err := concat(batches)
+	return table, status, err
+}
+
+// concat is tableops.Concat sparing the copy of a single batch.
+func concat(batches []domain.TableData) (domain.TableData, error) {
+	if len(batches) == 1 {
+		return batches[0], nil
+	}
+	return tableops.Concat(batches)
+}
+
+// stream emits the cached table for key as one batch, or the batches of a
+// load run once for all concurrent callers, caching their concatenation
+// for ttl. A non-positive ttl only deduplicates. The shared load outlives
+// the caller that started it and is cancelled only when every caller has
+// gone; a caller whose emit fails leaves it.
+func (c *resultCache) stream(
+	ctx context.Context,
+	key string,
+	refs cacheRefs,
+	ttl time.Duration,
+	load func(context.Context, func(domain.TableData) error) error,
+	emit func(domain.TableData, CacheStatus) error,
+) error {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && c.now().Before(e.expiresAt) {
+		c.mu.Unlock()
+		return emit(e.table, CacheStatus{Hit: true, Age: c.now().Sub(e.fetchedAt)})
+	}
+	f, ok := c.flights[key]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{
+			refs:    refs,
+			keep:    ttl > 0,
+			more:    make(chan struct{}),
+			space:   make(chan struct{}),
+			cursors: make(map[*int]struct{}),
+			cancel:  cancel,
+		}
+		c.flights[key] = f
+		go c.run(loadCtx, key, ttl, f, func(emit func(domain.TableData) error) error { return load(loadCtx, emit) })
+	}
+	next := f.base
+	f.cursors[&next] = struct{}{}
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		batches, more, done, err := f.batches[next-f.base:], f.more, f.done, f.err
+		c.mu.Unlock()
+		for _, batch := range batches {
+			if err := emit(batch, CacheStatus{}); err != nil {
+				c.leave(key, f, &next)
+				return err
+			}
+			c.mu.Lock()
+			next++
+			c.trim(key, f)
+			c.mu.Unlock()
+		}
+		switch {
+		case len(batches) > 0:
+		case done:
+			c.leave(key, f, &next)
+			return err
+		default:
+			select {
+			case <-more:
+			case <-ctx.Done():
+				c.leave(key, f, &next)
+				return contextError(ctx)
+			}
+		}
+	}
+}
+
+// leave drops the caller of f at cursor, cancelling f when it was the last
+// one.
+func (c *resultCache) leave(key string, f *flight, cursor *int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(f.cursors, cursor)
+	if len(f.cursors) == 0 {
+		f.cancel()
+		if c.flights[key] == f {
+			delete(c.flights, key)
+		}
+		return
+	}
+	c.trim(key, f)
+}
+
+// trim drops the batches of f every caller has had, unless they are kept
+// for the cache. It must be called with c.mu held.
+func (c *resultCache) trim(key string, f *flight) {
+	if f.keep || len(f.cursors) == 0 {
+		return
+	}
+	low := -1
+	for cursor := range f.cursors {
+		if low < 0 || *cursor < low {
+			low = *cursor
+		}
+	}
+	if low <= f.base {
+		return
+	}
+	clear(f.batches[:low-f.base])
+	f.batches = f.batches[low-f.base:]
+	f.base = low
+	// callers joining now would miss the dropped batches
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	close(f.space)
+	f.space = make(chan struct{})
+}
+
+func (c *resultCache) run(ctx context.Context, key string, ttl time.Duration, f *flight, load func(func(domain.TableData) error) error) {
+	defer f.cancel()
+	publish := func() {
+		close(f.more)
+		f.more = make(chan struct{})
+	}
+	err := load(func(batch domain.TableData) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for !f.keep && len(f.batches) >= flightWindow {
+			space := f.space
+			c.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				c.mu.Lock()
+				return ctx.Err()
+			}
+			c.mu.Lock()
+		}
+		f.batches = append(f.batches, batch)
+		publish()
+		return nil
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	if err == nil && ttl > 0 && !f.stale {
+		if table, err := concat(f.batches); err == nil {
+			c.store(key, f.refs, table, ttl)
+		}
+	}
+	f.done, f.err = true, err
+	publish()
+}
+
+// sweepInterval bounds how often store scans for expired entries.
+const sweepInterval = time.Minute
+
+// store must be called with c.mu held.
+func (c *resultCache) store(key string, refs cacheRefs, table domain.TableData, ttl time.Duration) {
+	now := c.now()
+	c.entries[key] = cacheEntry{refs: refs, table: table, fetchedAt: now, expiresAt: now.Add(ttl)}
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// evict drops the entries loaded from what stale matches, and keeps the
+// loads from it that are running from being stored.
+func (c *resultCache) evict(stale func(cacheRefs) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if stale(e.refs) {
+			delete(c.entries, k)
+		}
+	}
+	for _, f := range c.flights {
+		if stale(f.refs) {
+			f.stale = true
+		}
+	}
+}
+
+func (c *resultCache) DataSourceUpdated(ds domain.DataSource) {
+	c.evict(func(r cacheRefs) bool { return r.has(ds.ID) })
+}
+
+func (c *resultCache) DataSourceDeleted(id domain.DataSourceID) {
+	c.evict(func(r cacheRefs) bool { return r.has(id) })
+}
+
+func (c *resultCache) ComponentUpdated(comp domain.Component) {
+	c.evict(func(r cacheRefs) bool { return r.component == comp.ID })
+}
+
+func (c *resultCache) ComponentDeleted(id domain.ComponentID) {
+	c.evict(func(r cacheRefs) bool { return r.component == id })
+}
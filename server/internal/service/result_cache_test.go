@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+func TestResultCacheEvictsOnUpdate(t *testing.T) {
+	c := newResultCache()
+	ctx := context.Background()
+	table := domain.TableData{Columns: []domain.ColumnData{domain.NewColumnData("n", domain.PropertyTypeInteger)}}
+	load := func(_ context.Context, emit func(domain.TableData) error) error { return emit(table) }
+	put := func(key string, comp int64, ds ...int64) {
+		refs := cacheRefs{component: domain.NewComponentID(comp)}
+		for _, id := range ds {
+			refs.dataSources = append(refs.dataSources, domain.NewDataSourceID(id))
+		}
+		if _, _, err := c.get(ctx, key, refs, time.Hour, load); err != nil {
+			t.Fatalf("get %s: %v", key, err)
+		}
+	}
+	cached := func(key string) bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, ok := c.entries[key]
+		return ok
+	}
+
+	put("a", 1, 10)
+	put("b", 2, 10, 20)
+	put("c", 3, 30)
+	c.DataSourceUpdated(domain.DataSource{ID: domain.NewDataSourceID(20)})
+	if !cached("a") || cached("b") || !cached("c") {
+		t.Fatalf("after data source 20 update: a %t, b %t, c %t", cached("a"), cached("b"), cached("c"))
+	}
+	c.ComponentUpdated(domain.Component{ID: domain.NewComponentID(1)})
+	c.DataSourceDeleted(domain.NewDataSourceID(30))
+	if cached("a") || cached("c") {
+		t.Fatalf("entries left after updates: a %t, c %t", cached("a"), cached("c"))
+	}
+
+	// a load running across an update is not stored
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, _, err := c.get(ctx, "d", cacheRefs{component: domain.NewComponentID(4)}, time.Hour,
+			func(_ context.Context, emit func(domain.TableData) error) error {
+				<-release
+				return emit(table)
+			})
+		done <- err
+	}()
+	for {
+		c.mu.Lock()
+		_, running := c.flights["d"]
+		c.mu.Unlock()
+		if running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.ComponentDeleted(domain.NewComponentID(4))
+	close(release)
+	if err := <-done; err != nil || cached("d") {
+		t.Fatalf("stale load: err %v, cached %t", err, cached("d"))
+	}
+}
+
+func TestResultCacheBoundsUncachedFlights(t *testing.T) {
+	c := newResultCache()
+	ctx := context.Background()
+	table := domain.TableData{Columns: []domain.ColumnData{domain.NewColumnData("n", domain.PropertyTypeInteger)}}
+	const batches = 4 * flightWindow
+	var loads atomic.Int32
+	load := func(_ context.Context, emit func(domain.TableData) error) error {
+		loads.Add(1)
+		for i := 0; i < batches; i++ {
+			if err := emit(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	held := func(f *flight) int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(f.batches)
+	}
+
+	// a slow caller holds the load back instead of letting batches pile up
+	step := make(chan struct{})
+	slow := make(chan int)
+	go func() {
+		n := 0
+		err := c.stream(ctx, "k", cacheRefs{}, 0, load, func(domain.TableData, CacheStatus) error {
+			<-step
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Errorf("slow caller: %v", err)
+		}
+		slow <- n
+	}()
+	var f *flight
+	for f == nil {
+		c.mu.Lock()
+		f = c.flights["k"]
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	for held(f) < flightWindow {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := held(f); n != flightWindow {
+		t.Fatalf("flight holds %d batches, want %d", n, flightWindow)
+	}
+
+	// once a batch is dropped, callers can no longer join from the start
+	step <- struct{}{}
+	for joinable := true; joinable; {
+		c.mu.Lock()
+		joinable = c.flights["k"] == f
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	n := 0
+	if err := c.stream(ctx, "k", cacheRefs{}, 0, load, func(domain.TableData, CacheStatus) error {
+		n++
+		return nil
+	}); err != nil || n != batches {
+		t.Fatalf("late caller: %d batches, %v; want %d", n, err, batches)
+	}
+	close(step)
+	if n := <-slow; n != batches {
+		t.Fatalf("slow caller had %d batches, want %d", n, batches)
+	}
+	if n := loads.Load(); n != 2 {
+		t.Fatalf("loaded %d times, want 2", n)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+)
+
+// DefaultSchemaTTL is how long an introspected schema is served before the
+// data source is asked again.
+const DefaultSchemaTTL = 10 * time.Minute
+
+func schemaTTL(props map[domain.PropertyKey]domain.PropertyValue) (time.Duration, error) {
+	raw, ok := props[DataSourcePropertySchemaTTL]
+	if !ok || raw == "" {
+		return DefaultSchemaTTL, nil
+	}
+	ttl, err := time.ParseDuration(string(raw))
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("%w: %s: must be a non-negative duration such as 10m", ErrBadRequest, DataSourcePropertySchemaTTL)
+	}
+	return ttl, nil
+}
+
+// schemaCache keeps the last introspected schema of each data source. An
+// entry is only served for the data source version it was fetched from.
+type schemaCache struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[domain.DataSourceID]schemaEntry
+}
+
+type schemaEntry struct {
+	version   time.Time
+	schema    domain.DatabaseSchema
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{now: time.Now, entries: make(map[domain.DataSourceID]schemaEntry)}
+}
+
+func (c *schemaCache) get(ds domain.DataSource) (domain.DatabaseSchema, CacheStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ds.ID]
+	if !ok || !e.version.Equal(ds.UpdatedAt) || !c.now().Before(e.expiresAt) {
+		return domain.DatabaseSchema{}, CacheStatus{}, false
+	}
+	return e.schema, CacheStatus{Hit: true, Age: c.now().Sub(e.fetchedAt)}, true
+}
+
+func (c *schemaCache) store(ds domain.DataSource, schema domain.DatabaseSchema, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.entries[ds.ID] = schemaEntry{version: ds.UpdatedAt, schema: schema, fetchedAt: now, expiresAt: now.Add(ttl)}
+}
+
+// DataSourceUpdated keeps the entry; it no longer matches the version.
+func (c *schemaCache) DataSourceUpdated(domain.DataSource) {}
+
+func (c *schemaCache) DataSourceDeleted(id domain.DataSourceID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// DataSourceSchema returns the tables and columns of a data source, cached
+// for its schemaCacheTTL property. refresh skips the cache and replaces
+// the cached schema.
+func (s *QueryService) DataSourceSchema(ctx context.Context, id domain.DataSourceID, refresh bool) (domain.DatabaseSchema, CacheStatus, error) {
+	ds, err := s.dataSources.Get(ctx, id)
+	if err != nil {
+		return domain.DatabaseSchema{}, CacheStatus{}, err
+	}
+	ttl, err := schemaTTL(ds.Properties)
+	if err != nil {
+		return domain.DatabaseSchema{}, CacheStatus{}, err
+	}
+	if !refresh {
+		if schema, status, ok := s.schemas.get(ds); ok {
+			return schema, status, nil
+		}
+	}
+
+	class, err := s.classes.Get(ds.ClassID)
+	if err != nil {
+		if errors.Is(err, datasource.ErrUnknownClass) {
+			return domain.DatabaseSchema{}, CacheStatus{}, fmt.Errorf("%w: %v: %s", ErrBadRequest, err, ds.ClassID)
+		}
+		return domain.DatabaseSchema{}, CacheStatus{}, err
+	}
+	introspector, ok := class.(datasource.SchemaIntrospector)
+	if !ok {
+		return domain.DatabaseSchema{}, CacheStatus{}, fmt.Errorf("%w: class %s cannot describe its schema", ErrBadRequest, ds.ClassID)
+	}
+	limits, err := queryLimitsOf(ds.Properties)
+	if err != nil {
+		return domain.DatabaseSchema{}, CacheStatus{}, err
+	}
+	if limits.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.timeout)
+		defer cancel()
+	}
+	schema, err := introspector.Introspect(ctx, ds)
+	switch {
+	case errors.Is(err, datasource.ErrUnsupported):
+		return domain.DatabaseSchema{}, CacheStatus{}, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	case err != nil && ctx.Err() != nil:
+		return domain.DatabaseSchema{}, CacheStatus{}, contextError(ctx)
+	case err != nil:
+		return domain.DatabaseSchema{}, CacheStatus{}, err
+	}
+	if ttl > 0 {
+		s.schemas.store(ds, schema, ttl)
+	}
+	return schema, CacheStatus{}, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+// SilenceService manages silences, which mute the notifications of the
+// alerts they match for a while.
+type SilenceService struct {
+	repo *repository.SilenceRepository
+}
+
+func NewSilenceService(repo *repository.SilenceRepository) *SilenceService {
+	return &SilenceService{repo: repo}
+}
+
+func (s *SilenceService) Create(ctx context.Context, opts domain.CreateSilenceOptions) (domain.Silence, error) {
+	now := time.Now()
+	silence, err := silenceOf(opts, now)
+	if err != nil {
+		return domain.Silence{}, err
+	}
+	silence.ID = domain.NewSilenceID(now.UnixNano())
+	if err := s.repo.Create(ctx, silence); err != nil {
+		return domain.Silence{}, err
+	}
+	silence.State = silence.StateAt(now)
+	return silence, nil
+}
+
+func (s *SilenceService) Get(ctx context.Context, id domain.SilenceID) (domain.Silence, error) {
+	silence, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Silence{}, ErrNotFound
+		}
+		return domain.Silence{}, err
+	}
+	silence.State = silence.StateAt(time.Now())
+	return silence, nil
+}
+
+// List returns every silence, expired ones included, latest ending first.
+func (s *SilenceService) List(ctx context.Context) ([]domain.Silence, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range list {
+		list[i].State = list[i].StateAt(now)
+	}
+	return list, nil
+}
+
+// Active returns the silences in effect at t.
+func (s *SilenceService) Active(ctx context.Context, t time.Time) ([]domain.Silence, error) {
+	list, err := s.repo.Active(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].State = domain.SilenceActive
+	}
+	return list, nil
+}
+
+// Update replaces the matchers and bounds of a silence that has not
+// expired.
+func (s *SilenceService) Update(ctx context.Context, id domain.SilenceID, opts domain.UpdateSilenceOptions) (domain.Silence, error) {
+	current, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Silence{}, err
+	}
+	if current.State == domain.SilenceExpired {
+		return domain.Silence{}, fmt.Errorf("%w: the silence has expired", ErrConflict)
+	}
+	now := time.Now()
+	silence, err := silenceOf(domain.CreateSilenceOptions(opts), now)
+	if err != nil {
+		return domain.Silence{}, err
+	}
+	silence.ID = id
+	if err := s.repo.Update(ctx, silence); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Silence{}, ErrNotFound
+		}
+		return domain.Silence{}, err
+	}
+	silence.State = silence.StateAt(now)
+	return silence, nil
+}
+
+// Expire ends a silence now. Silences that have not started yet end
+// without ever starting.
+func (s *SilenceService) Expire(ctx context.Context, id domain.SilenceID) (domain.Silence, error) {
+	silence, err := s.Get(ctx, id)
+	if err != nil {
+		return domain.Silence{}, err
+	}
+	if silence.State == domain.SilenceExpired {
+		return silence, nil
+	}
+	now := time.Now()
+	if silence.StartsAt.After(now) {
+		silence.StartsAt = now
+	}
+	silence.EndsAt, silence.UpdatedAt = now, now
+	if err := s.repo.Update(ctx, silence); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Silence{}, ErrNotFound
+		}
+		return domain.Silence{}, err
+	}
+	silence.State = domain.SilenceExpired
+	return silence, nil
+}
+
+func (s *SilenceService) Delete(ctx context.Context, id domain.SilenceID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// silenceOf validates opts and builds the silence they describe at now.
+// A silence must end in the future, and at least one of its matchers must
+// not match the empty string, so that it cannot mute every alert.
+func silenceOf(opts domain.CreateSilenceOptions, now time.Time) (domain.Silence, error) {
+	if err := domain.Validate(opts); err != nil {
+		return domain.Silence{}, ErrBadRequest
+	}
+	matchers, err := compileMatchers(opts.Matchers)
+	if err != nil {
+		return domain.Silence{}, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	selective := false
+	for _, m := range matchers {
+		if !m.matches(map[string]string{}) {
+			selective = true
+		}
+	}
+	if !selective {
+		return domain.Silence{}, fmt.Errorf("%w: at least one matcher must not match the empty string", ErrBadRequest)
+	}
+
+	silence := domain.Silence{
+		Matchers:  opts.Matchers,
+		StartsAt:  now,
+		Comment:   opts.Comment,
+		CreatedBy: opts.CreatedBy,
+		UpdatedAt: now,
+	}
+	if opts.StartsAt != nil {
+		silence.StartsAt = *opts.StartsAt
+	}
+	switch {
+	case (opts.EndsAt == nil) == (opts.Duration == 0):
+		return domain.Silence{}, fmt.Errorf("%w: a silence needs exactly one of endsAt and duration", ErrBadRequest)
+	case opts.EndsAt != nil:
+		silence.EndsAt = *opts.EndsAt
+	default:
+		silence.EndsAt = silence.StartsAt.Add(time.Duration(opts.Duration))
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return domain.Silence{}, fmt.Errorf("%w: a silence must end after it starts", ErrBadRequest)
+	}
+	if !silence.EndsAt.After(now) {
+		return domain.Silence{}, fmt.Errorf("%w: a silence must end in the future", ErrBadRequest)
+	}
+	return silence, nil
+}
@@ -0,0 +1,65 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestSilenceService(t *testing.T) {
+	svc := service.NewSilenceService(repository.NewSilenceRepository(openServiceDB(t)))
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	team := []domain.LabelMatcher{{Label: "team", Op: domain.MatchEqual, Value: "db"}}
+
+	for name, opts := range map[string]domain.CreateSilenceOptions{
+		"no matchers":      {Duration: domain.Duration(time.Hour)},
+		"matches anything": {Matchers: []domain.LabelMatcher{{Label: "team", Op: domain.MatchRegexp, Value: ".*"}}, Duration: domain.Duration(time.Hour)},
+		"bad regexp":       {Matchers: []domain.LabelMatcher{{Label: "team", Op: domain.MatchRegexp, Value: "("}}, Duration: domain.Duration(time.Hour)},
+		"unbounded":        {Matchers: team},
+		"both bounds":      {Matchers: team, EndsAt: &future, Duration: domain.Duration(time.Hour)},
+		"ended":            {Matchers: team, StartsAt: &past, EndsAt: &past},
+		"in the past":      {Matchers: team, StartsAt: &past, Duration: domain.Duration(time.Minute)},
+	} {
+		if _, err := svc.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+
+	active, err := svc.Create(ctx, domain.CreateSilenceOptions{Matchers: team, Duration: domain.Duration(time.Hour), CreatedBy: "alice"})
+	if err != nil || active.State != domain.SilenceActive || !active.EndsAt.Equal(active.StartsAt.Add(time.Hour)) {
+		t.Fatalf("Create = %+v, %v", active, err)
+	}
+	pending, err := svc.Create(ctx, domain.CreateSilenceOptions{Matchers: team, StartsAt: &future, Duration: domain.Duration(time.Hour)})
+	if err != nil || pending.State != domain.SilencePending {
+		t.Fatalf("Create pending = %+v, %v", pending, err)
+	}
+
+	expired, err := svc.Expire(ctx, active.ID)
+	if err != nil || expired.State != domain.SilenceExpired {
+		t.Fatalf("Expire = %+v, %v", expired, err)
+	}
+	if _, err := svc.Update(ctx, active.ID, domain.UpdateSilenceOptions{Matchers: team, Duration: domain.Duration(time.Hour)}); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("Update expired = %v, want ErrConflict", err)
+	}
+	updated, err := svc.Update(ctx, pending.ID, domain.UpdateSilenceOptions{Matchers: team, Duration: domain.Duration(2 * time.Hour), Comment: "now"})
+	if err != nil || updated.State != domain.SilenceActive || updated.Comment != "now" {
+		t.Fatalf("Update = %+v, %v", updated, err)
+	}
+	list, err := svc.Active(ctx, time.Now())
+	if err != nil || len(list) != 1 || list[0].ID != pending.ID {
+		t.Fatalf("Active = %+v, %v", list, err)
+	}
+	if err := svc.Delete(ctx, pending.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.Get(ctx, pending.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/timerange"
+)
+
+// DefaultMaxDataPoints is how many points the time range of a request is
+// split into when the request does not say.
+const DefaultMaxDataPoints = 1000
+
+// QueryPropertyMinInterval is the smallest $__interval of a query, such as
+// the scrape interval of its metrics, e.g. "15s" or "1d".
+const QueryPropertyMinInterval domain.PropertyKey = "minInterval"
+
+func minInterval(props map[domain.PropertyKey]domain.PropertyValue) (time.Duration, error) {
+	raw := props[QueryPropertyMinInterval]
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := timerange.ParseDuration(string(raw))
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: %s: must be a positive duration such as 15s", ErrBadRequest, QueryPropertyMinInterval)
+	}
+	return d, nil
+}
+
+// queryRange returns the time range of sel with the interval that suits a
+// query with props, or nil when sel has no range.
+func queryRange(sel domain.VariableSelection, props map[domain.PropertyKey]domain.PropertyValue) (*domain.TimeRange, error) {
+	if sel.Range == nil {
+		return nil, nil
+	}
+	floor, err := minInterval(props)
+	if err != nil {
+		return nil, err
+	}
+	points := sel.MaxDataPoints
+	if points <= 0 {
+		points = DefaultMaxDataPoints
+	}
+	r := *sel.Range
+	r.Interval = timerange.Interval(r.To.Sub(r.From), points, floor)
+	return &r, nil
+}
+
+// withTimeVariables returns values extended by the built-in variables of
+// r. References to built-ins need a range; other names starting with two
+// underscores are left as written.
+func withTimeVariables(
+	values map[domain.Name]domain.VariableValue,
+	r *domain.TimeRange,
+	props map[domain.PropertyKey]domain.PropertyValue,
+) (map[domain.Name]domain.VariableValue, error) {
+	if r == nil {
+		for _, name := range propertyRefs(props) {
+			switch name {
+			case domain.VariableTimeFrom, domain.VariableTimeTo, domain.VariableInterval, domain.VariableIntervalMS:
+				return nil, fmt.Errorf("%w: $%s needs a time range; pass from and to", ErrBadRequest, name)
+			}
+		}
+		return values, nil
+	}
+	out := maps.Clone(values)
+	if out == nil {
+		out = make(map[domain.Name]domain.VariableValue, 4)
+	}
+	for name, v := range map[domain.Name]string{
+		domain.VariableTimeFrom:   r.From.UTC().Format(time.RFC3339Nano),
+		domain.VariableTimeTo:     r.To.UTC().Format(time.RFC3339Nano),
+		domain.VariableInterval:   timerange.FormatInterval(r.Interval),
+		domain.VariableIntervalMS: strconv.FormatInt(r.Interval.Milliseconds(), 10),
+	} {
+		out[name] = domain.VariableValue{Values: []domain.PropertyValue{domain.PropertyValue(v)}}
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/script"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+// TransformerService manages saved JavaScript transformers, which the
+// script steps of component transformations reference by name.
+type TransformerService struct {
+	repo *repository.TransformerRepository
+}
+
+func NewTransformerService(repo *repository.TransformerRepository) *TransformerService {
+	return &TransformerService{repo: repo}
+}
+
+func (s *TransformerService) Create(ctx context.Context, opts domain.CreateTransformerOptions) (domain.Transformer, error) {
+	if err := validateTransformer(opts); err != nil {
+		return domain.Transformer{}, err
+	}
+	now := time.Now()
+	t := domain.Transformer{
+		ID:          domain.NewTransformerID(now.UnixNano()),
+		Name:        opts.Name,
+		Description: opts.Description,
+		Script:      opts.Script,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		if errors.Is(err, repository.ErrDuplicateTransformer) {
+			return domain.Transformer{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.Transformer{}, err
+	}
+	return t, nil
+}
+
+func (s *TransformerService) Get(ctx context.Context, id domain.TransformerID) (domain.Transformer, error) {
+	t, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Transformer{}, ErrNotFound
+		}
+		return domain.Transformer{}, err
+	}
+	return t, nil
+}
+
+func (s *TransformerService) List(ctx context.Context) ([]domain.Transformer, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *TransformerService) Update(ctx context.Context, id domain.TransformerID, opts domain.UpdateTransformerOptions) (domain.Transformer, error) {
+	if err := validateTransformer(domain.CreateTransformerOptions(opts)); err != nil {
+		return domain.Transformer{}, err
+	}
+	t := domain.Transformer{
+		ID:          id,
+		Name:        opts.Name,
+		Description: opts.Description,
+		Script:      opts.Script,
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.repo.Update(ctx, t); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Transformer{}, ErrNotFound
+		}
+		if errors.Is(err, repository.ErrDuplicateTransformer) {
+			return domain.Transformer{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.Transformer{}, err
+	}
+	return t, nil
+}
+
+// Delete removes the transformer. Components still naming it fail to
+// render until their transformations change.
+func (s *TransformerService) Delete(ctx context.Context, id domain.TransformerID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Run applies src to table within the limits of a script step, so that a
+// transformer can be tried before it is saved. Scripts that fail or
+// exceed their limits are bad requests.
+func (s *TransformerService) Run(ctx context.Context, src string, table domain.TableData) (domain.TableData, error) {
+	p, err := script.Compile(src)
+	if err != nil {
+		return domain.TableData{}, fmt.Errorf("%w: script: %v", ErrBadRequest, err)
+	}
+	out, err := p.Run(ctx, table, script.DefaultLimits)
+	if err != nil {
+		return domain.TableData{}, fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+	return out, nil
+}
+
+// byName returns the transformer called name with its compiled script.
+func (s *TransformerService) byName(ctx context.Context, name domain.Name) (domain.Transformer, script.Program, error) {
+	t, err := s.repo.GetByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Transformer{}, script.Program{}, fmt.Errorf("%w: unknown transformer %s", ErrBadRequest, name)
+		}
+		return domain.Transformer{}, script.Program{}, err
+	}
+	p, err := script.Compile(t.Script)
+	if err != nil {
+		return domain.Transformer{}, script.Program{}, fmt.Errorf("%w: transformer %s: %v", ErrBadRequest, name, err)
+	}
+	return t, p, nil
+}
+
+func validateTransformer(opts domain.CreateTransformerOptions) error {
+	if err := domain.Validate(opts); err != nil {
+		return ErrBadRequest
+	}
+	if _, err := script.Compile(opts.Script); err != nil {
+		return fmt.Errorf("%w: script: %v", ErrBadRequest, err)
+	}
+	return nil
+}
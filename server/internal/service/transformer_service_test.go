@@ -0,0 +1,41 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestTransformerService(t *testing.T) {
+	svc := service.NewTransformerService(repository.NewTransformerRepository(openServiceDB(t)))
+	ctx := context.Background()
+
+	for name, opts := range map[string]domain.CreateTransformerOptions{
+		"no name":      {Script: "return table"},
+		"syntax error": {Name: "x", Script: "return ("},
+	} {
+		if _, err := svc.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: err = %v, want ErrBadRequest", name, err)
+		}
+	}
+	created, err := svc.Create(ctx, domain.CreateTransformerOptions{Name: "x", Script: "return table"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.Update(ctx, domain.NewTransformerID(1), domain.UpdateTransformerOptions{Name: "y", Script: "return table"}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("Update unknown: err = %v, want ErrNotFound", err)
+	}
+	if list, err := svc.List(ctx); err != nil || len(list) != 1 || list[0].ID != created.ID {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	for _, src := range []string{`throw new Error("boom")`, `return 1`, `for (;;) {}`} {
+		if _, err := svc.Run(ctx, src, domain.TableData{}); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("Run %s: err = %v, want ErrBadRequest", src, err)
+		}
+	}
+}
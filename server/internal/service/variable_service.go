@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/repository"
+)
+
+type VariableService struct {
+	repo *repository.VariableRepository
+}
+
+func NewVariableService(repo *repository.VariableRepository) *VariableService {
+	return &VariableService{repo: repo}
+}
+
+func (s *VariableService) Create(ctx context.Context, opts domain.CreateVariableOptions) (domain.Variable, error) {
+	if err := validateVariable(opts); err != nil {
+		return domain.Variable{}, err
+	}
+	now := time.Now()
+	v := variableOf(opts)
+	v.ID, v.UpdatedAt = domain.NewVariableID(now.UnixNano()), now
+	if err := s.checkCycles(ctx, v); err != nil {
+		return domain.Variable{}, err
+	}
+	if err := s.repo.Create(ctx, v); err != nil {
+		if errors.Is(err, repository.ErrDuplicateVariable) {
+			return domain.Variable{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.Variable{}, err
+	}
+	return v, nil
+}
+
+func (s *VariableService) Get(ctx context.Context, id domain.VariableID) (domain.Variable, error) {
+	v, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Variable{}, ErrNotFound
+		}
+		return domain.Variable{}, err
+	}
+	return v, nil
+}
+
+// List returns the project variables and those of page.
+func (s *VariableService) List(ctx context.Context, page domain.Name) ([]domain.Variable, error) {
+	return s.repo.List(ctx, page)
+}
+
+func (s *VariableService) Update(ctx context.Context, id domain.VariableID, opts domain.UpdateVariableOptions) (domain.Variable, error) {
+	if err := validateVariable(domain.CreateVariableOptions(opts)); err != nil {
+		return domain.Variable{}, err
+	}
+	v := variableOf(domain.CreateVariableOptions(opts))
+	v.ID, v.UpdatedAt = id, time.Now()
+	if err := s.checkCycles(ctx, v); err != nil {
+		return domain.Variable{}, err
+	}
+	if err := s.repo.Update(ctx, v); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.Variable{}, ErrNotFound
+		}
+		if errors.Is(err, repository.ErrDuplicateVariable) {
+			return domain.Variable{}, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return domain.Variable{}, err
+	}
+	return v, nil
+}
+
+func (s *VariableService) Delete(ctx context.Context, id domain.VariableID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// checkCycles rejects v if its query, with the variables of its page,
+// would come to reference itself. Cycles that a project variable closes
+// through page variables are only found when the page is resolved.
+func (s *VariableService) checkCycles(ctx context.Context, v domain.Variable) error {
+	list, err := s.repo.List(ctx, v.Page)
+	if err != nil {
+		return err
+	}
+	list = slices.DeleteFunc(list, func(o domain.Variable) bool { return o.ID == v.ID })
+	if _, err := variableOrder(append(list, v), []domain.Name{v.Name}); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+	return nil
+}
+
+func variableOf(opts domain.CreateVariableOptions) domain.Variable {
+	return domain.Variable{
+		Name:       opts.Name,
+		Label:      opts.Label,
+		Type:       opts.Type,
+		Page:       opts.Page,
+		Value:      opts.Value,
+		Options:    opts.Options,
+		Query:      opts.Query,
+		Multi:      opts.Multi,
+		IncludeAll: opts.IncludeAll,
+		AllValue:   opts.AllValue,
+	}
+}
+
+// validateVariable checks what each variable type needs: custom variables
+// their options, query variables a query against a data source. Only
+// those two types can be multi-value or include "All".
+func validateVariable(opts domain.CreateVariableOptions) error {
+	if err := domain.Validate(opts); err != nil {
+		return ErrBadRequest
+	}
+	if domain.BuiltinVariable(opts.Name) {
+		return fmt.Errorf("%w: variable names starting with __ are reserved", ErrBadRequest)
+	}
+	if !domain.ValidVariableName(opts.Name) {
+		return fmt.Errorf("%w: variable name %q must be a letter or underscore followed by letters, digits or underscores", ErrBadRequest, opts.Name)
+	}
+	switch opts.Type {
+	case domain.VariableConstant, domain.VariableTextBox:
+		if opts.Multi || opts.IncludeAll || opts.AllValue != "" {
+			return fmt.Errorf("%w: %s variable %s takes a single value", ErrBadRequest, opts.Type, opts.Name)
+		}
+	case domain.VariableCustom:
+		if len(opts.Options) == 0 {
+			return fmt.Errorf("%w: custom variable %s has no options", ErrBadRequest, opts.Name)
+		}
+		if opts.Value == domain.VariableAll && opts.IncludeAll {
+			break
+		}
+		if opts.Value != "" && !slices.Contains(opts.Options, opts.Value) {
+			return fmt.Errorf("%w: default of %s is not one of its options", ErrBadRequest, opts.Name)
+		}
+	case domain.VariableQuery:
+		q := opts.Query
+		if q == nil || q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+			return fmt.Errorf("%w: query variable %s has no query against a data source", ErrBadRequest, opts.Name)
+		}
+		if err := validateQueries([]domain.Query{*q}); err != nil {
+			return err
+		}
+		if _, err := cacheTTL(q.Properties); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown variable type %q", ErrBadRequest, opts.Type)
+	}
+	if opts.AllValue != "" && !opts.IncludeAll {
+		return fmt.Errorf("%w: variable %s has an all value but does not include All", ErrBadRequest, opts.Name)
+	}
+	return nil
+}
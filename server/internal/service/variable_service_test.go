@@ -0,0 +1,396 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/service"
+)
+
+func TestVariableService_Validates(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+
+	cases := map[string]domain.CreateVariableOptions{
+		"bad name":         {Name: "1st", Type: domain.VariableTextBox},
+		"unknown type":     {Name: "x", Type: "slider"},
+		"custom no option": {Name: "x", Type: domain.VariableCustom},
+		"default missing":  {Name: "x", Type: domain.VariableCustom, Options: []domain.PropertyValue{"a"}, Value: "b"},
+		"query no query":   {Name: "x", Type: domain.VariableQuery},
+	}
+	for name, opts := range cases {
+		if _, err := env.variables.Create(ctx, opts); !errors.Is(err, service.ErrBadRequest) {
+			t.Errorf("%s: expected ErrBadRequest, got %v", name, err)
+		}
+	}
+
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "x", Type: domain.VariableTextBox}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "x", Type: domain.VariableTextBox}); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("duplicate: expected ErrConflict, got %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "x", Type: domain.VariableTextBox, Page: "overview"}); err != nil {
+		t.Fatalf("page variable shadowing a project one: %v", err)
+	}
+}
+
+func TestQueryService_BindsVariables(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE orders (region TEXT, total REAL);
+		INSERT INTO orders VALUES ('eu', 1), ('eu', 2), ('us', 4);`)
+	ctx := context.Background()
+
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "region", Type: domain.VariableQuery,
+		Query: &domain.Query{
+			Name:         "regions",
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT region FROM orders ORDER BY region"},
+		},
+	}); err != nil {
+		t.Fatalf("Create region: %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "min", Type: domain.VariableTextBox, Value: "0",
+	}); err != nil {
+		t.Fatalf("Create min: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "totals",
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: env.ds.ID,
+			Properties: map[domain.PropertyKey]domain.PropertyValue{
+				"sql": "SELECT COUNT(*) AS n FROM orders WHERE region = $region AND total >= ${min}",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	count := func(sel domain.VariableSelection) int64 {
+		t.Helper()
+		table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, sel)
+		if err != nil {
+			t.Fatalf("ComponentData(%v): %v", sel.Values, err)
+		}
+		return table.Columns[0].Ints[0]
+	}
+	if n := count(domain.VariableSelection{}); n != 2 {
+		t.Fatalf("defaults: n = %d, want 2 (first region, eu)", n)
+	}
+	if n := count(domain.VariableSelection{Values: map[domain.Name][]domain.PropertyValue{"region": {"us"}, "min": {"2"}}}); n != 1 {
+		t.Fatalf("selected: n = %d, want 1", n)
+	}
+	// a textbox value is bound, never spliced into the statement
+	if n := count(domain.VariableSelection{Values: map[domain.Name][]domain.PropertyValue{"min": {"0 OR 1=1"}}}); n != 0 {
+		t.Fatalf("injection: n = %d, want 0", n)
+	}
+
+	_, _, err = env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{
+		Values: map[domain.Name][]domain.PropertyValue{"region": {"mars"}},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("unknown option: expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_InterpolatesOnlyListedProperties(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE t (n INTEGER);`)
+	ctx := context.Background()
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{
+		Name: "guarded", ClassID: "sqlite",
+		Properties: map[domain.PropertyKey]domain.PropertyValue{"path": domain.PropertyValue(env.path), "readOnly": "true"},
+	})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "write", Type: domain.VariableTextBox, Value: "false",
+	}); err != nil {
+		t.Fatalf("Create write: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "insert",
+		Queries: []domain.Query{{
+			Name:         "main",
+			DataSourceID: ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "INSERT INTO t VALUES (1)", "mutation": "$write"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+
+	// a selected value cannot turn the query into a mutation
+	_, _, err = env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{
+		Values: map[domain.Name][]domain.PropertyValue{"write": {"true"}},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("ComponentData = %v, want a read-only violation", err)
+	}
+}
+
+func TestQueryService_ResolvesChainedVariables(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE nodes (region TEXT, cluster TEXT, node TEXT);
+		INSERT INTO nodes VALUES ('eu', 'eu-1', 'n1'), ('eu', 'eu-1', 'n2'), ('eu', 'eu-2', 'n3'), ('us', 'us-1', 'n4');`)
+	ctx := context.Background()
+	sqlVariable := func(name domain.Name, stmt domain.PropertyValue, multi bool) {
+		t.Helper()
+		if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+			Name: name, Type: domain.VariableQuery, Multi: multi, IncludeAll: multi,
+			Query: &domain.Query{DataSourceID: env.ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": stmt}},
+		}); err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+	}
+	// created out of order: resolution follows references, not creation
+	sqlVariable("node", "SELECT node FROM nodes WHERE cluster IN ($cluster) ORDER BY node", true)
+	sqlVariable("cluster", "SELECT cluster FROM nodes WHERE region = $region ORDER BY cluster", true)
+	sqlVariable("region", "SELECT region FROM nodes ORDER BY region", false)
+
+	resolve := func(values map[domain.Name][]domain.PropertyValue) map[domain.Name]domain.ResolvedVariable {
+		t.Helper()
+		list, err := env.queries.ResolveVariables(ctx, domain.VariableSelection{Values: values})
+		if err != nil {
+			t.Fatalf("ResolveVariables(%v): %v", values, err)
+		}
+		var order []domain.Name
+		byName := make(map[domain.Name]domain.ResolvedVariable)
+		for _, r := range list {
+			order = append(order, r.Name)
+			byName[r.Name] = r
+		}
+		if !slices.Equal(order, []domain.Name{"region", "cluster", "node"}) {
+			t.Fatalf("order = %v, want region, cluster, node", order)
+		}
+		return byName
+	}
+
+	got := resolve(nil)
+	if n := got["node"]; !slices.Equal(n.Options, []domain.PropertyValue{"n1", "n2"}) || !slices.Equal(n.DependsOn, []domain.Name{"cluster"}) {
+		t.Fatalf("node = %+v, want the nodes of eu-1", n)
+	}
+	got = resolve(map[domain.Name][]domain.PropertyValue{"cluster": {domain.VariableAll}})
+	if n := got["node"]; !slices.Equal(n.Options, []domain.PropertyValue{"n1", "n2", "n3"}) {
+		t.Fatalf("node = %+v, want the nodes of every eu cluster", n)
+	}
+	if c := got["cluster"]; !slices.Equal(c.Selected, []domain.PropertyValue{domain.VariableAll}) || !slices.Equal(c.Value.Values, []domain.PropertyValue{"eu-1", "eu-2"}) {
+		t.Fatalf("cluster = %+v, want All standing for eu-1 and eu-2", c)
+	}
+	got = resolve(map[domain.Name][]domain.PropertyValue{"region": {"us"}, "cluster": {"us-1"}})
+	if n := got["node"]; !slices.Equal(n.Options, []domain.PropertyValue{"n4"}) {
+		t.Fatalf("node = %+v, want n4", n)
+	}
+
+	_, err := env.queries.ResolveVariables(ctx, domain.VariableSelection{
+		Values: map[domain.Name][]domain.PropertyValue{"region": {"eu", "us"}},
+	})
+	if !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("several values of a single-value variable: expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_ExpandsMultiValueVariables(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE t (env TEXT); INSERT INTO t VALUES ('prod'), ('dev'), ('qa');`)
+	ctx := context.Background()
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "env", Type: domain.VariableCustom, Multi: true, IncludeAll: true, Value: domain.VariableAll,
+		Options: []domain.PropertyValue{"prod", "dev", "qa"},
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "envs",
+		Queries: []domain.Query{{
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT COUNT(*) FROM t WHERE env IN ($env)"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create component: %v", err)
+	}
+	for _, tc := range []struct {
+		values []domain.PropertyValue
+		want   int64
+	}{
+		{nil, 3},
+		{[]domain.PropertyValue{"prod", "qa"}, 2},
+		{[]domain.PropertyValue{domain.VariableAll}, 3},
+	} {
+		table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{
+			Values: map[domain.Name][]domain.PropertyValue{"env": tc.values},
+		})
+		if err != nil {
+			t.Fatalf("%v: %v", tc.values, err)
+		}
+		if n := table.Columns[0].Ints[0]; n != tc.want {
+			t.Fatalf("%v: n = %d, want %d", tc.values, n, tc.want)
+		}
+	}
+}
+
+func TestVariableService_RejectsCycles(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	query := func(stmt domain.PropertyValue) *domain.Query {
+		return &domain.Query{DataSourceID: env.ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"sql": stmt}}
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "a", Type: domain.VariableQuery, Query: query("SELECT $b")}); err != nil {
+		t.Fatalf("Create a: %v", err)
+	}
+	b, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "b", Type: domain.VariableQuery, Query: query("SELECT 1")})
+	if err != nil {
+		t.Fatalf("Create b: %v", err)
+	}
+	_, err = env.variables.Update(ctx, b.ID, domain.UpdateVariableOptions{Name: "b", Type: domain.VariableQuery, Query: query("SELECT $a")})
+	var cycle *service.VariableCycleError
+	if !errors.Is(err, service.ErrBadRequest) || !errors.As(err, &cycle) {
+		t.Fatalf("expected a VariableCycleError, got %v", err)
+	}
+	if !slices.Equal(cycle.Cycle, []domain.Name{"b", "a", "b"}) {
+		t.Fatalf("cycle = %v, want b -> a -> b", cycle.Cycle)
+	}
+
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "b", Type: domain.VariableQuery, Page: "p", Query: query("SELECT $a")}); !errors.As(err, &cycle) {
+		t.Fatalf("page variable shadowing b: expected a VariableCycleError, got %v", err)
+	}
+
+	// a project variable closing a cycle through a page variable is only
+	// caught when the page is resolved
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "c", Type: domain.VariableQuery, Page: "p", Query: query("SELECT $b")}); err != nil {
+		t.Fatalf("Create c: %v", err)
+	}
+	if _, err := env.variables.Update(ctx, b.ID, domain.UpdateVariableOptions{Name: "b", Type: domain.VariableQuery, Query: query("SELECT $c")}); err != nil {
+		t.Fatalf("Update b: %v", err)
+	}
+	if _, err := env.queries.ResolveVariables(ctx, domain.VariableSelection{Page: "p"}); !errors.As(err, &cycle) || !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("resolve: expected a VariableCycleError, got %v", err)
+	}
+}
+
+func TestQueryService_TimeRangeVariables(t *testing.T) {
+	env := newQueryEnv(t, `CREATE TABLE events (at TEXT);
+		INSERT INTO events VALUES ('2024-03-01T10:00:00Z'), ('2024-03-01T11:30:00Z'), ('2024-03-02T09:00:00Z');`)
+	ctx := context.Background()
+	comp, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "events",
+		Queries: []domain.Query{{
+			DataSourceID: env.ds.ID,
+			Properties: map[domain.PropertyKey]domain.PropertyValue{
+				"sql":                            "SELECT COUNT(*) AS n, $__interval AS step FROM events WHERE at >= $__timeFrom AND at < $__timeTo",
+				service.QueryPropertyMinInterval: "5m",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	sel := domain.VariableSelection{Range: &domain.TimeRange{
+		From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	}}
+	table, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, sel)
+	if err != nil {
+		t.Fatalf("ComponentData: %v", err)
+	}
+	if n, step := table.Columns[0].Ints[0], table.Columns[1].Values[0]; n != 2 || step != "5m" {
+		t.Fatalf("n = %d, step = %s; want 2 events in a day at the 5m minimum interval", n, step)
+	}
+
+	if _, _, err := env.queries.ComponentData(ctx, comp.ID, domain.DataOptions{}, domain.VariableSelection{}); !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("without a range: expected ErrBadRequest, got %v", err)
+	}
+	macros, err := env.components.Create(ctx, domain.CreateComponentOptions{
+		VisualisationID: "table",
+		Name:            "macros",
+		Queries: []domain.Query{{
+			DataSourceID: env.ds.ID,
+			Properties:   map[domain.PropertyKey]domain.PropertyValue{"sql": "SELECT COUNT(*) FROM events WHERE $__timeFilter(at)"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	table, _, err = env.queries.ComponentData(ctx, macros.ID, domain.DataOptions{}, sel)
+	if err != nil || table.Columns[0].Ints[0] != 2 {
+		t.Fatalf("macro: %v, %+v; want 2 events", err, table)
+	}
+	if _, _, err := env.queries.ComponentData(ctx, macros.ID, domain.DataOptions{}, domain.VariableSelection{}); !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("macro without a range: expected ErrBadRequest, got %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{Name: "__timeFrom", Type: domain.VariableTextBox}); !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("reserved name: expected ErrBadRequest, got %v", err)
+	}
+}
+
+func TestQueryService_CachesVariableOptions(t *testing.T) {
+	env := newQueryEnv(t, "")
+	ctx := context.Background()
+	class := &blockingClass{release: make(chan struct{})}
+	close(class.release)
+	queries := service.NewQueryService(env.components, env.dataSources, env.variables, nil, datasource.NewRegistry(class))
+	ds, err := env.dataSources.Create(ctx, domain.CreateDataSourceOptions{Name: "counted", ClassID: "blocking"})
+	if err != nil {
+		t.Fatalf("Create ds: %v", err)
+	}
+	if _, err := env.variables.Create(ctx, domain.CreateVariableOptions{
+		Name: "dc", Type: domain.VariableCustom, Options: []domain.PropertyValue{"a", "b"},
+	}); err != nil {
+		t.Fatalf("Create dc: %v", err)
+	}
+	hostOpts := domain.CreateVariableOptions{
+		Name: "host", Type: domain.VariableQuery,
+		Query: &domain.Query{DataSourceID: ds.ID, Properties: map[domain.PropertyKey]domain.PropertyValue{"q": "hosts in $dc"}},
+	}
+	host, err := env.variables.Create(ctx, hostOpts)
+	if err != nil {
+		t.Fatalf("Create host: %v", err)
+	}
+
+	resolve := func(dc domain.PropertyValue, want int32) {
+		t.Helper()
+		sel := domain.VariableSelection{Values: map[domain.Name][]domain.PropertyValue{"dc": {dc}}}
+		if _, err := queries.ResolveVariables(ctx, sel); err != nil {
+			t.Fatalf("ResolveVariables: %v", err)
+		}
+		if got := class.calls.Load(); got != want {
+			t.Fatalf("dc=%s: %d queries, want %d", dc, got, want)
+		}
+	}
+	resolve("a", 1)
+	resolve("a", 1)
+	// another value of a dependency runs the query again
+	resolve("b", 2)
+	resolve("a", 2)
+
+	// so does a new version of the variable
+	if _, err := env.variables.Update(ctx, host.ID, domain.UpdateVariableOptions(hostOpts)); err != nil {
+		t.Fatalf("Update host: %v", err)
+	}
+	resolve("a", 3)
+
+	// and a zero cacheTTL turns the cache off
+	hostOpts.Query.Properties[service.ComponentPropertyCacheTTL] = "0s"
+	if _, err := env.variables.Update(ctx, host.ID, domain.UpdateVariableOptions(hostOpts)); err != nil {
+		t.Fatalf("Update host: %v", err)
+	}
+	resolve("a", 4)
+	resolve("a", 5)
+
+	hostOpts.Query.Properties[service.ComponentPropertyCacheTTL] = "soon"
+	if _, err := env.variables.Update(ctx, host.ID, domain.UpdateVariableOptions(hostOpts)); !errors.Is(err, service.ErrBadRequest) {
+		t.Fatalf("malformed cacheTTL: expected ErrBadRequest, got %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smilu97/refana/internal/datasource"
+	"github.com/smilu97/refana/internal/pkg/domain"
+	"github.com/smilu97/refana/internal/pkg/tableops"
+)
+
+// VariableCycleError reports variables whose queries reference each other
+// in a loop. Cycle starts and ends with the same name.
+type VariableCycleError struct {
+	Cycle []domain.Name `json:"cycle"`
+}
+
+func (e *VariableCycleError) Error() string {
+	names := make([]string, len(e.Cycle))
+	for i, n := range e.Cycle {
+		names[i] = string(n)
+	}
+	return "variables reference each other: " + strings.Join(names, " -> ")
+}
+
+func (e *VariableCycleError) ErrorCode() string { return "variable_cycle" }
+
+func (e *VariableCycleError) ErrorDetails() any { return e }
+
+// validateVariableRefs rejects references with an unknown format.
+func validateVariableRefs(props map[domain.PropertyKey]domain.PropertyValue) error {
+	for _, v := range props {
+		for _, ref := range domain.VariableRefs(string(v)) {
+			if !ref.Format.Valid() {
+				return fmt.Errorf("%w: unknown format %q of variable %s", ErrBadRequest, ref.Format, ref.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// propertyRefs returns the distinct names the properties reference, in
+// key order.
+func propertyRefs(props map[domain.PropertyKey]domain.PropertyValue) []domain.Name {
+	var names []domain.Name
+	for _, key := range slices.Sorted(maps.Keys(props)) {
+		for _, ref := range domain.VariableRefs(string(props[key])) {
+			if !slices.Contains(names, ref.Name) {
+				names = append(names, ref.Name)
+			}
+		}
+	}
+	return names
+}
+
+// variableDeps returns the names the query of a query variable references.
+func variableDeps(v domain.Variable) []domain.Name {
+	if v.Type != domain.VariableQuery || v.Query == nil {
+		return nil
+	}
+	return propertyRefs(v.Query.Properties)
+}
+
+// variableOrder returns the variables of list that names need, each after
+// the variables its query references; all of them when names is nil.
+// Later variables in list shadow earlier ones of the same name. Names
+// that are not variables are skipped.
+func variableOrder(list []domain.Variable, names []domain.Name) ([]domain.Variable, error) {
+	all := names == nil
+	scope := make(map[domain.Name]domain.Variable, len(list))
+	for _, v := range list {
+		if _, ok := scope[v.Name]; !ok && all {
+			names = append(names, v.Name)
+		}
+		scope[v.Name] = v
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var (
+		order []domain.Variable
+		state = make(map[domain.Name]int, len(scope))
+		path  []domain.Name
+	)
+	var visit func(name domain.Name) error
+	visit = func(name domain.Name) error {
+		v, ok := scope[name]
+		if !ok {
+			return nil
+		}
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return &VariableCycleError{Cycle: append(slices.Clone(path[start:]), name)}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range variableDeps(v) {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		order = append(order, v)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// interpolate returns q with the time range of sel and the variables its
+// properties reference resolved, built-ins included. Properties the class
+// binds keep their references and the values go to q.Variables; those it
+// interpolates get the values written in the format of each reference.
+// References in other properties are left as written.
+func (s *QueryService) interpolate(ctx context.Context, class datasource.Class, q domain.Query, sel domain.VariableSelection) (domain.Query, error) {
+	var err error
+	if q.Range, err = queryRange(sel, q.Properties); err != nil {
+		return domain.Query{}, err
+	}
+	props := variableInputs(class, q.Properties)
+	names := propertyRefs(props)
+	if len(names) == 0 {
+		return q, nil
+	}
+	if err := validateVariableRefs(props); err != nil {
+		return domain.Query{}, err
+	}
+	var values map[domain.Name]domain.VariableValue
+	if s.variables != nil {
+		resolved, err := s.resolveVariables(ctx, names, sel)
+		if err != nil {
+			return domain.Query{}, err
+		}
+		values = make(map[domain.Name]domain.VariableValue, len(resolved))
+		for _, r := range resolved {
+			values[r.Name] = r.Value
+		}
+	}
+	if values, err = withTimeVariables(values, q.Range, props); err != nil {
+		return domain.Query{}, err
+	}
+	return applyVariables(class, q, values), nil
+}
+
+// variableProperties returns the query properties class binds variables
+// as parameters of and those it has them written into.
+func variableProperties(class datasource.Class) (bound, written []domain.PropertyKey) {
+	if b, ok := class.(datasource.BindingClass); ok {
+		bound = b.BoundProperties()
+	}
+	if i, ok := class.(datasource.InterpolatingClass); ok {
+		written = i.InterpolatedProperties()
+	}
+	return bound, written
+}
+
+// variableInputs returns the properties among props that class takes
+// variables in.
+func variableInputs(class datasource.Class, props map[domain.PropertyKey]domain.PropertyValue) map[domain.PropertyKey]domain.PropertyValue {
+	bound, written := variableProperties(class)
+	out := make(map[domain.PropertyKey]domain.PropertyValue)
+	for key, v := range props {
+		if slices.Contains(bound, key) || slices.Contains(written, key) {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// applyVariables writes values into the properties of q the class
+// interpolates, or hands them to the class for the properties it binds.
+func applyVariables(class datasource.Class, q domain.Query, values map[domain.Name]domain.VariableValue) domain.Query {
+	bound, written := variableProperties(class)
+	props := make(map[domain.PropertyKey]domain.PropertyValue, len(q.Properties))
+	for key, v := range q.Properties {
+		if !slices.Contains(written, key) || slices.Contains(bound, key) {
+			props[key] = v
+			continue
+		}
+		props[key] = domain.PropertyValue(domain.ReplaceVariables(string(v), func(ref domain.VariableRef) (string, bool) {
+			val, ok := values[ref.Name]
+			if !ok {
+				return "", false
+			}
+			return domain.FormatVariable(val, ref.Format), true
+		}))
+	}
+	q.Properties = props
+	if len(bound) > 0 {
+		q.Variables = values
+	}
+	return q
+}
+
+// ResolveVariables evaluates every variable of sel.Page, and the project
+// variables it does not shadow, in dependency order: the query of a
+// variable runs with the values of the variables it references.
+func (s *QueryService) ResolveVariables(ctx context.Context, sel domain.VariableSelection) ([]domain.ResolvedVariable, error) {
+	if s.variables == nil {
+		return []domain.ResolvedVariable{}, nil
+	}
+	return s.resolveVariables(ctx, nil, sel)
+}
+
+// resolveVariables resolves the named variables and those they depend on,
+// or all of them when names is nil, taking values from sel.Values where
+// given and defaults otherwise. Names that are not variables are left
+// out, so that their references stay as written; selected values of such
+// names are ignored, since dashboards send every value to every component.
+func (s *QueryService) resolveVariables(ctx context.Context, names []domain.Name, sel domain.VariableSelection) ([]domain.ResolvedVariable, error) {
+	list, err := s.variables.List(ctx, sel.Page)
+	if err != nil {
+		return nil, err
+	}
+	order, err := variableOrder(list, names)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadRequest, err)
+	}
+
+	resolved := make([]domain.ResolvedVariable, 0, len(order))
+	values := make(map[domain.Name]domain.VariableValue, len(order))
+	for _, v := range order {
+		r, err := s.resolveVariable(ctx, v, sel, values)
+		if err != nil {
+			return nil, err
+		}
+		values[v.Name] = r.Value
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+// resolveVariable checks the values sel has for v against what v accepts,
+// or picks its default when there are none. values holds the variables
+// resolved so far, among them those v depends on.
+func (s *QueryService) resolveVariable(
+	ctx context.Context,
+	v domain.Variable,
+	sel domain.VariableSelection,
+	values map[domain.Name]domain.VariableValue,
+) (domain.ResolvedVariable, error) {
+	selected := sel.Values[v.Name]
+	r := domain.ResolvedVariable{Name: v.Name, Label: v.Label, Type: v.Type, Multi: v.Multi, IncludeAll: v.IncludeAll}
+	for _, dep := range variableDeps(v) {
+		if _, ok := values[dep]; ok {
+			r.DependsOn = append(r.DependsOn, dep)
+		}
+	}
+
+	switch v.Type {
+	case domain.VariableConstant:
+		if len(selected) > 1 || len(selected) == 1 && selected[0] != v.Value {
+			return r, fmt.Errorf("%w: variable %s is a constant", ErrBadRequest, v.Name)
+		}
+		r.Selected = []domain.PropertyValue{v.Value}
+		r.Value.Values = r.Selected
+		return r, nil
+	case domain.VariableTextBox:
+		if len(selected) > 1 {
+			return r, fmt.Errorf("%w: variable %s takes a single value", ErrBadRequest, v.Name)
+		}
+		r.Selected = selected
+		if len(selected) == 0 {
+			r.Selected = []domain.PropertyValue{v.Value}
+		}
+		r.Value.Values = r.Selected
+		return r, nil
+	}
+
+	options, err := s.variableOptions(ctx, v, sel, values)
+	if err != nil {
+		return r, err
+	}
+	r.Options = options
+	if r.Selected, err = selectOptions(v, options, selected); err != nil {
+		return r, err
+	}
+	switch {
+	case !slices.Equal(r.Selected, []domain.PropertyValue{domain.VariableAll}):
+		r.Value.Values = r.Selected
+	case v.AllValue != "":
+		r.Value = domain.VariableValue{Values: []domain.PropertyValue{v.AllValue}, Raw: true}
+	default:
+		r.Value.Values = options
+	}
+	return r, nil
+}
+
+// selectOptions checks selected against the options of a custom or query
+// variable, or returns its default: Value when it is an option or "All",
+// the first option otherwise.
+func selectOptions(v domain.Variable, options, selected []domain.PropertyValue) ([]domain.PropertyValue, error) {
+	if len(selected) == 0 {
+		switch {
+		case v.Value == domain.VariableAll && v.IncludeAll, v.Value != "" && slices.Contains(options, v.Value):
+			return []domain.PropertyValue{v.Value}, nil
+		case len(options) > 0:
+			return options[:1], nil
+		}
+		return []domain.PropertyValue{}, nil
+	}
+	if len(selected) > 1 && !v.Multi {
+		return nil, fmt.Errorf("%w: variable %s takes a single value", ErrBadRequest, v.Name)
+	}
+	if slices.Contains(selected, domain.VariableAll) {
+		if !v.IncludeAll {
+			return nil, fmt.Errorf("%w: variable %s does not include All", ErrBadRequest, v.Name)
+		}
+		return []domain.PropertyValue{domain.VariableAll}, nil
+	}
+	for _, val := range selected {
+		if !slices.Contains(options, val) {
+			return nil, fmt.Errorf("%w: %q is not an option of variable %s", ErrBadRequest, val, v.Name)
+		}
+	}
+	return selected, nil
+}
+
+// variableOptionsTTL is how long the options of a query variable are
+// reused when its query sets no cacheTTL property.
+const variableOptionsTTL = time.Minute
+
+// variableOptions lists the values a custom or query variable can take.
+// Query variables take the distinct values of the first column of their
+// query, in order of appearance.
+func (s *QueryService) variableOptions(
+	ctx context.Context,
+	v domain.Variable,
+	sel domain.VariableSelection,
+	values map[domain.Name]domain.VariableValue,
+) ([]domain.PropertyValue, error) {
+	if v.Type != domain.VariableQuery {
+		return v.Options, nil
+	}
+	table, err := s.variableTable(ctx, v, sel, values)
+	if err != nil {
+		return nil, fmt.Errorf("variable %s: %w", v.Name, err)
+	}
+	if len(table.Columns) == 0 {
+		return []domain.PropertyValue{}, nil
+	}
+	col := table.Columns[0]
+	seen := make(map[domain.PropertyValue]bool, col.Len())
+	options := make([]domain.PropertyValue, 0, col.Len())
+	for i := 0; i < col.Len(); i++ {
+		if col.IsNull(i) {
+			continue
+		}
+		if o := domain.PropertyValue(col.String(i)); !seen[o] {
+			seen[o] = true
+			options = append(options, o)
+		}
+	}
+	return options, nil
+}
+
+// variableTable runs the query of a query variable through the result
+// cache, so that its result is reused for the cacheTTL property of the
+// query, variableOptionsTTL when unset, while the variable, its data
+// source, the values of its dependencies and the time range stay the same.
+func (s *QueryService) variableTable(
+	ctx context.Context,
+	v domain.Variable,
+	sel domain.VariableSelection,
+	values map[domain.Name]domain.VariableValue,
+) (domain.TableData, error) {
+	ttl := variableOptionsTTL
+	if raw := v.Query.Properties[ComponentPropertyCacheTTL]; raw != "" {
+		var err error
+		if ttl, err = cacheTTL(v.Query.Properties); err != nil {
+			return domain.TableData{}, err
+		}
+	}
+	cq, err := s.prepareQuery(ctx, *v.Query, sel, values)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	deps := make(map[domain.Name]domain.VariableValue)
+	for _, dep := range variableDeps(v) {
+		if val, ok := values[dep]; ok {
+			deps[dep] = val
+		}
+	}
+	load := func(ctx context.Context, emit func(domain.TableData) error) error {
+		return s.stream(ctx, cq, domain.DataOptions{}, 0, emit)
+	}
+	table, _, err := s.cache.get(ctx, variableCacheKey(v, cq, deps), refsOf(cq), ttl, load)
+	return table, err
+}
+
+// prepareQuery resolves a query that belongs to no component, such as that
+// of a query variable, with values for the variables it references. The
+// time range of sel applies as to component queries.
+func (s *QueryService) prepareQuery(
+	ctx context.Context,
+	q domain.Query,
+	sel domain.VariableSelection,
+	values map[domain.Name]domain.VariableValue,
+) (componentQuery, error) {
+	ds, class, limits, err := s.target(ctx, q)
+	if err != nil {
+		return componentQuery{}, err
+	}
+	if q.Range, err = queryRange(sel, q.Properties); err != nil {
+		return componentQuery{}, err
+	}
+	if values, err = withTimeVariables(values, q.Range, variableInputs(class, q.Properties)); err != nil {
+		return componentQuery{}, err
+	}
+	q = applyVariables(class, q, values)
+	return componentQuery{comp: domain.Component{Query: q}, ds: ds, class: class, limits: limits}, nil
+}
+
+// QueryData runs a query that belongs to no component, such as that of an
+// alert rule, within its limits, with the variables it references
+// resolved for sel.
+func (s *QueryService) QueryData(ctx context.Context, q domain.Query, sel domain.VariableSelection) (domain.TableData, error) {
+	if q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+		return domain.TableData{}, fmt.Errorf("%w: query %s has no data source", ErrBadRequest, q.Name)
+	}
+	ds, class, limits, err := s.target(ctx, q)
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	if q, err = s.interpolate(ctx, class, q, sel); err != nil {
+		return domain.TableData{}, err
+	}
+	return s.collect(ctx, componentQuery{comp: domain.Component{Query: q}, ds: ds, class: class, limits: limits})
+}
+
+// collect streams the query of cq into a single table.
+func (s *QueryService) collect(ctx context.Context, cq componentQuery) (domain.TableData, error) {
+	var batches []domain.TableData
+	err := s.stream(ctx, cq, domain.DataOptions{}, 0, func(batch domain.TableData) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	if err != nil {
+		return domain.TableData{}, err
+	}
+	return tableops.Concat(batches)
+}
@@ -1,19 +1,77 @@
 package storage
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
+
+	"github.com/smilu97/refana/internal/pkg/domain"
 )
 
 // Migrate applies database schema using GORM's AutoMigrate.
 // Idempotent: safe to run multiple times.
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// Aliases were once indexed without uniqueness under the name the
+	// unique index would otherwise take; AutoMigrate keeps an index whose
+	// name exists, so the old one goes first.
+	if db.Migrator().HasIndex(&dataSourceModel{}, "idx_data_sources_alias") {
+		if err := db.Migrator().DropIndex(&dataSourceModel{}, "idx_data_sources_alias"); err != nil {
+			return err
+		}
+	}
+	backfillRefs := !db.Migrator().HasTable(&componentDataSourceModel{})
+	if err := db.AutoMigrate(
 		&componentModel{},
 		&dataSourceModel{},
 		&dataSourceClassModel{},
-	)
+		&componentDataSourceModel{},
+		&queryJobModel{},
+		&variableModel{},
+		&transformerModel{},
+		&alertRuleModel{},
+		&alertEventModel{},
+		&contactPointModel{},
+		&notificationPolicyModel{},
+		&silenceModel{},
+		&reportModel{},
+		&reportRunModel{},
+	); err != nil {
+		return err
+	}
+	if backfillRefs {
+		return backfillComponentDataSources(db)
+	}
+	return nil
+}
+
+// backfillComponentDataSources derives reference rows for components that
+// were stored before references were tracked outside of QueryJSON.
+func backfillComponentDataSources(db *gorm.DB) error {
+	var comps []componentModel
+	if err := db.Select("id", "query_json").Find(&comps).Error; err != nil {
+		return err
+	}
+	for _, c := range comps {
+		var q domain.Query
+		if err := json.Unmarshal([]byte(c.QueryJSON), &q); err != nil {
+			// Legacy rows may hold IDs in an undecodable form; they simply
+			// stay untracked until the component is saved again.
+			continue
+		}
+		if q.DataSourceID.IsZero() && q.DataSourceAlias == "" {
+			continue
+		}
+		ref := componentDataSourceModel{
+			ComponentID:     c.ID,
+			DataSourceID:    q.DataSourceID.Int64(),
+			DataSourceAlias: string(q.DataSourceAlias),
+		}
+		if err := db.Create(&ref).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Minimal models to establish tables. Columns are intentionally
@@ -22,6 +80,7 @@ type componentModel struct {
 	ID               int64     `gorm:"primaryKey;autoIncrement:false"`
 	VisualisationID  string    `gorm:"size:64;index"`
 	QueryJSON        string    `gorm:"type:text"`
+	QueriesJSON      string    `gorm:"type:text"`
 	Name             string    `gorm:"size:256"`
 	CoordinationJSON string    `gorm:"type:text"`
 	PropertiesJSON   string    `gorm:"type:text"`
@@ -32,11 +91,11 @@ type componentModel struct {
 func (componentModel) TableName() string { return "components" }
 
 type dataSourceModel struct {
-	ID             int64     `gorm:"primaryKey;autoIncrement:false"`
-	ClassID        string    `gorm:"size:64;index"`
-	Name           string    `gorm:"size:256"`
-	Alias          string    `gorm:"size:64;index"`
-	PropertiesJSON string    `gorm:"type:text"`
+	ID             int64  `gorm:"primaryKey;autoIncrement:false"`
+	ClassID        string `gorm:"size:64;index"`
+	Name           string `gorm:"size:256"`
+	Alias          string `gorm:"size:64;uniqueIndex:idx_data_sources_alias_unique,where:alias <> ''"`
+	PropertiesJSON string `gorm:"type:text"`
 	CreatedAt      time.Time
 	UpdatedAt      time.Time `gorm:"index"`
 }
@@ -52,3 +111,174 @@ type dataSourceClassModel struct {
 }
 
 func (dataSourceClassModel) TableName() string { return "data_source_classes" }
+
+// componentDataSourceModel records which data source each component query
+// points at, so references can be queried without decoding QueryJSON.
+type componentDataSourceModel struct {
+	ID              int64  `gorm:"primaryKey"`
+	ComponentID     int64  `gorm:"index"`
+	DataSourceID    int64  `gorm:"index"`
+	DataSourceAlias string `gorm:"size:64;index"`
+}
+
+func (componentDataSourceModel) TableName() string { return "component_data_sources" }
+
+// queryJobModel persists asynchronous query jobs and, once finished, their
+// results until expires_at.
+type queryJobModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement:false"`
+	ComponentID int64  `gorm:"index"`
+	Status      string `gorm:"size:16;index"`
+	RowsFetched int64
+	Error       string `gorm:"type:text"`
+	ResultJSON  string `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  *time.Time
+	ExpiresAt   *time.Time `gorm:"index"`
+}
+
+func (queryJobModel) TableName() string { return "query_jobs" }
+
+// variableModel persists dashboard variables. Page is empty for project
+// variables; names are unique per page.
+type variableModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement:false"`
+	Page        string `gorm:"size:256;uniqueIndex:idx_variables_page_name"`
+	Name        string `gorm:"size:64;uniqueIndex:idx_variables_page_name"`
+	Label       string `gorm:"size:256"`
+	Type        string `gorm:"size:16"`
+	Value       string `gorm:"type:text"`
+	OptionsJSON string `gorm:"type:text"`
+	QueryJSON   string `gorm:"type:text"`
+	Multi       bool
+	IncludeAll  bool
+	AllValue    string `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (variableModel) TableName() string { return "variables" }
+
+// transformerModel persists saved JavaScript transformers, unique by name.
+type transformerModel struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name        string `gorm:"size:64;uniqueIndex"`
+	Description string `gorm:"type:text"`
+	Script      string `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (transformerModel) TableName() string { return "transformers" }
+
+// alertRuleModel persists alert rules with the status their evaluations
+// left them in. ComponentID is zero for rules with a standalone query.
+type alertRuleModel struct {
+	ID              int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name            string `gorm:"size:256"`
+	ComponentID     int64  `gorm:"index"`
+	QueryJSON       string `gorm:"type:text"`
+	RangeFrom       string `gorm:"size:64"`
+	RangeTo         string `gorm:"size:64"`
+	Condition       string `gorm:"type:text"`
+	IntervalNs      int64
+	ForNs           int64
+	LabelsJSON      string `gorm:"type:text"`
+	AnnotationsJSON string `gorm:"type:text"`
+	Paused          bool
+	State           string `gorm:"size:16;index"`
+	StateSince      time.Time
+	EvaluatedAt     *time.Time
+	LastError       string `gorm:"type:text"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (alertRuleModel) TableName() string { return "alert_rules" }
+
+// alertEventModel is the history of the states of alert rules.
+type alertEventModel struct {
+	ID        int64     `gorm:"primaryKey"`
+	RuleID    int64     `gorm:"index:idx_alert_events_rule_at"`
+	FromState string    `gorm:"size:16"`
+	ToState   string    `gorm:"size:16"`
+	Error     string    `gorm:"type:text"`
+	At        time.Time `gorm:"index:idx_alert_events_rule_at"`
+}
+
+func (alertEventModel) TableName() string { return "alert_events" }
+
+// contactPointModel persists where notifications are delivered, unique by
+// name. SettingsJSON holds the settings of the notifier of Type.
+type contactPointModel struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name         string `gorm:"size:256;uniqueIndex"`
+	Type         string `gorm:"size:64"`
+	SettingsJSON string `gorm:"type:text"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (contactPointModel) TableName() string { return "contact_points" }
+
+// notificationPolicyModel persists the notification policy tree as a
+// single row.
+type notificationPolicyModel struct {
+	ID        int64  `gorm:"primaryKey;autoIncrement:false"`
+	TreeJSON  string `gorm:"type:text"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (notificationPolicyModel) TableName() string { return "notification_policies" }
+
+// silenceModel persists silences; EndsAt is indexed to find active ones.
+type silenceModel struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement:false"`
+	MatchersJSON string `gorm:"type:text"`
+	StartsAt     time.Time
+	EndsAt       time.Time `gorm:"index"`
+	Comment      string    `gorm:"type:text"`
+	CreatedBy    string    `gorm:"size:256"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (silenceModel) TableName() string { return "silences" }
+
+// reportModel persists scheduled reports. NextRunAt is indexed to find the
+// reports that are due.
+type reportModel struct {
+	ID                int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name              string `gorm:"size:256"`
+	Schedule          string `gorm:"size:128"`
+	TimeZone          string `gorm:"size:64"`
+	ComponentsJSON    string `gorm:"type:text"`
+	Page              string `gorm:"size:256"`
+	VariablesJSON     string `gorm:"type:text"`
+	RangeFrom         string `gorm:"size:64"`
+	RangeTo           string `gorm:"size:64"`
+	Format            string `gorm:"size:16"`
+	ContactPointsJSON string `gorm:"type:text"`
+	Paused            bool
+	NextRunAt         *time.Time `gorm:"index"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (reportModel) TableName() string { return "reports" }
+
+// reportRunModel is the history of the runs of reports.
+type reportRunModel struct {
+	ID             int64     `gorm:"primaryKey;autoIncrement:false"`
+	ReportID       int64     `gorm:"index:idx_report_runs_report_started"`
+	Trigger        string    `gorm:"size:16"`
+	Status         string    `gorm:"size:16"`
+	Error          string    `gorm:"type:text"`
+	DeliveriesJSON string    `gorm:"type:text"`
+	StartedAt      time.Time `gorm:"index:idx_report_runs_report_started"`
+	FinishedAt     time.Time
+}
+
+func (reportRunModel) TableName() string { return "report_runs" }
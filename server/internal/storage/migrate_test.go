@@ -18,7 +18,7 @@ func TestMigrateCreatesTables(t *testing.T) {
 		t.Fatalf("Migrate error: %v", err)
 	}
 
-	expectTables(t, db, "components", "data_sources")
+	expectTables(t, db, "components", "data_sources", "component_data_sources", "query_jobs", "variables")
 }
 
 func TestMigrateIsIdempotent(t *testing.T) {
@@ -32,6 +32,36 @@ func TestMigrateIsIdempotent(t *testing.T) {
 	}
 }
 
+func TestMigrateMakesAliasesUnique(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:migrate-alias?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// the data_sources table as it was before aliases were unique
+	for _, stmt := range []string{
+		`CREATE TABLE data_sources (id integer PRIMARY KEY, class_id text, name text, alias text,
+			properties_json text, created_at datetime, updated_at datetime)`,
+		`CREATE INDEX idx_data_sources_class_id ON data_sources(class_id)`,
+		`CREATE INDEX idx_data_sources_alias ON data_sources(alias)`,
+		`CREATE INDEX idx_data_sources_updated_at ON data_sources(updated_at)`,
+		`INSERT INTO data_sources (id, class_id, name, alias) VALUES (1, 'sqlite', 'a', 'main'), (2, 'sqlite', 'b', ''), (3, 'sqlite', 'c', '')`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("baseline schema: %v", err)
+		}
+	}
+
+	if err := storage.Migrate(db); err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	if err := db.Exec(`INSERT INTO data_sources (id, class_id, name, alias) VALUES (4, 'sqlite', 'd', 'main')`).Error; err == nil {
+		t.Fatal("inserted a duplicate alias after migrating")
+	}
+	if err := db.Exec(`INSERT INTO data_sources (id, class_id, name, alias) VALUES (5, 'sqlite', 'e', '')`).Error; err != nil {
+		t.Fatalf("empty aliases need not be unique: %v", err)
+	}
+}
+
 // Helpers
 func openInMemoryDB(t *testing.T) *gorm.DB {
 	t.Helper()